@@ -0,0 +1,291 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"os"
+	"strings"
+
+	"github.com/DailyPepper/auth-service/config"
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+	"github.com/DailyPepper/auth-service/internal/service"
+	"github.com/DailyPepper/auth-service/pkg/logger"
+	"github.com/DailyPepper/auth-service/pkg/signing"
+)
+
+// Служебные подкоманды: auth <command> [args]
+func runCommand(log *logger.Logger, command string, args []string) {
+	switch command {
+	case "verify-audit":
+		verifyAudit(log)
+	case "create-tenant":
+		createTenant(log, args)
+	case "register-client":
+		registerClient(log, args)
+	case "register-idp":
+		registerIdentityProvider(log, args)
+	default:
+		log.Fatal("❌ Unknown command: %s (available: verify-audit, create-tenant, register-client, register-idp)", command)
+	}
+}
+
+// Проходит цепочку аудита и сообщает о первом разрыве
+func verifyAudit(log *logger.Logger) {
+	cfg := config.Load()
+
+	repo, err := repository.NewPostgresRepository(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("❌ Failed to connect to database: %v", err)
+	}
+	defer repo.Close()
+
+	// Ключ не создаем: новой парой подписи старых контрольных точек не проверить
+	signer, err := signing.Load(cfg.SigningKeyPath)
+	if err != nil {
+		log.Fatal("❌ Failed to load signing key: %v", err)
+	}
+
+	auditService := service.NewAuditService(repo, signer, cfg.AuditCheckpointEvery)
+	result, err := auditService.Verify(context.Background())
+	if err != nil {
+		log.Fatal("❌ Failed to verify audit chain: %v", err)
+	}
+
+	if result.Break != nil {
+		log.Error("❌ Audit chain broken at event %d: %s", result.Break.EventID, result.Break.Reason)
+		log.Error("   Verified %d events and %d checkpoints before the break", result.EventsChecked, result.CheckpointsChecked)
+		repo.Close()
+		os.Exit(1)
+	}
+
+	log.Info("✅ Audit chain intact: %d events, %d signed checkpoints, head %s",
+		result.EventsChecked, result.CheckpointsChecked, result.LastHash)
+}
+
+// Создает тенант: auth create-tenant <slug> [name]
+func createTenant(log *logger.Logger, args []string) {
+	if len(args) < 1 {
+		log.Fatal("❌ Usage: create-tenant <slug> [name]")
+	}
+
+	cfg := config.Load()
+
+	repo, err := repository.NewPostgresRepository(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("❌ Failed to connect to database: %v", err)
+	}
+	defer repo.Close()
+
+	tenant := &models.Tenant{Slug: args[0]}
+	if len(args) > 1 {
+		tenant.Name = strings.Join(args[1:], " ")
+	}
+
+	tenant, err = service.NewTenantService(repo).CreateTenant(context.Background(), tenant)
+	if err != nil {
+		log.Fatal("❌ Failed to create tenant: %v", err)
+	}
+
+	log.Info("✅ Tenant %s created (id=%d)", tenant.Slug, tenant.ID)
+}
+
+// Регистрирует клиента OAuth 2.0:
+// auth register-client -name <name> [-tenant slug] [-grants ...] [-scopes ...] [-audiences ...]
+// [-redirect-uris ...] [-post-logout-redirect-uris ...] [-ttl 15m] [-public-key key.pem | -public]
+func registerClient(log *logger.Logger, args []string) {
+	cfg := config.Load()
+
+	flags := flag.NewFlagSet("register-client", flag.ExitOnError)
+	tenantSlug := flags.String("tenant", cfg.DefaultTenant, "tenant slug")
+	name := flags.String("name", "", "client name")
+	grants := flags.String("grants", models.GrantClientCredentials, "comma-separated grant types")
+	scopes := flags.String("scopes", "", "comma-separated allowed scopes")
+	audiences := flags.String("audiences", "", "comma-separated allowed audiences")
+	ttl := flags.Duration("ttl", cfg.AccessTokenTTL, "access token lifetime")
+	redirectURIs := flags.String("redirect-uris", "", "comma-separated redirect URIs for authorization_code")
+	postLogoutRedirectURIs := flags.String("post-logout-redirect-uris", "", "comma-separated redirect URIs after logout")
+	publicKeyPath := flags.String("public-key", "", "PEM public key for private_key_jwt (otherwise a client secret is issued)")
+	public := flags.Bool("public", false, "public client without credentials (browser or mobile app using PKCE)")
+	flags.Parse(args)
+
+	if *name == "" {
+		log.Fatal("❌ Usage: register-client -name <name> [-tenant slug] [-grants ...] [-scopes ...] [-audiences ...] " +
+			"[-redirect-uris ...] [-post-logout-redirect-uris ...] [-ttl 15m] [-public-key key.pem | -public]")
+	}
+	if *public && *publicKeyPath != "" {
+		log.Fatal("❌ -public and -public-key are mutually exclusive")
+	}
+
+	client := &models.OAuthClient{
+		Name:           *name,
+		AuthMethod:     models.ClientAuthSecret,
+		GrantTypes:     splitList(*grants),
+		Scopes:         splitList(*scopes),
+		Audiences:      splitList(*audiences),
+		AccessTokenTTL: *ttl,
+
+		RedirectURIs:           splitList(*redirectURIs),
+		PostLogoutRedirectURIs: splitList(*postLogoutRedirectURIs),
+	}
+	if *public {
+		client.AuthMethod = models.ClientAuthNone
+	}
+	if *publicKeyPath != "" {
+		publicKey, err := os.ReadFile(*publicKeyPath)
+		if err != nil {
+			log.Fatal("❌ Failed to read public key: %v", err)
+		}
+		client.AuthMethod = models.ClientAuthPrivateKeyJWT
+		client.PublicKeyPEM = string(publicKey)
+	}
+
+	repo, err := repository.NewPostgresRepository(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("❌ Failed to connect to database: %v", err)
+	}
+	defer repo.Close()
+
+	signer, err := signing.Load(cfg.SigningKeyPath)
+	if err != nil {
+		log.Fatal("❌ Failed to load signing key: %v", err)
+	}
+
+	ctx := context.Background()
+	tenant, err := service.NewTenantService(repo).Resolve(ctx, *tenantSlug)
+	if err != nil {
+		log.Fatal("❌ Failed to resolve tenant %s: %v", *tenantSlug, err)
+	}
+	ctx = models.WithTenant(ctx, tenant)
+
+	auditService := service.NewAuditService(repo, signer, cfg.AuditCheckpointEvery)
+	// Выдача токенов при регистрации не нужна
+	oauthService := service.NewOAuthService(repo, repo, repo, repo, repo, repo, nil, nil, nil, nil, nil, auditService, cfg.IssuerURL, cfg.DeviceCodeTTL)
+
+	client, secret, err := oauthService.RegisterClient(ctx, client)
+	if err != nil {
+		log.Fatal("❌ Failed to register client: %v", err)
+	}
+
+	log.Info("✅ OAuth client %s registered in tenant %s", client.Name, tenant.Slug)
+	log.Info("   client_id: %s", client.ClientID)
+	if secret != "" {
+		log.Info("   client_secret: %s (shown only once)", secret)
+	}
+}
+
+// Подключает внешний провайдер OpenID Connect для входа пользователей тенанта:
+// auth register-idp -slug <slug> -name <name> -issuer <url> -client-id <id> [-client-secret ...] [-tenant slug] [-scopes ...]
+// [-jit] [-sync never|profile|all] [-mapping mapping.json]
+// SAML-провайдер: -protocol saml -metadata idp-metadata.xml вместо -issuer и -client-id,
+// нужны SAML_SP_CERT_PATH и SAML_SP_KEY_PATH.
+// Секрет можно передать через IDP_CLIENT_SECRET, чтобы он не попал в историю shell.
+// Файл сопоставления - JSON models.AttributeMapping, например:
+// {"groups": "realm_access.roles", "group_roles": {"admins": ["admin"]}}
+func registerIdentityProvider(log *logger.Logger, args []string) {
+	cfg := config.Load()
+
+	flags := flag.NewFlagSet("register-idp", flag.ExitOnError)
+	tenantSlug := flags.String("tenant", cfg.DefaultTenant, "tenant slug")
+	slug := flags.String("slug", "", "provider slug used in URLs, e.g. google")
+	name := flags.String("name", "", "name shown on the login page")
+	protocol := flags.String("protocol", models.IdentityProviderOIDC, "federation protocol: oidc or saml")
+	issuer := flags.String("issuer", "", "OpenID Connect issuer URL")
+	clientID := flags.String("client-id", "", "client ID registered at the provider")
+	clientSecret := flags.String("client-secret", os.Getenv("IDP_CLIENT_SECRET"), "client secret registered at the provider")
+	scopes := flags.String("scopes", "openid,email,profile", "comma-separated scopes to request")
+	jit := flags.Bool("jit", false, "create users on their first login through the provider")
+	syncPolicy := flags.String("sync", models.SyncNever, "attributes re-synced on every login: never, profile or all")
+	mappingPath := flags.String("mapping", "", "JSON file with claim and group-to-role mapping")
+	metadataPath := flags.String("metadata", "", "SAML IdP metadata XML file")
+	flags.Parse(args)
+
+	saml := *protocol == models.IdentityProviderSAML
+	if *slug == "" || *name == "" || (saml && *metadataPath == "") || (!saml && (*issuer == "" || *clientID == "")) {
+		log.Fatal("❌ Usage: register-idp -slug <slug> -name <name> (-issuer <url> -client-id <id> [-client-secret ...] [-scopes ...] | -protocol saml -metadata <file>) [-tenant slug] [-jit] [-sync policy] [-mapping file]")
+	}
+
+	var metadata []byte
+	if saml {
+		data, err := os.ReadFile(*metadataPath)
+		if err != nil {
+			log.Fatal("❌ Failed to read SAML metadata: %v", err)
+		}
+		metadata = data
+	}
+
+	var mapping models.AttributeMapping
+	if *mappingPath != "" {
+		data, err := os.ReadFile(*mappingPath)
+		if err != nil {
+			log.Fatal("❌ Failed to read attribute mapping: %v", err)
+		}
+		if err := json.Unmarshal(data, &mapping); err != nil {
+			log.Fatal("❌ Failed to parse attribute mapping: %v", err)
+		}
+	}
+
+	repo, err := repository.NewPostgresRepository(cfg.DatabaseURL)
+	if err != nil {
+		log.Fatal("❌ Failed to connect to database: %v", err)
+	}
+	defer repo.Close()
+
+	signer, err := signing.Load(cfg.SigningKeyPath)
+	if err != nil {
+		log.Fatal("❌ Failed to load signing key: %v", err)
+	}
+
+	ctx := context.Background()
+	tenant, err := service.NewTenantService(repo).Resolve(ctx, *tenantSlug)
+	if err != nil {
+		log.Fatal("❌ Failed to resolve tenant %s: %v", *tenantSlug, err)
+	}
+	ctx = models.WithTenant(ctx, tenant)
+
+	auditService := service.NewAuditService(repo, signer, cfg.AuditCheckpointEvery)
+	// Вход при регистрации не выполняется, поэтому Registr и RBAC не нужны
+	callbackURL := cfg.IssuerURL + "/oauth2/federation/callback"
+	samlProvider, err := newSAMLServiceProvider(cfg)
+	if err != nil {
+		log.Fatal("❌ Failed to load SAML service provider: %v", err)
+	}
+	federationService := service.NewFederationService(repo, repo, repo, nil, nil, auditService, samlProvider, callbackURL, cfg.FederationStateTTL)
+
+	provider := &models.IdentityProvider{
+		Slug:         *slug,
+		Name:         *name,
+		Protocol:     *protocol,
+		SAMLMetadata: string(metadata),
+		Issuer:       *issuer,
+		ClientID:     *clientID,
+		ClientSecret: *clientSecret,
+		Scopes:       splitList(*scopes),
+
+		JITProvisioning:  *jit,
+		AttributeMapping: mapping,
+		SyncPolicy:       *syncPolicy,
+	}
+	if err := federationService.RegisterProvider(ctx, provider); err != nil {
+		log.Fatal("❌ Failed to register identity provider: %v", err)
+	}
+
+	log.Info("✅ Identity provider %s registered in tenant %s", provider.Slug, tenant.Slug)
+	if saml {
+		log.Info("   SP metadata for the provider: %s", samlProvider.MetadataURL.String())
+		log.Info("   assertion consumer service (HTTP-POST): %s", samlProvider.ACSURL.String())
+		return
+	}
+	log.Info("   redirect URI to configure at the provider: %s", callbackURL)
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
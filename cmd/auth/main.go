@@ -1,20 +1,35 @@
 package main
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
 	"github.com/DailyPepper/auth-service/config"
+	"github.com/DailyPepper/auth-service/internal/models"
 	"github.com/DailyPepper/auth-service/internal/repository"
 	"github.com/DailyPepper/auth-service/internal/server"
 	"github.com/DailyPepper/auth-service/internal/service"
+	"github.com/DailyPepper/auth-service/pkg/dnstxt"
+	"github.com/DailyPepper/auth-service/pkg/geoip"
 	"github.com/DailyPepper/auth-service/pkg/logger"
+	"github.com/DailyPepper/auth-service/pkg/mailer"
 	"github.com/DailyPepper/auth-service/pkg/migrations"
+	"github.com/DailyPepper/auth-service/pkg/signing"
 )
 
 func main() {
 	log := logger.New("info")
+
+	if len(os.Args) > 1 {
+		runCommand(log, os.Args[1], os.Args[2:])
+		return
+	}
+
 	log.Info("🔧 Initializing auth service...")
 
 	log.Info("1. Loading configuration...")
@@ -39,21 +54,113 @@ func main() {
 	}
 	log.Info("✅ Database migrations completed")
 
-	log.Info("4. Creating services...")
-	registrService := service.NewRegistrService(userRepo)
+	log.Info("4. Loading signing key...")
+	signer, err := signing.LoadOrGenerate(cfg.SigningKeyPath)
+	if err != nil {
+		log.Fatal("❌ Failed to load signing key: %v", err)
+	}
+	log.Info("✅ Signing key loaded (kid=%s)", signer.KeyID())
+
+	log.Info("5. Creating services...")
+	var mail mailer.Mailer = mailer.LogMailer{}
+	if cfg.SMTPAddr != "" {
+		mail = mailer.NewSMTPMailer(cfg.SMTPAddr, cfg.MailFrom, cfg.SMTPUsername, cfg.SMTPPassword)
+	}
+
+	auditService := service.NewAuditService(userRepo, signer, cfg.AuditCheckpointEvery)
+	tokenService := service.NewTokenService(signer, cfg.TokenIssuer, cfg.AccessTokenTTL)
+	// Общий кеш jti: доказательство, принятое при входе, не пройдет и на token endpoint
+	dpopService := service.NewDPoPService()
+	notificationService := service.NewNotificationService(mail, cfg.PublicURL)
+	deviceService := service.NewDeviceService(userRepo, userRepo, userRepo, userRepo, notificationService, auditService)
+
+	sessionService := service.NewSessionService(userRepo)
+	tenantService := service.NewTenantService(userRepo)
+	rbacService := service.NewRBACService(userRepo, userRepo, auditService)
+
+	policyService, err := service.NewPolicyService(userRepo, rbacService, auditService)
+	if err != nil {
+		log.Fatal("❌ Failed to create policy service: %v", err)
+	}
+
+	// GeoIP подключается, только если задан путь к базе
+	var geoService service.Geo
+	if cfg.GeoIPDatabasePath != "" {
+		geoReader, err := geoip.Open(cfg.GeoIPDatabasePath)
+		if err != nil {
+			log.Fatal("❌ Failed to open GeoIP database: %v", err)
+		}
+		defer geoReader.Close()
+		geoService = service.NewGeoService(geoReader, userRepo, cfg.GeoIPMaxTravelSpeed)
+		log.Info("🌍 GeoIP database loaded from %s", cfg.GeoIPDatabasePath)
+	}
+
+	// Проверки отношений подключаются, только если задана схема
+	var relationService service.Relations
+	if cfg.RelationNamespacesPath != "" {
+		namespaces, err := service.LoadRelationNamespaces(cfg.RelationNamespacesPath)
+		if err != nil {
+			log.Fatal("❌ Failed to load relation namespaces: %v", err)
+		}
+		relationService = service.NewRelationService(userRepo, namespaces, cfg.RelationCheckCacheTTL)
+		log.Info("🔗 Relation namespaces loaded from %s (%d namespaces)", cfg.RelationNamespacesPath, len(namespaces.Namespaces))
+	}
+
+	riskService, err := newRiskService(cfg, userRepo)
+	if err != nil {
+		log.Fatal("❌ Failed to create risk engine: %v", err)
+	}
+
+	challengeVerifier, err := newChallengeVerifier(cfg)
+	if err != nil {
+		log.Fatal("❌ Failed to create challenge verifier: %v", err)
+	}
+	challengeService := service.NewChallengeService(challengeVerifier, userRepo, service.ChallengePolicy{
+		OnRegister:         cfg.ChallengeOnRegister,
+		LoginAfterFailures: cfg.ChallengeLoginAfterFailures,
+		LoginWindow:        cfg.ChallengeLoginWindow,
+	})
+
+	// Без DNS_TXT_RECORDS_PATH домены проверяются по настоящим TXT-записям
+	var dnsResolver dnstxt.Resolver = dnstxt.NewNetResolver(cfg.DNSResolverAddr)
+	if cfg.DNSTXTRecordsPath != "" {
+		dnsResolver = dnstxt.NewStaticResolver(cfg.DNSTXTRecordsPath)
+		log.Info("📄 Domain TXT records are read from %s", cfg.DNSTXTRecordsPath)
+	}
+	domainService := service.NewDomainService(userRepo, userRepo, userRepo, rbacService, dnsResolver, auditService)
+
+	authProviders, err := newAuthProviders(cfg)
+	if err != nil {
+		log.Fatal("❌ Failed to create auth providers: %v", err)
+	}
+
+	registrService := service.NewRegistrService(userRepo, userRepo, userRepo, userRepo, userRepo, tokenService, dpopService, deviceService, geoService, riskService, challengeService, rbacService, policyService, domainService, authProviders, auditService, cfg.SessionTTL, cfg.ImpersonationTTL)
 	if registrService == nil {
 		log.Fatal("❌ Failed to create registr service - returned nil")
 	}
+	organizationService := service.NewOrganizationService(userRepo, userRepo, registrService, notificationService, auditService, cfg.InvitationTTL)
+	apiKeyService := service.NewAPIKeyService(userRepo, rbacService, auditService)
+	// Утверждения private_key_jwt принимаются с aud = token endpoint или issuer
+	oauthService := service.NewOAuthService(userRepo, userRepo, userRepo, userRepo, userRepo, userRepo, rbacService, policyService, registrService, tokenService, dpopService, auditService, cfg.IssuerURL, cfg.DeviceCodeTTL, cfg.IssuerURL+"/oauth2/token", cfg.IssuerURL, cfg.TokenIssuer)
+	oidcService := service.NewOIDCService(userRepo, userRepo, userRepo, registrService, tokenService, auditService, cfg.IssuerURL, cfg.AuthorizationCodeTTL)
+	samlProvider, err := newSAMLServiceProvider(cfg)
+	if err != nil {
+		log.Fatal("❌ Failed to load SAML service provider: %v", err)
+	}
+	federationService := service.NewFederationService(userRepo, userRepo, userRepo, registrService, rbacService, auditService, samlProvider, cfg.IssuerURL+"/oauth2/federation/callback", cfg.FederationStateTTL)
+	identityService := service.NewIdentityService(userRepo, userRepo, userRepo, userRepo, userRepo, federationService, auditService, cfg.ReauthenticationWindow)
 	log.Info("✅ Services created successfully")
 
-	log.Info("5. Creating gRPC server...")
-	grpcServer := server.NewGRPCServer(registrService)
+	log.Info("6. Creating gRPC server...")
+	grpcServer := server.NewGRPCServer(cfg, registrService, deviceService, sessionService, challengeService, rbacService, relationService, policyService, tenantService, organizationService, apiKeyService, oauthService, identityService, domainService)
 	if grpcServer == nil {
 		log.Fatal("❌ Failed to create gRPC server - returned nil")
 	}
 	log.Info("✅ gRPC server created successfully")
 
-	log.Info("6. Starting gRPC server on %s...", cfg.GRPCAddr)
+	httpServer := server.NewHTTPServer(cfg, tenantService, oauthService, oidcService, federationService)
+
+	log.Info("7. Starting gRPC server on %s...", cfg.GRPCAddr)
 
 	serverErr := make(chan error, 1)
 
@@ -63,6 +170,12 @@ func main() {
 		}
 	}()
 
+	go func() {
+		if err := httpServer.Start(cfg.HTTPAddr); err != nil {
+			serverErr <- err
+		}
+	}()
+
 	select {
 	case err := <-serverErr:
 		log.Fatal("❌ Failed to start gRPC server: %v", err)
@@ -72,6 +185,7 @@ func main() {
 
 	log.Info("✅ Auth Service started successfully")
 	log.Info("📍 gRPC Port: %s", cfg.GRPCAddr)
+	log.Info("📍 HTTP Port: %s", cfg.HTTPAddr)
 	log.Info("🗄️  Database: %s", cfg.DatabaseURL)
 	log.Info("📡 Ready to accept gRPC requests")
 
@@ -88,6 +202,117 @@ func main() {
 	}
 
 	log.Info("🛑 Shutting down server...")
+	httpServer.Stop()
 	grpcServer.Stop()
 	log.Info("👋 Server stopped gracefully")
 }
+
+// Собирает риск-движок из встроенных сигналов по конфигурации
+func newRiskService(cfg *config.Config, repo *repository.PostgresRepository) (*service.RiskService, error) {
+	location, err := time.LoadLocation(cfg.RiskTimezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid risk timezone: %w", err)
+	}
+
+	signals := []service.RiskSignal{
+		service.NewFailedVelocitySignal(repo, cfg.RiskVelocityWindow, cfg.RiskVelocityLimit),
+		service.NewNewDeviceSignal(repo),
+		service.NewTimeOfDaySignal(cfg.RiskUnusualHoursStart, cfg.RiskUnusualHoursEnd, location),
+		service.ImpossibleTravelSignal{},
+	}
+
+	if cfg.RiskIPBlocklistPath != "" {
+		blocklist, err := service.LoadIPReputationSignal(cfg.RiskIPBlocklistPath)
+		if err != nil {
+			return nil, err
+		}
+		signals = append(signals, blocklist)
+	}
+
+	thresholds := service.RiskThresholds{
+		Captcha: cfg.RiskCaptchaThreshold,
+		MFA:     cfg.RiskMFAThreshold,
+		Deny:    cfg.RiskDenyThreshold,
+	}
+
+	return service.NewRiskService(cfg.RiskSignalWeights, thresholds, signals...), nil
+}
+
+// Собирает провайдеров проверки пароля в порядке AUTH_PROVIDERS
+func newAuthProviders(cfg *config.Config) ([]service.AuthProvider, error) {
+	var providers []service.AuthProvider
+	for _, name := range strings.Split(cfg.AuthProviders, ",") {
+		switch strings.TrimSpace(name) {
+		case "":
+		case models.AuthProviderPassword:
+			providers = append(providers, service.PasswordAuthProvider{})
+		case models.AuthProviderLDAP:
+			ldapConfig := service.LDAPConfig{
+				URL:            cfg.LDAPURL,
+				StartTLS:       cfg.LDAPStartTLS,
+				AllowCleartext: cfg.LDAPAllowCleartext,
+				CACertPath:     cfg.LDAPCACertPath,
+				BindDN:         cfg.LDAPBindDN,
+				BindPassword:   cfg.LDAPBindPassword,
+				BaseDN:         cfg.LDAPBaseDN,
+				UserFilter:     cfg.LDAPUserFilter,
+				PoolSize:       cfg.LDAPPoolSize,
+				Timeout:        cfg.LDAPTimeout,
+			}
+			for _, template := range strings.Split(cfg.LDAPUserDNTemplates, ";") {
+				if template = strings.TrimSpace(template); template != "" {
+					ldapConfig.UserDNTemplates = append(ldapConfig.UserDNTemplates, template)
+				}
+			}
+			if cfg.LDAPMappingPath != "" {
+				data, err := os.ReadFile(cfg.LDAPMappingPath)
+				if err != nil {
+					return nil, fmt.Errorf("failed to read ldap mapping: %w", err)
+				}
+				if err := json.Unmarshal(data, &ldapConfig.Mapping); err != nil {
+					return nil, fmt.Errorf("failed to parse ldap mapping: %w", err)
+				}
+			}
+			provider, err := service.NewLDAPAuthProvider(ldapConfig)
+			if err != nil {
+				return nil, err
+			}
+			providers = append(providers, provider)
+		default:
+			return nil, fmt.Errorf("unknown auth provider %q", name)
+		}
+	}
+	return providers, nil
+}
+
+// Загружает ключ SAML SP; nil - SAML не настроен
+func newSAMLServiceProvider(cfg *config.Config) (*service.SAMLServiceProvider, error) {
+	if cfg.SAMLSPCertPath == "" && cfg.SAMLSPKeyPath == "" {
+		return nil, nil
+	}
+	if cfg.SAMLSPCertPath == "" || cfg.SAMLSPKeyPath == "" {
+		return nil, fmt.Errorf("both SAML_SP_CERT_PATH and SAML_SP_KEY_PATH are required")
+	}
+	return service.LoadSAMLServiceProvider(cfg.SAMLSPCertPath, cfg.SAMLSPKeyPath, cfg.IssuerURL)
+}
+
+// Выбирает реализацию заданий против ботов; nil - задания выключены
+func newChallengeVerifier(cfg *config.Config) (service.ChallengeVerifier, error) {
+	switch cfg.ChallengeProvider {
+	case "", "none":
+		return nil, nil
+	case "pow":
+		// Отдельный ключ: ключ подписи токенов не должен служить оракулом для заданий
+		if len(cfg.ChallengePoWSecret) < 32 {
+			return nil, fmt.Errorf("CHALLENGE_POW_SECRET of at least 32 bytes is required for pow challenges")
+		}
+		return service.NewProofOfWorkVerifier([]byte(cfg.ChallengePoWSecret), cfg.ChallengePoWDifficulty, cfg.ChallengePoWTTL), nil
+	case "captcha":
+		if cfg.CaptchaSecret == "" {
+			return nil, fmt.Errorf("CAPTCHA_SECRET is required for captcha challenges")
+		}
+		return service.NewCaptchaVerifier(cfg.CaptchaVerifyURL, cfg.CaptchaSiteKey, cfg.CaptchaSecret), nil
+	default:
+		return nil, fmt.Errorf("unknown challenge provider %q", cfg.ChallengeProvider)
+	}
+}
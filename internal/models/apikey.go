@@ -0,0 +1,124 @@
+package models
+
+import (
+	"errors"
+	"net/netip"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const PermissionServiceAccountsManage = "service_accounts:manage"
+
+// Ключ выглядит как sk_<префикс>.<секрет>; префикс хранится открыто и виден в списках
+const APIKeyPrefix = "sk_"
+
+var (
+	ErrServiceAccountNotFound      = errors.New("service account not found")
+	ErrServiceAccountAlreadyExists = errors.New("service account already exists")
+	ErrInvalidServiceAccountName   = errors.New("invalid service account name")
+	ErrAPIKeyNotFound              = errors.New("api key not found")
+	ErrInvalidAPIKey               = errors.New("invalid api key")
+	ErrInvalidAPIKeyScope          = errors.New("invalid api key scope")
+	ErrInvalidAPIKeyCIDR           = errors.New("invalid api key ip allowlist entry")
+	ErrInvalidAPIKeyExpiry         = errors.New("api key expiry must be in the future")
+	ErrAPIKeyIPNotAllowed          = errors.New("api key is not allowed from this ip")
+)
+
+var serviceAccountNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,99}$`)
+
+type ServiceAccount struct {
+	ID          int64     `json:"id" db:"id"`
+	TenantID    int64     `json:"tenant_id" db:"tenant_id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	CreatedBy   *int64    `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+func (a *ServiceAccount) Validate() error {
+	if !serviceAccountNamePattern.MatchString(a.Name) {
+		return ErrInvalidServiceAccountName
+	}
+	return nil
+}
+
+type APIKey struct {
+	ID               int64      `json:"id" db:"id"`
+	TenantID         int64      `json:"tenant_id" db:"tenant_id"`
+	ServiceAccountID int64      `json:"service_account_id" db:"service_account_id"`
+	Name             string     `json:"name" db:"name"`
+	Prefix           string     `json:"prefix" db:"prefix"`
+	SecretHash       string     `json:"-" db:"secret_hash"`
+	Scopes           []string   `json:"scopes" db:"scopes"`
+	AllowedCIDRs     []string   `json:"allowed_cidrs" db:"allowed_cidrs"`
+	ExpiresAt        *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	CreatedBy        *int64     `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt        time.Time  `json:"created_at" db:"created_at"`
+	LastUsedAt       *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	LastUsedIP       string     `json:"last_used_ip,omitempty" db:"last_used_ip"`
+	RevokedAt        *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	RotatedFrom      *int64     `json:"rotated_from,omitempty" db:"rotated_from"`
+}
+
+// Validate проверяет области (resource:action) и подсети allowlist
+func (k *APIKey) Validate() error {
+	for _, scope := range k.Scopes {
+		if !ValidPermissionName(scope) {
+			return ErrInvalidAPIKeyScope
+		}
+	}
+	for _, cidr := range k.AllowedCIDRs {
+		if _, err := parseCIDR(cidr); err != nil {
+			return ErrInvalidAPIKeyCIDR
+		}
+	}
+	return nil
+}
+
+func (k *APIKey) IsActive(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// AllowsIP: пустой allowlist разрешает любой адрес
+func (k *APIKey) AllowsIP(ip string) bool {
+	if len(k.AllowedCIDRs) == 0 {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+
+	for _, cidr := range k.AllowedCIDRs {
+		prefix, err := parseCIDR(cidr)
+		if err == nil && prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// Одиночный адрес в allowlist равен подсети /32 или /128
+func parseCIDR(cidr string) (netip.Prefix, error) {
+	if !strings.Contains(cidr, "/") {
+		addr, err := netip.ParseAddr(cidr)
+		if err != nil {
+			return netip.Prefix{}, err
+		}
+		return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+	}
+	prefix, err := netip.ParsePrefix(cidr)
+	if err != nil {
+		return netip.Prefix{}, err
+	}
+	return prefix.Masked(), nil
+}
+
+// Результат проверки ключа: кто вызывает и что ему можно
+type APIKeyPrincipal struct {
+	Key            *APIKey
+	ServiceAccount *ServiceAccount
+}
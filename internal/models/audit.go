@@ -0,0 +1,170 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+type AuditEventType string
+
+const (
+	AuditUserRegistered AuditEventType = "user.registered"
+	AuditLoginSucceeded AuditEventType = "login.succeeded"
+	AuditLoginFailed    AuditEventType = "login.failed"
+	// Вход остановлен до прохождения CAPTCHA или второго фактора
+	AuditLoginChallenged AuditEventType = "login.challenged"
+
+	AuditNewDeviceLogin         AuditEventType = "login.new_device"
+	AuditUnrecognizedLogin      AuditEventType = "login.reported_unrecognized"
+	AuditPasswordResetCompleted AuditEventType = "password.reset"
+
+	AuditRoleCreated AuditEventType = "role.created"
+	AuditRoleGranted AuditEventType = "role.granted"
+	AuditRoleRevoked AuditEventType = "role.revoked"
+
+	AuditPolicySaved   AuditEventType = "policy.saved"
+	AuditPolicyDeleted AuditEventType = "policy.deleted"
+
+	AuditOrganizationCreated     AuditEventType = "organization.created"
+	AuditOrganizationInvited     AuditEventType = "organization.member_invited"
+	AuditOrganizationJoined      AuditEventType = "organization.member_joined"
+	AuditOrganizationRemoved     AuditEventType = "organization.member_removed"
+	AuditOrganizationRoleChanged AuditEventType = "organization.member_role_changed"
+
+	AuditServiceAccountCreated AuditEventType = "service_account.created"
+	AuditAPIKeyCreated         AuditEventType = "api_key.created"
+	AuditAPIKeyRotated         AuditEventType = "api_key.rotated"
+	AuditAPIKeyRevoked         AuditEventType = "api_key.revoked"
+
+	AuditOAuthClientRegistered AuditEventType = "oauth_client.registered"
+	AuditOAuthTokenIssued      AuditEventType = "oauth.token_issued"
+	AuditOAuthConsentGranted   AuditEventType = "oauth.consent_granted"
+	AuditOAuthRefreshReused    AuditEventType = "oauth.refresh_token_reused"
+	AuditOAuthDeviceApproved   AuditEventType = "oauth.device_approved"
+	AuditOAuthDeviceDenied     AuditEventType = "oauth.device_denied"
+	AuditOAuthDeviceCodeFailed AuditEventType = "oauth.device_code_failed"
+	AuditOAuthTokenExchanged   AuditEventType = "oauth.token_exchanged"
+	AuditSessionEnded          AuditEventType = "session.ended"
+
+	AuditIdentityProviderRegistered AuditEventType = "identity_provider.registered"
+	AuditIdentityLinked             AuditEventType = "identity.linked"
+	AuditIdentityUnlinked           AuditEventType = "identity.unlinked"
+	AuditUsersMerged                AuditEventType = "user.merged"
+	AuditProfileSynced              AuditEventType = "user.profile_synced"
+	AuditImpersonationStarted       AuditEventType = "user.impersonation_started"
+	AuditImpersonationDenied        AuditEventType = "user.impersonation_denied"
+
+	AuditDomainAdded      AuditEventType = "domain.added"
+	AuditDomainVerified   AuditEventType = "domain.verified"
+	AuditDomainSSOChanged AuditEventType = "domain.sso_changed"
+)
+
+// Хеш "нулевого" события, с которого начинается цепочка
+var AuditGenesisHash = hex.EncodeToString(make([]byte, sha256.Size))
+
+var ErrAuditChainBroken = errors.New("audit chain is broken")
+
+type AuditEvent struct {
+	ID        int64             `json:"id" db:"id"`
+	Type      AuditEventType    `json:"type" db:"event_type"`
+	UserID    *int64            `json:"user_id,omitempty" db:"user_id"`
+	Email     string            `json:"email,omitempty" db:"email"`
+	Metadata  map[string]string `json:"metadata,omitempty" db:"metadata"`
+	CreatedAt time.Time         `json:"created_at" db:"created_at"`
+	TenantID  *int64            `json:"tenant_id,omitempty" db:"tenant_id"`
+
+	// Звенья цепочки: хеш предыдущей записи и хеш этой записи
+	PrevHash string `json:"prev_hash" db:"prev_hash"`
+	Hash     string `json:"hash" db:"hash"`
+}
+
+// Каноническое представление записи, которое попадает под хеш.
+// ID не входит: порядок задается ссылкой на предыдущий хеш.
+type auditCanonical struct {
+	Type      AuditEventType    `json:"type"`
+	UserID    *int64            `json:"user_id"`
+	Email     string            `json:"email"`
+	Metadata  map[string]string `json:"metadata"`
+	CreatedAt string            `json:"created_at"`
+	// omitempty: записи до появления тенантов хешируются как раньше
+	TenantID *int64 `json:"tenant_id,omitempty"`
+}
+
+func (e *AuditEvent) CanonicalEncoding() ([]byte, error) {
+	metadata := e.Metadata
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+
+	// encoding/json сортирует ключи map, поэтому кодирование детерминировано
+	return json.Marshal(auditCanonical{
+		Type:      e.Type,
+		UserID:    e.UserID,
+		Email:     e.Email,
+		Metadata:  metadata,
+		CreatedAt: e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		TenantID:  e.TenantID,
+	})
+}
+
+// ComputeHash считает SHA-256(prev_hash || canonical)
+func (e *AuditEvent) ComputeHash(prevHash string) (string, error) {
+	prev, err := hex.DecodeString(prevHash)
+	if err != nil {
+		return "", fmt.Errorf("invalid previous hash: %w", err)
+	}
+
+	canonical, err := e.CanonicalEncoding()
+	if err != nil {
+		return "", err
+	}
+
+	h := sha256.New()
+	h.Write(prev)
+	h.Write(canonical)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Seal привязывает запись к предыдущей
+func (e *AuditEvent) Seal(prevHash string) error {
+	hash, err := e.ComputeHash(prevHash)
+	if err != nil {
+		return err
+	}
+	e.PrevHash = prevHash
+	e.Hash = hash
+	return nil
+}
+
+// Подписанная контрольная точка цепочки
+type AuditCheckpoint struct {
+	ID        int64     `json:"id" db:"id"`
+	EventID   int64     `json:"event_id" db:"event_id"`
+	EventHash string    `json:"event_hash" db:"event_hash"`
+	KeyID     string    `json:"key_id" db:"key_id"`
+	Signature []byte    `json:"signature" db:"signature"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+// SigningPayload - данные, которые подписываются ключом сервиса
+func (c *AuditCheckpoint) SigningPayload() []byte {
+	return []byte(fmt.Sprintf("audit-checkpoint:%d:%s", c.EventID, c.EventHash))
+}
+
+// Первое найденное нарушение цепочки
+type AuditChainBreak struct {
+	EventID int64  `json:"event_id"`
+	Reason  string `json:"reason"`
+}
+
+// Результат проверки цепочки аудита
+type AuditVerification struct {
+	EventsChecked      int              `json:"events_checked"`
+	CheckpointsChecked int              `json:"checkpoints_checked"`
+	LastHash           string           `json:"last_hash"`
+	Break              *AuditChainBreak `json:"break,omitempty"`
+}
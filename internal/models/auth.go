@@ -7,28 +7,65 @@ import (
 
 // Запрос на вход
 type LoginRequest struct {
-	Email    string `json:"email" validate:"required,email"`
-	Password string `json:"password" validate:"required"`
+	Email     string            `json:"email" validate:"required,email"`
+	Password  string            `json:"password" validate:"required"`
+	Challenge ChallengeSolution `json:"-"`
+	Client    ClientInfo        `json:"-"`
+
+	// Организация, от имени которой работает сессия (0 - без организации)
+	OrganizationID int64 `json:"organization_id,omitempty"`
+
+	// Доказательство владения ключом, к которому привязать токен (nil - bearer)
+	DPoP *DPoPRequest `json:"-"`
+}
+
+// Провайдеры аутентификации, которые проверяют пароль при входе
+const (
+	AuthProviderPassword = "password"
+	AuthProviderLDAP     = "ldap"
+)
+
+// Результат проверки пароля провайдером аутентификации
+type Authentication struct {
+	Provider string
+	// Идентификатор у провайдера, для LDAP - DN записи
+	Subject   string
+	FirstName string
+	Surname   string
+	// Роли по группам каталога и все роли, которыми провайдер управляет;
+	// без ManagedRoles роли не синхронизируются
+	Roles        []string
+	ManagedRoles []string
 }
 
 // Ответ после успешного входа
 type LoginResponse struct {
-	AccessToken  string `json:"access_token"`
-	RefreshToken string `json:"refresh_token"`
-	User         User   `json:"user"`
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	SessionID    string    `json:"session_id"`
+	User         User      `json:"user"`
+	// DPoP для токена, привязанного к ключу, иначе Bearer
+	TokenType string `json:"token_type"`
+}
+
+// Запрос на смену пароля по одноразовому токену
+type ResetPasswordRequest struct {
+	Token       string `json:"token" validate:"required"`
+	NewPassword string `json:"new_password" validate:"required,min=8"`
 }
 
 var (
-	ErrUserAlreadyExists  = errors.New("user with this email already exists")
-	ErrInvalidCredentials = errors.New("invalid credentials")
-	ErrUserNotFound       = errors.New("user not found")
+	ErrUserAlreadyExists     = errors.New("user with this email already exists")
+	ErrInvalidCredentials    = errors.New("invalid credentials")
+	ErrUserNotFound          = errors.New("user not found")
+	ErrPasswordResetRequired = errors.New("password reset required")
+	ErrInvalidResetToken     = errors.New("invalid or expired password reset token")
+	ErrPasswordTooWeak       = errors.New("password is too weak")
 )
 
 func (u *User) BeforeCreate() error {
 	// Устанавливаем значения по умолчанию
-	if u.Role == "" {
-		u.Role = RoleUser
-	}
 	if u.CreatedAt.IsZero() {
 		u.CreatedAt = time.Now()
 	}
@@ -0,0 +1,36 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type ChallengeType string
+
+const (
+	ChallengeNone        ChallengeType = "none"
+	ChallengeProofOfWork ChallengeType = "proof_of_work"
+	ChallengeCaptcha     ChallengeType = "captcha"
+)
+
+var ErrChallengeFailed = errors.New("challenge verification failed")
+
+// Задание, которое клиент должен решить перед Register/Login
+type Challenge struct {
+	Type       ChallengeType `json:"type"`
+	Challenge  string        `json:"challenge,omitempty"`
+	Difficulty int           `json:"difficulty,omitempty"`
+	SiteKey    string        `json:"site_key,omitempty"`
+	ExpiresAt  time.Time     `json:"expires_at,omitempty"`
+}
+
+// Решение: для proof-of-work - исходное задание и найденный счетчик,
+// для CAPTCHA - токен виджета в Solution
+type ChallengeSolution struct {
+	Challenge string `json:"challenge,omitempty"`
+	Solution  string `json:"solution"`
+}
+
+func (s ChallengeSolution) IsEmpty() bool {
+	return s.Solution == ""
+}
@@ -0,0 +1,83 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+var ErrInvalidReportToken = errors.New("invalid or expired report token")
+
+// Контекст, из которого пришел запрос
+type ClientInfo struct {
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	DeviceID  string `json:"device_id,omitempty"`
+}
+
+// Известное устройство пользователя
+type KnownDevice struct {
+	ID              int64     `json:"id" db:"id"`
+	UserID          int64     `json:"user_id" db:"user_id"`
+	Fingerprint     string    `json:"fingerprint" db:"fingerprint"`
+	UserAgentFamily string    `json:"user_agent_family" db:"user_agent_family"`
+	IPPrefix        string    `json:"ip_prefix" db:"ip_prefix"`
+	DeviceID        string    `json:"device_id,omitempty" db:"device_id"`
+	SessionID       string    `json:"session_id" db:"session_id"`
+	ReportTokenHash string    `json:"-" db:"report_token_hash"`
+	FirstSeenAt     time.Time `json:"first_seen_at" db:"first_seen_at"`
+	LastSeenAt      time.Time `json:"last_seen_at" db:"last_seen_at"`
+}
+
+// UserAgentFamily сводит строку User-Agent к семейству клиента
+func UserAgentFamily(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+
+	// Порядок важен: Edge и Opera содержат "chrome", Chrome содержит "safari"
+	families := []struct {
+		marker string
+		family string
+	}{
+		{"edg/", "Edge"},
+		{"opr/", "Opera"},
+		{"firefox/", "Firefox"},
+		{"chrome/", "Chrome"},
+		{"safari/", "Safari"},
+		{"okhttp", "Android App"},
+		{"cfnetwork", "iOS App"},
+		{"grpc-", "gRPC"},
+		{"curl/", "curl"},
+	}
+
+	for _, f := range families {
+		if strings.Contains(ua, f.marker) {
+			return f.family
+		}
+	}
+	return "Other"
+}
+
+// IPPrefix возвращает сеть /24 для IPv4 и /48 для IPv6
+func IPPrefix(ip string) string {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return ""
+	}
+	if v4 := parsed.To4(); v4 != nil {
+		return (&net.IPNet{IP: v4.Mask(net.CIDRMask(24, 32)), Mask: net.CIDRMask(24, 32)}).String()
+	}
+	return (&net.IPNet{IP: parsed.Mask(net.CIDRMask(48, 128)), Mask: net.CIDRMask(48, 128)}).String()
+}
+
+// Fingerprint - отпечаток контекста входа
+func (c ClientInfo) Fingerprint() string {
+	sum := sha256.Sum256([]byte(strings.Join([]string{
+		UserAgentFamily(c.UserAgent),
+		IPPrefix(c.IP),
+		c.DeviceID,
+	}, "|")))
+	return hex.EncodeToString(sum[:])
+}
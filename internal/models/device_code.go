@@ -0,0 +1,93 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// Состояния запроса на вход устройства (RFC 8628)
+const (
+	DeviceAuthorizationPending  = "pending"
+	DeviceAuthorizationApproved = "approved"
+	DeviceAuthorizationDenied   = "denied"
+)
+
+// Интервал опроса по умолчанию и шаг увеличения после slow_down (RFC 8628, 3.2 и 3.5)
+const (
+	DevicePollInterval  = 5 * time.Second
+	DeviceSlowDownDelta = 5 * time.Second
+)
+
+// Алфавит user_code: согласные без похожих на цифры букв, чтобы код
+// нельзя было перепутать и из него не складывались слова (RFC 8628, 6.1)
+const UserCodeAlphabet = "BCDFGHJKLMNPQRSTVWXZ"
+
+// Длина user_code без дефиса
+const UserCodeLength = 8
+
+// Подбор user_code на странице подтверждения ограничен (RFC 8628, 5.1):
+// после UserCodeMaxFailures неверных кодов за окно ввод блокируется
+const (
+	UserCodeMaxFailures   = 5
+	UserCodeFailureWindow = 15 * time.Minute
+)
+
+var (
+	ErrInvalidUserCode         = errors.New("invalid or expired user code")
+	ErrTooManyUserCodeAttempts = errors.New("too many invalid user codes")
+)
+
+// DeviceAuthorization - запрос устройства без браузера (CLI, телевизор) на вход.
+// Коды хранятся только хешами; после одобрения у устройства своя сессия.
+type DeviceAuthorization struct {
+	DeviceCodeHash string   `json:"-" db:"device_code_hash"`
+	UserCodeHash   string   `json:"-" db:"user_code_hash"`
+	TenantID       int64    `json:"tenant_id" db:"tenant_id"`
+	ClientID       string   `json:"client_id" db:"client_id"`
+	Scopes         []string `json:"scopes" db:"scopes"`
+	Status         string   `json:"status" db:"status"`
+	UserID         *int64   `json:"user_id,omitempty" db:"user_id"`
+	SessionID      string   `json:"session_id,omitempty" db:"session_id"`
+	// IP и User-Agent устройства: с ними создается его сессия
+	Client       ClientInfo    `json:"client"`
+	PollInterval time.Duration `json:"poll_interval" db:"poll_interval_seconds"`
+	LastPolledAt *time.Time    `json:"last_polled_at,omitempty" db:"last_polled_at"`
+	CreatedAt    time.Time     `json:"created_at" db:"created_at"`
+	ExpiresAt    time.Time     `json:"expires_at" db:"expires_at"`
+	DecidedAt    *time.Time    `json:"decided_at,omitempty" db:"decided_at"`
+	UsedAt       *time.Time    `json:"used_at,omitempty" db:"used_at"`
+}
+
+// Ответ device authorization endpoint (RFC 8628, 3.2)
+type DeviceAuthorizationResponse struct {
+	DeviceCode              string
+	UserCode                string
+	VerificationURI         string
+	VerificationURIComplete string
+	ExpiresIn               time.Duration
+	Interval                time.Duration
+}
+
+// FormatUserCode делит код дефисом пополам: BCDF-GHJK
+func FormatUserCode(code string) string {
+	half := len(code) / 2
+	return code[:half] + "-" + code[half:]
+}
+
+// NormalizeUserCode приводит введенный пользователем код к виду, в котором
+// он хешируется: регистр и дефисы с пробелами не важны
+func NormalizeUserCode(input string) string {
+	var b strings.Builder
+	for _, c := range strings.ToUpper(input) {
+		if strings.ContainsRune(UserCodeAlphabet, c) {
+			b.WriteRune(c)
+		} else if c != '-' && c != ' ' {
+			return ""
+		}
+	}
+	if b.Len() != UserCodeLength {
+		return ""
+	}
+	return b.String()
+}
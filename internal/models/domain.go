@@ -0,0 +1,108 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const PermissionDomainsManage = "domains:manage"
+
+// Владение доменом подтверждается TXT-записью
+// _auth-challenge.<домен> со значением auth-service-verification=<токен>
+const (
+	DomainChallengePrefix      = "_auth-challenge."
+	DomainChallengeValuePrefix = "auth-service-verification="
+)
+
+// Способ входа, который клиент должен предложить пользователю
+const (
+	LoginMethodPassword = "password"
+	LoginMethodSSO      = "sso"
+)
+
+var (
+	ErrInvalidDomain     = errors.New("invalid domain")
+	ErrDomainNotFound    = errors.New("domain not found")
+	ErrDomainClaimed     = errors.New("domain is already verified by another owner")
+	ErrDomainNotVerified = errors.New("domain verification record not found")
+	ErrSSORequired       = errors.New("single sign-on is required for this email domain")
+)
+
+var domainPattern = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+type Domain struct {
+	ID             int64  `json:"id" db:"id"`
+	TenantID       int64  `json:"tenant_id" db:"tenant_id"`
+	OrganizationID *int64 `json:"organization_id,omitempty" db:"organization_id"`
+	Domain         string `json:"domain" db:"domain"`
+	// Токен не секретный: он публикуется в DNS
+	VerificationToken string     `json:"verification_token" db:"verification_token"`
+	Provider          string     `json:"provider,omitempty" db:"provider"`
+	SSOEnforced       bool       `json:"sso_enforced" db:"sso_enforced"`
+	CreatedBy         *int64     `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	VerifiedAt        *time.Time `json:"verified_at,omitempty" db:"verified_at"`
+}
+
+func (d *Domain) Validate() error {
+	d.Domain = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(d.Domain)), ".")
+	if len(d.Domain) > 253 || !domainPattern.MatchString(d.Domain) {
+		return ErrInvalidDomain
+	}
+	if d.SSOEnforced && d.Provider == "" {
+		return ErrInvalidDomain
+	}
+	return nil
+}
+
+func (d *Domain) Verified() bool {
+	return d.VerifiedAt != nil
+}
+
+// ChallengeName - имя TXT-записи для подтверждения домена
+func (d *Domain) ChallengeName() string {
+	return DomainChallengePrefix + d.Domain
+}
+
+// ChallengeValue - ожидаемое значение TXT-записи
+func (d *Domain) ChallengeValue() string {
+	return DomainChallengeValuePrefix + d.VerificationToken
+}
+
+// Настройки SSO подтвержденного домена
+type DomainSSO struct {
+	Provider    string
+	SSOEnforced bool
+}
+
+// Ответ home-realm discovery: как входить пользователю с этим email
+type LoginDiscovery struct {
+	Method         string
+	Provider       string
+	ProviderName   string
+	SSOEnforced    bool
+	OrganizationID *int64
+}
+
+// EmailDomain возвращает домен email в нижнем регистре или "", если его нет
+func EmailDomain(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at < 0 {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(email[at+1:])), ".")
+}
+
+// DomainCandidates - домен email и все его родительские домены, от самого
+// точного: подтвержденный acme.com распространяется и на eu.acme.com
+func DomainCandidates(email string) []string {
+	domain := EmailDomain(email)
+	var candidates []string
+	for domain != "" && strings.Contains(domain, ".") {
+		candidates = append(candidates, domain)
+		domain = domain[strings.Index(domain, ".")+1:]
+	}
+	return candidates
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+// Схема Authorization и token_type токена, привязанного к ключу клиента (RFC 9449)
+const DPoPTokenType = "DPoP"
+
+// Алгоритмы подписи DPoP-доказательств
+var DPoPSigningAlgorithms = []string{"ES256", "RS256", "PS256", "EdDSA"}
+
+// Насколько iat доказательства может отставать от часов сервера или опережать их.
+// Столько же помним jti, чтобы доказательство нельзя было предъявить повторно.
+const (
+	DPoPProofMaxAge    = 5 * time.Minute
+	DPoPProofClockSkew = 30 * time.Second
+)
+
+var ErrInvalidDPoPProof = errors.New("invalid dpop proof")
+
+// DPoPRequest - доказательство владения ключом и запрос, к которому оно относится.
+// Для gRPC вместо URI передается метод (/auth.AuthService/Login): он сверяется с путем htu.
+type DPoPRequest struct {
+	Proof      string
+	Method     string
+	URI        string
+	GRPCMethod string
+	// Токен, который предъявлен вместе с доказательством (claim ath); пусто при получении токена
+	AccessToken string
+}
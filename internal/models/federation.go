@@ -0,0 +1,271 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Протоколы внешних провайдеров входа
+const (
+	IdentityProviderOIDC = "oidc"
+	IdentityProviderSAML = "saml"
+)
+
+// Что обновляется при повторных входах через провайдера
+const (
+	// Атрибуты задаются только при создании пользователя
+	SyncNever = "never"
+	// Имя и подтверждение email обновляются при каждом входе
+	SyncProfile = "profile"
+	// Профиль и роли из сопоставления групп
+	SyncAll = "all"
+)
+
+var (
+	ErrIdentityProviderNotFound = errors.New("identity provider not found")
+	ErrIdentityProviderExists   = errors.New("identity provider already exists")
+	ErrInvalidIdentityProvider  = errors.New("invalid identity provider")
+	ErrFederationFailed         = errors.New("federated login failed")
+	ErrIdentityNotLinked        = errors.New("external identity is not linked to a user")
+	ErrSAMLNotConfigured        = errors.New("saml service provider is not configured")
+
+	ErrIdentityNotFound         = errors.New("identity not found")
+	ErrIdentityAlreadyLinked    = errors.New("external identity is already linked to a user")
+	ErrLastLoginMethod          = errors.New("cannot remove the last login method")
+	ErrReauthenticationRequired = errors.New("reauthentication required")
+	ErrInvalidMerge             = errors.New("invalid user merge")
+)
+
+const PermissionUsersMerge = "users:merge"
+
+var identityProviderSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+type IdentityProvider struct {
+	ID           int64      `json:"id" db:"id"`
+	TenantID     int64      `json:"tenant_id" db:"tenant_id"`
+	Slug         string     `json:"slug" db:"slug"`
+	Name         string     `json:"name" db:"name"`
+	Protocol     string     `json:"protocol" db:"protocol"`
+	Issuer       string     `json:"issuer" db:"issuer"`
+	ClientID     string     `json:"client_id" db:"client_id"`
+	ClientSecret string     `json:"-" db:"client_secret"`
+	Scopes       []string   `json:"scopes" db:"scopes"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	DisabledAt   *time.Time `json:"disabled_at,omitempty" db:"disabled_at"`
+
+	// Создавать пользователя при первом входе, если привязки еще нет
+	JITProvisioning  bool             `json:"jit_provisioning" db:"jit_provisioning"`
+	AttributeMapping AttributeMapping `json:"attribute_mapping" db:"attribute_mapping"`
+	SyncPolicy       string           `json:"sync_policy" db:"sync_policy"`
+
+	// Метаданные IdP SAML (XML); Issuer для SAML - его entityID
+	SAMLMetadata string `json:"-" db:"saml_metadata"`
+}
+
+func (p *IdentityProvider) Validate() error {
+	// callback занят адресом возврата от провайдеров: /oauth2/federation/callback
+	if !identityProviderSlugPattern.MatchString(p.Slug) || p.Slug == "callback" || strings.TrimSpace(p.Name) == "" {
+		return ErrInvalidIdentityProvider
+	}
+
+	switch p.Protocol {
+	case IdentityProviderOIDC:
+		issuer, err := url.Parse(p.Issuer)
+		if err != nil || (issuer.Scheme != "https" && issuer.Scheme != "http") || issuer.Host == "" || p.ClientID == "" {
+			return ErrInvalidIdentityProvider
+		}
+		for _, scope := range p.Scopes {
+			if !ValidOAuthScope(scope) {
+				return ErrInvalidIdentityProvider
+			}
+		}
+	case IdentityProviderSAML:
+		if p.Issuer == "" || p.SAMLMetadata == "" {
+			return ErrInvalidIdentityProvider
+		}
+	default:
+		return ErrInvalidIdentityProvider
+	}
+	switch p.SyncPolicy {
+	case SyncNever, SyncProfile, SyncAll:
+	default:
+		return ErrInvalidIdentityProvider
+	}
+	for _, role := range p.AttributeMapping.ManagedRoles() {
+		if !roleNamePattern.MatchString(role) {
+			return ErrInvalidIdentityProvider
+		}
+	}
+	return nil
+}
+
+// Сопоставление утверждений ID-токена атрибутам пользователя. Пустое поле -
+// стандартное утверждение OpenID Connect; вложенные утверждения задаются через
+// точку (например, realm_access.roles у Keycloak).
+type AttributeMapping struct {
+	Email         string `json:"email,omitempty"`
+	EmailVerified string `json:"email_verified,omitempty"`
+	FirstName     string `json:"first_name,omitempty"`
+	Surname       string `json:"surname,omitempty"`
+	Groups        string `json:"groups,omitempty"`
+
+	// Роли по группам провайдера и роли каждого пользователя провайдера
+	GroupRoles   map[string][]string `json:"group_roles,omitempty"`
+	DefaultRoles []string            `json:"default_roles,omitempty"`
+}
+
+// Apply заполняет атрибуты внешнего пользователя из его утверждений
+func (m *AttributeMapping) Apply(identity *ExternalIdentity) {
+	identity.Email = strings.ToLower(strings.TrimSpace(claimString(identity.Claims, orDefault(m.Email, "email"))))
+	identity.EmailVerified = claimBool(identity.Claims, orDefault(m.EmailVerified, "email_verified"))
+	identity.GivenName = strings.TrimSpace(claimString(identity.Claims, orDefault(m.FirstName, "given_name")))
+	identity.FamilyName = strings.TrimSpace(claimString(identity.Claims, orDefault(m.Surname, "family_name")))
+	identity.Groups = claimStrings(identity.Claims, orDefault(m.Groups, "groups"))
+}
+
+// Roles возвращает роли, которые положены пользователю с такими группами
+func (m *AttributeMapping) Roles(groups []string) []string {
+	roles := append([]string(nil), m.DefaultRoles...)
+	for _, group := range groups {
+		roles = append(roles, m.GroupRoles[group]...)
+	}
+	return roles
+}
+
+// ManagedRoles - все роли, которые выдает сопоставление. При синхронизации
+// отзываются только они: роли, выданные администратором вручную, не трогаем.
+func (m *AttributeMapping) ManagedRoles() []string {
+	roles := append([]string(nil), m.DefaultRoles...)
+	for _, groupRoles := range m.GroupRoles {
+		roles = append(roles, groupRoles...)
+	}
+	return roles
+}
+
+func orDefault(value, fallback string) string {
+	if value == "" {
+		return fallback
+	}
+	return value
+}
+
+func claimValue(claims map[string]interface{}, path string) interface{} {
+	var value interface{} = claims
+	for _, key := range strings.Split(path, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		value = object[key]
+	}
+	return value
+}
+
+func claimString(claims map[string]interface{}, path string) string {
+	value, _ := claimValue(claims, path).(string)
+	return value
+}
+
+// Некоторые провайдеры (например, Cognito) передают булевы утверждения строкой
+func claimBool(claims map[string]interface{}, path string) bool {
+	switch value := claimValue(claims, path).(type) {
+	case bool:
+		return value
+	case string:
+		return strings.EqualFold(value, "true")
+	}
+	return false
+}
+
+// Группы приходят массивом строк, а иногда одной строкой
+func claimStrings(claims map[string]interface{}, path string) []string {
+	switch value := claimValue(claims, path).(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, item := range value {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// Identity связывает учетную запись у внешнего провайдера с локальным пользователем
+type Identity struct {
+	ID          int64      `json:"id" db:"id"`
+	TenantID    int64      `json:"tenant_id" db:"tenant_id"`
+	UserID      int64      `json:"user_id" db:"user_id"`
+	Provider    string     `json:"provider" db:"provider"`
+	Subject     string     `json:"subject" db:"subject"`
+	Email       string     `json:"email" db:"email"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	LastLoginAt *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
+}
+
+// Способы, которыми пользователь может войти
+type LoginMethods struct {
+	Password   bool
+	Identities []*Identity
+}
+
+// Привязка внешней учетной записи к вошедшему пользователю
+type LinkIdentityRequest struct {
+	Provider string
+	IDToken  string
+	// Повторная аутентификация; без пароля нужен недавний вход
+	Password string
+}
+
+// Незавершенный вход через провайдера; state хранится только хешем.
+// Nonce для SAML - ID запроса AuthnRequest (InResponseTo в ответе).
+type FederationState struct {
+	StateHash    string     `json:"-" db:"state_hash"`
+	TenantID     int64      `json:"tenant_id" db:"tenant_id"`
+	Provider     string     `json:"provider" db:"provider"`
+	Nonce        string     `json:"-" db:"nonce"`
+	CodeVerifier string     `json:"-" db:"code_verifier"`
+	ReturnTo     string     `json:"return_to" db:"return_to"`
+	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt    time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt       *time.Time `json:"used_at,omitempty" db:"used_at"`
+}
+
+// Пользователь по данным проверенного ID-токена или утверждения SAML
+type ExternalIdentity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	GivenName     string
+	FamilyName    string
+	Groups        []string
+	// Все утверждения ID-токена или атрибуты утверждения SAML
+	Claims map[string]interface{}
+}
+
+// Вход пользователя, которого уже проверил внешний провайдер
+type ExternalLoginRequest struct {
+	User *User
+	// Slug провайдера, подтвердившего пользователя
+	Provider string
+	Method   string
+	Client   ClientInfo
+}
+
+// Параметры, с которыми провайдер вернул браузер на callback или ACS
+type FederationCallback struct {
+	State string
+	Code  string
+	// error из ответа провайдера (RFC 6749, 4.1.2.1)
+	Error string
+	// SAMLResponse из формы HTTP-POST binding (base64)
+	SAMLResponse string
+	Client       ClientInfo
+}
@@ -0,0 +1,41 @@
+package models
+
+import "errors"
+
+// Разрешение входить от имени пользователя (для поддержки)
+const PermissionUsersImpersonate = "users:impersonate"
+
+// Разрешения, обладателей которых нельзя имперсонировать: иначе через чужую
+// сессию можно было бы получить права другого администратора
+var AdministrativePermissions = []string{
+	PermissionRolesManage,
+	PermissionPoliciesManage,
+	PermissionUsersMerge,
+	PermissionUsersImpersonate,
+	PermissionServiceAccountsManage,
+	PermissionDomainsManage,
+}
+
+// Причина имперсонации попадает в аудит; длиннее - явно не то, что нужно
+const MaxImpersonationReasonLength = 500
+
+var (
+	ErrImpersonationForbidden     = errors.New("this user cannot be impersonated")
+	ErrInvalidImpersonationReason = errors.New("impersonation requires a reason")
+	ErrImpersonatedSession        = errors.New("not allowed while impersonating a user")
+)
+
+// IsAdministrator - роль admin или любое административное разрешение
+func (a *UserAccess) IsAdministrator() bool {
+	for _, role := range a.Roles {
+		if role == DefaultRoleAdmin {
+			return true
+		}
+	}
+	for _, permission := range AdministrativePermissions {
+		if a.HasPermission(permission) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,246 @@
+package models
+
+import (
+	"errors"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Типы грантов OAuth 2.0
+const (
+	GrantClientCredentials = "client_credentials"
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantDeviceCode        = "urn:ietf:params:oauth:grant-type:device_code"
+	GrantTokenExchange     = "urn:ietf:params:oauth:grant-type:token-exchange"
+)
+
+// Типы токенов в обмене (RFC 8693, 3). Наши access-токены - JWT, поэтому принимаются оба
+const (
+	TokenTypeAccessToken = "urn:ietf:params:oauth:token-type:access_token"
+	TokenTypeJWT         = "urn:ietf:params:oauth:token-type:jwt"
+)
+
+// Способы аутентификации клиента на token endpoint
+const (
+	ClientAuthSecret        = "client_secret"
+	ClientAuthPrivateKeyJWT = "private_key_jwt"
+	// Публичный клиент без секрета: authorization_code с PKCE, device_code и refresh_token
+	ClientAuthNone = "none"
+
+	ClientAssertionTypeJWTBearer = "urn:ietf:params:oauth:client-assertion-type:jwt-bearer"
+)
+
+// Коды ошибок token endpoint (RFC 6749, 5.2; invalid_target - RFC 8707)
+const (
+	OAuthInvalidRequest       = "invalid_request"
+	OAuthInvalidClient        = "invalid_client"
+	OAuthInvalidGrant         = "invalid_grant"
+	OAuthUnauthorizedClient   = "unauthorized_client"
+	OAuthUnsupportedGrantType = "unsupported_grant_type"
+	OAuthInvalidScope         = "invalid_scope"
+	OAuthInvalidTarget        = "invalid_target"
+
+	// Ошибки authorization endpoint (RFC 6749, 4.1.2.1; OpenID Connect Core, 3.1.2.6)
+	OAuthAccessDenied            = "access_denied"
+	OAuthUnsupportedResponseType = "unsupported_response_type"
+	OAuthLoginRequired           = "login_required"
+	OAuthConsentRequired         = "consent_required"
+
+	// Ошибки защищенных ресурсов, например userinfo (RFC 6750, 3.1)
+	OAuthInvalidToken      = "invalid_token"
+	OAuthInsufficientScope = "insufficient_scope"
+
+	// Ответы на опрос token endpoint устройством (RFC 8628, 3.5)
+	OAuthAuthorizationPending = "authorization_pending"
+	OAuthSlowDown             = "slow_down"
+	OAuthExpiredToken         = "expired_token"
+	// Неверное DPoP-доказательство (RFC 9449, 5)
+	OAuthInvalidDPoPProof = "invalid_dpop_proof"
+)
+
+var (
+	ErrOAuthClientNotFound = errors.New("oauth client not found")
+	ErrOAuthClientExists   = errors.New("oauth client already exists")
+	ErrInvalidOAuthClient  = errors.New("invalid oauth client")
+)
+
+// OAuthError возвращается клиенту как есть: код и описание из RFC 6749
+type OAuthError struct {
+	Code        string
+	Description string
+}
+
+func NewOAuthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+type OAuthClient struct {
+	ID             int64         `json:"id" db:"id"`
+	TenantID       int64         `json:"tenant_id" db:"tenant_id"`
+	ClientID       string        `json:"client_id" db:"client_id"`
+	Name           string        `json:"name" db:"name"`
+	AuthMethod     string        `json:"auth_method" db:"auth_method"`
+	SecretHash     string        `json:"-" db:"secret_hash"`
+	PublicKeyPEM   string        `json:"public_key_pem,omitempty" db:"public_key_pem"`
+	GrantTypes     []string      `json:"grant_types" db:"grant_types"`
+	Scopes         []string      `json:"scopes" db:"scopes"`
+	Audiences      []string      `json:"audiences" db:"audiences"`
+	AccessTokenTTL time.Duration `json:"access_token_ttl" db:"access_token_ttl_seconds"`
+	CreatedAt      time.Time     `json:"created_at" db:"created_at"`
+	DisabledAt     *time.Time    `json:"disabled_at,omitempty" db:"disabled_at"`
+
+	// Точные адреса возврата для authorization_code и end-session
+	RedirectURIs           []string `json:"redirect_uris,omitempty" db:"redirect_uris"`
+	PostLogoutRedirectURIs []string `json:"post_logout_redirect_uris,omitempty" db:"post_logout_redirect_uris"`
+}
+
+func (c *OAuthClient) Validate() error {
+	if strings.TrimSpace(c.Name) == "" || len(c.GrantTypes) == 0 || c.AccessTokenTTL <= 0 {
+		return ErrInvalidOAuthClient
+	}
+	switch c.AuthMethod {
+	case ClientAuthSecret:
+	case ClientAuthPrivateKeyJWT:
+		if c.PublicKeyPEM == "" {
+			return ErrInvalidOAuthClient
+		}
+	case ClientAuthNone:
+		// Публичному клиенту нечем подтвердить client_credentials и обмен токенов
+		if c.AllowsGrant(GrantClientCredentials) || c.AllowsGrant(GrantTokenExchange) {
+			return ErrInvalidOAuthClient
+		}
+	default:
+		return ErrInvalidOAuthClient
+	}
+	for _, grant := range c.GrantTypes {
+		switch grant {
+		case GrantClientCredentials, GrantAuthorizationCode, GrantRefreshToken, GrantDeviceCode, GrantTokenExchange:
+		default:
+			return ErrInvalidOAuthClient
+		}
+	}
+	for _, scope := range c.Scopes {
+		if !ValidOAuthScope(scope) {
+			return ErrInvalidOAuthClient
+		}
+	}
+	if c.AllowsGrant(GrantAuthorizationCode) && len(c.RedirectURIs) == 0 {
+		return ErrInvalidOAuthClient
+	}
+	for _, uris := range [][]string{c.RedirectURIs, c.PostLogoutRedirectURIs} {
+		for _, uri := range uris {
+			if !validRedirectURI(uri) {
+				return ErrInvalidOAuthClient
+			}
+		}
+	}
+	return nil
+}
+
+// Адрес возврата - абсолютный URI без фрагмента (RFC 6749, 3.1.2)
+func validRedirectURI(uri string) bool {
+	parsed, err := url.Parse(uri)
+	return err == nil && parsed.Scheme != "" && parsed.Fragment == "" && (parsed.Host != "" || parsed.Opaque != "")
+}
+
+// ValidOAuthScope проверяет символы scope-token (RFC 6749, 3.3)
+func ValidOAuthScope(scope string) bool {
+	if scope == "" || len(scope) > 100 {
+		return false
+	}
+	for _, c := range scope {
+		if c < 0x21 || c > 0x7e || c == '"' || c == '\\' {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	return containsString(c.GrantTypes, grantType)
+}
+
+// Адреса возврата сравниваются посимвольно, без нормализации
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	return containsString(c.RedirectURIs, uri)
+}
+
+func (c *OAuthClient) AllowsPostLogoutRedirectURI(uri string) bool {
+	return containsString(c.PostLogoutRedirectURIs, uri)
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Параметры запроса к token endpoint
+type TokenRequest struct {
+	GrantType           string
+	ClientID            string
+	ClientSecret        string
+	ClientAssertionType string
+	ClientAssertion     string
+	Scope               string
+	Audience            []string
+
+	// authorization_code (с PKCE) и refresh_token
+	Code         string
+	RedirectURI  string
+	CodeVerifier string
+	RefreshToken string
+
+	// device_code (RFC 8628)
+	DeviceCode string
+
+	// token-exchange (RFC 8693): токен пользователя и, по желанию, токен действующей стороны
+	SubjectToken       string
+	SubjectTokenType   string
+	ActorToken         string
+	ActorTokenType     string
+	RequestedTokenType string
+
+	// Доказательство DPoP (RFC 9449): выданные токены привязываются к его ключу
+	DPoP *DPoPRequest
+}
+
+type TokenResponse struct {
+	AccessToken string
+	TokenType   string
+	ExpiresIn   time.Duration
+	Scope       string
+
+	RefreshToken string
+	IDToken      string
+	// Только для token-exchange
+	IssuedTokenType string
+}
+
+// Actor - сторона, действующая от имени субъекта токена (claim act, RFC 8693, 4.1).
+// Act - предыдущие звенья цепочки делегирования.
+type Actor struct {
+	Subject string `json:"sub"`
+	Act     *Actor `json:"act,omitempty"`
+}
+
+// Depth - длина цепочки делегирования
+func (a *Actor) Depth() int {
+	depth := 0
+	for actor := a; actor != nil; actor = actor.Act {
+		depth++
+	}
+	return depth
+}
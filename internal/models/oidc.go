@@ -0,0 +1,147 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Области OpenID Connect
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+)
+
+// Значения prompt (OpenID Connect Core, 3.1.2.1)
+const (
+	PromptNone    = "none"
+	PromptLogin   = "login"
+	PromptConsent = "consent"
+)
+
+// PKCE: принимаем только S256 (RFC 7636, 4.2)
+const CodeChallengeS256 = "S256"
+
+// Параметры запроса к authorization endpoint
+type AuthorizationRequest struct {
+	ClientID            string
+	RedirectURI         string
+	ResponseType        string
+	Scopes              []string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Prompt              []string
+	// Максимальный возраст входа; nil - не ограничен
+	MaxAge *time.Duration
+
+	// Пользователь только что ввел пароль: prompt=login и max_age уже выполнены
+	LoginCompleted bool
+}
+
+func (r *AuthorizationRequest) HasPrompt(prompt string) bool {
+	return containsString(r.Prompt, prompt)
+}
+
+func (r *AuthorizationRequest) HasScope(scope string) bool {
+	return containsString(r.Scopes, scope)
+}
+
+// Итог обработки запроса авторизации: страница входа, страница согласия или редирект к клиенту
+type AuthorizationResult struct {
+	Client      *OAuthClient
+	Request     *AuthorizationRequest
+	NeedLogin   bool
+	NeedConsent bool
+	RedirectURL string
+}
+
+// OAuthGrant - что пользователь разрешил клиенту в рамках браузерной сессии
+type OAuthGrant struct {
+	TenantID  int64     `json:"tenant_id" db:"tenant_id"`
+	ClientID  string    `json:"client_id" db:"client_id"`
+	UserID    int64     `json:"user_id" db:"user_id"`
+	SessionID string    `json:"session_id" db:"session_id"`
+	Scopes    []string  `json:"scopes" db:"scopes"`
+	AuthTime  time.Time `json:"auth_time" db:"auth_time"`
+	// nonce из запроса авторизации попадает только в первый ID-токен
+	Nonce string `json:"-" db:"nonce"`
+}
+
+func (g *OAuthGrant) HasScope(scope string) bool {
+	return containsString(g.Scopes, scope)
+}
+
+type AuthorizationCode struct {
+	OAuthGrant
+	CodeHash      string     `json:"-" db:"code_hash"`
+	RedirectURI   string     `json:"redirect_uri" db:"redirect_uri"`
+	CodeChallenge string     `json:"-" db:"code_challenge"`
+	CreatedAt     time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt     time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt        *time.Time `json:"used_at,omitempty" db:"used_at"`
+}
+
+type OAuthRefreshToken struct {
+	OAuthGrant
+	TokenHash string     `json:"-" db:"token_hash"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at" db:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty" db:"used_at"`
+	// Ключ DPoP публичного клиента: обновить токен может только его владелец
+	DPoPThumbprint string `json:"-" db:"dpop_jkt"`
+}
+
+type OAuthConsent struct {
+	UserID    int64     `json:"user_id" db:"user_id"`
+	ClientID  string    `json:"client_id" db:"client_id"`
+	Scopes    []string  `json:"scopes" db:"scopes"`
+	GrantedAt time.Time `json:"granted_at" db:"granted_at"`
+}
+
+// Covers сообщает, дано ли согласие на все запрошенные области
+func (c *OAuthConsent) Covers(scopes []string) bool {
+	for _, scope := range scopes {
+		if !containsString(c.Scopes, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// Запрос к end-session endpoint (OpenID Connect RP-Initiated Logout)
+type EndSessionRequest struct {
+	IDTokenHint           string
+	ClientID              string
+	PostLogoutRedirectURI string
+	State                 string
+}
+
+// Проверенный запрос на выход: клиент и сессия из id_token_hint
+type EndSession struct {
+	Client      *OAuthClient
+	RedirectURL string
+	// Сессия, для которой выпущен id_token_hint (пусто без подсказки)
+	HintSessionID string
+}
+
+// StandardClaims - утверждения о пользователе, доступные по областям profile и email
+// (OpenID Connect Core, 5.4). sub добавляет вызывающий.
+func StandardClaims(user *User, scopes []string) map[string]interface{} {
+	claims := make(map[string]interface{})
+	if containsString(scopes, ScopeProfile) {
+		claims["name"] = strings.TrimSpace(user.FirstName + " " + user.Surname)
+		claims["given_name"] = user.FirstName
+		claims["family_name"] = user.Surname
+		if !user.Birthday.IsZero() {
+			claims["birthdate"] = user.Birthday.Format("2006-01-02")
+		}
+		claims["updated_at"] = user.UpdatedAt.Unix()
+	}
+	if containsString(scopes, ScopeEmail) {
+		claims["email"] = user.Email
+		claims["email_verified"] = user.IsVerified
+	}
+	return claims
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"errors"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Роль участника внутри организации (не путать с ролями RBAC тенанта)
+type OrganizationRole string
+
+const (
+	OrganizationOwner  OrganizationRole = "owner"
+	OrganizationAdmin  OrganizationRole = "admin"
+	OrganizationMember OrganizationRole = "member"
+)
+
+var (
+	ErrOrganizationNotFound      = errors.New("organization not found")
+	ErrInvalidOrganizationName   = errors.New("invalid organization name")
+	ErrInvalidOrganizationRole   = errors.New("invalid organization role")
+	ErrNotOrganizationMember     = errors.New("user is not a member of the organization")
+	ErrAlreadyOrganizationMember = errors.New("user is already a member of the organization")
+	ErrLastOrganizationOwner     = errors.New("organization must keep at least one owner")
+	ErrInvalidInvitation         = errors.New("invalid or expired invitation")
+)
+
+func (r OrganizationRole) Valid() bool {
+	switch r {
+	case OrganizationOwner, OrganizationAdmin, OrganizationMember:
+		return true
+	}
+	return false
+}
+
+// CanManageMembers - приглашать, удалять участников и менять их роли
+func (r OrganizationRole) CanManageMembers() bool {
+	return r == OrganizationOwner || r == OrganizationAdmin
+}
+
+type Organization struct {
+	ID        int64     `json:"id" db:"id"`
+	TenantID  int64     `json:"tenant_id" db:"tenant_id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedBy *int64    `json:"created_by,omitempty" db:"created_by"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func (o *Organization) Validate() error {
+	o.Name = strings.TrimSpace(o.Name)
+	if o.Name == "" || len(o.Name) > 255 {
+		return ErrInvalidOrganizationName
+	}
+	// Имя попадает в тему письма-приглашения: переводы строк дали бы
+	// подставить свои заголовки
+	if strings.IndexFunc(o.Name, unicode.IsControl) >= 0 {
+		return ErrInvalidOrganizationName
+	}
+	return nil
+}
+
+type OrganizationMembership struct {
+	OrganizationID int64            `json:"organization_id" db:"organization_id"`
+	UserID         int64            `json:"user_id" db:"user_id"`
+	Role           OrganizationRole `json:"role" db:"role"`
+	JoinedAt       time.Time        `json:"joined_at" db:"joined_at"`
+}
+
+type Invitation struct {
+	ID             int64            `json:"id" db:"id"`
+	OrganizationID int64            `json:"organization_id" db:"organization_id"`
+	Email          string           `json:"email" db:"email"`
+	Role           OrganizationRole `json:"role" db:"role"`
+	InvitedBy      *int64           `json:"invited_by,omitempty" db:"invited_by"`
+	CreatedAt      time.Time        `json:"created_at" db:"created_at"`
+	ExpiresAt      time.Time        `json:"expires_at" db:"expires_at"`
+}
+
+// Принятие приглашения: уже вошедшим пользователем (UserID)
+// или с регистрацией нового аккаунта на email приглашения (Registr)
+type AcceptInvitationRequest struct {
+	Token   string
+	UserID  *int64
+	Registr *Registr
+}
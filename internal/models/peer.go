@@ -0,0 +1,19 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrWorkloadNotAllowed = errors.New("workload is not allowed to call this method")
+
+// Личность вызывающего сервиса из проверенного клиентского сертификата mTLS
+type PeerIdentity struct {
+	Subject      string    `json:"subject"`
+	CommonName   string    `json:"common_name"`
+	DNSNames     []string  `json:"dns_names,omitempty"`
+	URIs         []string  `json:"uris,omitempty"`
+	SPIFFEID     string    `json:"spiffe_id,omitempty"`
+	SerialNumber string    `json:"serial_number"`
+	NotAfter     time.Time `json:"not_after"`
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+type PolicyEffect string
+
+const (
+	PolicyAllow PolicyEffect = "allow"
+	PolicyDeny  PolicyEffect = "deny"
+)
+
+// Действие, которое проверяется при входе; "*" в Actions - все действия
+const (
+	PolicyActionLogin = "login"
+	PolicyActionAny   = "*"
+
+	// Обмен токена (RFC 8693): resource - запрошенная аудитория,
+	// attributes - client_id, actor, subject_client_id и scope
+	PolicyActionTokenExchange = "token_exchange"
+)
+
+const PermissionPoliciesManage = "policies:manage"
+
+var (
+	ErrPolicyNotFound = errors.New("policy not found")
+	ErrPolicyDenied   = errors.New("denied by policy")
+	ErrInvalidPolicy  = errors.New("invalid policy")
+)
+
+var (
+	policyNamePattern   = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,99}$`)
+	policyActionPattern = regexp.MustCompile(`^[^\s]{1,100}$`)
+)
+
+// PolicyCompileError - выражение не компилируется или возвращает не bool
+type PolicyCompileError struct {
+	Issues string
+}
+
+func (e *PolicyCompileError) Error() string {
+	return "policy expression is invalid: " + e.Issues
+}
+
+// Политика на CEL. Выражение возвращает bool: true - политика сработала.
+type Policy struct {
+	ID          int64        `json:"id" db:"id"`
+	Name        string       `json:"name" db:"name"`
+	Description string       `json:"description" db:"description"`
+	Expression  string       `json:"expression" db:"expression"`
+	Effect      PolicyEffect `json:"effect" db:"effect"`
+	Actions     []string     `json:"actions" db:"actions"`
+	// Политики с большим приоритетом проверяются первыми
+	Priority  int       `json:"priority" db:"priority"`
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// Validate проверяет все, кроме самого выражения - его проверяет компилятор
+func (p *Policy) Validate() error {
+	if !policyNamePattern.MatchString(p.Name) || p.Expression == "" || len(p.Actions) == 0 {
+		return ErrInvalidPolicy
+	}
+	if p.Effect != PolicyAllow && p.Effect != PolicyDeny {
+		return ErrInvalidPolicy
+	}
+	for _, action := range p.Actions {
+		if !policyActionPattern.MatchString(action) {
+			return ErrInvalidPolicy
+		}
+	}
+	return nil
+}
+
+// Контекст вычисления политик
+type PolicyInput struct {
+	Action     string
+	Resource   string
+	User       *User
+	Access     *UserAccess
+	Claims     *TokenClaims
+	IP         string
+	UserAgent  string
+	Attributes map[string]string
+	Time       time.Time
+}
+
+// Решение и его объяснение
+type PolicyDecision struct {
+	Allowed bool `json:"allowed"`
+	// Имя сработавшей политики; пусто, если применено решение по умолчанию
+	Policy string       `json:"policy,omitempty"`
+	Effect PolicyEffect `json:"effect"`
+	Reason string       `json:"reason"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"time"
+)
+
+// Роли, которые создает миграция
+const (
+	DefaultRoleUser  = "user"
+	DefaultRoleAdmin = "admin"
+)
+
+// Разрешения, на которые опирается сам сервис
+const (
+	PermissionUsersRead   = "users:read"
+	PermissionRolesManage = "roles:manage"
+)
+
+var (
+	ErrRoleNotFound          = errors.New("role not found")
+	ErrRoleAlreadyExists     = errors.New("role already exists")
+	ErrInvalidRoleName       = errors.New("invalid role name")
+	ErrInvalidPermissionName = errors.New("invalid permission name")
+	ErrPermissionDenied      = errors.New("permission denied")
+)
+
+var (
+	roleNamePattern       = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,99}$`)
+	permissionNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]*:[a-z][a-z0-9_.-]*$`)
+)
+
+type Role struct {
+	ID          int64     `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Permissions []string  `json:"permissions"`
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+}
+
+// Validate проверяет имя роли и разрешений (resource:action)
+func (r *Role) Validate() error {
+	if !roleNamePattern.MatchString(r.Name) {
+		return ErrInvalidRoleName
+	}
+	for _, permission := range r.Permissions {
+		if !ValidPermissionName(permission) {
+			return ErrInvalidPermissionName
+		}
+	}
+	return nil
+}
+
+func ValidPermissionName(name string) bool {
+	return len(name) <= 100 && permissionNamePattern.MatchString(name)
+}
+
+// Роли пользователя и объединение их разрешений
+type UserAccess struct {
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+func (a *UserAccess) HasPermission(permission string) bool {
+	for _, p := range a.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -9,4 +9,8 @@ type Registr struct {
 	Email     string    `json:"email" validate:"required,email"`
 	Password  string    `json:"password" validate:"required,min=8"`
 	Phone     *string   `json:"phone,omitempty" validate:"omitempty,e164"`
+
+	// Решение CAPTCHA / proof-of-work и контекст клиента
+	Challenge ChallengeSolution `json:"-"`
+	Client    ClientInfo        `json:"-"`
 }
@@ -0,0 +1,109 @@
+package models
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+)
+
+var (
+	ErrUnknownNamespace        = errors.New("unknown namespace")
+	ErrUnknownRelation         = errors.New("unknown relation")
+	ErrInvalidRelationship     = errors.New("invalid relationship")
+	ErrRelationDepthExceeded   = errors.New("relation check depth exceeded")
+	ErrInvalidConsistencyToken = errors.New("invalid consistency token")
+)
+
+var (
+	relationNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,63}$`)
+	objectIDPattern     = regexp.MustCompile(`^[^\s#@]{1,255}$`)
+)
+
+// Объект в пространстве имен: document:readme
+type ObjectRef struct {
+	Namespace string `json:"namespace"`
+	ID        string `json:"id"`
+}
+
+func (o ObjectRef) String() string {
+	return o.Namespace + ":" + o.ID
+}
+
+func (o ObjectRef) Valid() bool {
+	return relationNamePattern.MatchString(o.Namespace) && objectIDPattern.MatchString(o.ID)
+}
+
+// Субъект: конкретный объект (user:42) или множество (group:eng#member)
+type SubjectRef struct {
+	Object   ObjectRef `json:"object"`
+	Relation string    `json:"relation,omitempty"`
+}
+
+func (s SubjectRef) String() string {
+	if s.Relation == "" {
+		return s.Object.String()
+	}
+	return s.Object.String() + "#" + s.Relation
+}
+
+// Кортеж отношения object#relation@subject
+type RelationTuple struct {
+	Resource ObjectRef  `json:"resource"`
+	Relation string     `json:"relation"`
+	Subject  SubjectRef `json:"subject"`
+}
+
+func (t RelationTuple) String() string {
+	return t.Resource.String() + "#" + t.Relation + "@" + t.Subject.String()
+}
+
+// Validate проверяет только синтаксис; соответствие схеме проверяет сервис
+func (t RelationTuple) Validate() error {
+	if !t.Resource.Valid() || !t.Subject.Object.Valid() || !ValidRelationName(t.Relation) {
+		return ErrInvalidRelationship
+	}
+	if t.Subject.Relation != "" && !ValidRelationName(t.Subject.Relation) {
+		return ErrInvalidRelationship
+	}
+	return nil
+}
+
+func ValidRelationName(name string) bool {
+	return relationNamePattern.MatchString(name)
+}
+
+type RelationOperation string
+
+const (
+	RelationTouch  RelationOperation = "touch"
+	RelationDelete RelationOperation = "delete"
+)
+
+type RelationUpdate struct {
+	Operation RelationOperation `json:"operation"`
+	Tuple     RelationTuple     `json:"tuple"`
+}
+
+// Ревизия хранилища кортежей: растет с каждой записью.
+// Клиенты получают ее как непрозрачный токен согласованности.
+type RelationRevision int64
+
+func (r RelationRevision) Token() string {
+	return strconv.FormatInt(int64(r), 10)
+}
+
+func ParseRelationRevision(token string) (RelationRevision, error) {
+	revision, err := strconv.ParseInt(token, 10, 64)
+	if err != nil || revision < 0 {
+		return 0, ErrInvalidConsistencyToken
+	}
+	return RelationRevision(revision), nil
+}
+
+// Требование к свежести ответа. По умолчанию допускается кешированный результат.
+type Consistency struct {
+	// Не использовать кеш
+	FullyConsistent bool
+	// Результат не старше указанной ревизии (токен из WriteRelationships)
+	AtLeastAsFresh RelationRevision
+}
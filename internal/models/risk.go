@@ -0,0 +1,53 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+type RiskDecision string
+
+const (
+	RiskAllow          RiskDecision = "allow"
+	RiskRequireCaptcha RiskDecision = "require_captcha"
+	RiskRequireMFA     RiskDecision = "require_mfa"
+	RiskDeny           RiskDecision = "deny"
+)
+
+// Причины неудачного входа, которые говорят о подборе пароля. Только они
+// учитываются в частоте ошибок: собственные отказы сервиса (риск, CAPTCHA,
+// политики) не должны продлевать блокировку
+const (
+	LoginFailureInvalidPassword = "invalid_password"
+	LoginFailureUnknownEmail    = "unknown_email"
+)
+
+var CredentialFailureReasons = []string{LoginFailureInvalidPassword, LoginFailureUnknownEmail}
+
+var (
+	ErrLoginDenied     = errors.New("login denied by risk policy")
+	ErrCaptchaRequired = errors.New("captcha required")
+	ErrMFARequired     = errors.New("multi-factor authentication required")
+)
+
+// Попытка входа, которую оценивает риск-движок. User == nil, если email не найден
+type LoginAttempt struct {
+	Email  string
+	User   *User
+	Client ClientInfo
+	Time   time.Time
+
+	// Заполняются, если подключена GeoIP-база
+	Location         *GeoLocation
+	ImpossibleTravel bool
+
+	// Отпечаток ключа DPoP, к которому привязать токен (пусто - bearer)
+	DPoPThumbprint string
+}
+
+// Результат оценки: итоговый балл, решение и вклад каждого сигнала
+type RiskAssessment struct {
+	Score    float64            `json:"score"`
+	Decision RiskDecision       `json:"decision"`
+	Signals  map[string]float64 `json:"signals"`
+}
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	ErrSessionRevoked  = errors.New("session revoked")
+	ErrInvalidToken    = errors.New("invalid token")
+)
+
+type Session struct {
+	ID                string     `json:"id" db:"id"`
+	UserID            int64      `json:"user_id" db:"user_id"`
+	RefreshTokenHash  string     `json:"-" db:"refresh_token_hash"`
+	UserAgent         string     `json:"user_agent" db:"user_agent"`
+	IP                string     `json:"ip" db:"ip"`
+	DeviceFingerprint string     `json:"device_fingerprint" db:"device_fingerprint"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	ExpiresAt         time.Time  `json:"expires_at" db:"expires_at"`
+	RevokedAt         *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+
+	// Геолокация IP на момент входа (если подключена GeoIP-база)
+	Location         *GeoLocation `json:"location,omitempty"`
+	ImpossibleTravel bool         `json:"impossible_travel" db:"impossible_travel"`
+
+	// Администратор, открывший сессию от имени пользователя
+	ImpersonatorID *int64 `json:"impersonator_id,omitempty" db:"impersonator_id"`
+
+	TenantID int64 `json:"tenant_id" db:"tenant_id"`
+}
+
+type GeoLocation struct {
+	CountryCode string  `json:"country_code" db:"country_code"`
+	Country     string  `json:"country" db:"country"`
+	City        string  `json:"city" db:"city"`
+	Latitude    float64 `json:"latitude" db:"latitude"`
+	Longitude   float64 `json:"longitude" db:"longitude"`
+}
+
+func (s *Session) IsActive(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// Данные из access-токена
+type TokenClaims struct {
+	UserID      int64     `json:"user_id"`
+	TenantID    int64     `json:"tenant_id"`
+	SessionID   string    `json:"session_id"`
+	Email       string    `json:"email"`
+	Roles       []string  `json:"roles"`
+	Permissions []string  `json:"permissions"`
+	IssuedAt    time.Time `json:"issued_at"`
+	ExpiresAt   time.Time `json:"expires_at"`
+
+	// Активная организация (0 - не выбрана)
+	OrganizationID   int64            `json:"organization_id,omitempty"`
+	OrganizationRole OrganizationRole `json:"organization_role,omitempty"`
+
+	// Клиент OAuth, которому выдан токен, и разрешенные ему области (пусто для прямого Login)
+	ClientID string   `json:"client_id,omitempty"`
+	Scopes   []string `json:"scopes,omitempty"`
+	// Для кого выдан токен (aud); пусто у токена прямого входа в этот сервис
+	Audience []string `json:"aud,omitempty"`
+
+	// Цепочка делегирования токена, полученного обменом (RFC 8693)
+	Actor *Actor `json:"act,omitempty"`
+	// Администратор, вошедший от имени пользователя (по сессии токена; 0 - нет)
+	ImpersonatorID int64 `json:"impersonator_id,omitempty"`
+	// Отпечаток ключа DPoP из cnf.jkt; пусто для bearer-токена
+	DPoPThumbprint string `json:"jkt,omitempty"`
+}
+
+// HasAudience: токен годится для audience. Токен без aud выдан прямым входом
+// и принимается везде, токен с aud - только там, для кого выдан.
+func (c *TokenClaims) HasAudience(audience string) bool {
+	if len(c.Audience) == 0 {
+		return true
+	}
+	for _, aud := range c.Audience {
+		if aud == audience {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,42 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"time"
+)
+
+var (
+	ErrTenantNotFound      = errors.New("tenant not found")
+	ErrTenantRequired      = errors.New("tenant is required")
+	ErrTenantAlreadyExists = errors.New("tenant already exists")
+	ErrInvalidTenantSlug   = errors.New("invalid tenant slug")
+)
+
+var tenantSlugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+// Тенант - изолированный пул пользователей (отдельный продукт)
+type Tenant struct {
+	ID        int64     `json:"id" db:"id"`
+	Slug      string    `json:"slug" db:"slug"`
+	Name      string    `json:"name" db:"name"`
+	IsActive  bool      `json:"is_active" db:"is_active"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
+
+func ValidTenantSlug(slug string) bool {
+	return tenantSlugPattern.MatchString(slug)
+}
+
+type tenantContextKey struct{}
+
+// WithTenant привязывает запрос к тенанту; репозитории без него не работают
+func WithTenant(ctx context.Context, tenant *Tenant) context.Context {
+	return context.WithValue(ctx, tenantContextKey{}, tenant)
+}
+
+func TenantFromContext(ctx context.Context) (*Tenant, bool) {
+	tenant, ok := ctx.Value(tenantContextKey{}).(*Tenant)
+	return tenant, ok && tenant != nil
+}
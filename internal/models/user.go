@@ -22,15 +22,14 @@ type User struct {
 	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
 
-	Role UserRole `json:"role" db:"role"`
-}
+	// Пароль нужно сменить перед следующим входом
+	PasswordResetRequired bool `json:"password_reset_required" db:"password_reset_required"`
 
-type UserRole string
+	TenantID int64 `json:"tenant_id" db:"tenant_id"`
 
-const (
-	RoleUser  UserRole = "user"
-	RoleAdmin UserRole = "admin"
-)
+	// Пользователь слит с другим и остается только ссылкой на него
+	MergedInto *int64 `json:"merged_into,omitempty" db:"merged_into"`
+}
 
 func (u *User) HashPassword() error {
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(u.Password), bcrypt.DefaultCost)
@@ -46,6 +45,12 @@ func (u *User) CheckPassword(password string) bool {
 	return err == nil
 }
 
+// HasPassword сообщает, можно ли войти по паролю. Хеш есть только у пользователя,
+// загруженного из хранилища: Authenticate и Login его очищают.
+func (u *User) HasPassword() bool {
+	return u.Password != ""
+}
+
 // Метод для возврата профиля (без чувствительных данных)
 func (u *User) ToProfile() *User {
 	return u
@@ -0,0 +1,317 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type APIKeyRepository interface {
+	// CreateServiceAccount возвращает models.ErrServiceAccountAlreadyExists, если имя занято
+	CreateServiceAccount(ctx context.Context, account *models.ServiceAccount) error
+	GetServiceAccount(ctx context.Context, id int64) (*models.ServiceAccount, error)
+
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	GetAPIKey(ctx context.Context, id int64) (*models.APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context, serviceAccountID int64) ([]*models.APIKey, error)
+	// RotateAPIKey создает ключ-замену и ограничивает срок старого моментом graceUntil
+	RotateAPIKey(ctx context.Context, oldID int64, key *models.APIKey, graceUntil time.Time) error
+	// RevokeAPIKey возвращает false, если ключ уже отозван или не найден
+	RevokeAPIKey(ctx context.Context, id int64, revokedAt time.Time) (bool, error)
+	// TouchAPIKey обновляет время и адрес последнего использования не чаще раза в minInterval
+	TouchAPIKey(ctx context.Context, id int64, ip string, now time.Time, minInterval time.Duration) error
+}
+
+const apiKeyColumns = `
+	id, tenant_id, service_account_id, name, prefix, secret_hash, scopes, allowed_cidrs,
+	expires_at, created_by, created_at, last_used_at, last_used_ip, revoked_at, rotated_from
+`
+
+func (r *PostgresRepository) CreateServiceAccount(ctx context.Context, account *models.ServiceAccount) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	account.TenantID = tenant
+
+	query := `
+		INSERT INTO service_accounts (tenant_id, name, description, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	err = r.db.QueryRowContext(ctx, query,
+		account.TenantID,
+		account.Name,
+		account.Description,
+		account.CreatedBy,
+		account.CreatedAt,
+	).Scan(&account.ID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+		return models.ErrServiceAccountAlreadyExists
+	}
+
+	return errors.Wrap(err, "failed to create service account")
+}
+
+func (r *PostgresRepository) GetServiceAccount(ctx context.Context, id int64) (*models.ServiceAccount, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, tenant_id, name, description, created_by, created_at
+		FROM service_accounts WHERE id = $1 AND tenant_id = $2
+	`
+
+	var account models.ServiceAccount
+	var createdBy sql.NullInt64
+	err = r.db.QueryRowContext(ctx, query, id, tenant).Scan(
+		&account.ID,
+		&account.TenantID,
+		&account.Name,
+		&account.Description,
+		&createdBy,
+		&account.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get service account")
+	}
+
+	if createdBy.Valid {
+		account.CreatedBy = &createdBy.Int64
+	}
+	return &account, nil
+}
+
+func (r *PostgresRepository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	return r.insertAPIKey(ctx, r.db, key)
+}
+
+// Общий интерфейс *sql.DB и *sql.Tx
+type rowQuerier interface {
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// insertAPIKey пишет ключ в сервисный аккаунт того же тенанта
+func (r *PostgresRepository) insertAPIKey(ctx context.Context, db rowQuerier, key *models.APIKey) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	key.TenantID = tenant
+
+	query := `
+		INSERT INTO api_keys (tenant_id, service_account_id, name, prefix, secret_hash, scopes, allowed_cidrs,
+		                      expires_at, created_by, created_at, rotated_from)
+		SELECT tenant_id, id, $3, $4, $5, $6, $7, $8, $9, $10, $11
+		FROM service_accounts WHERE id = $2 AND tenant_id = $1
+		RETURNING id
+	`
+
+	err = db.QueryRowContext(ctx, query,
+		key.TenantID,
+		key.ServiceAccountID,
+		key.Name,
+		key.Prefix,
+		key.SecretHash,
+		pq.Array(key.Scopes),
+		pq.Array(key.AllowedCIDRs),
+		key.ExpiresAt,
+		key.CreatedBy,
+		key.CreatedAt,
+		key.RotatedFrom,
+	).Scan(&key.ID)
+
+	if err == sql.ErrNoRows {
+		return models.ErrServiceAccountNotFound
+	}
+	return errors.Wrap(err, "failed to create api key")
+}
+
+func (r *PostgresRepository) GetAPIKey(ctx context.Context, id int64) (*models.APIKey, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1 AND tenant_id = $2`
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, id, tenant))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return key, errors.Wrap(err, "failed to get api key")
+}
+
+func (r *PostgresRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE prefix = $1 AND tenant_id = $2`
+
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, prefix, tenant))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return key, errors.Wrap(err, "failed to get api key by prefix")
+}
+
+func (r *PostgresRepository) ListAPIKeys(ctx context.Context, serviceAccountID int64) ([]*models.APIKey, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + apiKeyColumns + ` FROM api_keys
+		WHERE service_account_id = $1 AND tenant_id = $2
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, serviceAccountID, tenant)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list api keys")
+	}
+	defer rows.Close()
+
+	var keys []*models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan api key")
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, errors.Wrap(rows.Err(), "failed to iterate api keys")
+}
+
+func (r *PostgresRepository) RotateAPIKey(ctx context.Context, oldID int64, key *models.APIKey, graceUntil time.Time) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin api key transaction")
+	}
+	defer tx.Rollback()
+
+	// Старый ключ доживает до конца льготного периода, но не дольше своего срока
+	result, err := tx.ExecContext(ctx, `
+		UPDATE api_keys SET expires_at = LEAST(COALESCE(expires_at, $3), $3)
+		WHERE id = $1 AND tenant_id = $2 AND revoked_at IS NULL
+	`, oldID, tenant, graceUntil)
+	if err != nil {
+		return errors.Wrap(err, "failed to expire rotated api key")
+	}
+	if rows, err := result.RowsAffected(); err != nil || rows == 0 {
+		if err != nil {
+			return errors.Wrap(err, "failed to expire rotated api key")
+		}
+		return models.ErrAPIKeyNotFound
+	}
+
+	key.RotatedFrom = &oldID
+	if err := r.insertAPIKey(ctx, tx, key); err != nil {
+		return err
+	}
+
+	return errors.Wrap(tx.Commit(), "failed to commit api key rotation")
+}
+
+func (r *PostgresRepository) RevokeAPIKey(ctx context.Context, id int64, revokedAt time.Time) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	query := `UPDATE api_keys SET revoked_at = $1 WHERE id = $2 AND tenant_id = $3 AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, revokedAt, id, tenant)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to revoke api key")
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, errors.Wrap(err, "failed to revoke api key")
+}
+
+func (r *PostgresRepository) TouchAPIKey(ctx context.Context, id int64, ip string, now time.Time, minInterval time.Duration) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE api_keys SET last_used_at = $1, last_used_ip = $2
+		WHERE id = $3 AND tenant_id = $4
+		  AND (last_used_at IS NULL OR last_used_at < $5 OR last_used_ip IS DISTINCT FROM $2)
+	`
+
+	_, err = r.db.ExecContext(ctx, query, now, ip, id, tenant, now.Add(-minInterval))
+	return errors.Wrap(err, "failed to update api key last use")
+}
+
+func scanAPIKey(row rowScanner) (*models.APIKey, error) {
+	var key models.APIKey
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	var createdBy, rotatedFrom sql.NullInt64
+	var lastUsedIP sql.NullString
+
+	err := row.Scan(
+		&key.ID,
+		&key.TenantID,
+		&key.ServiceAccountID,
+		&key.Name,
+		&key.Prefix,
+		&key.SecretHash,
+		pq.Array(&key.Scopes),
+		pq.Array(&key.AllowedCIDRs),
+		&expiresAt,
+		&createdBy,
+		&key.CreatedAt,
+		&lastUsedAt,
+		&lastUsedIP,
+		&revokedAt,
+		&rotatedFrom,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if createdBy.Valid {
+		key.CreatedBy = &createdBy.Int64
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	key.LastUsedIP = lastUsedIP.String
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	if rotatedFrom.Valid {
+		key.RotatedFrom = &rotatedFrom.Int64
+	}
+
+	return &key, nil
+}
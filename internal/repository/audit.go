@@ -0,0 +1,257 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type AuditRepository interface {
+	// AppendAuditEvent сериализует запись цепочки: seal получает хеш последней записи
+	AppendAuditEvent(ctx context.Context, event *models.AuditEvent, seal func(prevHash string) error) error
+	// Цепочка и контрольные точки общие для всех тенантов: проверка читает их целиком
+	ListAuditEvents(ctx context.Context, afterID int64, limit int) ([]*models.AuditEvent, error)
+	CreateAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error
+	GetLastAuditCheckpoint(ctx context.Context) (*models.AuditCheckpoint, error)
+	ListAuditCheckpoints(ctx context.Context) ([]*models.AuditCheckpoint, error)
+	// CountFailedLogins считает неверные пароли с IP начиная с since. Ключ только IP:
+	// по одному email чужие ошибки не блокировали бы владельца
+	CountFailedLogins(ctx context.Context, ip string, since time.Time) (int, error)
+	// CountFailedUserCodes считает неверные user_code пользователя или с IP начиная с since
+	CountFailedUserCodes(ctx context.Context, userID int64, ip string, since time.Time) (int, error)
+}
+
+// Ключ advisory-блокировки, под которой дописывается цепочка аудита
+const auditChainLockKey = 7_263_001
+
+func (r *PostgresRepository) AppendAuditEvent(ctx context.Context, event *models.AuditEvent, seal func(prevHash string) error) error {
+	// Внутри InTx запись фиксируется вместе с остальными изменениями,
+	// блокировка цепочки держится до конца внешней транзакции
+	if tx, ok := txFromContext(ctx); ok {
+		return appendAuditEvent(ctx, tx, event, seal)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin audit transaction")
+	}
+	defer tx.Rollback()
+
+	if err := appendAuditEvent(ctx, tx, event, seal); err != nil {
+		return err
+	}
+
+	return errors.Wrap(tx.Commit(), "failed to commit audit event")
+}
+
+func appendAuditEvent(ctx context.Context, tx *sql.Tx, event *models.AuditEvent, seal func(prevHash string) error) error {
+	if _, err := tx.ExecContext(ctx, `SELECT pg_advisory_xact_lock($1)`, auditChainLockKey); err != nil {
+		return errors.Wrap(err, "failed to lock audit chain")
+	}
+
+	prevHash := models.AuditGenesisHash
+	err := tx.QueryRowContext(ctx, `SELECT hash FROM audit_events ORDER BY id DESC LIMIT 1`).Scan(&prevHash)
+	if err != nil && err != sql.ErrNoRows {
+		return errors.Wrap(err, "failed to get last audit event")
+	}
+
+	if err := seal(prevHash); err != nil {
+		return errors.Wrap(err, "failed to seal audit event")
+	}
+
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal audit metadata")
+	}
+
+	query := `
+		INSERT INTO audit_events (event_type, user_id, email, metadata, created_at, prev_hash, hash, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+
+	err = tx.QueryRowContext(ctx, query,
+		event.Type,
+		event.UserID,
+		event.Email,
+		metadata,
+		event.CreatedAt,
+		event.PrevHash,
+		event.Hash,
+		event.TenantID,
+	).Scan(&event.ID)
+
+	return errors.Wrap(err, "failed to insert audit event")
+}
+
+func (r *PostgresRepository) ListAuditEvents(ctx context.Context, afterID int64, limit int) ([]*models.AuditEvent, error) {
+	query := `
+		SELECT id, event_type, user_id, email, metadata, created_at, prev_hash, hash, tenant_id
+		FROM audit_events WHERE id > $1
+		ORDER BY id
+		LIMIT $2
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, afterID, limit)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list audit events")
+	}
+	defer rows.Close()
+
+	var events []*models.AuditEvent
+	for rows.Next() {
+		var event models.AuditEvent
+		var userID, tenantID sql.NullInt64
+		var metadata []byte
+
+		if err := rows.Scan(
+			&event.ID,
+			&event.Type,
+			&userID,
+			&event.Email,
+			&metadata,
+			&event.CreatedAt,
+			&event.PrevHash,
+			&event.Hash,
+			&tenantID,
+		); err != nil {
+			return nil, errors.Wrap(err, "failed to scan audit event")
+		}
+
+		if userID.Valid {
+			event.UserID = &userID.Int64
+		}
+		if tenantID.Valid {
+			event.TenantID = &tenantID.Int64
+		}
+		if len(metadata) > 0 {
+			if err := json.Unmarshal(metadata, &event.Metadata); err != nil {
+				return nil, errors.Wrap(err, "failed to unmarshal audit metadata")
+			}
+		}
+
+		events = append(events, &event)
+	}
+
+	return events, errors.Wrap(rows.Err(), "failed to iterate audit events")
+}
+
+func (r *PostgresRepository) CreateAuditCheckpoint(ctx context.Context, checkpoint *models.AuditCheckpoint) error {
+	query := `
+		INSERT INTO audit_checkpoints (event_id, event_hash, key_id, signature, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	err := r.conn(ctx).QueryRowContext(ctx, query,
+		checkpoint.EventID,
+		checkpoint.EventHash,
+		checkpoint.KeyID,
+		checkpoint.Signature,
+		checkpoint.CreatedAt,
+	).Scan(&checkpoint.ID)
+
+	return errors.Wrap(err, "failed to create audit checkpoint")
+}
+
+func (r *PostgresRepository) GetLastAuditCheckpoint(ctx context.Context) (*models.AuditCheckpoint, error) {
+	query := `
+		SELECT id, event_id, event_hash, key_id, signature, created_at
+		FROM audit_checkpoints ORDER BY event_id DESC LIMIT 1
+	`
+
+	var checkpoint models.AuditCheckpoint
+	err := r.conn(ctx).QueryRowContext(ctx, query).Scan(
+		&checkpoint.ID,
+		&checkpoint.EventID,
+		&checkpoint.EventHash,
+		&checkpoint.KeyID,
+		&checkpoint.Signature,
+		&checkpoint.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get last audit checkpoint")
+	}
+
+	return &checkpoint, nil
+}
+
+func (r *PostgresRepository) ListAuditCheckpoints(ctx context.Context) ([]*models.AuditCheckpoint, error) {
+	query := `
+		SELECT id, event_id, event_hash, key_id, signature, created_at
+		FROM audit_checkpoints ORDER BY event_id
+	`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list audit checkpoints")
+	}
+	defer rows.Close()
+
+	var checkpoints []*models.AuditCheckpoint
+	for rows.Next() {
+		var checkpoint models.AuditCheckpoint
+		if err := rows.Scan(
+			&checkpoint.ID,
+			&checkpoint.EventID,
+			&checkpoint.EventHash,
+			&checkpoint.KeyID,
+			&checkpoint.Signature,
+			&checkpoint.CreatedAt,
+		); err != nil {
+			return nil, errors.Wrap(err, "failed to scan audit checkpoint")
+		}
+		checkpoints = append(checkpoints, &checkpoint)
+	}
+
+	return checkpoints, errors.Wrap(rows.Err(), "failed to iterate audit checkpoints")
+}
+
+func (r *PostgresRepository) CountFailedLogins(ctx context.Context, ip string, since time.Time) (int, error) {
+	if ip == "" {
+		return 0, nil
+	}
+
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		SELECT COUNT(*) FROM audit_events
+		WHERE event_type = $1 AND created_at >= $2 AND tenant_id = $3
+		  AND metadata->>'ip' = $4 AND metadata->>'reason' = ANY($5)
+	`
+
+	var count int
+	err = r.db.QueryRowContext(ctx, query, models.AuditLoginFailed, since, tenant, ip, pq.Array(models.CredentialFailureReasons)).Scan(&count)
+	return count, errors.Wrap(err, "failed to count failed logins")
+}
+
+func (r *PostgresRepository) CountFailedUserCodes(ctx context.Context, userID int64, ip string, since time.Time) (int, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	// Пустой IP не совпадает ни с чем: считаются только ошибки пользователя
+	query := `
+		SELECT COUNT(*) FROM audit_events
+		WHERE event_type = $1 AND created_at >= $2 AND tenant_id = $3
+		  AND (user_id = $4 OR ($5 <> '' AND metadata->>'ip' = $5))
+	`
+
+	var count int
+	err = r.db.QueryRowContext(ctx, query, models.AuditOAuthDeviceCodeFailed, since, tenant, userID, ip).Scan(&count)
+	return count, errors.Wrap(err, "failed to count failed user codes")
+}
@@ -0,0 +1,140 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/pkg/errors"
+)
+
+type DeviceRepository interface {
+	GetKnownDevice(ctx context.Context, userID int64, fingerprint string) (*models.KnownDevice, error)
+	GetKnownDeviceByReportToken(ctx context.Context, tokenHash string) (*models.KnownDevice, error)
+	CountKnownDevices(ctx context.Context, userID int64) (int, error)
+	CreateKnownDevice(ctx context.Context, device *models.KnownDevice) error
+	TouchKnownDevice(ctx context.Context, id int64, sessionID string, seenAt time.Time) error
+	DeleteKnownDevice(ctx context.Context, id int64) error
+}
+
+const knownDeviceColumns = `
+	id, user_id, fingerprint, user_agent_family, ip_prefix, device_id,
+	session_id, report_token_hash, first_seen_at, last_seen_at
+`
+
+func (r *PostgresRepository) GetKnownDevice(ctx context.Context, userID int64, fingerprint string) (*models.KnownDevice, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + knownDeviceColumns + ` FROM known_devices WHERE user_id = $1 AND fingerprint = $2 AND tenant_id = $3`
+
+	device, err := scanKnownDevice(r.db.QueryRowContext(ctx, query, userID, fingerprint, tenant))
+	return device, errors.Wrap(err, "failed to get known device")
+}
+
+func (r *PostgresRepository) GetKnownDeviceByReportToken(ctx context.Context, tokenHash string) (*models.KnownDevice, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + knownDeviceColumns + ` FROM known_devices WHERE report_token_hash = $1 AND tenant_id = $2`
+
+	device, err := scanKnownDevice(r.db.QueryRowContext(ctx, query, tokenHash, tenant))
+	return device, errors.Wrap(err, "failed to get known device by report token")
+}
+
+func (r *PostgresRepository) CountKnownDevices(ctx context.Context, userID int64) (int, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	err = r.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM known_devices WHERE user_id = $1 AND tenant_id = $2`, userID, tenant).Scan(&count)
+	return count, errors.Wrap(err, "failed to count known devices")
+}
+
+func (r *PostgresRepository) CreateKnownDevice(ctx context.Context, device *models.KnownDevice) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO known_devices (user_id, fingerprint, user_agent_family, ip_prefix, device_id,
+		                           session_id, report_token_hash, first_seen_at, last_seen_at, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		RETURNING id
+	`
+
+	err = r.db.QueryRowContext(ctx, query,
+		device.UserID,
+		device.Fingerprint,
+		device.UserAgentFamily,
+		device.IPPrefix,
+		device.DeviceID,
+		device.SessionID,
+		device.ReportTokenHash,
+		device.FirstSeenAt,
+		device.LastSeenAt,
+		tenant,
+	).Scan(&device.ID)
+
+	return errors.Wrap(err, "failed to create known device")
+}
+
+func (r *PostgresRepository) TouchKnownDevice(ctx context.Context, id int64, sessionID string, seenAt time.Time) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE known_devices SET session_id = $1, last_seen_at = $2 WHERE id = $3 AND tenant_id = $4`
+
+	_, err = r.db.ExecContext(ctx, query, sessionID, seenAt, id, tenant)
+	return errors.Wrap(err, "failed to touch known device")
+}
+
+func (r *PostgresRepository) DeleteKnownDevice(ctx context.Context, id int64) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `DELETE FROM known_devices WHERE id = $1 AND tenant_id = $2`, id, tenant)
+	return errors.Wrap(err, "failed to delete known device")
+}
+
+func scanKnownDevice(row *sql.Row) (*models.KnownDevice, error) {
+	var device models.KnownDevice
+	var reportTokenHash sql.NullString
+
+	err := row.Scan(
+		&device.ID,
+		&device.UserID,
+		&device.Fingerprint,
+		&device.UserAgentFamily,
+		&device.IPPrefix,
+		&device.DeviceID,
+		&device.SessionID,
+		&reportTokenHash,
+		&device.FirstSeenAt,
+		&device.LastSeenAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	device.ReportTokenHash = reportTokenHash.String
+
+	return &device, nil
+}
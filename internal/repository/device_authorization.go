@@ -0,0 +1,198 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type DeviceAuthorizationRepository interface {
+	CreateDeviceAuthorization(ctx context.Context, authorization *models.DeviceAuthorization) error
+	GetDeviceAuthorization(ctx context.Context, deviceCodeHash string) (*models.DeviceAuthorization, error)
+	// FindPendingDeviceAuthorization ищет неподтвержденный и непросроченный запрос по user_code
+	FindPendingDeviceAuthorization(ctx context.Context, userCodeHash string, now time.Time) (*models.DeviceAuthorization, error)
+	RecordDevicePoll(ctx context.Context, deviceCodeHash string, polledAt time.Time, interval time.Duration) error
+	// DecideDeviceAuthorization сохраняет решение пользователя; false, если запрос
+	// уже решен или просрочен
+	DecideDeviceAuthorization(ctx context.Context, authorization *models.DeviceAuthorization) (bool, error)
+	// ConsumeDeviceAuthorization помечает одобренный запрос использованным;
+	// false, если устройство уже получило по нему токены
+	ConsumeDeviceAuthorization(ctx context.Context, deviceCodeHash string, usedAt time.Time) (bool, error)
+}
+
+const deviceAuthorizationColumns = `
+	device_code_hash, user_code_hash, tenant_id, client_id, scopes, status, user_id, session_id,
+	ip, user_agent, poll_interval_seconds, last_polled_at, created_at, expires_at, decided_at, used_at
+`
+
+func (r *PostgresRepository) CreateDeviceAuthorization(ctx context.Context, authorization *models.DeviceAuthorization) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	authorization.TenantID = tenant
+
+	query := `
+		INSERT INTO oauth_device_authorizations (device_code_hash, user_code_hash, tenant_id, client_id, scopes,
+		                                         status, ip, user_agent, poll_interval_seconds, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+	`
+
+	_, err = r.db.ExecContext(ctx, query,
+		authorization.DeviceCodeHash,
+		authorization.UserCodeHash,
+		authorization.TenantID,
+		authorization.ClientID,
+		pq.Array(authorization.Scopes),
+		authorization.Status,
+		authorization.Client.IP,
+		authorization.Client.UserAgent,
+		int64(authorization.PollInterval/time.Second),
+		authorization.CreatedAt,
+		authorization.ExpiresAt,
+	)
+	return errors.Wrap(err, "failed to create device authorization")
+}
+
+func (r *PostgresRepository) GetDeviceAuthorization(ctx context.Context, deviceCodeHash string) (*models.DeviceAuthorization, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + deviceAuthorizationColumns + `
+		FROM oauth_device_authorizations WHERE device_code_hash = $1 AND tenant_id = $2`
+
+	authorization, err := scanDeviceAuthorization(r.db.QueryRowContext(ctx, query, deviceCodeHash, tenant))
+	return authorization, errors.Wrap(err, "failed to get device authorization")
+}
+
+func (r *PostgresRepository) FindPendingDeviceAuthorization(ctx context.Context, userCodeHash string, now time.Time) (*models.DeviceAuthorization, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + deviceAuthorizationColumns + `
+		FROM oauth_device_authorizations
+		WHERE user_code_hash = $1 AND tenant_id = $2 AND status = 'pending' AND expires_at > $3
+		ORDER BY created_at DESC
+		LIMIT 1`
+
+	authorization, err := scanDeviceAuthorization(r.db.QueryRowContext(ctx, query, userCodeHash, tenant, now))
+	return authorization, errors.Wrap(err, "failed to find device authorization")
+}
+
+func (r *PostgresRepository) RecordDevicePoll(ctx context.Context, deviceCodeHash string, polledAt time.Time, interval time.Duration) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `
+		UPDATE oauth_device_authorizations SET last_polled_at = $3, poll_interval_seconds = $4
+		WHERE device_code_hash = $1 AND tenant_id = $2
+	`, deviceCodeHash, tenant, polledAt, int64(interval/time.Second))
+	return errors.Wrap(err, "failed to record device poll")
+}
+
+func (r *PostgresRepository) DecideDeviceAuthorization(ctx context.Context, authorization *models.DeviceAuthorization) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	var sessionID sql.NullString
+	if authorization.SessionID != "" {
+		sessionID = sql.NullString{String: authorization.SessionID, Valid: true}
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE oauth_device_authorizations SET status = $3, user_id = $4, session_id = $5, decided_at = $6
+		WHERE device_code_hash = $1 AND tenant_id = $2 AND status = 'pending' AND expires_at > $6
+	`, authorization.DeviceCodeHash, tenant, authorization.Status, authorization.UserID, sessionID, authorization.DecidedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to decide device authorization")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to decide device authorization")
+	}
+	return affected == 1, nil
+}
+
+func (r *PostgresRepository) ConsumeDeviceAuthorization(ctx context.Context, deviceCodeHash string, usedAt time.Time) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE oauth_device_authorizations SET used_at = $3
+		WHERE device_code_hash = $1 AND tenant_id = $2 AND status = 'approved' AND used_at IS NULL
+	`, deviceCodeHash, tenant, usedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to consume device authorization")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to consume device authorization")
+	}
+	return affected == 1, nil
+}
+
+// scanDeviceAuthorization возвращает nil без ошибки, если запроса нет
+func scanDeviceAuthorization(row *sql.Row) (*models.DeviceAuthorization, error) {
+	var authorization models.DeviceAuthorization
+	var userID sql.NullInt64
+	var sessionID sql.NullString
+	var interval int64
+	var lastPolledAt, decidedAt, usedAt sql.NullTime
+
+	err := row.Scan(
+		&authorization.DeviceCodeHash,
+		&authorization.UserCodeHash,
+		&authorization.TenantID,
+		&authorization.ClientID,
+		pq.Array(&authorization.Scopes),
+		&authorization.Status,
+		&userID,
+		&sessionID,
+		&authorization.Client.IP,
+		&authorization.Client.UserAgent,
+		&interval,
+		&lastPolledAt,
+		&authorization.CreatedAt,
+		&authorization.ExpiresAt,
+		&decidedAt,
+		&usedAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	authorization.PollInterval = time.Duration(interval) * time.Second
+	if userID.Valid {
+		authorization.UserID = &userID.Int64
+	}
+	authorization.SessionID = sessionID.String
+	if lastPolledAt.Valid {
+		authorization.LastPolledAt = &lastPolledAt.Time
+	}
+	if decidedAt.Valid {
+		authorization.DecidedAt = &decidedAt.Time
+	}
+	if usedAt.Valid {
+		authorization.UsedAt = &usedAt.Time
+	}
+	return &authorization, nil
+}
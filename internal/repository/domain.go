@@ -0,0 +1,182 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type DomainRepository interface {
+	CreateDomain(ctx context.Context, domain *models.Domain) error
+	GetDomain(ctx context.Context, id int64) (*models.Domain, error)
+	// ListDomains возвращает домены организации или, при nil, все домены тенанта
+	ListDomains(ctx context.Context, organizationID *int64) ([]*models.Domain, error)
+	// MarkDomainVerified возвращает models.ErrDomainClaimed, если домен уже подтвердил другой владелец
+	MarkDomainVerified(ctx context.Context, id int64, at time.Time) error
+	UpdateDomainSSO(ctx context.Context, id int64, provider string, enforced bool) error
+	// FindVerifiedDomain возвращает самый точный (длинный) подтвержденный домен из candidates или nil
+	FindVerifiedDomain(ctx context.Context, candidates []string) (*models.Domain, error)
+}
+
+const domainColumns = `
+	id, tenant_id, organization_id, domain, verification_token, provider, sso_enforced, created_by, created_at, verified_at
+`
+
+func (r *PostgresRepository) CreateDomain(ctx context.Context, domain *models.Domain) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	domain.TenantID = tenant
+
+	query := `
+		INSERT INTO domains (tenant_id, organization_id, domain, verification_token, provider, sso_enforced, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	`
+
+	err = r.db.QueryRowContext(ctx, query,
+		domain.TenantID,
+		domain.OrganizationID,
+		domain.Domain,
+		domain.VerificationToken,
+		domain.Provider,
+		domain.SSOEnforced,
+		domain.CreatedBy,
+		domain.CreatedAt,
+	).Scan(&domain.ID)
+
+	return errors.Wrap(err, "failed to create domain")
+}
+
+func (r *PostgresRepository) GetDomain(ctx context.Context, id int64) (*models.Domain, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + domainColumns + ` FROM domains WHERE id = $1 AND tenant_id = $2`
+
+	domain, err := scanDomain(r.db.QueryRowContext(ctx, query, id, tenant))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get domain")
+	}
+	return domain, nil
+}
+
+func (r *PostgresRepository) ListDomains(ctx context.Context, organizationID *int64) ([]*models.Domain, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + domainColumns + ` FROM domains
+		WHERE tenant_id = $1 AND ($2::INTEGER IS NULL OR organization_id = $2)
+		ORDER BY domain, created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, tenant, organizationID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list domains")
+	}
+	defer rows.Close()
+
+	var domains []*models.Domain
+	for rows.Next() {
+		domain, err := scanDomain(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan domain")
+		}
+		domains = append(domains, domain)
+	}
+
+	return domains, errors.Wrap(rows.Err(), "failed to list domains")
+}
+
+func (r *PostgresRepository) MarkDomainVerified(ctx context.Context, id int64, at time.Time) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `UPDATE domains SET verified_at = $1 WHERE id = $2 AND tenant_id = $3 AND verified_at IS NULL`, at, id, tenant)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+		return models.ErrDomainClaimed
+	}
+
+	return errors.Wrap(err, "failed to mark domain verified")
+}
+
+func (r *PostgresRepository) UpdateDomainSSO(ctx context.Context, id int64, provider string, enforced bool) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `UPDATE domains SET provider = $1, sso_enforced = $2 WHERE id = $3 AND tenant_id = $4`, provider, enforced, id, tenant)
+	return errors.Wrap(err, "failed to update domain")
+}
+
+func (r *PostgresRepository) FindVerifiedDomain(ctx context.Context, candidates []string) (*models.Domain, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + domainColumns + ` FROM domains
+		WHERE domain = ANY($1) AND tenant_id = $2 AND verified_at IS NOT NULL
+		ORDER BY length(domain) DESC
+		LIMIT 1
+	`
+
+	domain, err := scanDomain(r.db.QueryRowContext(ctx, query, pq.Array(candidates), tenant))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to find verified domain")
+	}
+	return domain, nil
+}
+
+func scanDomain(row rowScanner) (*models.Domain, error) {
+	var domain models.Domain
+	var organizationID, createdBy sql.NullInt64
+	var verifiedAt sql.NullTime
+
+	err := row.Scan(
+		&domain.ID,
+		&domain.TenantID,
+		&organizationID,
+		&domain.Domain,
+		&domain.VerificationToken,
+		&domain.Provider,
+		&domain.SSOEnforced,
+		&createdBy,
+		&domain.CreatedAt,
+		&verifiedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if organizationID.Valid {
+		domain.OrganizationID = &organizationID.Int64
+	}
+	if createdBy.Valid {
+		domain.CreatedBy = &createdBy.Int64
+	}
+	if verifiedAt.Valid {
+		domain.VerifiedAt = &verifiedAt.Time
+	}
+	return &domain, nil
+}
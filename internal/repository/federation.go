@@ -0,0 +1,348 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type IdentityProviderRepository interface {
+	// CreateIdentityProvider возвращает models.ErrIdentityProviderExists, если slug занят
+	CreateIdentityProvider(ctx context.Context, provider *models.IdentityProvider) error
+	GetIdentityProvider(ctx context.Context, slug string) (*models.IdentityProvider, error)
+	ListIdentityProviders(ctx context.Context) ([]*models.IdentityProvider, error)
+}
+
+type IdentityRepository interface {
+	// CreateIdentity возвращает models.ErrIdentityAlreadyLinked, если учетная запись провайдера уже привязана
+	CreateIdentity(ctx context.Context, identity *models.Identity) error
+	GetIdentity(ctx context.Context, provider, subject string) (*models.Identity, error)
+	ListUserIdentities(ctx context.Context, userID int64) ([]*models.Identity, error)
+	TouchIdentity(ctx context.Context, id int64, at time.Time) error
+	// DeleteIdentity возвращает false, если у пользователя нет такой привязки
+	DeleteIdentity(ctx context.Context, userID, id int64) (bool, error)
+
+	CreateFederationState(ctx context.Context, state *models.FederationState) error
+	// ConsumeFederationState помечает state использованным; nil, если он неизвестен, просрочен или уже использован
+	ConsumeFederationState(ctx context.Context, stateHash string, now time.Time) (*models.FederationState, error)
+}
+
+const identityProviderColumns = `
+	id, tenant_id, slug, name, protocol, issuer, client_id, client_secret, scopes, created_at, disabled_at,
+	jit_provisioning, attribute_mapping, sync_policy, saml_metadata
+`
+
+func (r *PostgresRepository) CreateIdentityProvider(ctx context.Context, provider *models.IdentityProvider) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	provider.TenantID = tenant
+
+	mapping, err := json.Marshal(provider.AttributeMapping)
+	if err != nil {
+		return errors.Wrap(err, "failed to marshal attribute mapping")
+	}
+
+	query := `
+		INSERT INTO identity_providers (tenant_id, slug, name, protocol, issuer, client_id, client_secret, scopes, created_at,
+			jit_provisioning, attribute_mapping, sync_policy, saml_metadata)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id
+	`
+
+	err = r.db.QueryRowContext(ctx, query,
+		provider.TenantID,
+		provider.Slug,
+		provider.Name,
+		provider.Protocol,
+		provider.Issuer,
+		provider.ClientID,
+		provider.ClientSecret,
+		pq.Array(provider.Scopes),
+		provider.CreatedAt,
+		provider.JITProvisioning,
+		mapping,
+		provider.SyncPolicy,
+		provider.SAMLMetadata,
+	).Scan(&provider.ID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+		return models.ErrIdentityProviderExists
+	}
+
+	return errors.Wrap(err, "failed to create identity provider")
+}
+
+func (r *PostgresRepository) GetIdentityProvider(ctx context.Context, slug string) (*models.IdentityProvider, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + identityProviderColumns + ` FROM identity_providers WHERE slug = $1 AND tenant_id = $2`
+
+	provider, err := scanIdentityProvider(r.db.QueryRowContext(ctx, query, slug, tenant))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get identity provider")
+	}
+	return provider, nil
+}
+
+func (r *PostgresRepository) ListIdentityProviders(ctx context.Context) ([]*models.IdentityProvider, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + identityProviderColumns + ` FROM identity_providers WHERE tenant_id = $1 ORDER BY name`
+
+	rows, err := r.db.QueryContext(ctx, query, tenant)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list identity providers")
+	}
+	defer rows.Close()
+
+	var providers []*models.IdentityProvider
+	for rows.Next() {
+		provider, err := scanIdentityProvider(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan identity provider")
+		}
+		providers = append(providers, provider)
+	}
+
+	return providers, errors.Wrap(rows.Err(), "failed to list identity providers")
+}
+
+func scanIdentityProvider(row rowScanner) (*models.IdentityProvider, error) {
+	var provider models.IdentityProvider
+	var disabledAt sql.NullTime
+	var mapping []byte
+
+	err := row.Scan(
+		&provider.ID,
+		&provider.TenantID,
+		&provider.Slug,
+		&provider.Name,
+		&provider.Protocol,
+		&provider.Issuer,
+		&provider.ClientID,
+		&provider.ClientSecret,
+		pq.Array(&provider.Scopes),
+		&provider.CreatedAt,
+		&disabledAt,
+		&provider.JITProvisioning,
+		&mapping,
+		&provider.SyncPolicy,
+		&provider.SAMLMetadata,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(mapping, &provider.AttributeMapping); err != nil {
+		return nil, err
+	}
+
+	if disabledAt.Valid {
+		provider.DisabledAt = &disabledAt.Time
+	}
+	return &provider, nil
+}
+
+func (r *PostgresRepository) CreateIdentity(ctx context.Context, identity *models.Identity) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	identity.TenantID = tenant
+
+	query := `
+		INSERT INTO identities (tenant_id, user_id, provider, subject, email, created_at, last_login_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	err = r.db.QueryRowContext(ctx, query,
+		identity.TenantID,
+		identity.UserID,
+		identity.Provider,
+		identity.Subject,
+		identity.Email,
+		identity.CreatedAt,
+		identity.LastLoginAt,
+	).Scan(&identity.ID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+		return models.ErrIdentityAlreadyLinked
+	}
+
+	return errors.Wrap(err, "failed to create identity")
+}
+
+func (r *PostgresRepository) GetIdentity(ctx context.Context, provider, subject string) (*models.Identity, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, tenant_id, user_id, provider, subject, email, created_at, last_login_at
+		FROM identities WHERE provider = $1 AND subject = $2 AND tenant_id = $3
+	`
+
+	identity, err := scanIdentity(r.db.QueryRowContext(ctx, query, provider, subject, tenant))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get identity")
+	}
+	return identity, nil
+}
+
+func (r *PostgresRepository) ListUserIdentities(ctx context.Context, userID int64) ([]*models.Identity, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, tenant_id, user_id, provider, subject, email, created_at, last_login_at
+		FROM identities WHERE user_id = $1 AND tenant_id = $2
+		ORDER BY created_at
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, tenant)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list identities")
+	}
+	defer rows.Close()
+
+	var identities []*models.Identity
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan identity")
+		}
+		identities = append(identities, identity)
+	}
+
+	return identities, errors.Wrap(rows.Err(), "failed to list identities")
+}
+
+func (r *PostgresRepository) TouchIdentity(ctx context.Context, id int64, at time.Time) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, `UPDATE identities SET last_login_at = $1 WHERE id = $2 AND tenant_id = $3`, at, id, tenant)
+	return errors.Wrap(err, "failed to touch identity")
+}
+
+func (r *PostgresRepository) DeleteIdentity(ctx context.Context, userID, id int64) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM identities WHERE id = $1 AND user_id = $2 AND tenant_id = $3`, id, userID, tenant)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete identity")
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, errors.Wrap(err, "failed to delete identity")
+}
+
+func scanIdentity(row rowScanner) (*models.Identity, error) {
+	var identity models.Identity
+	var lastLoginAt sql.NullTime
+
+	err := row.Scan(
+		&identity.ID,
+		&identity.TenantID,
+		&identity.UserID,
+		&identity.Provider,
+		&identity.Subject,
+		&identity.Email,
+		&identity.CreatedAt,
+		&lastLoginAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if lastLoginAt.Valid {
+		identity.LastLoginAt = &lastLoginAt.Time
+	}
+	return &identity, nil
+}
+
+func (r *PostgresRepository) CreateFederationState(ctx context.Context, state *models.FederationState) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	state.TenantID = tenant
+
+	query := `
+		INSERT INTO federation_states (state_hash, tenant_id, provider, nonce, code_verifier, return_to, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+	`
+
+	_, err = r.db.ExecContext(ctx, query,
+		state.StateHash,
+		state.TenantID,
+		state.Provider,
+		state.Nonce,
+		state.CodeVerifier,
+		state.ReturnTo,
+		state.CreatedAt,
+		state.ExpiresAt,
+	)
+	return errors.Wrap(err, "failed to create federation state")
+}
+
+func (r *PostgresRepository) ConsumeFederationState(ctx context.Context, stateHash string, now time.Time) (*models.FederationState, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE federation_states SET used_at = $3
+		WHERE state_hash = $1 AND tenant_id = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING state_hash, tenant_id, provider, nonce, code_verifier, return_to, created_at, expires_at
+	`
+
+	var state models.FederationState
+	err = r.db.QueryRowContext(ctx, query, stateHash, tenant, now).Scan(
+		&state.StateHash,
+		&state.TenantID,
+		&state.Provider,
+		&state.Nonce,
+		&state.CodeVerifier,
+		&state.ReturnTo,
+		&state.CreatedAt,
+		&state.ExpiresAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to consume federation state")
+	}
+
+	state.UsedAt = &now
+	return &state, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/pkg/errors"
+)
+
+type UserMergeRepository interface {
+	// MergeUsers переносит привязки, сессии и роли source к target и оставляет
+	// source неактивной ссылкой на target. Все изменения - одной транзакцией;
+	// models.ErrInvalidMerge, если кого-то из них нет или он уже слит.
+	MergeUsers(ctx context.Context, sourceID, targetID int64, now time.Time) error
+}
+
+func (r *PostgresRepository) MergeUsers(ctx context.Context, sourceID, targetID int64, now time.Time) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin merge transaction")
+	}
+	defer tx.Rollback()
+
+	// Блокируем обоих пользователей: параллельное слияние в обратную сторону
+	// дождется этого и увидит ссылку
+	var locked int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM (
+			SELECT id FROM users
+			WHERE id IN ($1, $2) AND tenant_id = $3 AND merged_into IS NULL
+			ORDER BY id FOR UPDATE
+		) u
+	`, sourceID, targetID, tenant).Scan(&locked)
+	if err != nil {
+		return errors.Wrap(err, "failed to lock users")
+	}
+	if locked != 2 {
+		return models.ErrInvalidMerge
+	}
+
+	steps := []struct {
+		query string
+		what  string
+	}{
+		{`UPDATE identities SET user_id = $2 WHERE user_id = $1 AND tenant_id = $3`, "identities"},
+		{`UPDATE sessions SET user_id = $2 WHERE user_id = $1 AND tenant_id = $3`, "sessions"},
+		// Refresh-токены клиентов принадлежат сессиям и переезжают вместе с ними
+		{`UPDATE oauth_refresh_tokens SET user_id = $2 WHERE user_id = $1 AND tenant_id = $3`, "refresh tokens"},
+		{`
+			INSERT INTO user_roles (user_id, role_id, granted_at, granted_by)
+			SELECT $2, ur.role_id, ur.granted_at, ur.granted_by
+			FROM user_roles ur JOIN users u ON u.id = ur.user_id
+			WHERE ur.user_id = $1 AND u.tenant_id = $3
+			ON CONFLICT (user_id, role_id) DO NOTHING
+		`, "roles"},
+		{`DELETE FROM user_roles ur USING users u WHERE ur.user_id = u.id AND u.id = $1 AND u.tenant_id = $3`, "roles"},
+	}
+	for _, step := range steps {
+		if _, err := tx.ExecContext(ctx, step.query, sourceID, targetID, tenant); err != nil {
+			return errors.Wrapf(err, "failed to move %s", step.what)
+		}
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		UPDATE users SET merged_into = $2, is_active = false, updated_at = $4
+		WHERE id = $1 AND tenant_id = $3
+	`, sourceID, targetID, tenant, now)
+	if err != nil {
+		return errors.Wrap(err, "failed to mark merged user")
+	}
+
+	return errors.Wrap(tx.Commit(), "failed to commit merge")
+}
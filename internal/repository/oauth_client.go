@@ -0,0 +1,105 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type OAuthClientRepository interface {
+	// CreateOAuthClient возвращает models.ErrOAuthClientExists, если client_id занят
+	CreateOAuthClient(ctx context.Context, client *models.OAuthClient) error
+	GetOAuthClient(ctx context.Context, clientID string) (*models.OAuthClient, error)
+}
+
+func (r *PostgresRepository) CreateOAuthClient(ctx context.Context, client *models.OAuthClient) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	client.TenantID = tenant
+
+	query := `
+		INSERT INTO oauth_clients (tenant_id, client_id, name, auth_method, secret_hash, public_key_pem,
+		                           grant_types, scopes, audiences, access_token_ttl_seconds, created_at,
+		                           redirect_uris, post_logout_redirect_uris)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id
+	`
+
+	err = r.db.QueryRowContext(ctx, query,
+		client.TenantID,
+		client.ClientID,
+		client.Name,
+		client.AuthMethod,
+		sql.NullString{String: client.SecretHash, Valid: client.SecretHash != ""},
+		sql.NullString{String: client.PublicKeyPEM, Valid: client.PublicKeyPEM != ""},
+		pq.Array(client.GrantTypes),
+		pq.Array(client.Scopes),
+		pq.Array(client.Audiences),
+		int64(client.AccessTokenTTL/time.Second),
+		client.CreatedAt,
+		pq.Array(client.RedirectURIs),
+		pq.Array(client.PostLogoutRedirectURIs),
+	).Scan(&client.ID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+		return models.ErrOAuthClientExists
+	}
+
+	return errors.Wrap(err, "failed to create oauth client")
+}
+
+func (r *PostgresRepository) GetOAuthClient(ctx context.Context, clientID string) (*models.OAuthClient, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT id, tenant_id, client_id, name, auth_method, secret_hash, public_key_pem,
+		       grant_types, scopes, audiences, access_token_ttl_seconds, created_at, disabled_at,
+		       redirect_uris, post_logout_redirect_uris
+		FROM oauth_clients WHERE client_id = $1 AND tenant_id = $2
+	`
+
+	var client models.OAuthClient
+	var secretHash, publicKey sql.NullString
+	var ttlSeconds int64
+	var disabledAt sql.NullTime
+	err = r.db.QueryRowContext(ctx, query, clientID, tenant).Scan(
+		&client.ID,
+		&client.TenantID,
+		&client.ClientID,
+		&client.Name,
+		&client.AuthMethod,
+		&secretHash,
+		&publicKey,
+		pq.Array(&client.GrantTypes),
+		pq.Array(&client.Scopes),
+		pq.Array(&client.Audiences),
+		&ttlSeconds,
+		&client.CreatedAt,
+		&disabledAt,
+		pq.Array(&client.RedirectURIs),
+		pq.Array(&client.PostLogoutRedirectURIs),
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get oauth client")
+	}
+
+	client.SecretHash = secretHash.String
+	client.PublicKeyPEM = publicKey.String
+	client.AccessTokenTTL = time.Duration(ttlSeconds) * time.Second
+	if disabledAt.Valid {
+		client.DisabledAt = &disabledAt.Time
+	}
+	return &client, nil
+}
@@ -0,0 +1,243 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type OIDCRepository interface {
+	CreateAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error
+	// ConsumeAuthorizationCode помечает код использованным и возвращает его;
+	// nil, если кода нет, он просрочен или уже использован
+	ConsumeAuthorizationCode(ctx context.Context, codeHash string, now time.Time) (*models.AuthorizationCode, error)
+
+	CreateRefreshToken(ctx context.Context, token *models.OAuthRefreshToken) error
+	GetRefreshToken(ctx context.Context, tokenHash string) (*models.OAuthRefreshToken, error)
+	// MarkRefreshTokenUsed возвращает false, если токен уже был использован
+	MarkRefreshTokenUsed(ctx context.Context, tokenHash string, usedAt time.Time) (bool, error)
+
+	GetConsent(ctx context.Context, userID int64, clientID string) (*models.OAuthConsent, error)
+	SaveConsent(ctx context.Context, consent *models.OAuthConsent) error
+}
+
+func (r *PostgresRepository) CreateAuthorizationCode(ctx context.Context, code *models.AuthorizationCode) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	code.TenantID = tenant
+
+	query := `
+		INSERT INTO oauth_authorization_codes (code_hash, tenant_id, client_id, user_id, session_id, redirect_uri,
+		                                       scopes, nonce, code_challenge, auth_time, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+	`
+
+	_, err = r.db.ExecContext(ctx, query,
+		code.CodeHash,
+		code.TenantID,
+		code.ClientID,
+		code.UserID,
+		code.SessionID,
+		code.RedirectURI,
+		pq.Array(code.Scopes),
+		code.Nonce,
+		code.CodeChallenge,
+		code.AuthTime,
+		code.CreatedAt,
+		code.ExpiresAt,
+	)
+	return errors.Wrap(err, "failed to create authorization code")
+}
+
+func (r *PostgresRepository) ConsumeAuthorizationCode(ctx context.Context, codeHash string, now time.Time) (*models.AuthorizationCode, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		UPDATE oauth_authorization_codes SET used_at = $3
+		WHERE code_hash = $1 AND tenant_id = $2 AND used_at IS NULL AND expires_at > $3
+		RETURNING code_hash, tenant_id, client_id, user_id, session_id, redirect_uri,
+		          scopes, nonce, code_challenge, auth_time, created_at, expires_at, used_at
+	`
+
+	var code models.AuthorizationCode
+	var usedAt sql.NullTime
+	err = r.db.QueryRowContext(ctx, query, codeHash, tenant, now).Scan(
+		&code.CodeHash,
+		&code.TenantID,
+		&code.ClientID,
+		&code.UserID,
+		&code.SessionID,
+		&code.RedirectURI,
+		pq.Array(&code.Scopes),
+		&code.Nonce,
+		&code.CodeChallenge,
+		&code.AuthTime,
+		&code.CreatedAt,
+		&code.ExpiresAt,
+		&usedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to consume authorization code")
+	}
+
+	if usedAt.Valid {
+		code.UsedAt = &usedAt.Time
+	}
+	return &code, nil
+}
+
+func (r *PostgresRepository) CreateRefreshToken(ctx context.Context, token *models.OAuthRefreshToken) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	token.TenantID = tenant
+
+	query := `
+		INSERT INTO oauth_refresh_tokens (token_hash, tenant_id, client_id, user_id, session_id,
+		                                  scopes, auth_time, created_at, expires_at, dpop_jkt)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+	`
+
+	var jkt sql.NullString
+	if token.DPoPThumbprint != "" {
+		jkt = sql.NullString{String: token.DPoPThumbprint, Valid: true}
+	}
+
+	_, err = r.db.ExecContext(ctx, query,
+		token.TokenHash,
+		token.TenantID,
+		token.ClientID,
+		token.UserID,
+		token.SessionID,
+		pq.Array(token.Scopes),
+		token.AuthTime,
+		token.CreatedAt,
+		token.ExpiresAt,
+		jkt,
+	)
+	return errors.Wrap(err, "failed to create refresh token")
+}
+
+func (r *PostgresRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.OAuthRefreshToken, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT token_hash, tenant_id, client_id, user_id, session_id, scopes,
+		       auth_time, created_at, expires_at, used_at, dpop_jkt
+		FROM oauth_refresh_tokens WHERE token_hash = $1 AND tenant_id = $2
+	`
+
+	var token models.OAuthRefreshToken
+	var usedAt sql.NullTime
+	var jkt sql.NullString
+	err = r.db.QueryRowContext(ctx, query, tokenHash, tenant).Scan(
+		&token.TokenHash,
+		&token.TenantID,
+		&token.ClientID,
+		&token.UserID,
+		&token.SessionID,
+		pq.Array(&token.Scopes),
+		&token.AuthTime,
+		&token.CreatedAt,
+		&token.ExpiresAt,
+		&usedAt,
+		&jkt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get refresh token")
+	}
+
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	token.DPoPThumbprint = jkt.String
+	return &token, nil
+}
+
+func (r *PostgresRepository) MarkRefreshTokenUsed(ctx context.Context, tokenHash string, usedAt time.Time) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE oauth_refresh_tokens SET used_at = $3
+		WHERE token_hash = $1 AND tenant_id = $2 AND used_at IS NULL
+	`, tokenHash, tenant, usedAt)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to mark refresh token used")
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, errors.Wrap(err, "failed to mark refresh token used")
+	}
+	return affected == 1, nil
+}
+
+func (r *PostgresRepository) GetConsent(ctx context.Context, userID int64, clientID string) (*models.OAuthConsent, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT user_id, client_id, scopes, granted_at
+		FROM oauth_consents WHERE user_id = $1 AND client_id = $2 AND tenant_id = $3
+	`
+
+	var consent models.OAuthConsent
+	err = r.db.QueryRowContext(ctx, query, userID, clientID, tenant).Scan(
+		&consent.UserID,
+		&consent.ClientID,
+		pq.Array(&consent.Scopes),
+		&consent.GrantedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get consent")
+	}
+	return &consent, nil
+}
+
+// SaveConsent заменяет ранее данное согласие
+func (r *PostgresRepository) SaveConsent(ctx context.Context, consent *models.OAuthConsent) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO oauth_consents (tenant_id, user_id, client_id, scopes, granted_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (tenant_id, user_id, client_id)
+		DO UPDATE SET scopes = EXCLUDED.scopes, granted_at = EXCLUDED.granted_at
+	`
+
+	_, err = r.db.ExecContext(ctx, query, tenant, consent.UserID, consent.ClientID, pq.Array(consent.Scopes), consent.GrantedAt)
+	return errors.Wrap(err, "failed to save consent")
+}
@@ -0,0 +1,309 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/pkg/errors"
+)
+
+// Организации принадлежат тенанту; участники и приглашения проверяются через организацию
+type OrganizationRepository interface {
+	// CreateOrganization создает организацию и делает создателя ее владельцем
+	CreateOrganization(ctx context.Context, org *models.Organization, ownerID int64) error
+	GetOrganization(ctx context.Context, id int64) (*models.Organization, error)
+	// GetOrganizationMembership возвращает nil, если пользователь не участник
+	GetOrganizationMembership(ctx context.Context, orgID, userID int64) (*models.OrganizationMembership, error)
+	// AddOrganizationMember возвращает false, если пользователь уже участник
+	AddOrganizationMember(ctx context.Context, membership *models.OrganizationMembership) (bool, error)
+	UpdateOrganizationMemberRole(ctx context.Context, orgID, userID int64, role models.OrganizationRole) (bool, error)
+	RemoveOrganizationMember(ctx context.Context, orgID, userID int64) (bool, error)
+	CountOrganizationOwners(ctx context.Context, orgID int64) (int, error)
+
+	CreateInvitation(ctx context.Context, invitation *models.Invitation, tokenHash string) error
+	// GetInvitation возвращает действующее приглашение (nil, если токен использован или истек)
+	GetInvitation(ctx context.Context, tokenHash string, now time.Time) (*models.Invitation, error)
+	// ConsumeInvitation помечает приглашение принятым; false, если его уже приняли
+	ConsumeInvitation(ctx context.Context, invitationID, userID int64, now time.Time) (bool, error)
+}
+
+func (r *PostgresRepository) CreateOrganization(ctx context.Context, org *models.Organization, ownerID int64) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	org.TenantID = tenant
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin organization transaction")
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO organizations (tenant_id, name, created_by, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+
+	err = tx.QueryRowContext(ctx, query, org.TenantID, org.Name, org.CreatedBy, org.CreatedAt).Scan(&org.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to insert organization")
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO organization_members (organization_id, user_id, role, joined_at)
+		SELECT $1, id, $3, $4 FROM users WHERE id = $2 AND tenant_id = $5
+	`, org.ID, ownerID, models.OrganizationOwner, org.CreatedAt, tenant)
+	if err != nil {
+		return errors.Wrap(err, "failed to add organization owner")
+	}
+
+	return errors.Wrap(tx.Commit(), "failed to commit organization")
+}
+
+func (r *PostgresRepository) GetOrganization(ctx context.Context, id int64) (*models.Organization, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT id, tenant_id, name, created_by, created_at FROM organizations WHERE id = $1 AND tenant_id = $2`
+
+	var org models.Organization
+	var createdBy sql.NullInt64
+	err = r.db.QueryRowContext(ctx, query, id, tenant).Scan(
+		&org.ID,
+		&org.TenantID,
+		&org.Name,
+		&createdBy,
+		&org.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get organization")
+	}
+
+	if createdBy.Valid {
+		org.CreatedBy = &createdBy.Int64
+	}
+	return &org, nil
+}
+
+func (r *PostgresRepository) GetOrganizationMembership(ctx context.Context, orgID, userID int64) (*models.OrganizationMembership, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT m.organization_id, m.user_id, m.role, m.joined_at
+		FROM organization_members m
+		JOIN organizations o ON o.id = m.organization_id
+		WHERE m.organization_id = $1 AND m.user_id = $2 AND o.tenant_id = $3
+	`
+
+	var membership models.OrganizationMembership
+	err = r.db.QueryRowContext(ctx, query, orgID, userID, tenant).Scan(
+		&membership.OrganizationID,
+		&membership.UserID,
+		&membership.Role,
+		&membership.JoinedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get organization membership")
+	}
+
+	return &membership, nil
+}
+
+func (r *PostgresRepository) AddOrganizationMember(ctx context.Context, membership *models.OrganizationMembership) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// Организация и пользователь должны быть из тенанта запроса
+	query := `
+		INSERT INTO organization_members (organization_id, user_id, role, joined_at)
+		SELECT o.id, u.id, $3, $4
+		FROM organizations o, users u
+		WHERE o.id = $1 AND o.tenant_id = $5
+		  AND u.id = $2 AND u.tenant_id = $5
+		ON CONFLICT (organization_id, user_id) DO NOTHING
+	`
+
+	result, err := r.db.ExecContext(ctx, query,
+		membership.OrganizationID,
+		membership.UserID,
+		membership.Role,
+		membership.JoinedAt,
+		tenant,
+	)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to add organization member")
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, errors.Wrap(err, "failed to add organization member")
+}
+
+func (r *PostgresRepository) UpdateOrganizationMemberRole(ctx context.Context, orgID, userID int64, role models.OrganizationRole) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE organization_members m SET role = $3
+		FROM organizations o
+		WHERE o.id = m.organization_id AND o.tenant_id = $4
+		  AND m.organization_id = $1 AND m.user_id = $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, orgID, userID, role, tenant)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to update organization member role")
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, errors.Wrap(err, "failed to update organization member role")
+}
+
+func (r *PostgresRepository) RemoveOrganizationMember(ctx context.Context, orgID, userID int64) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		DELETE FROM organization_members m USING organizations o
+		WHERE o.id = m.organization_id AND o.tenant_id = $3
+		  AND m.organization_id = $1 AND m.user_id = $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, orgID, userID, tenant)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to remove organization member")
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, errors.Wrap(err, "failed to remove organization member")
+}
+
+func (r *PostgresRepository) CountOrganizationOwners(ctx context.Context, orgID int64) (int, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		SELECT COUNT(*)
+		FROM organization_members m
+		JOIN organizations o ON o.id = m.organization_id
+		WHERE m.organization_id = $1 AND o.tenant_id = $2 AND m.role = $3
+	`
+
+	var count int
+	err = r.db.QueryRowContext(ctx, query, orgID, tenant, models.OrganizationOwner).Scan(&count)
+	return count, errors.Wrap(err, "failed to count organization owners")
+}
+
+func (r *PostgresRepository) CreateInvitation(ctx context.Context, invitation *models.Invitation, tokenHash string) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO organization_invitations (organization_id, email, role, token_hash, invited_by, created_at, expires_at)
+		SELECT id, $2, $3, $4, $5, $6, $7 FROM organizations WHERE id = $1 AND tenant_id = $8
+		RETURNING id
+	`
+
+	err = r.db.QueryRowContext(ctx, query,
+		invitation.OrganizationID,
+		invitation.Email,
+		invitation.Role,
+		tokenHash,
+		invitation.InvitedBy,
+		invitation.CreatedAt,
+		invitation.ExpiresAt,
+		tenant,
+	).Scan(&invitation.ID)
+
+	if err == sql.ErrNoRows {
+		return models.ErrOrganizationNotFound
+	}
+	return errors.Wrap(err, "failed to create invitation")
+}
+
+func (r *PostgresRepository) GetInvitation(ctx context.Context, tokenHash string, now time.Time) (*models.Invitation, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT i.id, i.organization_id, i.email, i.role, i.invited_by, i.created_at, i.expires_at
+		FROM organization_invitations i
+		JOIN organizations o ON o.id = i.organization_id
+		WHERE i.token_hash = $1 AND o.tenant_id = $2
+		  AND i.accepted_at IS NULL AND i.expires_at > $3
+	`
+
+	var invitation models.Invitation
+	var invitedBy sql.NullInt64
+	err = r.db.QueryRowContext(ctx, query, tokenHash, tenant, now).Scan(
+		&invitation.ID,
+		&invitation.OrganizationID,
+		&invitation.Email,
+		&invitation.Role,
+		&invitedBy,
+		&invitation.CreatedAt,
+		&invitation.ExpiresAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get invitation")
+	}
+
+	if invitedBy.Valid {
+		invitation.InvitedBy = &invitedBy.Int64
+	}
+	return &invitation, nil
+}
+
+func (r *PostgresRepository) ConsumeInvitation(ctx context.Context, invitationID, userID int64, now time.Time) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		UPDATE organization_invitations i SET accepted_at = $3, accepted_by = $2
+		FROM organizations o
+		WHERE o.id = i.organization_id AND o.tenant_id = $4
+		  AND i.id = $1 AND i.accepted_at IS NULL AND i.expires_at > $3
+	`
+
+	result, err := r.db.ExecContext(ctx, query, invitationID, userID, now, tenant)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to consume invitation")
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, errors.Wrap(err, "failed to consume invitation")
+}
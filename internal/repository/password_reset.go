@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+type PasswordResetRepository interface {
+	CreatePasswordResetToken(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error
+	// ConsumePasswordResetToken помечает токен использованным и возвращает ID пользователя (0, если токен недействителен)
+	ConsumePasswordResetToken(ctx context.Context, tokenHash string, now time.Time) (int64, error)
+}
+
+func (r *PostgresRepository) CreatePasswordResetToken(ctx context.Context, userID int64, tokenHash string, expiresAt time.Time) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO password_reset_tokens (token_hash, user_id, expires_at, created_at, tenant_id)
+		VALUES ($1, $2, $3, $4, $5)
+	`
+
+	_, err = r.db.ExecContext(ctx, query, tokenHash, userID, expiresAt, time.Now(), tenant)
+	return errors.Wrap(err, "failed to create password reset token")
+}
+
+func (r *PostgresRepository) ConsumePasswordResetToken(ctx context.Context, tokenHash string, now time.Time) (int64, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	query := `
+		UPDATE password_reset_tokens SET used_at = $1
+		WHERE token_hash = $2 AND tenant_id = $3 AND used_at IS NULL AND expires_at > $1
+		RETURNING user_id
+	`
+
+	var userID int64
+	err = r.db.QueryRowContext(ctx, query, now, tokenHash, tenant).Scan(&userID)
+
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+
+	return userID, errors.Wrap(err, "failed to consume password reset token")
+}
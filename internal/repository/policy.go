@@ -0,0 +1,130 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type PolicyRepository interface {
+	// SavePolicy создает политику или обновляет существующую с тем же именем
+	SavePolicy(ctx context.Context, policy *models.Policy) error
+	// DeletePolicy возвращает false, если политики не было
+	DeletePolicy(ctx context.Context, name string) (bool, error)
+	ListPolicies(ctx context.Context) ([]*models.Policy, error)
+	// ListPoliciesForAction возвращает включенные политики действия в порядке проверки
+	ListPoliciesForAction(ctx context.Context, action string) ([]*models.Policy, error)
+}
+
+const policyColumns = `id, name, description, expression, effect, actions, priority, enabled, created_at, updated_at`
+
+func (r *PostgresRepository) SavePolicy(ctx context.Context, policy *models.Policy) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		INSERT INTO policies (name, description, expression, effect, actions, priority, enabled, created_at, updated_at, tenant_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $8, $9)
+		ON CONFLICT (tenant_id, name) DO UPDATE SET
+			description = EXCLUDED.description,
+			expression = EXCLUDED.expression,
+			effect = EXCLUDED.effect,
+			actions = EXCLUDED.actions,
+			priority = EXCLUDED.priority,
+			enabled = EXCLUDED.enabled,
+			updated_at = EXCLUDED.updated_at
+		RETURNING id, created_at
+	`
+
+	err = r.db.QueryRowContext(ctx, query,
+		policy.Name,
+		policy.Description,
+		policy.Expression,
+		policy.Effect,
+		pq.Array(policy.Actions),
+		policy.Priority,
+		policy.Enabled,
+		policy.UpdatedAt,
+		tenant,
+	).Scan(&policy.ID, &policy.CreatedAt)
+
+	return errors.Wrap(err, "failed to save policy")
+}
+
+func (r *PostgresRepository) DeletePolicy(ctx context.Context, name string) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	result, err := r.db.ExecContext(ctx, `DELETE FROM policies WHERE name = $1 AND tenant_id = $2`, name, tenant)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to delete policy")
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, errors.Wrap(err, "failed to delete policy")
+}
+
+func (r *PostgresRepository) ListPolicies(ctx context.Context) ([]*models.Policy, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + policyColumns + ` FROM policies WHERE tenant_id = $1 ORDER BY priority DESC, name`
+
+	policies, err := r.queryPolicies(ctx, query, tenant)
+	return policies, errors.Wrap(err, "failed to list policies")
+}
+
+func (r *PostgresRepository) ListPoliciesForAction(ctx context.Context, action string) ([]*models.Policy, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + policyColumns + ` FROM policies
+		WHERE tenant_id = $2 AND enabled AND actions && ARRAY[$1, '*']::TEXT[]
+		ORDER BY priority DESC, name
+	`
+
+	policies, err := r.queryPolicies(ctx, query, action, tenant)
+	return policies, errors.Wrap(err, "failed to list policies for action")
+}
+
+func (r *PostgresRepository) queryPolicies(ctx context.Context, query string, args ...interface{}) ([]*models.Policy, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var policies []*models.Policy
+	for rows.Next() {
+		var policy models.Policy
+		err := rows.Scan(
+			&policy.ID,
+			&policy.Name,
+			&policy.Description,
+			&policy.Expression,
+			&policy.Effect,
+			pq.Array(&policy.Actions),
+			&policy.Priority,
+			&policy.Enabled,
+			&policy.CreatedAt,
+			&policy.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, &policy)
+	}
+
+	return policies, rows.Err()
+}
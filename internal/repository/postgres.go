@@ -16,6 +16,8 @@ type UserRepository interface {
 	GetUserByID(ctx context.Context, id int64) (*models.User, error)
 	UpdateUser(ctx context.Context, user *models.User) error
 	UpdateLastLogin(ctx context.Context, userID int64, loginTime time.Time) error
+	UpdatePassword(ctx context.Context, userID int64, passwordHash string) error
+	SetPasswordResetRequired(ctx context.Context, userID int64, required bool) error
 	Close() error
 }
 
@@ -46,13 +48,19 @@ func NewPostgresRepository(dbURL string) (*PostgresRepository, error) {
 }
 
 func (r *PostgresRepository) CreateUser(ctx context.Context, user *models.User) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	user.TenantID = tenant
+
 	query := `
-		INSERT INTO users (first_name, surname, birthday, email, phone, password_hash, is_active, is_verified, role, created_at, updated_at) 
+		INSERT INTO users (first_name, surname, birthday, email, phone, password_hash, is_active, is_verified, created_at, updated_at, tenant_id) 
 		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
 		RETURNING id
 	`
 
-	err := r.db.QueryRowContext(ctx, query,
+	err = r.conn(ctx).QueryRowContext(ctx, query,
 		user.FirstName,
 		user.Surname,
 		user.Birthday,
@@ -61,9 +69,9 @@ func (r *PostgresRepository) CreateUser(ctx context.Context, user *models.User)
 		user.Password,
 		user.IsActive,
 		user.IsVerified,
-		user.Role,
 		user.CreatedAt,
 		user.UpdatedAt,
+		user.TenantID,
 	).Scan(&user.ID)
 
 	return errors.Wrap(err, "failed to create user")
@@ -72,15 +80,22 @@ func (r *PostgresRepository) CreateUser(ctx context.Context, user *models.User)
 func (r *PostgresRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	query := `
 		SELECT id, first_name, surname, birthday, email, phone, password_hash, 
-		       is_active, is_verified, last_login, role, created_at, updated_at
-		FROM users WHERE email = $1
+		       is_active, is_verified, last_login, created_at, updated_at,
+		       password_reset_required, tenant_id, merged_into
+		FROM users WHERE email = $1 AND tenant_id = $2
 	`
 
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var user models.User
 	var phone sql.NullString
 	var lastLogin sql.NullTime
+	var mergedInto sql.NullInt64
 
-	err := r.db.QueryRowContext(ctx, query, email).Scan(
+	err = r.db.QueryRowContext(ctx, query, email, tenant).Scan(
 		&user.ID,
 		&user.FirstName,
 		&user.Surname,
@@ -91,9 +106,11 @@ func (r *PostgresRepository) GetUserByEmail(ctx context.Context, email string) (
 		&user.IsActive,
 		&user.IsVerified,
 		&lastLogin,
-		&user.Role,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.PasswordResetRequired,
+		&user.TenantID,
+		&mergedInto,
 	)
 
 	if err == sql.ErrNoRows {
@@ -111,6 +128,9 @@ func (r *PostgresRepository) GetUserByEmail(ctx context.Context, email string) (
 	if lastLogin.Valid {
 		user.LastLogin = &lastLogin.Time
 	}
+	if mergedInto.Valid {
+		user.MergedInto = &mergedInto.Int64
+	}
 
 	return &user, nil
 }
@@ -118,15 +138,22 @@ func (r *PostgresRepository) GetUserByEmail(ctx context.Context, email string) (
 func (r *PostgresRepository) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
 	query := `
 		SELECT id, first_name, surname, birthday, email, phone, password_hash, 
-		       is_active, is_verified, last_login, role, created_at, updated_at
-		FROM users WHERE id = $1
+		       is_active, is_verified, last_login, created_at, updated_at,
+		       password_reset_required, tenant_id, merged_into
+		FROM users WHERE id = $1 AND tenant_id = $2
 	`
 
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	var user models.User
 	var phone sql.NullString
 	var lastLogin sql.NullTime
+	var mergedInto sql.NullInt64
 
-	err := r.db.QueryRowContext(ctx, query, id).Scan(
+	err = r.conn(ctx).QueryRowContext(ctx, query, id, tenant).Scan(
 		&user.ID,
 		&user.FirstName,
 		&user.Surname,
@@ -137,9 +164,11 @@ func (r *PostgresRepository) GetUserByID(ctx context.Context, id int64) (*models
 		&user.IsActive,
 		&user.IsVerified,
 		&lastLogin,
-		&user.Role,
 		&user.CreatedAt,
 		&user.UpdatedAt,
+		&user.PasswordResetRequired,
+		&user.TenantID,
+		&mergedInto,
 	)
 
 	if err == sql.ErrNoRows {
@@ -157,6 +186,9 @@ func (r *PostgresRepository) GetUserByID(ctx context.Context, id int64) (*models
 	if lastLogin.Valid {
 		user.LastLogin = &lastLogin.Time
 	}
+	if mergedInto.Valid {
+		user.MergedInto = &mergedInto.Int64
+	}
 
 	return &user, nil
 }
@@ -165,11 +197,16 @@ func (r *PostgresRepository) UpdateUser(ctx context.Context, user *models.User)
 	query := `
 		UPDATE users 
 		SET first_name = $1, surname = $2, birthday = $3, email = $4, phone = $5,
-		    is_active = $6, is_verified = $7, role = $8, updated_at = $9
-		WHERE id = $10
+		    is_active = $6, is_verified = $7, updated_at = $8
+		WHERE id = $9 AND tenant_id = $10
 	`
 
-	_, err := r.db.ExecContext(ctx, query,
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.db.ExecContext(ctx, query,
 		user.FirstName,
 		user.Surname,
 		user.Birthday,
@@ -177,21 +214,54 @@ func (r *PostgresRepository) UpdateUser(ctx context.Context, user *models.User)
 		user.Phone,
 		user.IsActive,
 		user.IsVerified,
-		user.Role,
 		user.UpdatedAt,
 		user.ID,
+		tenant,
 	)
 
 	return errors.Wrap(err, "failed to update user")
 }
 
 func (r *PostgresRepository) UpdateLastLogin(ctx context.Context, userID int64, loginTime time.Time) error {
-	query := `UPDATE users SET last_login = $1, updated_at = $2 WHERE id = $3`
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
 
-	_, err := r.db.ExecContext(ctx, query, loginTime, time.Now(), userID)
+	query := `UPDATE users SET last_login = $1, updated_at = $2 WHERE id = $3 AND tenant_id = $4`
+
+	_, err = r.db.ExecContext(ctx, query, loginTime, time.Now(), userID, tenant)
 	return errors.Wrap(err, "failed to update last login")
 }
 
+func (r *PostgresRepository) UpdatePassword(ctx context.Context, userID int64, passwordHash string) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `
+		UPDATE users
+		SET password_hash = $1, password_reset_required = false, updated_at = $2
+		WHERE id = $3 AND tenant_id = $4
+	`
+
+	_, err = r.db.ExecContext(ctx, query, passwordHash, time.Now(), userID, tenant)
+	return errors.Wrap(err, "failed to update password")
+}
+
+func (r *PostgresRepository) SetPasswordResetRequired(ctx context.Context, userID int64, required bool) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE users SET password_reset_required = $1, updated_at = $2 WHERE id = $3 AND tenant_id = $4`
+
+	_, err = r.db.ExecContext(ctx, query, required, time.Now(), userID, tenant)
+	return errors.Wrap(err, "failed to set password reset flag")
+}
+
 func (r *PostgresRepository) Close() error {
 	return r.db.Close()
 }
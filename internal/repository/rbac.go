@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+// Роли с tenant_id IS NULL встроенные и видны во всех тенантах,
+// остальные принадлежат тенанту, в котором созданы
+type RBACRepository interface {
+	// CreateRole создает роль тенанта и недостающие разрешения; models.ErrRoleAlreadyExists, если имя занято
+	CreateRole(ctx context.Context, role *models.Role) error
+	GetRoleByName(ctx context.Context, name string) (*models.Role, error)
+	// GrantRole возвращает false, если роль уже была выдана
+	GrantRole(ctx context.Context, userID, roleID int64, grantedBy *int64, now time.Time) (bool, error)
+	// RevokeRole возвращает false, если роли у пользователя не было
+	RevokeRole(ctx context.Context, userID, roleID int64) (bool, error)
+	GetUserAccess(ctx context.Context, userID int64) (*models.UserAccess, error)
+}
+
+// Код ошибки Postgres unique_violation
+const pqUniqueViolation = "23505"
+
+func (r *PostgresRepository) CreateRole(ctx context.Context, role *models.Role) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin role transaction")
+	}
+	defer tx.Rollback()
+
+	query := `
+		INSERT INTO roles (name, description, created_at, tenant_id)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+
+	err = tx.QueryRowContext(ctx, query, role.Name, role.Description, role.CreatedAt, tenant).Scan(&role.ID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+		return models.ErrRoleAlreadyExists
+	}
+	if err != nil {
+		return errors.Wrap(err, "failed to insert role")
+	}
+
+	for _, permission := range role.Permissions {
+		_, err := tx.ExecContext(ctx, `INSERT INTO permissions (name) VALUES ($1) ON CONFLICT (name) DO NOTHING`, permission)
+		if err != nil {
+			return errors.Wrap(err, "failed to insert permission")
+		}
+
+		_, err = tx.ExecContext(ctx, `
+			INSERT INTO role_permissions (role_id, permission_id)
+			SELECT $1, id FROM permissions WHERE name = $2
+			ON CONFLICT DO NOTHING
+		`, role.ID, permission)
+		if err != nil {
+			return errors.Wrap(err, "failed to attach permission to role")
+		}
+	}
+
+	return errors.Wrap(tx.Commit(), "failed to commit role")
+}
+
+func (r *PostgresRepository) GetRoleByName(ctx context.Context, name string) (*models.Role, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT r.id, r.name, r.description, r.created_at,
+		       COALESCE(array_agg(p.name ORDER BY p.name) FILTER (WHERE p.name IS NOT NULL), '{}')
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+		WHERE r.name = $1 AND (r.tenant_id = $2 OR r.tenant_id IS NULL)
+		GROUP BY r.id
+		ORDER BY r.tenant_id NULLS LAST
+		LIMIT 1
+	`
+
+	var role models.Role
+	err = r.conn(ctx).QueryRowContext(ctx, query, name, tenant).Scan(
+		&role.ID,
+		&role.Name,
+		&role.Description,
+		&role.CreatedAt,
+		pq.Array(&role.Permissions),
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get role")
+	}
+
+	return &role, nil
+}
+
+func (r *PostgresRepository) GrantRole(ctx context.Context, userID, roleID int64, grantedBy *int64, now time.Time) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	// Пользователь и роль должны быть видны из тенанта запроса
+	query := `
+		INSERT INTO user_roles (user_id, role_id, granted_at, granted_by)
+		SELECT u.id, r.id, $3, $4
+		FROM users u, roles r
+		WHERE u.id = $1 AND u.tenant_id = $5
+		  AND r.id = $2 AND (r.tenant_id = $5 OR r.tenant_id IS NULL)
+		ON CONFLICT (user_id, role_id) DO NOTHING
+	`
+
+	result, err := r.conn(ctx).ExecContext(ctx, query, userID, roleID, now, grantedBy, tenant)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to grant role")
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, errors.Wrap(err, "failed to grant role")
+}
+
+func (r *PostgresRepository) RevokeRole(ctx context.Context, userID, roleID int64) (bool, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	query := `
+		DELETE FROM user_roles ur USING users u
+		WHERE ur.user_id = u.id AND u.tenant_id = $3
+		  AND ur.user_id = $1 AND ur.role_id = $2
+	`
+
+	result, err := r.db.ExecContext(ctx, query, userID, roleID, tenant)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to revoke role")
+	}
+
+	rows, err := result.RowsAffected()
+	return rows > 0, errors.Wrap(err, "failed to revoke role")
+}
+
+func (r *PostgresRepository) GetUserAccess(ctx context.Context, userID int64) (*models.UserAccess, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT
+			COALESCE((SELECT array_agg(r.name ORDER BY r.name)
+			          FROM user_roles ur
+			          JOIN users u ON u.id = ur.user_id AND u.tenant_id = $2
+			          JOIN roles r ON r.id = ur.role_id
+			          WHERE ur.user_id = $1), '{}'),
+			COALESCE((SELECT array_agg(DISTINCT p.name ORDER BY p.name)
+			          FROM user_roles ur
+			          JOIN users u ON u.id = ur.user_id AND u.tenant_id = $2
+			          JOIN role_permissions rp ON rp.role_id = ur.role_id
+			          JOIN permissions p ON p.id = rp.permission_id
+			          WHERE ur.user_id = $1), '{}')
+	`
+
+	var access models.UserAccess
+	err = r.db.QueryRowContext(ctx, query, userID, tenant).Scan(
+		pq.Array(&access.Roles),
+		pq.Array(&access.Permissions),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user access")
+	}
+
+	return &access, nil
+}
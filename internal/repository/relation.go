@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/pkg/errors"
+)
+
+type RelationRepository interface {
+	// WriteRelationTuples атомарно применяет изменения и возвращает новую ревизию
+	WriteRelationTuples(ctx context.Context, updates []models.RelationUpdate) (models.RelationRevision, error)
+	// ListRelationSubjects возвращает субъектов кортежей resource#relation@...
+	ListRelationSubjects(ctx context.Context, resource models.ObjectRef, relation string) ([]models.SubjectRef, error)
+	// ListRelationObjectIDs возвращает объекты пространства имен, у которых есть хотя бы один кортеж
+	ListRelationObjectIDs(ctx context.Context, namespace string) ([]string, error)
+	GetRelationRevision(ctx context.Context) (models.RelationRevision, error)
+}
+
+func (r *PostgresRepository) WriteRelationTuples(ctx context.Context, updates []models.RelationUpdate) (models.RelationRevision, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to begin relation transaction")
+	}
+	defer tx.Rollback()
+
+	// Блокировка строки счетчика сериализует запись кортежей
+	var revision models.RelationRevision
+	err = tx.QueryRowContext(ctx, `UPDATE relation_revision SET revision = revision + 1 RETURNING revision`).Scan(&revision)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to bump relation revision")
+	}
+
+	for _, update := range updates {
+		t := update.Tuple
+
+		switch update.Operation {
+		case models.RelationTouch:
+			_, err = tx.ExecContext(ctx, `
+				INSERT INTO relation_tuples (namespace, object_id, relation, subject_namespace, subject_id, subject_relation, created_revision, tenant_id)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+				ON CONFLICT DO NOTHING
+			`, t.Resource.Namespace, t.Resource.ID, t.Relation, t.Subject.Object.Namespace, t.Subject.Object.ID, t.Subject.Relation, revision, tenant)
+		case models.RelationDelete:
+			_, err = tx.ExecContext(ctx, `
+				DELETE FROM relation_tuples
+				WHERE tenant_id = $7 AND namespace = $1 AND object_id = $2 AND relation = $3
+				  AND subject_namespace = $4 AND subject_id = $5 AND subject_relation = $6
+			`, t.Resource.Namespace, t.Resource.ID, t.Relation, t.Subject.Object.Namespace, t.Subject.Object.ID, t.Subject.Relation, tenant)
+		default:
+			return 0, models.ErrInvalidRelationship
+		}
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed to write relation tuple %s", t)
+		}
+	}
+
+	return revision, errors.Wrap(tx.Commit(), "failed to commit relation tuples")
+}
+
+func (r *PostgresRepository) ListRelationSubjects(ctx context.Context, resource models.ObjectRef, relation string) ([]models.SubjectRef, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT subject_namespace, subject_id, subject_relation
+		FROM relation_tuples
+		WHERE tenant_id = $4 AND namespace = $1 AND object_id = $2 AND relation = $3
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, resource.Namespace, resource.ID, relation, tenant)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list relation subjects")
+	}
+	defer rows.Close()
+
+	var subjects []models.SubjectRef
+	for rows.Next() {
+		var subject models.SubjectRef
+		if err := rows.Scan(&subject.Object.Namespace, &subject.Object.ID, &subject.Relation); err != nil {
+			return nil, errors.Wrap(err, "failed to scan relation subject")
+		}
+		subjects = append(subjects, subject)
+	}
+
+	return subjects, errors.Wrap(rows.Err(), "failed to list relation subjects")
+}
+
+func (r *PostgresRepository) ListRelationObjectIDs(ctx context.Context, namespace string) ([]string, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT DISTINCT object_id FROM relation_tuples WHERE tenant_id = $1 AND namespace = $2 ORDER BY object_id`
+
+	rows, err := r.db.QueryContext(ctx, query, tenant, namespace)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list relation objects")
+	}
+	defer rows.Close()
+
+	var ids []string
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, errors.Wrap(err, "failed to scan relation object")
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, errors.Wrap(rows.Err(), "failed to list relation objects")
+}
+
+// Ревизия общая для всех тенантов: она только упорядочивает записи
+func (r *PostgresRepository) GetRelationRevision(ctx context.Context) (models.RelationRevision, error) {
+	var revision models.RelationRevision
+	err := r.db.QueryRowContext(ctx, `SELECT revision FROM relation_revision`).Scan(&revision)
+	return revision, errors.Wrap(err, "failed to get relation revision")
+}
@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/pkg/errors"
+)
+
+type SessionRepository interface {
+	CreateSession(ctx context.Context, session *models.Session) error
+	GetSession(ctx context.Context, id string) (*models.Session, error)
+	ListActiveSessions(ctx context.Context, userID int64, now time.Time) ([]*models.Session, error)
+	// GetLastLocatedSession возвращает последнюю сессию пользователя с известной геолокацией
+	GetLastLocatedSession(ctx context.Context, userID int64) (*models.Session, error)
+	RevokeSession(ctx context.Context, id string, revokedAt time.Time) error
+	RevokeUserSessions(ctx context.Context, userID int64, revokedAt time.Time) error
+}
+
+const sessionColumns = `
+	id, user_id, refresh_token_hash, user_agent, ip, device_fingerprint,
+	created_at, expires_at, revoked_at,
+	country_code, country, city, latitude, longitude, impossible_travel, tenant_id, impersonator_id
+`
+
+func (r *PostgresRepository) CreateSession(ctx context.Context, session *models.Session) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+	session.TenantID = tenant
+
+	query := `
+		INSERT INTO sessions (id, user_id, refresh_token_hash, user_agent, ip, device_fingerprint, created_at, expires_at,
+		                      country_code, country, city, latitude, longitude, impossible_travel, tenant_id, impersonator_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)
+	`
+
+	var countryCode, country, city sql.NullString
+	var latitude, longitude sql.NullFloat64
+	if loc := session.Location; loc != nil {
+		countryCode = sql.NullString{String: loc.CountryCode, Valid: true}
+		country = sql.NullString{String: loc.Country, Valid: true}
+		city = sql.NullString{String: loc.City, Valid: true}
+		latitude = sql.NullFloat64{Float64: loc.Latitude, Valid: true}
+		longitude = sql.NullFloat64{Float64: loc.Longitude, Valid: true}
+	}
+
+	_, err = r.db.ExecContext(ctx, query,
+		session.ID,
+		session.UserID,
+		session.RefreshTokenHash,
+		session.UserAgent,
+		session.IP,
+		session.DeviceFingerprint,
+		session.CreatedAt,
+		session.ExpiresAt,
+		countryCode,
+		country,
+		city,
+		latitude,
+		longitude,
+		session.ImpossibleTravel,
+		session.TenantID,
+		session.ImpersonatorID,
+	)
+
+	return errors.Wrap(err, "failed to create session")
+}
+
+func (r *PostgresRepository) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1 AND tenant_id = $2`
+
+	session, err := scanSession(r.db.QueryRowContext(ctx, query, id, tenant))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return session, errors.Wrap(err, "failed to get session")
+}
+
+func (r *PostgresRepository) ListActiveSessions(ctx context.Context, userID int64, now time.Time) ([]*models.Session, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + sessionColumns + ` FROM sessions
+		WHERE user_id = $1 AND tenant_id = $2 AND revoked_at IS NULL AND expires_at > $3
+		ORDER BY created_at DESC
+	`
+
+	rows, err := r.db.QueryContext(ctx, query, userID, tenant, now)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list sessions")
+	}
+	defer rows.Close()
+
+	var sessions []*models.Session
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to scan session")
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, errors.Wrap(rows.Err(), "failed to iterate sessions")
+}
+
+func (r *PostgresRepository) GetLastLocatedSession(ctx context.Context, userID int64) (*models.Session, error) {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		SELECT ` + sessionColumns + ` FROM sessions
+		WHERE user_id = $1 AND tenant_id = $2 AND latitude IS NOT NULL
+		ORDER BY created_at DESC
+		LIMIT 1
+	`
+
+	session, err := scanSession(r.db.QueryRowContext(ctx, query, userID, tenant))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+
+	return session, errors.Wrap(err, "failed to get last located session")
+}
+
+func (r *PostgresRepository) RevokeSession(ctx context.Context, id string, revokedAt time.Time) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE sessions SET revoked_at = $1 WHERE id = $2 AND tenant_id = $3 AND revoked_at IS NULL`
+
+	_, err = r.db.ExecContext(ctx, query, revokedAt, id, tenant)
+	return errors.Wrap(err, "failed to revoke session")
+}
+
+func (r *PostgresRepository) RevokeUserSessions(ctx context.Context, userID int64, revokedAt time.Time) error {
+	tenant, err := tenantID(ctx)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE sessions SET revoked_at = $1 WHERE user_id = $2 AND tenant_id = $3 AND revoked_at IS NULL`
+
+	_, err = r.db.ExecContext(ctx, query, revokedAt, userID, tenant)
+	return errors.Wrap(err, "failed to revoke user sessions")
+}
+
+// Общий интерфейс *sql.Row и *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSession(row rowScanner) (*models.Session, error) {
+	var session models.Session
+	var revokedAt sql.NullTime
+	var countryCode, country, city sql.NullString
+	var latitude, longitude sql.NullFloat64
+	var impersonatorID sql.NullInt64
+
+	err := row.Scan(
+		&session.ID,
+		&session.UserID,
+		&session.RefreshTokenHash,
+		&session.UserAgent,
+		&session.IP,
+		&session.DeviceFingerprint,
+		&session.CreatedAt,
+		&session.ExpiresAt,
+		&revokedAt,
+		&countryCode,
+		&country,
+		&city,
+		&latitude,
+		&longitude,
+		&session.ImpossibleTravel,
+		&session.TenantID,
+		&impersonatorID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+	if impersonatorID.Valid {
+		session.ImpersonatorID = &impersonatorID.Int64
+	}
+	if latitude.Valid && longitude.Valid {
+		session.Location = &models.GeoLocation{
+			CountryCode: countryCode.String,
+			Country:     country.String,
+			City:        city.String,
+			Latitude:    latitude.Float64,
+			Longitude:   longitude.Float64,
+		}
+	}
+
+	return &session, nil
+}
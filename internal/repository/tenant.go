@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/lib/pq"
+	"github.com/pkg/errors"
+)
+
+type TenantRepository interface {
+	CreateTenant(ctx context.Context, tenant *models.Tenant) error
+	GetTenantBySlug(ctx context.Context, slug string) (*models.Tenant, error)
+	ListTenants(ctx context.Context) ([]*models.Tenant, error)
+}
+
+// tenantID возвращает тенант запроса. Все запросы к данным пользователей
+// фильтруются по нему; без тенанта в контексте запрос не выполняется.
+func tenantID(ctx context.Context) (int64, error) {
+	tenant, ok := models.TenantFromContext(ctx)
+	if !ok {
+		return 0, models.ErrTenantRequired
+	}
+	return tenant.ID, nil
+}
+
+func (r *PostgresRepository) CreateTenant(ctx context.Context, tenant *models.Tenant) error {
+	query := `
+		INSERT INTO tenants (slug, name, is_active, created_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id
+	`
+
+	err := r.db.QueryRowContext(ctx, query, tenant.Slug, tenant.Name, tenant.IsActive, tenant.CreatedAt).Scan(&tenant.ID)
+	if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolation {
+		return models.ErrTenantAlreadyExists
+	}
+
+	return errors.Wrap(err, "failed to create tenant")
+}
+
+func (r *PostgresRepository) GetTenantBySlug(ctx context.Context, slug string) (*models.Tenant, error) {
+	query := `SELECT id, slug, name, is_active, created_at FROM tenants WHERE slug = $1`
+
+	var tenant models.Tenant
+	err := r.db.QueryRowContext(ctx, query, slug).Scan(
+		&tenant.ID,
+		&tenant.Slug,
+		&tenant.Name,
+		&tenant.IsActive,
+		&tenant.CreatedAt,
+	)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get tenant")
+	}
+
+	return &tenant, nil
+}
+
+func (r *PostgresRepository) ListTenants(ctx context.Context) ([]*models.Tenant, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT id, slug, name, is_active, created_at FROM tenants ORDER BY id`)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tenants")
+	}
+	defer rows.Close()
+
+	var tenants []*models.Tenant
+	for rows.Next() {
+		var tenant models.Tenant
+		if err := rows.Scan(&tenant.ID, &tenant.Slug, &tenant.Name, &tenant.IsActive, &tenant.CreatedAt); err != nil {
+			return nil, errors.Wrap(err, "failed to scan tenant")
+		}
+		tenants = append(tenants, &tenant)
+	}
+
+	return tenants, errors.Wrap(rows.Err(), "failed to iterate tenants")
+}
@@ -0,0 +1,218 @@
+package repository
+
+import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/pressly/goose/v3"
+)
+
+// Тесты изоляции идут на настоящей базе: TEST_DATABASE_URL указывает на
+// пустую (или уже мигрированную) базу Postgres. Без нее тесты пропускаются.
+func newTestRepository(t *testing.T) *PostgresRepository {
+	t.Helper()
+
+	dbURL := os.Getenv("TEST_DATABASE_URL")
+	if dbURL == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	db, err := sql.Open("postgres", dbURL)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := goose.SetDialect("postgres"); err != nil {
+		t.Fatalf("set dialect: %v", err)
+	}
+	if err := goose.Up(db, "../../migrations"); err != nil {
+		t.Fatalf("run migrations: %v", err)
+	}
+	db.Close()
+
+	repo, err := NewPostgresRepository(dbURL)
+	if err != nil {
+		t.Fatalf("connect: %v", err)
+	}
+	t.Cleanup(func() { repo.Close() })
+	return repo
+}
+
+// Тенант с уникальным slug, чтобы тесты не мешали друг другу на общей базе
+func newTestTenant(t *testing.T, repo *PostgresRepository) context.Context {
+	t.Helper()
+
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+
+	tenant := &models.Tenant{
+		Slug:      "test-" + hex.EncodeToString(suffix),
+		Name:      "Test tenant",
+		IsActive:  true,
+		CreatedAt: time.Now(),
+	}
+	if err := repo.CreateTenant(context.Background(), tenant); err != nil {
+		t.Fatalf("create tenant: %v", err)
+	}
+	return models.WithTenant(context.Background(), tenant)
+}
+
+func newTestUser(t *testing.T, ctx context.Context, repo *PostgresRepository, email string) *models.User {
+	t.Helper()
+
+	now := time.Now()
+	user := &models.User{
+		FirstName: "Test",
+		Surname:   "User",
+		Email:     email,
+		IsActive:  true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := repo.CreateUser(ctx, user); err != nil {
+		t.Fatalf("create user: %v", err)
+	}
+	return user
+}
+
+func TestTenantRequired(t *testing.T) {
+	repo := newTestRepository(t)
+
+	if _, err := repo.GetUserByEmail(context.Background(), "nobody@example.com"); err != models.ErrTenantRequired {
+		t.Fatalf("GetUserByEmail without tenant: got %v, want ErrTenantRequired", err)
+	}
+	if _, err := repo.ListRelationObjectIDs(context.Background(), "document"); err != models.ErrTenantRequired {
+		t.Fatalf("ListRelationObjectIDs without tenant: got %v, want ErrTenantRequired", err)
+	}
+}
+
+func TestTenantIsolationUsers(t *testing.T) {
+	repo := newTestRepository(t)
+	tenantA := newTestTenant(t, repo)
+	tenantB := newTestTenant(t, repo)
+
+	user := newTestUser(t, tenantA, repo, "alice@example.com")
+
+	got, err := repo.GetUserByEmail(tenantB, user.Email)
+	if err != nil {
+		t.Fatalf("GetUserByEmail: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("tenant B sees user %d of tenant A by email", got.ID)
+	}
+
+	got, err = repo.GetUserByID(tenantB, user.ID)
+	if err != nil {
+		t.Fatalf("GetUserByID: %v", err)
+	}
+	if got != nil {
+		t.Fatalf("tenant B sees user %d of tenant A by ID", got.ID)
+	}
+
+	// Запись по чужому ID не должна задеть пользователя тенанта A
+	if err := repo.SetPasswordResetRequired(tenantB, user.ID, true); err != nil {
+		t.Fatalf("SetPasswordResetRequired: %v", err)
+	}
+	got, err = repo.GetUserByID(tenantA, user.ID)
+	if err != nil || got == nil {
+		t.Fatalf("GetUserByID in own tenant: %v, %v", got, err)
+	}
+	if got.PasswordResetRequired {
+		t.Fatal("tenant B updated user of tenant A")
+	}
+
+	// Email уникален в пределах тенанта, а не глобально
+	other := newTestUser(t, tenantB, repo, user.Email)
+	if other.ID == user.ID || other.TenantID == user.TenantID {
+		t.Fatalf("user of tenant B shares identity with tenant A: %+v", other)
+	}
+}
+
+func TestTenantIsolationSessions(t *testing.T) {
+	repo := newTestRepository(t)
+	tenantA := newTestTenant(t, repo)
+	tenantB := newTestTenant(t, repo)
+
+	user := newTestUser(t, tenantA, repo, "bob@example.com")
+
+	id := make([]byte, 16)
+	rand.Read(id)
+	hash := make([]byte, 32)
+	rand.Read(hash)
+
+	now := time.Now()
+	session := &models.Session{
+		ID:               hex.EncodeToString(id),
+		UserID:           user.ID,
+		RefreshTokenHash: hex.EncodeToString(hash),
+		CreatedAt:        now,
+		ExpiresAt:        now.Add(time.Hour),
+	}
+	if err := repo.CreateSession(tenantA, session); err != nil {
+		t.Fatalf("create session: %v", err)
+	}
+
+	got, err := repo.GetSession(tenantB, session.ID)
+	if err != nil {
+		t.Fatalf("GetSession: %v", err)
+	}
+	if got != nil {
+		t.Fatal("tenant B sees session of tenant A")
+	}
+
+	sessions, err := repo.ListActiveSessions(tenantB, user.ID, now)
+	if err != nil {
+		t.Fatalf("ListActiveSessions: %v", err)
+	}
+	if len(sessions) != 0 {
+		t.Fatalf("tenant B lists %d sessions of tenant A", len(sessions))
+	}
+}
+
+func TestTenantIsolationRelations(t *testing.T) {
+	repo := newTestRepository(t)
+	tenantA := newTestTenant(t, repo)
+	tenantB := newTestTenant(t, repo)
+
+	tuple := models.RelationTuple{
+		Resource: models.ObjectRef{Namespace: "document", ID: "readme"},
+		Relation: "viewer",
+		Subject:  models.SubjectRef{Object: models.ObjectRef{Namespace: "user", ID: "1"}},
+	}
+	if _, err := repo.WriteRelationTuples(tenantA, []models.RelationUpdate{{Operation: models.RelationTouch, Tuple: tuple}}); err != nil {
+		t.Fatalf("write relation tuples: %v", err)
+	}
+
+	subjects, err := repo.ListRelationSubjects(tenantB, tuple.Resource, tuple.Relation)
+	if err != nil {
+		t.Fatalf("ListRelationSubjects: %v", err)
+	}
+	if len(subjects) != 0 {
+		t.Fatalf("tenant B sees subjects of tenant A: %v", subjects)
+	}
+
+	ids, err := repo.ListRelationObjectIDs(tenantB, tuple.Resource.Namespace)
+	if err != nil {
+		t.Fatalf("ListRelationObjectIDs: %v", err)
+	}
+	if len(ids) != 0 {
+		t.Fatalf("tenant B lists objects of tenant A: %v", ids)
+	}
+
+	// Удаление из тенанта B не трогает кортеж тенанта A
+	if _, err := repo.WriteRelationTuples(tenantB, []models.RelationUpdate{{Operation: models.RelationDelete, Tuple: tuple}}); err != nil {
+		t.Fatalf("delete relation tuples: %v", err)
+	}
+	subjects, err = repo.ListRelationSubjects(tenantA, tuple.Resource, tuple.Relation)
+	if err != nil {
+		t.Fatalf("ListRelationSubjects in own tenant: %v", err)
+	}
+	if len(subjects) != 1 || subjects[0] != tuple.Subject {
+		t.Fatalf("tenant A subjects after delete from tenant B: %v", subjects)
+	}
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/pkg/errors"
+)
+
+// Transactor объединяет вызовы нескольких репозиториев в одну транзакцию
+type Transactor interface {
+	// InTx выполняет fn в транзакции: методы, вызванные с контекстом fn, пишут в нее.
+	// Вложенный вызов переиспользует внешнюю транзакцию
+	InTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+// Общий интерфейс *sql.DB и *sql.Tx для методов, которые работают внутри InTx
+type querier interface {
+	rowQuerier
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+type txKey struct{}
+
+func txFromContext(ctx context.Context) (*sql.Tx, bool) {
+	tx, ok := ctx.Value(txKey{}).(*sql.Tx)
+	return tx, ok
+}
+
+func (r *PostgresRepository) InTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := txFromContext(ctx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return errors.Wrap(err, "failed to begin transaction")
+	}
+	defer tx.Rollback()
+
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+
+	return errors.Wrap(tx.Commit(), "failed to commit transaction")
+}
+
+// conn возвращает транзакцию из InTx, а вне ее - пул соединений
+func (r *PostgresRepository) conn(ctx context.Context) querier {
+	if tx, ok := txFromContext(ctx); ok {
+		return tx
+	}
+	return r.db
+}
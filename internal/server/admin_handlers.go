@@ -0,0 +1,109 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/generated/auth"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// requirePermission аутентифицирует пользователя и проверяет его разрешение
+func (s *GRPCServer) requirePermission(ctx context.Context, permission string) (*models.User, error) {
+	_, user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.rbacService.RequirePermission(ctx, user.ID, permission); err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+	return user, nil
+}
+
+func (s *GRPCServer) CreateRole(ctx context.Context, req *auth.CreateRoleRequest) (*auth.CreateRoleResponse, error) {
+	log.Printf("gRPC CreateRole called for role: %s", req.Name)
+
+	admin, err := s.requirePermission(ctx, models.PermissionRolesManage)
+	if err != nil {
+		return nil, err
+	}
+
+	role, err := s.rbacService.CreateRole(ctx, admin.ID, &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	})
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.CreateRoleResponse{
+		Role: &auth.Role{
+			Id:          role.ID,
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: role.Permissions,
+			CreatedAt:   timestamppb.New(role.CreatedAt),
+		},
+	}, nil
+}
+
+func (s *GRPCServer) GrantRole(ctx context.Context, req *auth.GrantRoleRequest) (*auth.GrantRoleResponse, error) {
+	log.Printf("gRPC GrantRole called: role %s to user %d", req.Role, req.UserId)
+
+	admin, err := s.requirePermission(ctx, models.PermissionRolesManage)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.rbacService.GrantRole(ctx, &admin.ID, req.UserId, req.Role); err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.GrantRoleResponse{}, nil
+}
+
+func (s *GRPCServer) RevokeRole(ctx context.Context, req *auth.RevokeRoleRequest) (*auth.RevokeRoleResponse, error) {
+	log.Printf("gRPC RevokeRole called: role %s from user %d", req.Role, req.UserId)
+
+	admin, err := s.requirePermission(ctx, models.PermissionRolesManage)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.rbacService.RevokeRole(ctx, &admin.ID, req.UserId, req.Role); err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.RevokeRoleResponse{}, nil
+}
+
+func (s *GRPCServer) ListUserPermissions(ctx context.Context, req *auth.ListUserPermissionsRequest) (*auth.ListUserPermissionsResponse, error) {
+	log.Printf("gRPC ListUserPermissions called for user: %d", req.UserId)
+
+	_, user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Свои разрешения можно смотреть всегда, чужие - только с users:read
+	userID := user.ID
+	if req.UserId != 0 && req.UserId != user.ID {
+		if err := s.rbacService.RequirePermission(ctx, user.ID, models.PermissionUsersRead); err != nil {
+			return nil, s.mapErrorToStatus(err)
+		}
+		userID = req.UserId
+	}
+
+	access, err := s.rbacService.GetUserAccess(ctx, userID)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.ListUserPermissionsResponse{
+		Roles:       access.Roles,
+		Permissions: access.Permissions,
+	}, nil
+}
@@ -0,0 +1,171 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/generated/auth"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *GRPCServer) CreateServiceAccount(ctx context.Context, req *auth.CreateServiceAccountRequest) (*auth.CreateServiceAccountResponse, error) {
+	log.Printf("gRPC CreateServiceAccount called: %s", req.Name)
+
+	admin, err := s.requirePermission(ctx, models.PermissionServiceAccountsManage)
+	if err != nil {
+		return nil, err
+	}
+
+	account, err := s.apiKeyService.CreateServiceAccount(ctx, admin.ID, &models.ServiceAccount{
+		Name:        req.Name,
+		Description: req.Description,
+	})
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.CreateServiceAccountResponse{
+		ServiceAccount: &auth.ServiceAccount{
+			Id:          account.ID,
+			Name:        account.Name,
+			Description: account.Description,
+			CreatedAt:   timestamppb.New(account.CreatedAt),
+		},
+	}, nil
+}
+
+func (s *GRPCServer) CreateApiKey(ctx context.Context, req *auth.CreateApiKeyRequest) (*auth.CreateApiKeyResponse, error) {
+	log.Printf("gRPC CreateApiKey called for service account: %d", req.ServiceAccountId)
+
+	admin, err := s.requirePermission(ctx, models.PermissionServiceAccountsManage)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &models.APIKey{
+		ServiceAccountID: req.ServiceAccountId,
+		Name:             req.Name,
+		Scopes:           req.Scopes,
+		AllowedCIDRs:     req.AllowedCidrs,
+	}
+	if req.ExpiresAt != nil {
+		expiresAt := req.ExpiresAt.AsTime()
+		key.ExpiresAt = &expiresAt
+	}
+
+	key, secret, err := s.apiKeyService.CreateAPIKey(ctx, admin.ID, key)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.CreateApiKeyResponse{
+		ApiKey: apiKeyToProto(key),
+		Key:    secret,
+	}, nil
+}
+
+func (s *GRPCServer) ListApiKeys(ctx context.Context, req *auth.ListApiKeysRequest) (*auth.ListApiKeysResponse, error) {
+	log.Printf("gRPC ListApiKeys called for service account: %d", req.ServiceAccountId)
+
+	if _, err := s.requirePermission(ctx, models.PermissionServiceAccountsManage); err != nil {
+		return nil, err
+	}
+
+	keys, err := s.apiKeyService.ListAPIKeys(ctx, req.ServiceAccountId)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	resp := &auth.ListApiKeysResponse{}
+	for _, key := range keys {
+		resp.ApiKeys = append(resp.ApiKeys, apiKeyToProto(key))
+	}
+	return resp, nil
+}
+
+func (s *GRPCServer) RotateApiKey(ctx context.Context, req *auth.RotateApiKeyRequest) (*auth.RotateApiKeyResponse, error) {
+	log.Printf("gRPC RotateApiKey called for key: %d", req.Id)
+
+	admin, err := s.requirePermission(ctx, models.PermissionServiceAccountsManage)
+	if err != nil {
+		return nil, err
+	}
+
+	grace := time.Duration(req.GracePeriodSeconds) * time.Second
+	key, secret, err := s.apiKeyService.RotateAPIKey(ctx, admin.ID, req.Id, grace)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.RotateApiKeyResponse{
+		ApiKey: apiKeyToProto(key),
+		Key:    secret,
+	}, nil
+}
+
+func (s *GRPCServer) RevokeApiKey(ctx context.Context, req *auth.RevokeApiKeyRequest) (*auth.RevokeApiKeyResponse, error) {
+	log.Printf("gRPC RevokeApiKey called for key: %d", req.Id)
+
+	admin, err := s.requirePermission(ctx, models.PermissionServiceAccountsManage)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.apiKeyService.RevokeAPIKey(ctx, admin.ID, req.Id); err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.RevokeApiKeyResponse{}, nil
+}
+
+func (s *GRPCServer) ValidateApiKey(ctx context.Context, req *auth.ValidateApiKeyRequest) (*auth.ValidateApiKeyResponse, error) {
+	log.Printf("gRPC ValidateApiKey called")
+
+	// Сервис передает IP клиента ключа явно; иначе берем IP вызывающего
+	ip := req.Ip
+	if ip == "" {
+		ip = s.clientInfo(ctx).IP
+	}
+
+	principal, err := s.apiKeyService.ValidateAPIKey(ctx, req.Key, ip)
+	switch err {
+	case nil:
+	case models.ErrInvalidAPIKey, models.ErrAPIKeyIPNotAllowed:
+		return &auth.ValidateApiKeyResponse{Valid: false}, nil
+	default:
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.ValidateApiKeyResponse{
+		Valid:              true,
+		ServiceAccountId:   principal.ServiceAccount.ID,
+		ServiceAccountName: principal.ServiceAccount.Name,
+		Scopes:             principal.Key.Scopes,
+		KeyPrefix:          principal.Key.Prefix,
+	}, nil
+}
+
+func apiKeyToProto(key *models.APIKey) *auth.ApiKey {
+	item := &auth.ApiKey{
+		Id:               key.ID,
+		ServiceAccountId: key.ServiceAccountID,
+		Name:             key.Name,
+		Prefix:           key.Prefix,
+		Scopes:           key.Scopes,
+		AllowedCidrs:     key.AllowedCIDRs,
+		CreatedAt:        timestamppb.New(key.CreatedAt),
+		LastUsedIp:       key.LastUsedIP,
+	}
+	if key.ExpiresAt != nil {
+		item.ExpiresAt = timestamppb.New(*key.ExpiresAt)
+	}
+	if key.LastUsedAt != nil {
+		item.LastUsedAt = timestamppb.New(*key.LastUsedAt)
+	}
+	if key.RevokedAt != nil {
+		item.RevokedAt = timestamppb.New(*key.RevokedAt)
+	}
+	return item
+}
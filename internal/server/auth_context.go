@@ -0,0 +1,91 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/generated/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// Метаданные с DPoP-доказательством - аналог заголовка DPoP (RFC 9449)
+const mdDPoP = "dpop"
+
+// bearerToken достает токен из метаданных authorization: Bearer <token> или DPoP <token>
+func bearerToken(ctx context.Context) string {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return ""
+	}
+
+	header := firstMetadata(md, "authorization")
+	for _, scheme := range []string{"Bearer ", models.DPoPTokenType + " "} {
+		if len(header) > len(scheme) && strings.EqualFold(header[:len(scheme)], scheme) {
+			return strings.TrimSpace(header[len(scheme):])
+		}
+	}
+	return ""
+}
+
+// dpopProof - DPoP-доказательство вызова из метаданных; nil, если его нет.
+// Доказательств больше одного быть не должно (RFC 9449, 4.3): пустое не пройдет проверку.
+func dpopProof(ctx context.Context) *models.DPoPRequest {
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return nil
+	}
+	values := md.Get(mdDPoP)
+	if len(values) == 0 {
+		return nil
+	}
+
+	method, _ := grpc.Method(ctx)
+	proof := &models.DPoPRequest{Method: http.MethodPost, GRPCMethod: method}
+	if len(values) == 1 {
+		proof.Proof = values[0]
+	}
+	return proof
+}
+
+// dpopFromProto - доказательство, которое сервис переслал вместе с токеном
+func dpopFromProto(proof *auth.DPoPProof) *models.DPoPRequest {
+	if proof == nil || proof.Proof == "" {
+		return nil
+	}
+	return &models.DPoPRequest{
+		Proof:      proof.Proof,
+		Method:     proof.HttpMethod,
+		URI:        proof.HttpUri,
+		GRPCMethod: proof.GrpcMethod,
+	}
+}
+
+// authenticate проверяет токен вызывающего пользователя
+func (s *GRPCServer) authenticate(ctx context.Context) (*models.TokenClaims, *models.User, error) {
+	claims, user, err := s.registrService.AuthenticateRequest(ctx, bearerToken(ctx), dpopProof(ctx))
+	if err != nil {
+		return nil, nil, s.mapErrorToStatus(err)
+	}
+	// Токен, выданный другой аудитории или клиенту OAuth, не открывает RPC этого сервиса
+	if !claims.HasAudience(s.cfg.TokenIssuer) {
+		return nil, nil, s.mapErrorToStatus(models.ErrInvalidToken)
+	}
+	return claims, user, nil
+}
+
+// authenticateOwner - authenticate для действий, которые может совершить только сам
+// пользователь: привязка способов входа, подтверждение устройств. Администратору,
+// вошедшему от имени пользователя, они недоступны.
+func (s *GRPCServer) authenticateOwner(ctx context.Context) (*models.TokenClaims, *models.User, error) {
+	claims, user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.ImpersonatorID != 0 {
+		return nil, nil, s.mapErrorToStatus(models.ErrImpersonatedSession)
+	}
+	return claims, user, nil
+}
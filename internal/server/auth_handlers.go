@@ -2,8 +2,9 @@ package server
 
 import (
 	"context"
+	"errors"
 	"log"
-	"time"
+	"strconv"
 
 	"github.com/DailyPepper/auth-service/internal/models"
 	"github.com/DailyPepper/auth-service/pkg/generated/auth"
@@ -20,6 +21,8 @@ func (s *GRPCServer) Register(ctx context.Context, req *auth.RegisterRequest) (*
 		Password:  req.Password,
 		FirstName: req.FirstName,
 		Surname:   req.Surname,
+		Challenge: challengeSolution(req.Challenge),
+		Client:    s.clientInfo(ctx),
 	}
 
 	user, err := s.registrService.Registration(ctx, registrModel)
@@ -40,8 +43,13 @@ func (s *GRPCServer) Login(ctx context.Context, req *auth.LoginRequest) (*auth.L
 	log.Printf("gRPC Login called for email: %s", req.Email)
 
 	loginModel := &models.LoginRequest{
-		Email:    req.Email,
-		Password: req.Password,
+		Email:     req.Email,
+		Password:  req.Password,
+		Challenge: challengeSolution(req.Challenge),
+		Client:    s.clientInfo(ctx),
+
+		OrganizationID: req.OrganizationId,
+		DPoP:           dpopProof(ctx),
 	}
 
 	loginResponse, err := s.registrService.Login(ctx, loginModel)
@@ -52,28 +60,87 @@ func (s *GRPCServer) Login(ctx context.Context, req *auth.LoginRequest) (*auth.L
 	return &auth.LoginResponse{
 		AccessToken:  loginResponse.AccessToken,
 		RefreshToken: loginResponse.RefreshToken,
-		ExpiresAt:    timestamppb.New(time.Now().Add(24 * time.Hour)), // TODO: использовать реальное время истечения
+		ExpiresAt:    timestamppb.New(loginResponse.ExpiresAt),
+		SessionId:    loginResponse.SessionID,
+		TokenType:    loginResponse.TokenType,
 	}, nil
 }
 
 func (s *GRPCServer) ValidateToken(ctx context.Context, req *auth.ValidateTokenRequest) (*auth.ValidateTokenResponse, error) {
 	log.Printf("gRPC ValidateToken called")
 
-	user, err := s.registrService.ValidateToken(ctx, req.Token)
-	if err != nil {
+	claims, user, err := s.registrService.AuthenticateRequest(ctx, req.Token, dpopFromProto(req.Dpop))
+	if err != nil || (req.Audience != "" && !claims.HasAudience(req.Audience)) {
 		return &auth.ValidateTokenResponse{
 			Valid: false,
 		}, nil
 	}
 
-	return &auth.ValidateTokenResponse{
-		Valid:  true,
-		UserId: string(rune(user.ID)),
-		Email:  user.Email,
+	resp := &auth.ValidateTokenResponse{
+		Valid:       true,
+		UserId:      strconv.FormatInt(user.ID, 10),
+		Email:       user.Email,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+
+		OrganizationId:   claims.OrganizationID,
+		OrganizationRole: string(claims.OrganizationRole),
+
+		DpopBound: claims.DPoPThumbprint != "",
+		Audience:  claims.Audience,
+		ClientId:  claims.ClientID,
+		Scopes:    claims.Scopes,
+	}
+	for actor := claims.Actor; actor != nil; actor = actor.Act {
+		resp.Actors = append(resp.Actors, actor.Subject)
+	}
+	if claims.ImpersonatorID != 0 {
+		resp.ImpersonatorId = strconv.FormatInt(claims.ImpersonatorID, 10)
+		// Email - для журналов сервисов; если администратора уже нет, хватит ID
+		if impersonator, err := s.registrService.GetUserProfile(ctx, claims.ImpersonatorID); err == nil {
+			resp.ImpersonatorEmail = impersonator.Email
+		}
+	}
+	return resp, nil
+}
+
+func (s *GRPCServer) ReportUnrecognizedLogin(ctx context.Context, req *auth.ReportUnrecognizedLoginRequest) (*auth.ReportUnrecognizedLoginResponse, error) {
+	log.Printf("gRPC ReportUnrecognizedLogin called")
+
+	resetToken, err := s.deviceService.ReportUnrecognizedLogin(ctx, req.Token)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.ReportUnrecognizedLoginResponse{
+		PasswordResetToken: resetToken,
 	}, nil
 }
 
+func (s *GRPCServer) ResetPassword(ctx context.Context, req *auth.ResetPasswordRequest) (*auth.ResetPasswordResponse, error) {
+	log.Printf("gRPC ResetPassword called")
+
+	err := s.registrService.ResetPassword(ctx, &models.ResetPasswordRequest{
+		Token:       req.Token,
+		NewPassword: req.NewPassword,
+	})
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.ResetPasswordResponse{}, nil
+}
+
 func (s *GRPCServer) mapErrorToStatus(err error) error {
+	var compileErr *models.PolicyCompileError
+	if errors.As(err, &compileErr) {
+		return status.Error(codes.InvalidArgument, compileErr.Error())
+	}
+	var oauthErr *models.OAuthError
+	if errors.As(err, &oauthErr) {
+		return oauthErrorToStatus(oauthErr)
+	}
+
 	switch err {
 	case models.ErrUserAlreadyExists:
 		return status.Error(codes.AlreadyExists, "user with this email already exists")
@@ -81,6 +148,142 @@ func (s *GRPCServer) mapErrorToStatus(err error) error {
 		return status.Error(codes.Unauthenticated, "invalid email or password")
 	case models.ErrUserNotFound:
 		return status.Error(codes.NotFound, "user not found")
+	case models.ErrInvalidToken, models.ErrSessionRevoked:
+		return status.Error(codes.Unauthenticated, "invalid or expired token")
+	case models.ErrInvalidDPoPProof:
+		return status.Error(codes.Unauthenticated, "invalid dpop proof")
+	case models.ErrWorkloadNotAllowed:
+		return status.Error(codes.PermissionDenied, "workload is not allowed to call this method")
+	case models.ErrPasswordResetRequired:
+		return status.Error(codes.FailedPrecondition, "password reset required")
+	case models.ErrInvalidReportToken:
+		return status.Error(codes.InvalidArgument, "invalid or expired link")
+	case models.ErrInvalidResetToken:
+		return status.Error(codes.InvalidArgument, "invalid or expired password reset token")
+	case models.ErrLoginDenied:
+		return status.Error(codes.PermissionDenied, "login denied")
+	case models.ErrCaptchaRequired:
+		return status.Error(codes.FailedPrecondition, "captcha required")
+	case models.ErrMFARequired:
+		return status.Error(codes.FailedPrecondition, "multi-factor authentication required")
+	case models.ErrChallengeFailed:
+		return status.Error(codes.InvalidArgument, "challenge verification failed")
+	case models.ErrPasswordTooWeak:
+		return status.Error(codes.InvalidArgument, "password must be at least 8 characters")
+	case models.ErrPermissionDenied:
+		return status.Error(codes.PermissionDenied, "permission denied")
+	case models.ErrRoleNotFound:
+		return status.Error(codes.NotFound, "role not found")
+	case models.ErrRoleAlreadyExists:
+		return status.Error(codes.AlreadyExists, "role already exists")
+	case models.ErrInvalidRoleName:
+		return status.Error(codes.InvalidArgument, "role name must match [a-z][a-z0-9_-]*")
+	case models.ErrInvalidPermissionName:
+		return status.Error(codes.InvalidArgument, "permission must look like resource:action")
+	case models.ErrUnknownNamespace:
+		return status.Error(codes.InvalidArgument, "unknown namespace")
+	case models.ErrUnknownRelation:
+		return status.Error(codes.InvalidArgument, "unknown relation or permission")
+	case models.ErrInvalidRelationship:
+		return status.Error(codes.InvalidArgument, "invalid relationship")
+	case models.ErrInvalidConsistencyToken:
+		return status.Error(codes.InvalidArgument, "invalid consistency token")
+	case models.ErrRelationDepthExceeded:
+		return status.Error(codes.FailedPrecondition, "relation check depth exceeded")
+	case models.ErrPolicyDenied:
+		return status.Error(codes.PermissionDenied, "denied by policy")
+	case models.ErrPolicyNotFound:
+		return status.Error(codes.NotFound, "policy not found")
+	case models.ErrInvalidPolicy:
+		return status.Error(codes.InvalidArgument, "policy needs a name, an allow/deny effect, actions and an expression")
+	case models.ErrOrganizationNotFound:
+		return status.Error(codes.NotFound, "organization not found")
+	case models.ErrInvalidOrganizationName:
+		return status.Error(codes.InvalidArgument, "organization name is required")
+	case models.ErrInvalidOrganizationRole:
+		return status.Error(codes.InvalidArgument, "organization role must be owner, admin or member")
+	case models.ErrNotOrganizationMember:
+		return status.Error(codes.PermissionDenied, "not a member of the organization")
+	case models.ErrAlreadyOrganizationMember:
+		return status.Error(codes.AlreadyExists, "already a member of the organization")
+	case models.ErrLastOrganizationOwner:
+		return status.Error(codes.FailedPrecondition, "organization must keep at least one owner")
+	case models.ErrInvalidInvitation:
+		return status.Error(codes.InvalidArgument, "invalid or expired invitation")
+	case models.ErrServiceAccountNotFound:
+		return status.Error(codes.NotFound, "service account not found")
+	case models.ErrServiceAccountAlreadyExists:
+		return status.Error(codes.AlreadyExists, "service account already exists")
+	case models.ErrInvalidServiceAccountName:
+		return status.Error(codes.InvalidArgument, "service account name must be lowercase letters, digits, dashes and underscores")
+	case models.ErrAPIKeyNotFound:
+		return status.Error(codes.NotFound, "api key not found")
+	case models.ErrInvalidAPIKey:
+		return status.Error(codes.Unauthenticated, "invalid or expired api key")
+	case models.ErrInvalidAPIKeyScope:
+		return status.Error(codes.InvalidArgument, "api key scopes must look like resource:action")
+	case models.ErrInvalidAPIKeyCIDR:
+		return status.Error(codes.InvalidArgument, "api key ip allowlist must contain addresses or CIDR ranges")
+	case models.ErrInvalidAPIKeyExpiry:
+		return status.Error(codes.InvalidArgument, "api key expiry must be in the future")
+	case models.ErrAPIKeyIPNotAllowed:
+		return status.Error(codes.PermissionDenied, "api key is not allowed from this ip")
+	case models.ErrOAuthClientNotFound:
+		return status.Error(codes.NotFound, "oauth client not found")
+	case models.ErrOAuthClientExists:
+		return status.Error(codes.AlreadyExists, "oauth client already exists")
+	case models.ErrInvalidOAuthClient:
+		return status.Error(codes.InvalidArgument, "oauth client needs a name, grant types, a token lifetime and valid credentials")
+	case models.ErrInvalidUserCode:
+		return status.Error(codes.NotFound, "invalid or expired user code")
+	case models.ErrTooManyUserCodeAttempts:
+		return status.Error(codes.ResourceExhausted, "too many invalid user codes, try again later")
+	case models.ErrIdentityProviderNotFound:
+		return status.Error(codes.NotFound, "identity provider not found")
+	case models.ErrIdentityProviderExists:
+		return status.Error(codes.AlreadyExists, "identity provider already exists")
+	case models.ErrInvalidIdentityProvider:
+		return status.Error(codes.InvalidArgument, "identity provider needs a slug, a name and either an http(s) issuer with a client id or signed saml metadata")
+	case models.ErrSAMLNotConfigured:
+		return status.Error(codes.FailedPrecondition, "saml service provider is not configured")
+	case models.ErrFederationFailed:
+		return status.Error(codes.Unauthenticated, "federated login failed")
+	case models.ErrIdentityNotLinked:
+		return status.Error(codes.FailedPrecondition, "external identity is not linked to a user")
+	case models.ErrIdentityNotFound:
+		return status.Error(codes.NotFound, "identity not found")
+	case models.ErrIdentityAlreadyLinked:
+		return status.Error(codes.AlreadyExists, "external identity is already linked to a user")
+	case models.ErrLastLoginMethod:
+		return status.Error(codes.FailedPrecondition, "cannot remove the last login method")
+	case models.ErrReauthenticationRequired:
+		return status.Error(codes.Unauthenticated, "reauthentication required: pass the current password or sign in again")
+	case models.ErrInvalidMerge:
+		return status.Error(codes.InvalidArgument, "users to merge must be different and not already merged")
+	case models.ErrImpersonationForbidden:
+		return status.Error(codes.PermissionDenied, "this user cannot be impersonated")
+	case models.ErrInvalidImpersonationReason:
+		return status.Error(codes.InvalidArgument, "impersonation requires a reason of at most 500 characters")
+	case models.ErrImpersonatedSession:
+		return status.Error(codes.PermissionDenied, "not allowed while impersonating a user")
+	case models.ErrInvalidDomain:
+		return status.Error(codes.InvalidArgument, "invalid domain; enforcing single sign-on requires a provider")
+	case models.ErrDomainNotFound:
+		return status.Error(codes.NotFound, "domain not found")
+	case models.ErrDomainClaimed:
+		return status.Error(codes.AlreadyExists, "domain is already verified by another owner")
+	case models.ErrDomainNotVerified:
+		return status.Error(codes.FailedPrecondition, "domain verification TXT record not found")
+	case models.ErrSSORequired:
+		return status.Error(codes.FailedPrecondition, "single sign-on is required for this email domain")
+	case models.ErrTenantNotFound:
+		return status.Error(codes.NotFound, "tenant not found")
+	case models.ErrTenantRequired:
+		return status.Error(codes.InvalidArgument, "tenant is required (x-tenant metadata)")
+	case models.ErrTenantAlreadyExists:
+		return status.Error(codes.AlreadyExists, "tenant already exists")
+	case models.ErrInvalidTenantSlug:
+		return status.Error(codes.InvalidArgument, "tenant slug must be lowercase letters, digits and dashes")
 	default:
 		log.Printf("Internal error: %v", err)
 		return status.Error(codes.Internal, "internal server error")
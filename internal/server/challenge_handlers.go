@@ -0,0 +1,38 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/generated/auth"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *GRPCServer) GetChallenge(ctx context.Context, req *auth.GetChallengeRequest) (*auth.GetChallengeResponse, error) {
+	log.Printf("gRPC GetChallenge called")
+
+	challenge, err := s.challengeService.Issue(ctx)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	resp := &auth.GetChallengeResponse{
+		Type:       string(challenge.Type),
+		Challenge:  challenge.Challenge,
+		Difficulty: int32(challenge.Difficulty),
+		SiteKey:    challenge.SiteKey,
+	}
+	if !challenge.ExpiresAt.IsZero() {
+		resp.ExpiresAt = timestamppb.New(challenge.ExpiresAt)
+	}
+
+	return resp, nil
+}
+
+func challengeSolution(solution *auth.ChallengeSolution) models.ChallengeSolution {
+	return models.ChallengeSolution{
+		Challenge: solution.GetChallenge(),
+		Solution:  solution.GetSolution(),
+	}
+}
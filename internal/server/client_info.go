@@ -0,0 +1,73 @@
+package server
+
+import (
+	"context"
+	"net"
+	"strings"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+)
+
+// Метаданные, которые клиенты передают о себе
+const (
+	mdForwardedFor = "x-forwarded-for"
+	mdUserAgent    = "x-user-agent"
+	mdDeviceID     = "x-device-id"
+	mdTenant       = "x-tenant"
+)
+
+// clientInfo собирает IP, User-Agent и ID устройства из контекста запроса
+func (s *GRPCServer) clientInfo(ctx context.Context) models.ClientInfo {
+	var info models.ClientInfo
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			info.IP = host
+		}
+	}
+
+	md, ok := metadata.FromIncomingContext(ctx)
+	if !ok {
+		return info
+	}
+
+	// Браузерные клиенты через прокси передают настоящий User-Agent отдельно
+	info.UserAgent = firstMetadata(md, mdUserAgent)
+	if info.UserAgent == "" {
+		info.UserAgent = firstMetadata(md, "user-agent")
+	}
+	info.DeviceID = firstMetadata(md, mdDeviceID)
+
+	if s.cfg.TrustForwardedFor {
+		if forwarded := firstMetadata(md, mdForwardedFor); forwarded != "" {
+			info.IP = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	return info
+}
+
+func firstMetadata(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// resolveTenant определяет тенант по метаданным x-tenant, без них - тенант по умолчанию
+func (s *GRPCServer) resolveTenant(ctx context.Context) (*models.Tenant, error) {
+	var slug string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		slug = firstMetadata(md, mdTenant)
+	}
+	if slug == "" {
+		slug = s.cfg.DefaultTenant
+	}
+	if slug == "" {
+		return nil, models.ErrTenantRequired
+	}
+
+	return s.tenantService.Resolve(ctx, slug)
+}
@@ -0,0 +1,112 @@
+package server
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+)
+
+// handleDevice - страница подтверждения входа устройства (RFC 8628, 3.3). Пользователь
+// входит, если еще не вошел, вводит код с экрана устройства и разрешает или отклоняет вход.
+// verification_uri_complete приходит с user_code в query.
+func (s *HTTPServer) handleDevice(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderErrorPage(w, http.StatusBadRequest, "The request is malformed.")
+		return
+	}
+	if r.Method == http.MethodPost && !validCSRF(r) {
+		renderErrorPage(w, http.StatusForbidden, "The form has expired. Please start over.")
+		return
+	}
+	userCode := strings.TrimSpace(r.Form.Get("user_code"))
+
+	session := s.browserSession(r)
+	if r.Method == http.MethodPost && r.PostForm.Has("password") {
+		email := strings.TrimSpace(r.PostForm.Get("email"))
+		var cookie string
+		var err error
+		session, cookie, err = s.oidcService.Login(r.Context(), email, r.PostForm.Get("password"), s.clientInfo(r))
+		if err != nil {
+			message := loginErrorMessage(err)
+			if message == "" {
+				log.Printf("Internal error: %v", err)
+				renderErrorPage(w, http.StatusInternalServerError, "Sign-in is temporarily unavailable.")
+				return
+			}
+			renderPage(w, http.StatusUnauthorized, "device_login", &oidcPage{
+				Title:    "Sign in",
+				CSRF:     s.csrfToken(w, r),
+				UserCode: userCode,
+				Email:    email,
+				Error:    message,
+			})
+			return
+		}
+		s.setCookie(w, sessionCookieName, cookie, session.ExpiresAt)
+	}
+
+	if session == nil {
+		renderPage(w, http.StatusOK, "device_login", &oidcPage{
+			Title:    "Sign in",
+			CSRF:     s.csrfToken(w, r),
+			UserCode: userCode,
+		})
+		return
+	}
+	if userCode == "" {
+		renderPage(w, http.StatusOK, "device_code", &oidcPage{Title: "Connect a device", CSRF: s.csrfToken(w, r)})
+		return
+	}
+
+	if decision := r.PostForm.Get("decision"); decision != "" {
+		approved := decision == "allow"
+		if _, err := s.oauthService.DecideDeviceAuthorization(r.Context(), session.UserID, userCode, approved, s.clientInfo(r)); err != nil {
+			s.renderDeviceError(w, r, userCode, err)
+			return
+		}
+		if approved {
+			renderPage(w, http.StatusOK, "device_approved", &oidcPage{Title: "Device connected"})
+		} else {
+			renderPage(w, http.StatusOK, "device_denied", &oidcPage{Title: "Request denied"})
+		}
+		return
+	}
+
+	authorization, client, err := s.oauthService.PendingDeviceAuthorization(r.Context(), session.UserID, userCode, s.clientInfo(r))
+	if err != nil {
+		s.renderDeviceError(w, r, userCode, err)
+		return
+	}
+	renderPage(w, http.StatusOK, "device_consent", &oidcPage{
+		Title:      "Connect a device",
+		ClientName: client.Name,
+		CSRF:       s.csrfToken(w, r),
+		UserCode:   userCode,
+		Scopes:     describeScopes(authorization.Scopes),
+	})
+}
+
+// renderDeviceError: неверный код - повторный ввод, отказ во входе - сообщение
+func (s *HTTPServer) renderDeviceError(w http.ResponseWriter, r *http.Request, userCode string, err error) {
+	if err == models.ErrTooManyUserCodeAttempts {
+		renderErrorPage(w, http.StatusTooManyRequests, "Too many invalid codes. Please wait a few minutes and try again.")
+		return
+	}
+	if err == models.ErrInvalidUserCode {
+		renderPage(w, http.StatusBadRequest, "device_code", &oidcPage{
+			Title:    "Connect a device",
+			CSRF:     s.csrfToken(w, r),
+			UserCode: userCode,
+			Error:    "This code is invalid or has expired. Check the code on your device.",
+		})
+		return
+	}
+	if message := loginErrorMessage(err); message != "" {
+		renderErrorPage(w, http.StatusForbidden, message)
+		return
+	}
+	log.Printf("Internal error: %v", err)
+	renderErrorPage(w, http.StatusInternalServerError, "The request could not be processed.")
+}
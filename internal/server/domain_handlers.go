@@ -0,0 +1,134 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/generated/auth"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *GRPCServer) AddDomain(ctx context.Context, req *auth.AddDomainRequest) (*auth.AddDomainResponse, error) {
+	log.Printf("gRPC AddDomain called: %s", req.Domain)
+
+	_, user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	domain, err := s.domainService.AddDomain(ctx, user.ID, &models.Domain{
+		OrganizationID: organizationIDFromProto(req.OrganizationId),
+		Domain:         req.Domain,
+		Provider:       req.Provider,
+		SSOEnforced:    req.SsoEnforced,
+	})
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.AddDomainResponse{Domain: domainToProto(domain)}, nil
+}
+
+func (s *GRPCServer) VerifyDomain(ctx context.Context, req *auth.VerifyDomainRequest) (*auth.VerifyDomainResponse, error) {
+	log.Printf("gRPC VerifyDomain called for domain: %d", req.Id)
+
+	_, user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	domain, err := s.domainService.VerifyDomain(ctx, user.ID, req.Id)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.VerifyDomainResponse{Domain: domainToProto(domain)}, nil
+}
+
+func (s *GRPCServer) ListDomains(ctx context.Context, req *auth.ListDomainsRequest) (*auth.ListDomainsResponse, error) {
+	log.Printf("gRPC ListDomains called for organization: %d", req.OrganizationId)
+
+	_, user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	domains, err := s.domainService.ListDomains(ctx, user.ID, organizationIDFromProto(req.OrganizationId))
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	resp := &auth.ListDomainsResponse{}
+	for _, domain := range domains {
+		resp.Domains = append(resp.Domains, domainToProto(domain))
+	}
+	return resp, nil
+}
+
+func (s *GRPCServer) ConfigureDomainSso(ctx context.Context, req *auth.ConfigureDomainSsoRequest) (*auth.ConfigureDomainSsoResponse, error) {
+	log.Printf("gRPC ConfigureDomainSso called for domain: %d", req.Id)
+
+	_, user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	domain, err := s.domainService.ConfigureSSO(ctx, user.ID, req.Id, models.DomainSSO{
+		Provider:    req.Provider,
+		SSOEnforced: req.SsoEnforced,
+	})
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.ConfigureDomainSsoResponse{Domain: domainToProto(domain)}, nil
+}
+
+func (s *GRPCServer) DiscoverLoginMethod(ctx context.Context, req *auth.DiscoverLoginMethodRequest) (*auth.DiscoverLoginMethodResponse, error) {
+	log.Printf("gRPC DiscoverLoginMethod called")
+
+	discovery, err := s.domainService.DiscoverLoginMethod(ctx, req.Email)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	resp := &auth.DiscoverLoginMethodResponse{
+		Method:       discovery.Method,
+		Provider:     discovery.Provider,
+		ProviderName: discovery.ProviderName,
+		SsoEnforced:  discovery.SSOEnforced,
+	}
+	if discovery.OrganizationID != nil {
+		resp.OrganizationId = *discovery.OrganizationID
+	}
+	return resp, nil
+}
+
+// В protobuf 0 означает домен всего тенанта
+func organizationIDFromProto(id int64) *int64 {
+	if id == 0 {
+		return nil
+	}
+	return &id
+}
+
+func domainToProto(domain *models.Domain) *auth.Domain {
+	item := &auth.Domain{
+		Id:             domain.ID,
+		Domain:         domain.Domain,
+		Provider:       domain.Provider,
+		SsoEnforced:    domain.SSOEnforced,
+		Verified:       domain.Verified(),
+		ChallengeName:  domain.ChallengeName(),
+		ChallengeValue: domain.ChallengeValue(),
+		CreatedAt:      timestamppb.New(domain.CreatedAt),
+	}
+	if domain.OrganizationID != nil {
+		item.OrganizationId = *domain.OrganizationID
+	}
+	if domain.VerifiedAt != nil {
+		item.VerifiedAt = timestamppb.New(*domain.VerifiedAt)
+	}
+	return item
+}
@@ -0,0 +1,163 @@
+package server
+
+import (
+	"crypto/subtle"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+)
+
+// handleFederationStart отправляет браузер к внешнему провайдеру. Исходный запрос
+// авторизации (authz) возвращается после входа через сохраненный state.
+func (s *HTTPServer) handleFederationStart(w http.ResponseWriter, r *http.Request) {
+	authz := r.URL.Query().Get("authz")
+	if _, err := url.ParseQuery(authz); err != nil {
+		renderErrorPage(w, http.StatusBadRequest, "The authorization request is malformed.")
+		return
+	}
+
+	redirectURL, state, err := s.federationService.Begin(r.Context(), r.PathValue("provider"), authz)
+	if err == models.ErrIdentityProviderNotFound || err == models.ErrSAMLNotConfigured {
+		renderErrorPage(w, http.StatusNotFound, loginErrorMessage(err))
+		return
+	}
+	if err != nil {
+		log.Printf("Internal error: %v", err)
+		renderErrorPage(w, http.StatusBadGateway, "The external provider is unavailable.")
+		return
+	}
+
+	// state привязан к браузеру: иначе чужой ответ провайдера можно было бы
+	// подсунуть жертве и войти ей в аккаунт атакующего
+	s.setFederationCookie(w, state, time.Now().Add(s.cfg.FederationStateTTL))
+	setNoStore(w)
+	http.Redirect(w, r, redirectURL, http.StatusFound)
+}
+
+// handleFederationCallback принимает ответ провайдера OpenID Connect и продолжает исходный запрос авторизации
+func (s *HTTPServer) handleFederationCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	s.completeFederation(w, r, &models.FederationCallback{
+		State: query.Get("state"),
+		Code:  query.Get("code"),
+		Error: query.Get("error"),
+	})
+}
+
+// handleSAMLACS - Assertion Consumer Service: ответ SAML-провайдера (HTTP-POST binding),
+// state возвращается в RelayState
+func (s *HTTPServer) handleSAMLACS(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderErrorPage(w, http.StatusBadRequest, "The sign-in response is malformed.")
+		return
+	}
+	s.completeFederation(w, r, &models.FederationCallback{
+		State:        r.PostForm.Get("RelayState"),
+		SAMLResponse: r.PostForm.Get("SAMLResponse"),
+	})
+}
+
+// handleSAMLMetadata отдает метаданные SP для настройки SAML-провайдера
+func (s *HTTPServer) handleSAMLMetadata(w http.ResponseWriter, r *http.Request) {
+	metadata, err := s.federationService.SAMLMetadata()
+	if err == models.ErrSAMLNotConfigured {
+		http.NotFound(w, r)
+		return
+	}
+	if err != nil {
+		log.Printf("Internal error: %v", err)
+		http.Error(w, "internal error", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/samlmetadata+xml")
+	w.Write(metadata)
+}
+
+func (s *HTTPServer) completeFederation(w http.ResponseWriter, r *http.Request, callback *models.FederationCallback) {
+	cookie, err := r.Cookie(federationCookieName)
+	if err != nil || callback.State == "" || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(callback.State)) != 1 {
+		renderErrorPage(w, http.StatusForbidden, "The sign-in request has expired. Please start over.")
+		return
+	}
+	s.setFederationCookie(w, "", time.Time{})
+
+	callback.Client = s.clientInfo(r)
+	resp, returnTo, err := s.federationService.Complete(r.Context(), callback)
+
+	var req *models.AuthorizationRequest
+	if values, parseErr := url.ParseQuery(returnTo); parseErr == nil && returnTo != "" {
+		req, _ = parseAuthorizationRequest(values)
+	}
+
+	if err != nil {
+		message := loginErrorMessage(err)
+		if message == "" {
+			log.Printf("Internal error: %v", err)
+			renderErrorPage(w, http.StatusInternalServerError, "Sign-in is temporarily unavailable.")
+			return
+		}
+		if req == nil {
+			renderErrorPage(w, http.StatusUnauthorized, message)
+			return
+		}
+		result, err := s.oidcService.Authorize(r.Context(), req, nil)
+		s.respondAuthorization(w, r, result, err, "", message)
+		return
+	}
+
+	session, sessionCookie, err := s.oidcService.StartSession(r.Context(), resp)
+	if err != nil {
+		log.Printf("Internal error: %v", err)
+		renderErrorPage(w, http.StatusInternalServerError, "Sign-in is temporarily unavailable.")
+		return
+	}
+	s.setCookie(w, sessionCookieName, sessionCookie, session.ExpiresAt)
+
+	if req == nil {
+		renderPage(w, http.StatusOK, "signed_in", &oidcPage{Title: "Signed in"})
+		return
+	}
+
+	// Провайдер только что проверил пользователя: prompt=login и max_age выполнены
+	req.LoginCompleted = true
+	result, err := s.oidcService.Authorize(r.Context(), req, session)
+	s.respondAuthorization(w, r, result, err, "", "")
+}
+
+// identityProviders - кнопки входа через провайдеров тенанта; без них форма входа все равно работает
+func (s *HTTPServer) identityProviders(r *http.Request) []*models.IdentityProvider {
+	providers, err := s.federationService.ListProviders(r.Context())
+	if err != nil {
+		log.Printf("Failed to list identity providers: %v", err)
+		return nil
+	}
+	return providers
+}
+
+// setFederationCookie ставит cookie с state входа через провайдера. SAML-провайдер
+// возвращает браузер межсайтовым POST, с которым cookie SameSite=Lax не отправляется,
+// поэтому по HTTPS используется SameSite=None: в cookie только случайный state.
+func (s *HTTPServer) setFederationCookie(w http.ResponseWriter, state string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     federationCookieName,
+		Value:    state,
+		Path:     "/oauth2/",
+		Secure:   strings.HasPrefix(s.cfg.IssuerURL, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if cookie.Secure {
+		cookie.SameSite = http.SameSiteNoneMode
+	}
+	if state == "" {
+		cookie.MaxAge = -1
+	} else {
+		cookie.Expires = expires
+	}
+	http.SetCookie(w, cookie)
+}
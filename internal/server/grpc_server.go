@@ -5,42 +5,153 @@ import (
 	"log"
 	"net"
 
+	"github.com/DailyPepper/auth-service/config"
 	"github.com/DailyPepper/auth-service/internal/service"
 	"github.com/DailyPepper/auth-service/pkg/generated/auth"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 type GRPCServer struct {
 	auth.UnimplementedAuthServiceServer
-	registrService service.Registr
-	server         *grpc.Server
+	cfg                 *config.Config
+	registrService      service.Registr
+	deviceService       service.Devices
+	sessionService      service.Sessions
+	challengeService    service.Challenges
+	rbacService         service.RBAC
+	relationService     service.Relations
+	policyService       service.Policies
+	tenantService       service.Tenants
+	organizationService service.Organizations
+	apiKeyService       service.APIKeys
+	oauthService        service.OAuth
+	identityService     service.Identities
+	domainService       service.Domains
+	server              *grpc.Server
+	tlsReloader         *tlsReloader
+	workloadPolicy      *workloadPolicy
 }
 
-func NewGRPCServer(registrService service.Registr) *GRPCServer {
+func NewGRPCServer(
+	cfg *config.Config,
+	registrService service.Registr,
+	deviceService service.Devices,
+	sessionService service.Sessions,
+	challengeService service.Challenges,
+	rbacService service.RBAC,
+	relationService service.Relations,
+	policyService service.Policies,
+	tenantService service.Tenants,
+	organizationService service.Organizations,
+	apiKeyService service.APIKeys,
+	oauthService service.OAuth,
+	identityService service.Identities,
+	domainService service.Domains,
+) *GRPCServer {
 	return &GRPCServer{
-		registrService: registrService,
+		cfg:                 cfg,
+		registrService:      registrService,
+		deviceService:       deviceService,
+		sessionService:      sessionService,
+		challengeService:    challengeService,
+		rbacService:         rbacService,
+		relationService:     relationService,
+		policyService:       policyService,
+		tenantService:       tenantService,
+		organizationService: organizationService,
+		apiKeyService:       apiKeyService,
+		oauthService:        oauthService,
+		identityService:     identityService,
+		domainService:       domainService,
 	}
 }
 
-func (s *GRPCServer) Start(port string) error {
-	lis, err := net.Listen("tcp", ":"+port)
+func (s *GRPCServer) Start(addr string) error {
+	// CA клиентов без сертификата сервера означал бы открытый канал вместо ожидаемого mTLS
+	if s.cfg.TLSClientCAPath != "" && s.cfg.TLSCertPath == "" {
+		return fmt.Errorf("TLS_CLIENT_CA_PATH requires TLS (TLS_CERT_PATH)")
+	}
+
+	if s.cfg.SPIFFEPolicyPath != "" {
+		// SPIFFE ID берется из клиентского сертификата - без mTLS политика бессмысленна
+		if s.cfg.TLSClientCAPath == "" {
+			return fmt.Errorf("SPIFFE_POLICY_PATH requires mTLS (TLS_CLIENT_CA_PATH)")
+		}
+
+		policy, err := loadWorkloadPolicy(s.cfg.SPIFFEPolicyPath)
+		if err != nil {
+			return err
+		}
+		s.workloadPolicy = policy
+		log.Printf("🪪 SPIFFE workload policy loaded from %s (%d rules)", s.cfg.SPIFFEPolicyPath, len(policy.Rules))
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.UnaryInterceptor(s.unaryInterceptor()),
+	}
+
+	creds, err := s.transportCredentials()
+	if err != nil {
+		return err
+	}
+	if creds != nil {
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	lis, err := net.Listen("tcp", addr)
 	if err != nil {
 		return fmt.Errorf("failed to listen: %v", err)
 	}
 
-	s.server = grpc.NewServer(
-		grpc.UnaryInterceptor(s.unaryInterceptor()),
-	)
+	s.server = grpc.NewServer(opts...)
 
 	auth.RegisterAuthServiceServer(s.server, s)
 
-	log.Printf("gRPC server starting on port %s", port)
+	log.Printf("gRPC server starting on %s", addr)
 	return s.server.Serve(lis)
 }
 
+// transportCredentials включает TLS (и mTLS, если задан CA клиентов).
+// Без сертификата сервер работает в открытом виде - только для локальной разработки.
+func (s *GRPCServer) transportCredentials() (credentials.TransportCredentials, error) {
+	if s.cfg.TLSCertPath == "" {
+		log.Printf("⚠️  TLS is disabled: TLS_CERT_PATH is not set, traffic is not encrypted")
+		return nil, nil
+	}
+
+	reloader, err := newTLSReloader(s.cfg.TLSCertPath, s.cfg.TLSKeyPath, s.cfg.TLSClientCAPath)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsConfig, err := reloader.tlsConfig(s.cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	s.tlsReloader = reloader
+	if s.cfg.TLSReloadInterval > 0 {
+		go reloader.watch(s.cfg.TLSReloadInterval)
+	} else {
+		log.Printf("⚠️  TLS certificate reload is disabled: TLS_RELOAD_INTERVAL is not positive")
+	}
+
+	if s.cfg.TLSClientCAPath != "" {
+		log.Printf("🔐 mTLS enabled, client CA bundle: %s", s.cfg.TLSClientCAPath)
+	} else {
+		log.Printf("🔐 TLS enabled")
+	}
+
+	return credentials.NewTLS(tlsConfig), nil
+}
+
 func (s *GRPCServer) Stop() {
 	if s.server != nil {
 		s.server.GracefulStop()
 		log.Println("gRPC server stopped")
 	}
+	if s.tlsReloader != nil {
+		s.tlsReloader.Close()
+	}
 }
@@ -0,0 +1,214 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/config"
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/service"
+)
+
+const httpShutdownTimeout = 10 * time.Second
+
+// HTTPServer обслуживает протокольные эндпоинты OAuth 2.0 и OpenID Connect, которым нужен HTTP
+type HTTPServer struct {
+	cfg               *config.Config
+	tenantService     service.Tenants
+	oauthService      service.OAuth
+	oidcService       service.OIDC
+	federationService service.Federation
+	server            *http.Server
+	tlsReloader       *tlsReloader
+}
+
+func NewHTTPServer(cfg *config.Config, tenantService service.Tenants, oauthService service.OAuth, oidcService service.OIDC, federationService service.Federation) *HTTPServer {
+	return &HTTPServer{
+		cfg:               cfg,
+		tenantService:     tenantService,
+		oauthService:      oauthService,
+		oidcService:       oidcService,
+		federationService: federationService,
+	}
+}
+
+func (s *HTTPServer) Start(addr string) error {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /oauth2/token", s.handleToken)
+	mux.HandleFunc("POST /oauth2/device_authorization", s.handleDeviceAuthorization)
+
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.handleDiscovery)
+	mux.HandleFunc("GET /.well-known/jwks.json", s.handleJWKS)
+	mux.HandleFunc("GET /oauth2/authorize", s.handleAuthorize)
+	mux.HandleFunc("POST /oauth2/authorize", s.handleAuthorize)
+	mux.HandleFunc("POST /oauth2/login", s.handleLogin)
+	mux.HandleFunc("POST /oauth2/consent", s.handleConsent)
+	mux.HandleFunc("GET /oauth2/device", s.handleDevice)
+	mux.HandleFunc("POST /oauth2/device", s.handleDevice)
+	mux.HandleFunc("GET /oauth2/userinfo", s.handleUserInfo)
+	mux.HandleFunc("POST /oauth2/userinfo", s.handleUserInfo)
+	mux.HandleFunc("GET /oauth2/logout", s.handleEndSession)
+	mux.HandleFunc("POST /oauth2/logout", s.handleEndSession)
+	mux.HandleFunc("GET /oauth2/federation/{provider}", s.handleFederationStart)
+	mux.HandleFunc("GET /oauth2/federation/callback", s.handleFederationCallback)
+	mux.HandleFunc("GET /oauth2/saml/metadata", s.handleSAMLMetadata)
+	mux.HandleFunc("POST /oauth2/saml/acs", s.handleSAMLACS)
+
+	s.server = &http.Server{
+		Handler:           s.withTenant(mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	lis, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+
+	// Тот же сертификат, что и у gRPC, но без mTLS: клиенты OAuth аутентифицируются сами
+	if s.cfg.TLSCertPath != "" {
+		reloader, err := newTLSReloader(s.cfg.TLSCertPath, s.cfg.TLSKeyPath, "")
+		if err != nil {
+			return err
+		}
+		tlsConfig, err := reloader.tlsConfig(s.cfg)
+		if err != nil {
+			return err
+		}
+		s.tlsReloader = reloader
+		go reloader.watch(s.cfg.TLSReloadInterval)
+
+		s.server.TLSConfig = tlsConfig
+		log.Printf("HTTP server starting on %s (TLS)", addr)
+		err = s.server.ServeTLS(lis, "", "")
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	}
+
+	log.Printf("HTTP server starting on %s", addr)
+	err = s.server.Serve(lis)
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *HTTPServer) Stop() {
+	if s.server != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), httpShutdownTimeout)
+		defer cancel()
+		if err := s.server.Shutdown(ctx); err != nil {
+			log.Printf("HTTP server shutdown: %v", err)
+		}
+		log.Println("HTTP server stopped")
+	}
+	if s.tlsReloader != nil {
+		s.tlsReloader.Close()
+	}
+}
+
+// withTenant определяет тенант по заголовку X-Tenant, как интерцептор gRPC по x-tenant
+func (s *HTTPServer) withTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		log.Printf("📨 HTTP %s %s", r.Method, r.URL.Path)
+
+		slug := r.Header.Get("X-Tenant")
+		if slug == "" {
+			slug = s.cfg.DefaultTenant
+		}
+		if slug == "" {
+			writeOAuthError(w, models.NewOAuthError(models.OAuthInvalidRequest, "tenant is required"), "")
+			return
+		}
+
+		tenant, err := s.tenantService.Resolve(r.Context(), slug)
+		if err == models.ErrTenantNotFound {
+			writeOAuthError(w, models.NewOAuthError(models.OAuthInvalidRequest, "unknown tenant"), "")
+			return
+		}
+		if err != nil {
+			writeServerError(w, err)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(models.WithTenant(r.Context(), tenant)))
+	})
+}
+
+// clientInfo собирает IP и User-Agent браузера, как GRPCServer.clientInfo для gRPC
+func (s *HTTPServer) clientInfo(r *http.Request) models.ClientInfo {
+	var info models.ClientInfo
+
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		info.IP = host
+	}
+	info.UserAgent = r.UserAgent()
+
+	if s.cfg.TrustForwardedFor {
+		if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
+			info.IP = strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	return info
+}
+
+// dpopProof - заголовок DPoP запроса; nil, если его нет. htu сверяется с внешним
+// адресом провайдера: за прокси Host запроса может быть другим. Заголовок должен
+// быть ровно один (RFC 9449, 4.3): пустое доказательство не пройдет проверку.
+func (s *HTTPServer) dpopProof(r *http.Request) *models.DPoPRequest {
+	values := r.Header.Values("DPoP")
+	if len(values) == 0 {
+		return nil
+	}
+
+	proof := &models.DPoPRequest{Method: r.Method, URI: s.oidcService.Issuer() + r.URL.Path}
+	if len(values) == 1 {
+		proof.Proof = values[0]
+	}
+	return proof
+}
+
+// Ответы с токенами и данными пользователя не кешируются (RFC 6749, 5.1)
+func setNoStore(w http.ResponseWriter) {
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+}
+
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Printf("Failed to write HTTP response: %v", err)
+	}
+}
+
+// writeOAuthError отвечает ошибкой RFC 6749, 5.2. authScheme - схема, которой
+// клиент аутентифицировался в заголовке Authorization (для 401 invalid_client)
+func writeOAuthError(w http.ResponseWriter, oauthErr *models.OAuthError, authScheme string) {
+	status := http.StatusBadRequest
+	if oauthErr.Code == models.OAuthInvalidClient && authScheme != "" {
+		status = http.StatusUnauthorized
+		w.Header().Set("WWW-Authenticate", authScheme+` realm="oauth2"`)
+	}
+
+	body := map[string]string{"error": oauthErr.Code}
+	if oauthErr.Description != "" {
+		body["error_description"] = oauthErr.Description
+	}
+	setNoStore(w)
+	writeJSON(w, status, body)
+}
+
+func writeServerError(w http.ResponseWriter, err error) {
+	log.Printf("Internal error: %v", err)
+	setNoStore(w)
+	writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "server_error"})
+}
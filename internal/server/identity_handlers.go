@@ -0,0 +1,114 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/generated/auth"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *GRPCServer) ListIdentities(ctx context.Context, req *auth.ListIdentitiesRequest) (*auth.ListIdentitiesResponse, error) {
+	log.Printf("gRPC ListIdentities called")
+
+	_, user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	methods, err := s.identityService.ListIdentities(ctx, user.ID)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	resp := &auth.ListIdentitiesResponse{HasPassword: methods.Password}
+	for _, identity := range methods.Identities {
+		resp.Identities = append(resp.Identities, identityToProto(identity))
+	}
+	return resp, nil
+}
+
+func (s *GRPCServer) LinkIdentity(ctx context.Context, req *auth.LinkIdentityRequest) (*auth.LinkIdentityResponse, error) {
+	log.Printf("gRPC LinkIdentity called for provider: %s", req.Provider)
+
+	claims, user, err := s.authenticateOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := s.identityService.LinkIdentity(ctx, user.ID, claims.SessionID, &models.LinkIdentityRequest{
+		Provider: req.Provider,
+		IDToken:  req.IdToken,
+		Password: req.Password,
+	})
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.LinkIdentityResponse{Identity: identityToProto(identity)}, nil
+}
+
+func (s *GRPCServer) UnlinkIdentity(ctx context.Context, req *auth.UnlinkIdentityRequest) (*auth.UnlinkIdentityResponse, error) {
+	log.Printf("gRPC UnlinkIdentity called for identity: %d", req.Id)
+
+	_, user, err := s.authenticateOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.identityService.UnlinkIdentity(ctx, user.ID, req.Id); err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.UnlinkIdentityResponse{}, nil
+}
+
+func (s *GRPCServer) MergeUsers(ctx context.Context, req *auth.MergeUsersRequest) (*auth.MergeUsersResponse, error) {
+	log.Printf("gRPC MergeUsers called: user %d into %d", req.SourceUserId, req.TargetUserId)
+
+	admin, err := s.requirePermission(ctx, models.PermissionUsersMerge)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.identityService.MergeUsers(ctx, admin.ID, req.SourceUserId, req.TargetUserId); err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.MergeUsersResponse{}, nil
+}
+
+func (s *GRPCServer) Impersonate(ctx context.Context, req *auth.ImpersonateRequest) (*auth.ImpersonateResponse, error) {
+	log.Printf("gRPC Impersonate called for user: %d", req.UserId)
+
+	admin, err := s.requirePermission(ctx, models.PermissionUsersImpersonate)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.registrService.Impersonate(ctx, admin, req.UserId, req.Reason, s.clientInfo(ctx))
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.ImpersonateResponse{
+		AccessToken: resp.AccessToken,
+		ExpiresAt:   timestamppb.New(resp.ExpiresAt),
+		SessionId:   resp.SessionID,
+	}, nil
+}
+
+func identityToProto(identity *models.Identity) *auth.Identity {
+	item := &auth.Identity{
+		Id:        identity.ID,
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: timestamppb.New(identity.CreatedAt),
+	}
+	if identity.LastLoginAt != nil {
+		item.LastLoginAt = timestamppb.New(*identity.LastLoginAt)
+	}
+	return item
+}
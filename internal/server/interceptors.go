@@ -4,6 +4,7 @@ import (
 	"context"
 	"log"
 
+	"github.com/DailyPepper/auth-service/internal/models"
 	"google.golang.org/grpc"
 )
 
@@ -11,8 +12,30 @@ func (s *GRPCServer) unaryInterceptor() grpc.UnaryServerInterceptor {
 	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
 		log.Printf("📨 gRPC method called: %s", info.FullMethod)
 
+		// Личность клиента mTLS доступна обработчикам через PeerIdentityFromContext
+		identity := peerIdentity(ctx)
+		if identity != nil {
+			log.Printf("🔐 mTLS peer: %s %s", identity.Subject, identity.SPIFFEID)
+			ctx = withPeerIdentity(ctx, identity)
+		}
+
+		// Какие методы может вызывать рабочая нагрузка
+		if s.workloadPolicy != nil {
+			if err := s.workloadPolicy.authorize(info.FullMethod, identity); err != nil {
+				log.Printf("⛔ gRPC method %s denied for workload %+v", info.FullMethod, identity)
+				return nil, s.mapErrorToStatus(err)
+			}
+		}
+
+		// Все данные пользователей принадлежат тенанту запроса
+		tenant, err := s.resolveTenant(ctx)
+		if err != nil {
+			log.Printf("⛔ gRPC method %s rejected: %v", info.FullMethod, err)
+			return nil, s.mapErrorToStatus(err)
+		}
+		ctx = models.WithTenant(ctx, tenant)
+
 		// Можно добавить дополнительную логику:
-		// - Аутентификацию
 		// - Валидацию
 		// - Метрики
 		// - Трассировку
@@ -0,0 +1,284 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/generated/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Token - token endpoint OAuth 2.0 для клиентов gRPC
+func (s *GRPCServer) Token(ctx context.Context, req *auth.TokenRequest) (*auth.TokenResponse, error) {
+	log.Printf("gRPC Token called: grant %s, client %s", req.GrantType, req.ClientId)
+
+	resp, err := s.oauthService.Token(ctx, &models.TokenRequest{
+		GrantType:           req.GrantType,
+		ClientID:            req.ClientId,
+		ClientSecret:        req.ClientSecret,
+		ClientAssertionType: req.ClientAssertionType,
+		ClientAssertion:     req.ClientAssertion,
+		Scope:               req.Scope,
+		Audience:            req.Audience,
+		Code:                req.Code,
+		RedirectURI:         req.RedirectUri,
+		CodeVerifier:        req.CodeVerifier,
+		RefreshToken:        req.RefreshToken,
+		DeviceCode:          req.DeviceCode,
+		DPoP:                dpopProof(ctx),
+	})
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return tokenResponseToProto(resp), nil
+}
+
+// TokenExchange - обмен токена (RFC 8693) для клиентов gRPC
+func (s *GRPCServer) TokenExchange(ctx context.Context, req *auth.TokenExchangeRequest) (*auth.TokenResponse, error) {
+	log.Printf("gRPC TokenExchange called: client %s, audience %v", req.ClientId, req.Audience)
+
+	subjectTokenType := req.SubjectTokenType
+	if subjectTokenType == "" {
+		subjectTokenType = models.TokenTypeAccessToken
+	}
+	actorTokenType := req.ActorTokenType
+	if actorTokenType == "" && req.ActorToken != "" {
+		actorTokenType = models.TokenTypeAccessToken
+	}
+
+	resp, err := s.oauthService.Token(ctx, &models.TokenRequest{
+		GrantType:           models.GrantTokenExchange,
+		ClientID:            req.ClientId,
+		ClientSecret:        req.ClientSecret,
+		ClientAssertionType: req.ClientAssertionType,
+		ClientAssertion:     req.ClientAssertion,
+		Scope:               req.Scope,
+		Audience:            req.Audience,
+		SubjectToken:        req.SubjectToken,
+		SubjectTokenType:    subjectTokenType,
+		ActorToken:          req.ActorToken,
+		ActorTokenType:      actorTokenType,
+		RequestedTokenType:  req.RequestedTokenType,
+		DPoP:                dpopProof(ctx),
+	})
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return tokenResponseToProto(resp), nil
+}
+
+func tokenResponseToProto(resp *models.TokenResponse) *auth.TokenResponse {
+	return &auth.TokenResponse{
+		AccessToken: resp.AccessToken,
+		TokenType:   resp.TokenType,
+		ExpiresIn:   int64(resp.ExpiresIn.Seconds()),
+		Scope:       resp.Scope,
+
+		RefreshToken:    resp.RefreshToken,
+		IdToken:         resp.IDToken,
+		IssuedTokenType: resp.IssuedTokenType,
+	}
+}
+
+// StartDeviceAuthorization - device authorization endpoint для клиентов gRPC
+func (s *GRPCServer) StartDeviceAuthorization(ctx context.Context, req *auth.StartDeviceAuthorizationRequest) (*auth.StartDeviceAuthorizationResponse, error) {
+	log.Printf("gRPC StartDeviceAuthorization called: client %s", req.ClientId)
+
+	resp, err := s.oauthService.StartDeviceAuthorization(ctx, &models.TokenRequest{
+		ClientID:            req.ClientId,
+		ClientSecret:        req.ClientSecret,
+		ClientAssertionType: req.ClientAssertionType,
+		ClientAssertion:     req.ClientAssertion,
+		Scope:               req.Scope,
+	}, s.clientInfo(ctx))
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.StartDeviceAuthorizationResponse{
+		DeviceCode:              resp.DeviceCode,
+		UserCode:                resp.UserCode,
+		VerificationUri:         resp.VerificationURI,
+		VerificationUriComplete: resp.VerificationURIComplete,
+		ExpiresIn:               int64(resp.ExpiresIn.Seconds()),
+		Interval:                int64(resp.Interval.Seconds()),
+	}, nil
+}
+
+// ApproveDeviceAuthorization - подтверждение входа устройства из приложения, где пользователь уже вошел
+func (s *GRPCServer) ApproveDeviceAuthorization(ctx context.Context, req *auth.ApproveDeviceAuthorizationRequest) (*auth.ApproveDeviceAuthorizationResponse, error) {
+	log.Printf("gRPC ApproveDeviceAuthorization called: approve %t", req.Approve)
+
+	_, user, err := s.authenticateOwner(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	authorization, err := s.oauthService.DecideDeviceAuthorization(ctx, user.ID, req.UserCode, req.Approve, s.clientInfo(ctx))
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.ApproveDeviceAuthorizationResponse{
+		ClientId:  authorization.ClientID,
+		Scopes:    authorization.Scopes,
+		SessionId: authorization.SessionID,
+	}, nil
+}
+
+// oauthErrorToStatus переводит ошибку протокола OAuth в статус gRPC, сохраняя код RFC 6749 в тексте
+func oauthErrorToStatus(oauthErr *models.OAuthError) error {
+	code := codes.InvalidArgument
+	switch oauthErr.Code {
+	case models.OAuthInvalidClient:
+		code = codes.Unauthenticated
+	case models.OAuthUnauthorizedClient:
+		code = codes.PermissionDenied
+	case models.OAuthUnsupportedGrantType:
+		code = codes.Unimplemented
+	case models.OAuthAuthorizationPending, models.OAuthSlowDown:
+		code = codes.FailedPrecondition
+	case models.OAuthAccessDenied:
+		code = codes.PermissionDenied
+	}
+	return status.Error(code, oauthErr.Error())
+}
+
+// handleToken - POST /oauth2/token (application/x-www-form-urlencoded)
+func (s *HTTPServer) handleToken(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, models.NewOAuthError(models.OAuthInvalidRequest, "malformed form body"), "")
+		return
+	}
+	form := r.PostForm
+
+	req := &models.TokenRequest{
+		GrantType:           form.Get("grant_type"),
+		ClientID:            form.Get("client_id"),
+		ClientSecret:        form.Get("client_secret"),
+		ClientAssertionType: form.Get("client_assertion_type"),
+		ClientAssertion:     form.Get("client_assertion"),
+		Scope:               form.Get("scope"),
+		// audience - распространенное расширение, resource - RFC 8707
+		Audience:     append(form["audience"], form["resource"]...),
+		Code:         form.Get("code"),
+		RedirectURI:  form.Get("redirect_uri"),
+		CodeVerifier: form.Get("code_verifier"),
+		RefreshToken: form.Get("refresh_token"),
+		DeviceCode:   form.Get("device_code"),
+
+		SubjectToken:       form.Get("subject_token"),
+		SubjectTokenType:   form.Get("subject_token_type"),
+		ActorToken:         form.Get("actor_token"),
+		ActorTokenType:     form.Get("actor_token_type"),
+		RequestedTokenType: form.Get("requested_token_type"),
+
+		DPoP: s.dpopProof(r),
+	}
+
+	authScheme, oauthErr := basicClientCredentials(r, req)
+	if oauthErr != nil {
+		writeOAuthError(w, oauthErr, authScheme)
+		return
+	}
+
+	resp, err := s.oauthService.Token(r.Context(), req)
+	if err != nil {
+		if errors.As(err, &oauthErr) {
+			writeOAuthError(w, oauthErr, authScheme)
+			return
+		}
+		writeServerError(w, err)
+		return
+	}
+
+	body := map[string]interface{}{
+		"access_token": resp.AccessToken,
+		"token_type":   resp.TokenType,
+		"expires_in":   int64(resp.ExpiresIn.Seconds()),
+	}
+	if resp.Scope != "" {
+		body["scope"] = resp.Scope
+	}
+	if resp.RefreshToken != "" {
+		body["refresh_token"] = resp.RefreshToken
+	}
+	if resp.IDToken != "" {
+		body["id_token"] = resp.IDToken
+	}
+	if resp.IssuedTokenType != "" {
+		body["issued_token_type"] = resp.IssuedTokenType
+	}
+	setNoStore(w)
+	writeJSON(w, http.StatusOK, body)
+}
+
+// handleDeviceAuthorization - POST /oauth2/device_authorization (RFC 8628, 3.1)
+func (s *HTTPServer) handleDeviceAuthorization(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, models.NewOAuthError(models.OAuthInvalidRequest, "malformed form body"), "")
+		return
+	}
+	form := r.PostForm
+
+	req := &models.TokenRequest{
+		ClientID:            form.Get("client_id"),
+		ClientSecret:        form.Get("client_secret"),
+		ClientAssertionType: form.Get("client_assertion_type"),
+		ClientAssertion:     form.Get("client_assertion"),
+		Scope:               form.Get("scope"),
+	}
+
+	authScheme, oauthErr := basicClientCredentials(r, req)
+	if oauthErr != nil {
+		writeOAuthError(w, oauthErr, authScheme)
+		return
+	}
+
+	resp, err := s.oauthService.StartDeviceAuthorization(r.Context(), req, s.clientInfo(r))
+	if err != nil {
+		if errors.As(err, &oauthErr) {
+			writeOAuthError(w, oauthErr, authScheme)
+			return
+		}
+		writeServerError(w, err)
+		return
+	}
+
+	setNoStore(w)
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"device_code":               resp.DeviceCode,
+		"user_code":                 resp.UserCode,
+		"verification_uri":          resp.VerificationURI,
+		"verification_uri_complete": resp.VerificationURIComplete,
+		"expires_in":                int64(resp.ExpiresIn.Seconds()),
+		"interval":                  int64(resp.Interval.Seconds()),
+	})
+}
+
+// basicClientCredentials переносит в запрос client_secret_basic: id и секрет
+// в Authorization, закодированные как form-urlencoded. Возвращает схему
+// аутентификации для ответа 401.
+func basicClientCredentials(r *http.Request, req *models.TokenRequest) (string, *models.OAuthError) {
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return "", nil
+	}
+	if req.ClientSecret != "" || req.ClientAssertion != "" {
+		return "", models.NewOAuthError(models.OAuthInvalidRequest, "use only one client authentication method")
+	}
+	clientID, errID := url.QueryUnescape(username)
+	secret, errSecret := url.QueryUnescape(password)
+	if errID != nil || errSecret != nil || (req.ClientID != "" && req.ClientID != clientID) {
+		return "Basic", models.NewOAuthError(models.OAuthInvalidClient, "malformed client credentials")
+	}
+	req.ClientID, req.ClientSecret = clientID, secret
+	return "Basic", nil
+}
@@ -0,0 +1,441 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+)
+
+// Cookie браузерной сессии провайдера и CSRF-токена его форм
+const (
+	sessionCookieName    = "auth_session"
+	csrfCookieName       = "auth_csrf"
+	federationCookieName = "auth_federation"
+)
+
+// handleDiscovery - метаданные провайдера (OpenID Connect Discovery 1.0)
+func (s *HTTPServer) handleDiscovery(w http.ResponseWriter, r *http.Request) {
+	issuer := s.oidcService.Issuer()
+
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                        issuer,
+		"authorization_endpoint":        issuer + "/oauth2/authorize",
+		"token_endpoint":                issuer + "/oauth2/token",
+		"device_authorization_endpoint": issuer + "/oauth2/device_authorization",
+		"userinfo_endpoint":             issuer + "/oauth2/userinfo",
+		"end_session_endpoint":          issuer + "/oauth2/logout",
+		"jwks_uri":                      issuer + "/.well-known/jwks.json",
+
+		"response_types_supported":              []string{"code"},
+		"response_modes_supported":              []string{"query"},
+		"grant_types_supported":                 []string{models.GrantAuthorizationCode, models.GrantRefreshToken, models.GrantClientCredentials, models.GrantDeviceCode, models.GrantTokenExchange},
+		"subject_types_supported":               []string{"public"},
+		"id_token_signing_alg_values_supported": []string{"EdDSA"},
+		"scopes_supported":                      []string{models.ScopeOpenID, models.ScopeProfile, models.ScopeEmail},
+		"claims_supported": []string{
+			"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "sid", "at_hash",
+			"name", "given_name", "family_name", "birthdate", "updated_at", "email", "email_verified",
+		},
+		"prompt_values_supported":                          []string{models.PromptNone, models.PromptLogin, models.PromptConsent},
+		"code_challenge_methods_supported":                 []string{models.CodeChallengeS256},
+		"token_endpoint_auth_methods_supported":            []string{"client_secret_basic", "client_secret_post", "private_key_jwt", "none"},
+		"token_endpoint_auth_signing_alg_values_supported": []string{"RS256", "PS256", "ES256", "EdDSA"},
+		"authorization_response_iss_parameter_supported":   true,
+		"dpop_signing_alg_values_supported":                models.DPoPSigningAlgorithms,
+	})
+}
+
+func (s *HTTPServer) handleJWKS(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "public, max-age=3600")
+	writeJSON(w, http.StatusOK, map[string]interface{}{"keys": s.oidcService.JWKS()})
+}
+
+// handleAuthorize - authorization endpoint; параметры в query (GET) или в форме (POST)
+func (s *HTTPServer) handleAuthorize(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderErrorPage(w, http.StatusBadRequest, "The authorization request is malformed.")
+		return
+	}
+
+	req, err := parseAuthorizationRequest(r.Form)
+	if err != nil {
+		renderErrorPage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	session := s.browserSession(r)
+	result, err := s.oidcService.Authorize(r.Context(), req, session)
+	s.respondAuthorization(w, r, result, err, "", "")
+}
+
+// handleLogin принимает форму входа и продолжает исходный запрос авторизации
+func (s *HTTPServer) handleLogin(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || !validCSRF(r) {
+		renderErrorPage(w, http.StatusForbidden, "The form has expired. Please start over.")
+		return
+	}
+
+	req, err := s.formAuthorizationRequest(r)
+	if err != nil {
+		renderErrorPage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	email := strings.TrimSpace(r.PostForm.Get("email"))
+	session, cookie, err := s.oidcService.Login(r.Context(), email, r.PostForm.Get("password"), s.clientInfo(r))
+	if err != nil {
+		message := loginErrorMessage(err)
+		if message == "" {
+			log.Printf("Internal error: %v", err)
+			renderErrorPage(w, http.StatusInternalServerError, "Sign-in is temporarily unavailable.")
+			return
+		}
+		result, err := s.oidcService.Authorize(r.Context(), req, nil)
+		s.respondAuthorization(w, r, result, err, email, message)
+		return
+	}
+
+	s.setCookie(w, sessionCookieName, cookie, session.ExpiresAt)
+
+	// Пароль только что введен: prompt=login и max_age выполнены
+	req.LoginCompleted = true
+	result, err := s.oidcService.Authorize(r.Context(), req, session)
+	s.respondAuthorization(w, r, result, err, "", "")
+}
+
+func (s *HTTPServer) handleConsent(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil || !validCSRF(r) {
+		renderErrorPage(w, http.StatusForbidden, "The form has expired. Please start over.")
+		return
+	}
+
+	req, err := s.formAuthorizationRequest(r)
+	if err != nil {
+		renderErrorPage(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	approved := r.PostForm.Get("decision") == "allow"
+	result, err := s.oidcService.Consent(r.Context(), req, s.browserSession(r), approved)
+	s.respondAuthorization(w, r, result, err, "", "")
+}
+
+// respondAuthorization показывает страницу входа или согласия либо возвращает пользователя клиенту
+func (s *HTTPServer) respondAuthorization(w http.ResponseWriter, r *http.Request, result *models.AuthorizationResult, err error, email, loginError string) {
+	if err != nil {
+		var oauthErr *models.OAuthError
+		if errors.As(err, &oauthErr) {
+			renderErrorPage(w, http.StatusBadRequest, oauthErr.Description)
+			return
+		}
+		log.Printf("Internal error: %v", err)
+		renderErrorPage(w, http.StatusInternalServerError, "The request could not be processed.")
+		return
+	}
+
+	switch {
+	case result.RedirectURL != "":
+		status := http.StatusFound
+		if r.Method == http.MethodPost {
+			status = http.StatusSeeOther
+		}
+		setNoStore(w)
+		http.Redirect(w, r, result.RedirectURL, status)
+	case result.NeedLogin:
+		status := http.StatusOK
+		if loginError != "" {
+			status = http.StatusUnauthorized
+		}
+		renderPage(w, status, "login", &oidcPage{
+			Title:         "Sign in",
+			ClientName:    result.Client.Name,
+			CSRF:          s.csrfToken(w, r),
+			Authorization: encodeAuthorizationRequest(result.Request),
+			Email:         email,
+			Error:         loginError,
+			Providers:     s.identityProviders(r),
+		})
+	case result.NeedConsent:
+		renderPage(w, http.StatusOK, "consent", &oidcPage{
+			Title:         "Allow access",
+			ClientName:    result.Client.Name,
+			CSRF:          s.csrfToken(w, r),
+			Authorization: encodeAuthorizationRequest(result.Request),
+			Scopes:        describeScopes(result.Request.Scopes),
+		})
+	}
+}
+
+// handleUserInfo - userinfo endpoint; access-токен только в заголовке Authorization.
+// Токен, привязанный к ключу, приходит со схемой DPoP и заголовком DPoP (RFC 9449, 7.1).
+func (s *HTTPServer) handleUserInfo(w http.ResponseWriter, r *http.Request) {
+	scheme := "Bearer"
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		if token, ok = strings.CutPrefix(r.Header.Get("Authorization"), models.DPoPTokenType+" "); ok {
+			scheme = models.DPoPTokenType
+		}
+	}
+	if !ok || token == "" {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+		setNoStore(w)
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	info, err := s.oidcService.UserInfo(r.Context(), token, s.dpopProof(r))
+	if err != nil {
+		var oauthErr *models.OAuthError
+		if !errors.As(err, &oauthErr) {
+			writeServerError(w, err)
+			return
+		}
+
+		// Ошибки защищенного ресурса передаются в WWW-Authenticate (RFC 6750, 3)
+		status := http.StatusUnauthorized
+		if oauthErr.Code == models.OAuthInsufficientScope {
+			status = http.StatusForbidden
+		}
+		if oauthErr.Code == models.OAuthInvalidDPoPProof {
+			scheme = models.DPoPTokenType
+		}
+		w.Header().Set("WWW-Authenticate", scheme+` error="`+oauthErr.Code+`", error_description="`+oauthErr.Description+`"`)
+		setNoStore(w)
+		w.WriteHeader(status)
+		return
+	}
+
+	setNoStore(w)
+	writeJSON(w, http.StatusOK, info)
+}
+
+// handleEndSession - end-session endpoint. Без id_token_hint текущей сессии
+// выход подтверждается пользователем: иначе любой сайт мог бы разлогинить его.
+func (s *HTTPServer) handleEndSession(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderErrorPage(w, http.StatusBadRequest, "The logout request is malformed.")
+		return
+	}
+
+	req := &models.EndSessionRequest{
+		IDTokenHint:           r.Form.Get("id_token_hint"),
+		ClientID:              r.Form.Get("client_id"),
+		PostLogoutRedirectURI: r.Form.Get("post_logout_redirect_uri"),
+		State:                 r.Form.Get("state"),
+	}
+
+	endSession, err := s.oidcService.EndSession(r.Context(), req)
+	if err != nil {
+		var oauthErr *models.OAuthError
+		if errors.As(err, &oauthErr) {
+			renderErrorPage(w, http.StatusBadRequest, oauthErr.Description)
+			return
+		}
+		log.Printf("Internal error: %v", err)
+		renderErrorPage(w, http.StatusInternalServerError, "The request could not be processed.")
+		return
+	}
+
+	if session := s.browserSession(r); session != nil {
+		confirmed := r.Method == http.MethodPost && r.PostForm.Get("confirm") != ""
+		if confirmed && !validCSRF(r) {
+			renderErrorPage(w, http.StatusForbidden, "The form has expired. Please start over.")
+			return
+		}
+
+		if !confirmed && endSession.HintSessionID != session.ID {
+			page := &oidcPage{
+				Title:  "Sign out",
+				CSRF:   s.csrfToken(w, r),
+				Params: endSessionParams(req),
+			}
+			if endSession.Client != nil {
+				page.ClientName = endSession.Client.Name
+			}
+			renderPage(w, http.StatusOK, "logout", page)
+			return
+		}
+
+		if err := s.oidcService.Logout(r.Context(), session); err != nil {
+			log.Printf("Internal error: %v", err)
+			renderErrorPage(w, http.StatusInternalServerError, "The request could not be processed.")
+			return
+		}
+	}
+
+	s.setCookie(w, sessionCookieName, "", time.Time{})
+	if endSession.RedirectURL != "" {
+		setNoStore(w)
+		http.Redirect(w, r, endSession.RedirectURL, http.StatusSeeOther)
+		return
+	}
+	renderPage(w, http.StatusOK, "signed_out", &oidcPage{Title: "Signed out"})
+}
+
+// browserSession возвращает сессию из cookie; ошибка хранилища равносильна ее отсутствию
+func (s *HTTPServer) browserSession(r *http.Request) *models.Session {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil
+	}
+
+	session, err := s.oidcService.BrowserSession(r.Context(), cookie.Value)
+	if err != nil {
+		log.Printf("Failed to load browser session: %v", err)
+		return nil
+	}
+	return session
+}
+
+// formAuthorizationRequest восстанавливает запрос авторизации из скрытого поля формы
+func (s *HTTPServer) formAuthorizationRequest(r *http.Request) (*models.AuthorizationRequest, error) {
+	values, err := url.ParseQuery(r.PostForm.Get("authz"))
+	if err != nil {
+		return nil, errors.New("The authorization request is malformed.")
+	}
+	return parseAuthorizationRequest(values)
+}
+
+func parseAuthorizationRequest(values url.Values) (*models.AuthorizationRequest, error) {
+	req := &models.AuthorizationRequest{
+		ClientID:            values.Get("client_id"),
+		RedirectURI:         values.Get("redirect_uri"),
+		ResponseType:        values.Get("response_type"),
+		Scopes:              strings.Fields(values.Get("scope")),
+		State:               values.Get("state"),
+		Nonce:               values.Get("nonce"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+		Prompt:              strings.Fields(values.Get("prompt")),
+	}
+
+	if maxAge := values.Get("max_age"); maxAge != "" {
+		seconds, err := strconv.Atoi(maxAge)
+		if err != nil || seconds < 0 {
+			return nil, errors.New("max_age must be a non-negative number of seconds.")
+		}
+		duration := time.Duration(seconds) * time.Second
+		req.MaxAge = &duration
+	}
+
+	return req, nil
+}
+
+// encodeAuthorizationRequest - обратное parseAuthorizationRequest, для скрытых полей форм
+func encodeAuthorizationRequest(req *models.AuthorizationRequest) string {
+	values := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+
+	set("client_id", req.ClientID)
+	set("redirect_uri", req.RedirectURI)
+	set("response_type", req.ResponseType)
+	set("scope", strings.Join(req.Scopes, " "))
+	set("state", req.State)
+	set("nonce", req.Nonce)
+	set("code_challenge", req.CodeChallenge)
+	set("code_challenge_method", req.CodeChallengeMethod)
+	set("prompt", strings.Join(req.Prompt, " "))
+	if req.MaxAge != nil {
+		set("max_age", strconv.FormatInt(int64(*req.MaxAge/time.Second), 10))
+	}
+
+	return values.Encode()
+}
+
+func endSessionParams(req *models.EndSessionRequest) map[string]string {
+	params := map[string]string{}
+	for name, value := range map[string]string{
+		"id_token_hint":            req.IDTokenHint,
+		"client_id":                req.ClientID,
+		"post_logout_redirect_uri": req.PostLogoutRedirectURI,
+		"state":                    req.State,
+	} {
+		if value != "" {
+			params[name] = value
+		}
+	}
+	return params
+}
+
+// loginErrorMessage - текст для формы входа; пусто для внутренних ошибок
+func loginErrorMessage(err error) string {
+	switch err {
+	case models.ErrInvalidCredentials:
+		return "Invalid email or password."
+	case models.ErrPasswordResetRequired:
+		return "You need to reset your password before signing in."
+	case models.ErrCaptchaRequired, models.ErrChallengeFailed:
+		return "Additional verification is required. Please sign in from the application."
+	case models.ErrMFARequired:
+		return "Multi-factor authentication is required. Please sign in from the application."
+	case models.ErrLoginDenied, models.ErrPolicyDenied:
+		return "Sign-in is not allowed."
+	case models.ErrIdentityNotLinked:
+		return "No account is linked to this sign-in. Sign in with your password first."
+	case models.ErrFederationFailed:
+		return "Sign-in with the external provider failed. Please try again."
+	case models.ErrIdentityProviderNotFound, models.ErrSAMLNotConfigured:
+		return "This sign-in option is not available."
+	case models.ErrSSORequired:
+		return "Your organization requires signing in with its identity provider."
+	default:
+		return ""
+	}
+}
+
+// csrfToken возвращает токен из cookie, при необходимости выпуская новый (double submit)
+func (s *HTTPServer) csrfToken(w http.ResponseWriter, r *http.Request) string {
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && cookie.Value != "" {
+		return cookie.Value
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("Failed to generate CSRF token: %v", err)
+		return ""
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+	s.setCookie(w, csrfCookieName, token, time.Time{})
+	return token
+}
+
+func validCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie(csrfCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(r.PostForm.Get("csrf"))) == 1
+}
+
+// setCookie ставит cookie провайдера; пустое значение удаляет ее, нулевой срок - cookie сессии браузера.
+// SameSite=Lax: cookie должна приходить при переходе от клиента на /oauth2/authorize.
+func (s *HTTPServer) setCookie(w http.ResponseWriter, name, value string, expires time.Time) {
+	cookie := &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     "/oauth2/",
+		Secure:   strings.HasPrefix(s.cfg.IssuerURL, "https://"),
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	}
+	if value == "" {
+		cookie.MaxAge = -1
+	} else if !expires.IsZero() {
+		cookie.Expires = expires
+	}
+	http.SetCookie(w, cookie)
+}
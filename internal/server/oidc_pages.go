@@ -0,0 +1,183 @@
+package server
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+)
+
+// Страницы входа, согласия, выхода и подтверждения устройств. Разметка минимальная: приложения
+// при необходимости ставят перед провайдером свой фронтенд.
+var oidcPages = template.Must(template.New("").Parse(`
+{{define "header"}}<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<title>{{.Title}}</title>
+<style>
+body { font-family: system-ui, sans-serif; background: #f4f5f7; margin: 0; }
+main { max-width: 360px; margin: 10vh auto; background: #fff; padding: 32px; border-radius: 8px; box-shadow: 0 1px 4px rgba(0,0,0,.1); }
+h1 { font-size: 20px; margin-top: 0; }
+label { display: block; margin: 12px 0 4px; font-size: 14px; }
+input[type=email], input[type=password], input[type=text] { width: 100%; box-sizing: border-box; padding: 8px; }
+button { margin-top: 20px; padding: 8px 16px; }
+.error { color: #b00020; }
+.provider { display: block; margin: 8px 0; padding: 8px; border: 1px solid #ccc; border-radius: 4px; text-align: center; color: inherit; text-decoration: none; }
+</style>
+</head>
+<body>
+<main>
+<h1>{{.Title}}</h1>
+{{end}}
+
+{{define "footer"}}</main>
+</body>
+</html>
+{{end}}
+
+{{define "login"}}{{template "header" .}}
+<p>Sign in to continue to <strong>{{.ClientName}}</strong>.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+{{range .Providers}}<a class="provider" href="/oauth2/federation/{{.Slug}}?authz={{$.Authorization}}">Continue with {{.Name}}</a>
+{{end}}<form method="post" action="/oauth2/login">
+<input type="hidden" name="csrf" value="{{.CSRF}}">
+<input type="hidden" name="authz" value="{{.Authorization}}">
+<label for="email">Email</label>
+<input id="email" type="email" name="email" value="{{.Email}}" autocomplete="username" required autofocus>
+<label for="password">Password</label>
+<input id="password" type="password" name="password" autocomplete="current-password" required>
+<button type="submit">Sign in</button>
+</form>
+{{template "footer"}}{{end}}
+
+{{define "consent"}}{{template "header" .}}
+<p><strong>{{.ClientName}}</strong> is requesting access to:</p>
+<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>
+<form method="post" action="/oauth2/consent">
+<input type="hidden" name="csrf" value="{{.CSRF}}">
+<input type="hidden" name="authz" value="{{.Authorization}}">
+<button type="submit" name="decision" value="allow">Allow</button>
+<button type="submit" name="decision" value="deny">Deny</button>
+</form>
+{{template "footer"}}{{end}}
+
+{{define "logout"}}{{template "header" .}}
+<p>Do you want to sign out{{if .ClientName}} of <strong>{{.ClientName}}</strong> and all other applications{{end}}?</p>
+<form method="post" action="/oauth2/logout">
+<input type="hidden" name="csrf" value="{{.CSRF}}">
+<input type="hidden" name="confirm" value="1">
+{{range $name, $value := .Params}}<input type="hidden" name="{{$name}}" value="{{$value}}">
+{{end}}<button type="submit">Sign out</button>
+</form>
+{{template "footer"}}{{end}}
+
+{{define "signed_out"}}{{template "header" .}}
+<p>You have been signed out.</p>
+{{template "footer"}}{{end}}
+
+{{define "signed_in"}}{{template "header" .}}
+<p>You are signed in. You can return to the application.</p>
+{{template "footer"}}{{end}}
+
+{{define "device_login"}}{{template "header" .}}
+<p>Sign in to connect your device.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="/oauth2/device">
+<input type="hidden" name="csrf" value="{{.CSRF}}">
+<label for="email">Email</label>
+<input id="email" type="email" name="email" value="{{.Email}}" autocomplete="username" required autofocus>
+<label for="password">Password</label>
+<input id="password" type="password" name="password" autocomplete="current-password" required>
+<label for="user_code">Code shown on your device</label>
+<input id="user_code" type="text" name="user_code" value="{{.UserCode}}" autocomplete="off" autocapitalize="characters">
+<button type="submit">Sign in</button>
+</form>
+{{template "footer"}}{{end}}
+
+{{define "device_code"}}{{template "header" .}}
+<p>Enter the code shown on your device.</p>
+{{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+<form method="post" action="/oauth2/device">
+<input type="hidden" name="csrf" value="{{.CSRF}}">
+<label for="user_code">Code</label>
+<input id="user_code" type="text" name="user_code" value="{{.UserCode}}" autocomplete="off" autocapitalize="characters" required autofocus>
+<button type="submit">Continue</button>
+</form>
+{{template "footer"}}{{end}}
+
+{{define "device_consent"}}{{template "header" .}}
+<p>A device with the code <strong>{{.UserCode}}</strong> wants to sign in to <strong>{{.ClientName}}</strong> with access to:</p>
+<ul>{{range .Scopes}}<li>{{.}}</li>{{end}}</ul>
+<p>Only allow this if you started the sign-in on your own device.</p>
+<form method="post" action="/oauth2/device">
+<input type="hidden" name="csrf" value="{{.CSRF}}">
+<input type="hidden" name="user_code" value="{{.UserCode}}">
+<button type="submit" name="decision" value="allow">Allow</button>
+<button type="submit" name="decision" value="deny">Deny</button>
+</form>
+{{template "footer"}}{{end}}
+
+{{define "device_approved"}}{{template "header" .}}
+<p>Your device is signed in. You can return to it now.</p>
+{{template "footer"}}{{end}}
+
+{{define "device_denied"}}{{template "header" .}}
+<p>The device was not allowed to sign in.</p>
+{{template "footer"}}{{end}}
+
+{{define "error"}}{{template "header" .}}
+<p class="error">{{.Error}}</p>
+{{template "footer"}}{{end}}
+`))
+
+type oidcPage struct {
+	Title         string
+	ClientName    string
+	CSRF          string
+	Authorization string
+	Email         string
+	UserCode      string
+	Error         string
+	Scopes        []string
+	Params        map[string]string
+	Providers     []*models.IdentityProvider
+}
+
+// Описания областей на странице согласия; прочие области показываются как есть
+var scopeDescriptions = map[string]string{
+	models.ScopeOpenID:  "Sign you in with your account",
+	models.ScopeProfile: "Your name and date of birth",
+	models.ScopeEmail:   "Your email address",
+}
+
+func describeScopes(scopes []string) []string {
+	described := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if description, ok := scopeDescriptions[scope]; ok {
+			described = append(described, description)
+		} else {
+			described = append(described, scope)
+		}
+	}
+	return described
+}
+
+func renderPage(w http.ResponseWriter, status int, name string, page *oidcPage) {
+	setNoStore(w)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	// Страницы с формами входа нельзя встраивать в чужие фреймы
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; frame-ancestors 'none'")
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.WriteHeader(status)
+
+	if err := oidcPages.ExecuteTemplate(w, name, page); err != nil {
+		log.Printf("Failed to render %s page: %v", name, err)
+	}
+}
+
+func renderErrorPage(w http.ResponseWriter, status int, message string) {
+	renderPage(w, status, "error", &oidcPage{Title: "Something went wrong", Error: message})
+}
@@ -0,0 +1,120 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/generated/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *GRPCServer) CreateOrganization(ctx context.Context, req *auth.CreateOrganizationRequest) (*auth.CreateOrganizationResponse, error) {
+	log.Printf("gRPC CreateOrganization called: %s", req.Name)
+
+	_, user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	org, err := s.organizationService.CreateOrganization(ctx, user.ID, &models.Organization{Name: req.Name})
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.CreateOrganizationResponse{
+		Organization: &auth.Organization{
+			Id:        org.ID,
+			Name:      org.Name,
+			CreatedAt: timestamppb.New(org.CreatedAt),
+		},
+	}, nil
+}
+
+func (s *GRPCServer) InviteMember(ctx context.Context, req *auth.InviteMemberRequest) (*auth.InviteMemberResponse, error) {
+	log.Printf("gRPC InviteMember called: %s to organization %d", req.Email, req.OrganizationId)
+
+	_, user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	invitation, err := s.organizationService.InviteMember(ctx, user.ID, req.OrganizationId, req.Email, models.OrganizationRole(req.Role))
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.InviteMemberResponse{
+		InvitationId: invitation.ID,
+		ExpiresAt:    timestamppb.New(invitation.ExpiresAt),
+	}, nil
+}
+
+func (s *GRPCServer) AcceptInvitation(ctx context.Context, req *auth.AcceptInvitationRequest) (*auth.AcceptInvitationResponse, error) {
+	log.Printf("gRPC AcceptInvitation called")
+
+	accept := &models.AcceptInvitationRequest{Token: req.Token}
+
+	// Вошедший пользователь привязывает свой аккаунт, иначе регистрируем новый
+	if bearerToken(ctx) != "" {
+		_, user, err := s.authenticate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		accept.UserID = &user.ID
+	} else {
+		if req.Password == "" {
+			return nil, status.Error(codes.Unauthenticated, "sign in or provide a password to create an account")
+		}
+		accept.Registr = &models.Registr{
+			Password:  req.Password,
+			FirstName: req.FirstName,
+			Surname:   req.Surname,
+			Client:    s.clientInfo(ctx),
+		}
+	}
+
+	membership, err := s.organizationService.AcceptInvitation(ctx, accept)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.AcceptInvitationResponse{
+		OrganizationId: membership.OrganizationID,
+		UserId:         membership.UserID,
+		Role:           string(membership.Role),
+	}, nil
+}
+
+func (s *GRPCServer) RemoveMember(ctx context.Context, req *auth.RemoveMemberRequest) (*auth.RemoveMemberResponse, error) {
+	log.Printf("gRPC RemoveMember called: user %d from organization %d", req.UserId, req.OrganizationId)
+
+	_, user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.organizationService.RemoveMember(ctx, user.ID, req.OrganizationId, req.UserId); err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.RemoveMemberResponse{}, nil
+}
+
+func (s *GRPCServer) ChangeMemberRole(ctx context.Context, req *auth.ChangeMemberRoleRequest) (*auth.ChangeMemberRoleResponse, error) {
+	log.Printf("gRPC ChangeMemberRole called: user %d in organization %d to %s", req.UserId, req.OrganizationId, req.Role)
+
+	_, user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.organizationService.ChangeMemberRole(ctx, user.ID, req.OrganizationId, req.UserId, models.OrganizationRole(req.Role))
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.ChangeMemberRoleResponse{}, nil
+}
@@ -0,0 +1,58 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+type peerIdentityKey struct{}
+
+// PeerIdentityFromContext возвращает проверенную личность клиента mTLS
+// (nil, если клиент не предъявил сертификат)
+func PeerIdentityFromContext(ctx context.Context) *models.PeerIdentity {
+	identity, _ := ctx.Value(peerIdentityKey{}).(*models.PeerIdentity)
+	return identity
+}
+
+func withPeerIdentity(ctx context.Context, identity *models.PeerIdentity) context.Context {
+	return context.WithValue(ctx, peerIdentityKey{}, identity)
+}
+
+// peerIdentity достает личность из сертификата клиента, прошедшего проверку цепочки
+func peerIdentity(ctx context.Context) *models.PeerIdentity {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+
+	tlsInfo, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(tlsInfo.State.VerifiedChains) == 0 || len(tlsInfo.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+
+	cert := tlsInfo.State.VerifiedChains[0][0]
+
+	identity := &models.PeerIdentity{
+		Subject:      cert.Subject.String(),
+		CommonName:   cert.Subject.CommonName,
+		DNSNames:     cert.DNSNames,
+		SerialNumber: cert.SerialNumber.String(),
+		NotAfter:     cert.NotAfter,
+	}
+	for _, uri := range cert.URIs {
+		identity.URIs = append(identity.URIs, uri.String())
+	}
+
+	// Некорректный SPIFFE ID не дает прав рабочей нагрузки, но соединение остается
+	spiffeID, err := spiffeIDFromURIs(identity.URIs)
+	if err != nil {
+		log.Printf("⚠️  Ignoring SPIFFE ID of peer %s: %v", identity.Subject, err)
+	}
+	identity.SPIFFEID = spiffeID
+
+	return identity
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/generated/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *GRPCServer) SavePolicy(ctx context.Context, req *auth.SavePolicyRequest) (*auth.SavePolicyResponse, error) {
+	log.Printf("gRPC SavePolicy called for policy: %s", req.GetPolicy().GetName())
+
+	admin, err := s.requirePermission(ctx, models.PermissionPoliciesManage)
+	if err != nil {
+		return nil, err
+	}
+	if req.Policy == nil {
+		return nil, status.Error(codes.InvalidArgument, "policy is required")
+	}
+
+	policy, err := s.policyService.SavePolicy(ctx, admin.ID, &models.Policy{
+		Name:        req.Policy.Name,
+		Description: req.Policy.Description,
+		Expression:  req.Policy.Expression,
+		Effect:      models.PolicyEffect(req.Policy.Effect),
+		Actions:     req.Policy.Actions,
+		Priority:    int(req.Policy.Priority),
+		Enabled:     req.Policy.Enabled,
+	})
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.SavePolicyResponse{Policy: policyToProto(policy)}, nil
+}
+
+func (s *GRPCServer) DeletePolicy(ctx context.Context, req *auth.DeletePolicyRequest) (*auth.DeletePolicyResponse, error) {
+	log.Printf("gRPC DeletePolicy called for policy: %s", req.Name)
+
+	admin, err := s.requirePermission(ctx, models.PermissionPoliciesManage)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.policyService.DeletePolicy(ctx, admin.ID, req.Name); err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.DeletePolicyResponse{}, nil
+}
+
+func (s *GRPCServer) ListPolicies(ctx context.Context, req *auth.ListPoliciesRequest) (*auth.ListPoliciesResponse, error) {
+	log.Printf("gRPC ListPolicies called")
+
+	if _, err := s.requirePermission(ctx, models.PermissionPoliciesManage); err != nil {
+		return nil, err
+	}
+
+	policies, err := s.policyService.ListPolicies(ctx)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	resp := &auth.ListPoliciesResponse{}
+	for _, policy := range policies {
+		resp.Policies = append(resp.Policies, policyToProto(policy))
+	}
+	return resp, nil
+}
+
+func (s *GRPCServer) Authorize(ctx context.Context, req *auth.AuthorizeRequest) (*auth.AuthorizeResponse, error) {
+	log.Printf("gRPC Authorize called for action: %s", req.Action)
+
+	claims, user, err := s.registrService.AuthenticateRequest(ctx, req.Token, dpopFromProto(req.Dpop))
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	// Сервис передает IP пользователя явно; иначе берем его из запроса
+	ip, userAgent := req.Ip, req.UserAgent
+	if ip == "" {
+		client := s.clientInfo(ctx)
+		ip = client.IP
+		if userAgent == "" {
+			userAgent = client.UserAgent
+		}
+	}
+
+	decision, err := s.policyService.Authorize(ctx, &models.PolicyInput{
+		Action:     req.Action,
+		Resource:   req.Resource,
+		User:       user,
+		Claims:     claims,
+		IP:         ip,
+		UserAgent:  userAgent,
+		Attributes: req.Attributes,
+	})
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.AuthorizeResponse{
+		Allowed: decision.Allowed,
+		Policy:  decision.Policy,
+		Effect:  string(decision.Effect),
+		Reason:  decision.Reason,
+	}, nil
+}
+
+func policyToProto(policy *models.Policy) *auth.Policy {
+	return &auth.Policy{
+		Id:          policy.ID,
+		Name:        policy.Name,
+		Description: policy.Description,
+		Expression:  policy.Expression,
+		Effect:      string(policy.Effect),
+		Actions:     policy.Actions,
+		Priority:    int32(policy.Priority),
+		Enabled:     policy.Enabled,
+		CreatedAt:   timestamppb.New(policy.CreatedAt),
+		UpdatedAt:   timestamppb.New(policy.UpdatedAt),
+	}
+}
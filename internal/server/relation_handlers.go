@@ -0,0 +1,136 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/generated/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *GRPCServer) WriteRelationships(ctx context.Context, req *auth.WriteRelationshipsRequest) (*auth.WriteRelationshipsResponse, error) {
+	log.Printf("gRPC WriteRelationships called with %d updates", len(req.Updates))
+
+	if s.relationService == nil {
+		return nil, errRelationsDisabled
+	}
+
+	updates := make([]models.RelationUpdate, 0, len(req.Updates))
+	for _, update := range req.Updates {
+		var operation models.RelationOperation
+		switch update.Operation {
+		case auth.RelationshipOperation_RELATIONSHIP_OPERATION_TOUCH:
+			operation = models.RelationTouch
+		case auth.RelationshipOperation_RELATIONSHIP_OPERATION_DELETE:
+			operation = models.RelationDelete
+		default:
+			return nil, status.Error(codes.InvalidArgument, "relationship operation is required")
+		}
+
+		updates = append(updates, models.RelationUpdate{
+			Operation: operation,
+			Tuple:     relationTuple(update.Relationship),
+		})
+	}
+
+	revision, err := s.relationService.WriteRelationships(ctx, updates)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.WriteRelationshipsResponse{
+		WrittenAt: revision.Token(),
+	}, nil
+}
+
+func (s *GRPCServer) CheckPermission(ctx context.Context, req *auth.CheckPermissionRequest) (*auth.CheckPermissionResponse, error) {
+	log.Printf("gRPC CheckPermission called")
+
+	if s.relationService == nil {
+		return nil, errRelationsDisabled
+	}
+
+	consistency, err := relationConsistency(req.Consistency)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	allowed, revision, err := s.relationService.CheckPermission(ctx, models.RelationTuple{
+		Resource: objectRef(req.Resource),
+		Relation: req.Permission,
+		Subject:  subjectRef(req.Subject),
+	}, consistency)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.CheckPermissionResponse{
+		Allowed:   allowed,
+		CheckedAt: revision.Token(),
+	}, nil
+}
+
+func (s *GRPCServer) LookupResources(ctx context.Context, req *auth.LookupResourcesRequest) (*auth.LookupResourcesResponse, error) {
+	log.Printf("gRPC LookupResources called for namespace: %s", req.ResourceNamespace)
+
+	if s.relationService == nil {
+		return nil, errRelationsDisabled
+	}
+
+	consistency, err := relationConsistency(req.Consistency)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	ids, revision, err := s.relationService.LookupResources(ctx, req.ResourceNamespace, req.Permission, subjectRef(req.Subject), consistency)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	return &auth.LookupResourcesResponse{
+		ResourceIds: ids,
+		LookedUpAt:  revision.Token(),
+	}, nil
+}
+
+var errRelationsDisabled = status.Error(codes.Unimplemented, "relationship authorization is not configured")
+
+func objectRef(ref *auth.ObjectReference) models.ObjectRef {
+	return models.ObjectRef{
+		Namespace: ref.GetNamespace(),
+		ID:        ref.GetId(),
+	}
+}
+
+func subjectRef(ref *auth.SubjectReference) models.SubjectRef {
+	return models.SubjectRef{
+		Object:   objectRef(ref.GetObject()),
+		Relation: ref.GetRelation(),
+	}
+}
+
+func relationTuple(rel *auth.Relationship) models.RelationTuple {
+	return models.RelationTuple{
+		Resource: objectRef(rel.GetResource()),
+		Relation: rel.GetRelation(),
+		Subject:  subjectRef(rel.GetSubject()),
+	}
+}
+
+func relationConsistency(c *auth.Consistency) (models.Consistency, error) {
+	consistency := models.Consistency{
+		FullyConsistent: c.GetFullyConsistent(),
+	}
+
+	if token := c.GetAtLeastAsFresh(); token != "" {
+		revision, err := models.ParseRelationRevision(token)
+		if err != nil {
+			return models.Consistency{}, err
+		}
+		consistency.AtLeastAsFresh = revision
+	}
+
+	return consistency, nil
+}
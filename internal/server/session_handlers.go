@@ -0,0 +1,45 @@
+package server
+
+import (
+	"context"
+	"log"
+
+	"github.com/DailyPepper/auth-service/pkg/generated/auth"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *GRPCServer) ListSessions(ctx context.Context, req *auth.ListSessionsRequest) (*auth.ListSessionsResponse, error) {
+	log.Printf("gRPC ListSessions called")
+
+	claims, user, err := s.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := s.sessionService.ListSessions(ctx, user.ID)
+	if err != nil {
+		return nil, s.mapErrorToStatus(err)
+	}
+
+	resp := &auth.ListSessionsResponse{}
+	for _, session := range sessions {
+		item := &auth.Session{
+			Id:               session.ID,
+			Ip:               session.IP,
+			UserAgent:        session.UserAgent,
+			CreatedAt:        timestamppb.New(session.CreatedAt),
+			ExpiresAt:        timestamppb.New(session.ExpiresAt),
+			ImpossibleTravel: session.ImpossibleTravel,
+			Current:          session.ID == claims.SessionID,
+			Impersonated:     session.ImpersonatorID != nil,
+		}
+		if session.Location != nil {
+			item.City = session.Location.City
+			item.Country = session.Location.Country
+			item.CountryCode = session.Location.CountryCode
+		}
+		resp.Sessions = append(resp.Sessions, item)
+	}
+
+	return resp, nil
+}
@@ -0,0 +1,83 @@
+package server
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const spiffeScheme = "spiffe"
+
+// SPIFFEID - идентификатор рабочей нагрузки вида spiffe://trust-domain/path
+type SPIFFEID struct {
+	TrustDomain string
+	Path        string
+}
+
+func (id SPIFFEID) String() string {
+	return spiffeScheme + "://" + id.TrustDomain + id.Path
+}
+
+// ParseSPIFFEID разбирает и проверяет SPIFFE ID по спецификации:
+// trust domain в нижнем регистре, без порта, userinfo, query и fragment,
+// сегменты пути непустые и не равны "." / ".."
+func ParseSPIFFEID(raw string) (SPIFFEID, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return SPIFFEID{}, fmt.Errorf("invalid SPIFFE ID %q: %w", raw, err)
+	}
+
+	if u.Scheme != spiffeScheme {
+		return SPIFFEID{}, fmt.Errorf("invalid SPIFFE ID %q: scheme must be spiffe", raw)
+	}
+	if u.Host == "" {
+		return SPIFFEID{}, fmt.Errorf("invalid SPIFFE ID %q: missing trust domain", raw)
+	}
+	if u.User != nil || u.Port() != "" || u.RawQuery != "" || u.Fragment != "" || u.Opaque != "" {
+		return SPIFFEID{}, fmt.Errorf("invalid SPIFFE ID %q: must not contain userinfo, port, query or fragment", raw)
+	}
+
+	for _, c := range u.Host {
+		if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+			return SPIFFEID{}, fmt.Errorf("invalid SPIFFE ID %q: bad character %q in trust domain", raw, c)
+		}
+	}
+
+	if u.Path != "" {
+		for _, segment := range strings.Split(strings.TrimPrefix(u.Path, "/"), "/") {
+			if segment == "" || segment == "." || segment == ".." {
+				return SPIFFEID{}, fmt.Errorf("invalid SPIFFE ID %q: bad path segment %q", raw, segment)
+			}
+			for _, c := range segment {
+				if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || c == '.' || c == '-' || c == '_') {
+					return SPIFFEID{}, fmt.Errorf("invalid SPIFFE ID %q: bad character %q in path", raw, c)
+				}
+			}
+		}
+	}
+
+	return SPIFFEID{TrustDomain: u.Host, Path: u.Path}, nil
+}
+
+// spiffeIDFromURIs: X509-SVID содержит ровно один URI SAN со схемой spiffe
+func spiffeIDFromURIs(uris []string) (string, error) {
+	var found []string
+	for _, uri := range uris {
+		if strings.HasPrefix(uri, spiffeScheme+"://") {
+			found = append(found, uri)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return "", nil
+	case 1:
+		id, err := ParseSPIFFEID(found[0])
+		if err != nil {
+			return "", err
+		}
+		return id.String(), nil
+	default:
+		return "", fmt.Errorf("certificate has %d SPIFFE URI SANs, expected one", len(found))
+	}
+}
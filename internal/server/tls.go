@@ -0,0 +1,209 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DailyPepper/auth-service/config"
+)
+
+// tlsReloader держит текущие сертификат сервера и CA клиентов и перечитывает
+// их, когда файлы на диске меняются (например, при ротации cert-manager)
+type tlsReloader struct {
+	certPath     string
+	keyPath      string
+	clientCAPath string
+
+	mu        sync.RWMutex
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	modTimes  map[string]time.Time
+
+	stop chan struct{}
+}
+
+func newTLSReloader(certPath, keyPath, clientCAPath string) (*tlsReloader, error) {
+	r := &tlsReloader{
+		certPath:     certPath,
+		keyPath:      keyPath,
+		clientCAPath: clientCAPath,
+		modTimes:     make(map[string]time.Time),
+		stop:         make(chan struct{}),
+	}
+
+	if err := r.reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *tlsReloader) files() []string {
+	files := []string{r.certPath, r.keyPath}
+	if r.clientCAPath != "" {
+		files = append(files, r.clientCAPath)
+	}
+	return files
+}
+
+func (r *tlsReloader) reload() error {
+	modTimes := make(map[string]time.Time)
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to stat %s: %w", path, err)
+		}
+		modTimes[path] = info.ModTime()
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certPath, r.keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to load server certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.clientCAPath != "" {
+		pem, err := os.ReadFile(r.clientCAPath)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA bundle: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("client CA bundle %s contains no certificates", r.clientCAPath)
+		}
+	}
+
+	r.mu.Lock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	r.modTimes = modTimes
+	r.mu.Unlock()
+
+	return nil
+}
+
+func (r *tlsReloader) changed() bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, path := range r.files() {
+		info, err := os.Stat(path)
+		if err != nil {
+			continue
+		}
+		if !info.ModTime().Equal(r.modTimes[path]) {
+			return true
+		}
+	}
+	return false
+}
+
+// watch раз в interval проверяет время изменения файлов.
+// При ошибке перезагрузки продолжаем работать со старыми сертификатами.
+func (r *tlsReloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.stop:
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+			if err := r.reload(); err != nil {
+				log.Printf("❌ Failed to reload TLS certificates: %v", err)
+				continue
+			}
+			log.Printf("🔐 TLS certificates reloaded")
+		}
+	}
+}
+
+func (r *tlsReloader) Close() {
+	close(r.stop)
+}
+
+func (r *tlsReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.cert, nil
+}
+
+// tlsConfig собирает серверную конфигурацию; ClientCAs подставляются
+// на каждое рукопожатие, чтобы подхватывать обновленный бандл
+func (r *tlsReloader) tlsConfig(cfg *config.Config) (*tls.Config, error) {
+	minVersion, err := parseTLSVersion(cfg.TLSMinVersion)
+	if err != nil {
+		return nil, err
+	}
+
+	cipherSuites, err := parseCipherSuites(cfg.TLSCipherSuites)
+	if err != nil {
+		return nil, err
+	}
+
+	base := &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   cipherSuites,
+		GetCertificate: r.getCertificate,
+	}
+
+	if r.clientCAPath != "" {
+		base.ClientAuth = tls.RequireAndVerifyClientCert
+		if cfg.TLSClientAuthOptional {
+			base.ClientAuth = tls.VerifyClientCertIfGiven
+		}
+	}
+
+	return &tls.Config{
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			conf := base.Clone()
+			r.mu.RLock()
+			conf.ClientCAs = r.clientCAs
+			r.mu.RUnlock()
+			return conf, nil
+		},
+	}, nil
+}
+
+func parseTLSVersion(version string) (uint16, error) {
+	switch version {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	default:
+		return 0, fmt.Errorf("unsupported TLS min version %q (use 1.2 or 1.3)", version)
+	}
+}
+
+// parseCipherSuites принимает имена наборов через запятую (TLS_ECDHE_...).
+// Пустой список - наборы Go по умолчанию. Для TLS 1.3 наборы не настраиваются.
+func parseCipherSuites(names string) ([]uint16, error) {
+	if strings.TrimSpace(names) == "" {
+		return nil, nil
+	}
+
+	known := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		known[suite.Name] = suite.ID
+	}
+
+	var ids []uint16
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown or insecure TLS cipher suite %q", name)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+)
+
+const authServicePrefix = "/auth.AuthService/"
+
+// Методы, которые может вызывать только рабочая нагрузка из политики.
+// Админские RPC добавляются сюда вместе с обработчиками.
+var adminMethods = map[string]bool{
+	authServicePrefix + "CreateRole": true,
+	authServicePrefix + "GrantRole":  true,
+	authServicePrefix + "RevokeRole": true,
+
+	// Политики доступа (policies:manage)
+	authServicePrefix + "SavePolicy":   true,
+	authServicePrefix + "DeletePolicy": true,
+
+	// Сервисные аккаунты и API-ключи (service_accounts:manage)
+	authServicePrefix + "CreateServiceAccount": true,
+	authServicePrefix + "CreateApiKey":         true,
+	authServicePrefix + "ListApiKeys":          true,
+	authServicePrefix + "RotateApiKey":         true,
+	authServicePrefix + "RevokeApiKey":         true,
+
+	// Слияние учетных записей и вход от имени пользователя (users:merge, users:impersonate)
+	authServicePrefix + "MergeUsers":  true,
+	authServicePrefix + "Impersonate": true,
+
+	// Отношения - API для сервисов, а не для пользователей
+	authServicePrefix + "WriteRelationships": true,
+	authServicePrefix + "CheckPermission":    true,
+	authServicePrefix + "LookupResources":    true,
+	authServicePrefix + "Authorize":          true,
+}
+
+// workloadPolicy сопоставляет SPIFFE ID вызывающих сервисов с методами AuthService.
+//
+//	{
+//	  "trust_domain": "example.org",
+//	  "protected_methods": ["ValidateToken"],
+//	  "rules": [
+//	    {"spiffe_id": "spiffe://example.org/ns/gateway/sa/api", "methods": ["Login", "ValidateToken"]},
+//	    {"spiffe_id": "spiffe://example.org/ns/admin/*", "methods": ["*"]}
+//	  ]
+//	}
+//
+// Защищенные методы (protected_methods и админские RPC) разрешены только
+// нагрузкам, чье правило их перечисляет. Для остальных методов правило
+// ограничивает только те нагрузки, которым оно подходит; вызовы без SPIFFE ID
+// (например, пользовательские клиенты) проходят.
+type workloadPolicy struct {
+	TrustDomain      string         `json:"trust_domain"`
+	ProtectedMethods []string       `json:"protected_methods"`
+	Rules            []workloadRule `json:"rules"`
+
+	protected map[string]bool
+}
+
+type workloadRule struct {
+	// Точный SPIFFE ID или префикс с "/*" на конце
+	SPIFFEID string `json:"spiffe_id"`
+	// Короткие (Login) или полные (/auth.AuthService/Login) имена, "*" - все методы
+	Methods []string `json:"methods"`
+}
+
+func loadWorkloadPolicy(path string) (*workloadPolicy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read workload policy: %w", err)
+	}
+
+	var policy workloadPolicy
+	if err := json.Unmarshal(data, &policy); err != nil {
+		return nil, fmt.Errorf("failed to parse workload policy: %w", err)
+	}
+
+	for _, rule := range policy.Rules {
+		pattern := strings.TrimSuffix(rule.SPIFFEID, "/*")
+		if _, err := ParseSPIFFEID(pattern); err != nil {
+			return nil, fmt.Errorf("invalid workload policy rule: %w", err)
+		}
+	}
+
+	policy.protected = make(map[string]bool)
+	for method := range adminMethods {
+		policy.protected[method] = true
+	}
+	for _, method := range policy.ProtectedMethods {
+		policy.protected[fullMethodName(method)] = true
+	}
+
+	return &policy, nil
+}
+
+// authorize возвращает models.ErrWorkloadNotAllowed, если вызов запрещен
+func (p *workloadPolicy) authorize(fullMethod string, identity *models.PeerIdentity) error {
+	var spiffeID string
+	if identity != nil {
+		spiffeID = identity.SPIFFEID
+	}
+
+	if spiffeID != "" && p.TrustDomain != "" {
+		id, err := ParseSPIFFEID(spiffeID)
+		if err != nil || id.TrustDomain != p.TrustDomain {
+			return models.ErrWorkloadNotAllowed
+		}
+	}
+
+	rule := p.match(spiffeID)
+
+	if p.protected[fullMethod] {
+		if rule == nil || !rule.allows(fullMethod) {
+			return models.ErrWorkloadNotAllowed
+		}
+		return nil
+	}
+
+	if rule != nil && !rule.allows(fullMethod) {
+		return models.ErrWorkloadNotAllowed
+	}
+	return nil
+}
+
+// match выбирает правило: точное совпадение важнее самого длинного префикса
+func (p *workloadPolicy) match(spiffeID string) *workloadRule {
+	if spiffeID == "" {
+		return nil
+	}
+
+	var best *workloadRule
+	bestLen := -1
+
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if rule.SPIFFEID == spiffeID {
+			return rule
+		}
+
+		if prefix, ok := strings.CutSuffix(rule.SPIFFEID, "*"); ok && strings.HasPrefix(spiffeID, prefix) && len(prefix) > bestLen {
+			best = rule
+			bestLen = len(prefix)
+		}
+	}
+
+	return best
+}
+
+func (r *workloadRule) allows(fullMethod string) bool {
+	for _, method := range r.Methods {
+		if method == "*" || fullMethodName(method) == fullMethod {
+			return true
+		}
+	}
+	return false
+}
+
+func fullMethodName(method string) string {
+	if strings.HasPrefix(method, "/") {
+		return method
+	}
+	return authServicePrefix + method
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+
+	"github.com/pkg/errors"
+)
+
+// Время последнего использования пишем не на каждый запрос
+const apiKeyTouchInterval = time.Minute
+
+type APIKeyService struct {
+	keyRepo repository.APIKeyRepository
+	rbac    RBAC
+	audit   Audit
+}
+
+func NewAPIKeyService(keyRepo repository.APIKeyRepository, rbac RBAC, audit Audit) *APIKeyService {
+	return &APIKeyService{
+		keyRepo: keyRepo,
+		rbac:    rbac,
+		audit:   audit,
+	}
+}
+
+func (s *APIKeyService) CreateServiceAccount(ctx context.Context, actorID int64, account *models.ServiceAccount) (*models.ServiceAccount, error) {
+	account.Name = strings.TrimSpace(account.Name)
+	if err := account.Validate(); err != nil {
+		return nil, err
+	}
+	account.CreatedBy = &actorID
+	account.CreatedAt = time.Now()
+
+	if err := s.keyRepo.CreateServiceAccount(ctx, account); err != nil {
+		if err == models.ErrServiceAccountAlreadyExists {
+			return nil, err
+		}
+		return nil, errors.Wrap(err, "failed to create service account")
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditServiceAccountCreated,
+		UserID: &actorID,
+		Metadata: map[string]string{
+			"service_account_id": strconv.FormatInt(account.ID, 10),
+			"name":               account.Name,
+		},
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to record audit event")
+	}
+
+	return account, nil
+}
+
+// CreateAPIKey выпускает ключ сервисного аккаунта. Секрет возвращается один раз,
+// в базе остается только его хеш. Выдать можно только области, которые есть у самого администратора.
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, actorID int64, key *models.APIKey) (*models.APIKey, string, error) {
+	key.Name = strings.TrimSpace(key.Name)
+	key.Scopes = uniqueSorted(key.Scopes)
+	key.AllowedCIDRs = uniqueSorted(key.AllowedCIDRs)
+	if err := key.Validate(); err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+	if key.ExpiresAt != nil && !key.ExpiresAt.After(now) {
+		return nil, "", models.ErrInvalidAPIKeyExpiry
+	}
+	if err := s.requireScopes(ctx, actorID, key.Scopes); err != nil {
+		return nil, "", err
+	}
+
+	account, err := s.keyRepo.GetServiceAccount(ctx, key.ServiceAccountID)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to get service account")
+	}
+	if account == nil {
+		return nil, "", models.ErrServiceAccountNotFound
+	}
+
+	secret, err := s.newSecret(key)
+	if err != nil {
+		return nil, "", err
+	}
+	key.CreatedBy = &actorID
+	key.CreatedAt = now
+
+	if err := s.keyRepo.CreateAPIKey(ctx, key); err != nil {
+		if err == models.ErrServiceAccountNotFound {
+			return nil, "", err
+		}
+		return nil, "", errors.Wrap(err, "failed to create api key")
+	}
+
+	if err := s.recordKeyAudit(ctx, models.AuditAPIKeyCreated, actorID, key, nil); err != nil {
+		return nil, "", err
+	}
+
+	return key, secret, nil
+}
+
+func (s *APIKeyService) ListAPIKeys(ctx context.Context, serviceAccountID int64) ([]*models.APIKey, error) {
+	keys, err := s.keyRepo.ListAPIKeys(ctx, serviceAccountID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list api keys")
+	}
+	return keys, nil
+}
+
+// RotateAPIKey выпускает замену с теми же областями и сроком жизни.
+// Старый ключ продолжает работать grace (0 - перестает сразу).
+func (s *APIKeyService) RotateAPIKey(ctx context.Context, actorID, keyID int64, grace time.Duration) (*models.APIKey, string, error) {
+	old, err := s.keyRepo.GetAPIKey(ctx, keyID)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to get api key")
+	}
+
+	now := time.Now()
+	if old == nil || !old.IsActive(now) {
+		return nil, "", models.ErrAPIKeyNotFound
+	}
+	if err := s.requireScopes(ctx, actorID, old.Scopes); err != nil {
+		return nil, "", err
+	}
+
+	key := &models.APIKey{
+		ServiceAccountID: old.ServiceAccountID,
+		Name:             old.Name,
+		Scopes:           old.Scopes,
+		AllowedCIDRs:     old.AllowedCIDRs,
+		CreatedBy:        &actorID,
+		CreatedAt:        now,
+	}
+	if old.ExpiresAt != nil {
+		expiresAt := now.Add(old.ExpiresAt.Sub(old.CreatedAt))
+		key.ExpiresAt = &expiresAt
+	}
+
+	secret, err := s.newSecret(key)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if grace < 0 {
+		grace = 0
+	}
+	if err := s.keyRepo.RotateAPIKey(ctx, old.ID, key, now.Add(grace)); err != nil {
+		if err == models.ErrAPIKeyNotFound || err == models.ErrServiceAccountNotFound {
+			return nil, "", err
+		}
+		return nil, "", errors.Wrap(err, "failed to rotate api key")
+	}
+
+	if err := s.recordKeyAudit(ctx, models.AuditAPIKeyRotated, actorID, key, map[string]string{
+		"rotated_from": old.Prefix,
+		"grace":        grace.String(),
+	}); err != nil {
+		return nil, "", err
+	}
+
+	return key, secret, nil
+}
+
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, actorID, keyID int64) error {
+	key, err := s.keyRepo.GetAPIKey(ctx, keyID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get api key")
+	}
+	if key == nil {
+		return models.ErrAPIKeyNotFound
+	}
+
+	revoked, err := s.keyRepo.RevokeAPIKey(ctx, keyID, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to revoke api key")
+	}
+	if !revoked {
+		return models.ErrAPIKeyNotFound
+	}
+
+	return s.recordKeyAudit(ctx, models.AuditAPIKeyRevoked, actorID, key, nil)
+}
+
+// ValidateAPIKey проверяет ключ из запроса и возвращает сервисный аккаунт и области.
+// Неизвестный префикс, неверный секрет, отзыв и истечение неразличимы для вызывающего.
+func (s *APIKeyService) ValidateAPIKey(ctx context.Context, rawKey, ip string) (*models.APIKeyPrincipal, error) {
+	prefix, secret, ok := strings.Cut(strings.TrimSpace(rawKey), ".")
+	if !ok || !strings.HasPrefix(prefix, models.APIKeyPrefix) || secret == "" {
+		return nil, models.ErrInvalidAPIKey
+	}
+
+	key, err := s.keyRepo.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get api key")
+	}
+	if key == nil {
+		return nil, models.ErrInvalidAPIKey
+	}
+
+	if subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(key.SecretHash)) != 1 {
+		return nil, models.ErrInvalidAPIKey
+	}
+
+	now := time.Now()
+	if !key.IsActive(now) {
+		return nil, models.ErrInvalidAPIKey
+	}
+	if !key.AllowsIP(ip) {
+		return nil, models.ErrAPIKeyIPNotAllowed
+	}
+
+	account, err := s.keyRepo.GetServiceAccount(ctx, key.ServiceAccountID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get service account")
+	}
+	if account == nil {
+		return nil, models.ErrInvalidAPIKey
+	}
+
+	if err := s.keyRepo.TouchAPIKey(ctx, key.ID, ip, now, apiKeyTouchInterval); err != nil {
+		return nil, errors.Wrap(err, "failed to track api key use")
+	}
+
+	return &models.APIKeyPrincipal{Key: key, ServiceAccount: account}, nil
+}
+
+// requireScopes не дает выдать ключу больше, чем есть у администратора
+func (s *APIKeyService) requireScopes(ctx context.Context, actorID int64, scopes []string) error {
+	access, err := s.rbac.GetUserAccess(ctx, actorID)
+	if err != nil {
+		return err
+	}
+	for _, scope := range scopes {
+		if !access.HasPermission(scope) {
+			return models.ErrPermissionDenied
+		}
+	}
+	return nil
+}
+
+// newSecret заполняет префикс и хеш ключа и возвращает полный ключ для клиента
+func (s *APIKeyService) newSecret(key *models.APIKey) (string, error) {
+	id := make([]byte, 6)
+	if _, err := rand.Read(id); err != nil {
+		return "", errors.Wrap(err, "failed to generate api key prefix")
+	}
+
+	secret, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	key.Prefix = models.APIKeyPrefix + hex.EncodeToString(id)
+	key.SecretHash = hashToken(secret)
+
+	return key.Prefix + "." + secret, nil
+}
+
+func (s *APIKeyService) recordKeyAudit(ctx context.Context, eventType models.AuditEventType, actorID int64, key *models.APIKey, metadata map[string]string) error {
+	if metadata == nil {
+		metadata = make(map[string]string)
+	}
+	metadata["service_account_id"] = strconv.FormatInt(key.ServiceAccountID, 10)
+	metadata["prefix"] = key.Prefix
+	metadata["scopes"] = strings.Join(key.Scopes, " ")
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:     eventType,
+		UserID:   &actorID,
+		Metadata: metadata,
+	}); err != nil {
+		return errors.Wrap(err, "failed to record audit event")
+	}
+	return nil
+}
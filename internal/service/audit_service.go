@@ -0,0 +1,163 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+	"github.com/DailyPepper/auth-service/pkg/signing"
+
+	"github.com/pkg/errors"
+)
+
+const auditVerifyBatchSize = 1000
+
+type AuditService struct {
+	auditRepo       repository.AuditRepository
+	signer          *signing.Signer
+	checkpointEvery int64
+}
+
+func NewAuditService(auditRepo repository.AuditRepository, signer *signing.Signer, checkpointEvery int) *AuditService {
+	if checkpointEvery <= 0 {
+		checkpointEvery = 100
+	}
+	return &AuditService{
+		auditRepo:       auditRepo,
+		signer:          signer,
+		checkpointEvery: int64(checkpointEvery),
+	}
+}
+
+func (s *AuditService) Record(ctx context.Context, event *models.AuditEvent) error {
+	// Postgres хранит время с точностью до микросекунд - обрезаем заранее,
+	// иначе хеш прочитанной записи не совпадет с записанным
+	event.CreatedAt = time.Now().UTC().Truncate(time.Microsecond)
+
+	if tenant, ok := models.TenantFromContext(ctx); ok {
+		event.TenantID = &tenant.ID
+	}
+
+	if err := s.auditRepo.AppendAuditEvent(ctx, event, event.Seal); err != nil {
+		return errors.Wrap(err, "failed to append audit event")
+	}
+
+	return s.checkpointIfDue(ctx, event)
+}
+
+// Подписываем контрольную точку раз в checkpointEvery записей
+func (s *AuditService) checkpointIfDue(ctx context.Context, event *models.AuditEvent) error {
+	last, err := s.auditRepo.GetLastAuditCheckpoint(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to get last audit checkpoint")
+	}
+
+	var lastEventID int64
+	if last != nil {
+		lastEventID = last.EventID
+	}
+	if event.ID-lastEventID < s.checkpointEvery {
+		return nil
+	}
+
+	checkpoint := &models.AuditCheckpoint{
+		EventID:   event.ID,
+		EventHash: event.Hash,
+		KeyID:     s.signer.KeyID(),
+		CreatedAt: time.Now().UTC(),
+	}
+	checkpoint.Signature = s.signer.Sign(checkpoint.SigningPayload())
+
+	return errors.Wrap(s.auditRepo.CreateAuditCheckpoint(ctx, checkpoint), "failed to create audit checkpoint")
+}
+
+// Verify проходит цепочку от начала и возвращает первое нарушение
+func (s *AuditService) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	checkpoints, err := s.auditRepo.ListAuditCheckpoints(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list audit checkpoints")
+	}
+
+	byEventID := make(map[int64]*models.AuditCheckpoint, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		byEventID[checkpoint.EventID] = checkpoint
+	}
+
+	result := &models.AuditVerification{LastHash: models.AuditGenesisHash}
+	var lastID int64
+
+	for {
+		events, err := s.auditRepo.ListAuditEvents(ctx, lastID, auditVerifyBatchSize)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list audit events")
+		}
+
+		for _, event := range events {
+			if reason := s.verifyEvent(event, result.LastHash); reason != "" {
+				result.Break = &models.AuditChainBreak{EventID: event.ID, Reason: reason}
+				return result, nil
+			}
+
+			if checkpoint, ok := byEventID[event.ID]; ok {
+				if reason := s.verifyCheckpoint(checkpoint, event); reason != "" {
+					result.Break = &models.AuditChainBreak{EventID: event.ID, Reason: reason}
+					return result, nil
+				}
+				result.CheckpointsChecked++
+				delete(byEventID, event.ID)
+			}
+
+			result.LastHash = event.Hash
+			result.EventsChecked++
+			lastID = event.ID
+		}
+
+		if len(events) < auditVerifyBatchSize {
+			break
+		}
+	}
+
+	// Контрольная точка указывает на запись, которой больше нет - хвост цепочки удален
+	for _, checkpoint := range checkpoints {
+		if _, missing := byEventID[checkpoint.EventID]; missing {
+			result.Break = &models.AuditChainBreak{
+				EventID: checkpoint.EventID,
+				Reason:  "checkpointed event is missing from the chain",
+			}
+			break
+		}
+	}
+
+	return result, nil
+}
+
+func (s *AuditService) verifyEvent(event *models.AuditEvent, prevHash string) string {
+	if event.PrevHash != prevHash {
+		return fmt.Sprintf("prev_hash %s does not match previous record hash %s", event.PrevHash, prevHash)
+	}
+
+	hash, err := event.ComputeHash(prevHash)
+	if err != nil {
+		return err.Error()
+	}
+	if hash != event.Hash {
+		return fmt.Sprintf("record hash %s does not match recomputed hash %s", event.Hash, hash)
+	}
+
+	return ""
+}
+
+func (s *AuditService) verifyCheckpoint(checkpoint *models.AuditCheckpoint, event *models.AuditEvent) string {
+	if checkpoint.EventHash != event.Hash {
+		return fmt.Sprintf("checkpoint %d hash does not match record hash", checkpoint.ID)
+	}
+	if checkpoint.KeyID != s.signer.KeyID() {
+		return fmt.Sprintf("checkpoint %d is signed by unknown key %s", checkpoint.ID, checkpoint.KeyID)
+	}
+	if !s.signer.Verify(checkpoint.SigningPayload(), checkpoint.Signature) {
+		return fmt.Sprintf("checkpoint %d has an invalid signature", checkpoint.ID)
+	}
+	return ""
+}
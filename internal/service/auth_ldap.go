@@ -0,0 +1,384 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+
+	"github.com/go-ldap/ldap/v3"
+	"github.com/pkg/errors"
+)
+
+// Настройки каталога LDAP / Active Directory
+type LDAPConfig struct {
+	// ldap://host:389 или ldaps://host:636
+	URL      string
+	StartTLS bool
+	// ldap:// без StartTLS передает пароли открытым текстом - только явно
+	AllowCleartext bool
+	// PEM с корневыми сертификатами каталога; пусто - системные
+	CACertPath string
+
+	// Шаблоны DN для прямого bind пользователя, например
+	// uid={username},ou=people,dc=example,dc=com или {email} для UPN в AD.
+	// Без шаблонов запись ищется по UserFilter от имени BindDN. С шаблоном DN
+	// запись читается по этому DN, с UPN - ищется по UserFilter под BaseDN.
+	UserDNTemplates []string
+	BindDN          string
+	BindPassword    string
+	BaseDN          string
+	UserFilter      string
+
+	// Атрибуты записи и роли по группам, в формате сопоставления провайдеров входа
+	Mapping models.AttributeMapping
+
+	PoolSize int
+	Timeout  time.Duration
+}
+
+// Стандартные атрибуты каталога в терминах сопоставления по умолчанию
+var ldapStandardAttributes = map[string]string{
+	"mail":      "email",
+	"givenName": "given_name",
+	"sn":        "family_name",
+	"memberOf":  "groups",
+}
+
+// LDAPAuthProvider проверяет пароль bind-ом в корпоративный каталог. Запись
+// каталога принимается, только если ее email совпадает с email входа: иначе
+// uid=alice из каталога вошел бы в чужой аккаунт alice@другой-домен.
+type LDAPAuthProvider struct {
+	cfg  LDAPConfig
+	tls  *tls.Config
+	pool *ldapPool
+}
+
+func NewLDAPAuthProvider(cfg LDAPConfig) (*LDAPAuthProvider, error) {
+	if len(cfg.UserDNTemplates) == 0 && (cfg.BaseDN == "" || cfg.BindDN == "") {
+		return nil, errors.New("ldap needs user DN templates or a base DN with a bind DN for search")
+	}
+	for _, template := range cfg.UserDNTemplates {
+		if !ldapDNTemplate(template) && cfg.BaseDN == "" {
+			return nil, errors.New("ldap needs a base DN to find entries of users bound by UPN")
+		}
+	}
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(mail={email})"
+	}
+	if cfg.PoolSize <= 0 {
+		cfg.PoolSize = 1
+	}
+
+	parsed, err := url.Parse(cfg.URL)
+	if err != nil || (parsed.Scheme != "ldap" && parsed.Scheme != "ldaps") {
+		return nil, errors.New("ldap url must be ldap:// or ldaps://")
+	}
+	if parsed.Scheme == "ldap" && !cfg.StartTLS && !cfg.AllowCleartext {
+		return nil, errors.New("ldap:// without StartTLS sends passwords in cleartext; use ldaps://, enable StartTLS or allow cleartext explicitly")
+	}
+	tlsConfig := &tls.Config{ServerName: parsed.Hostname(), MinVersion: tls.VersionTLS12}
+	if cfg.CACertPath != "" {
+		data, err := os.ReadFile(cfg.CACertPath)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read ldap ca certificate")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, errors.New("ldap ca certificate file has no certificates")
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	p := &LDAPAuthProvider{cfg: cfg, tls: tlsConfig}
+	p.pool = newLDAPPool(cfg.PoolSize, p.dial)
+	return p, nil
+}
+
+func (p *LDAPAuthProvider) Name() string {
+	return models.AuthProviderLDAP
+}
+
+func (p *LDAPAuthProvider) Authenticate(ctx context.Context, email, password string, user *models.User) (*models.Authentication, error) {
+	// Bind с пустым паролем - анонимный и "успешен" на многих серверах
+	if email == "" || password == "" {
+		return nil, models.ErrInvalidCredentials
+	}
+
+	// Соединение из пула мог закрыть сервер - тогда пробуем еще раз на новом
+	for retry := 0; ; retry++ {
+		conn, err := p.pool.get(ctx)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to connect to ldap")
+		}
+		entry, err := p.authenticate(conn, email, password)
+		// Обрыв чтения go-ldap возвращает без кода ErrorNetwork, но соединение
+		// к этому моменту уже помечено закрытым
+		broken := err != nil && (ldap.IsErrorWithCode(err, ldap.ErrorNetwork) || conn.IsClosing())
+		p.pool.put(conn, broken)
+		if broken && retry == 0 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return p.authentication(entry, email)
+	}
+}
+
+// authenticate выполняет bind пользователя и возвращает его запись
+func (p *LDAPAuthProvider) authenticate(conn *ldap.Conn, email, password string) (*ldap.Entry, error) {
+	if len(p.cfg.UserDNTemplates) == 0 {
+		if err := conn.Bind(p.cfg.BindDN, p.cfg.BindPassword); err != nil {
+			return nil, errors.Wrap(err, "ldap service bind failed")
+		}
+		entry, err := p.search(conn, email)
+		if err != nil {
+			return nil, err
+		}
+		if err := conn.Bind(entry.DN, password); err != nil {
+			return nil, bindError(err)
+		}
+		return entry, nil
+	}
+
+	for _, template := range p.cfg.UserDNTemplates {
+		dn := expandLDAPTemplate(template, email, ldap.EscapeDN)
+		err := conn.Bind(dn, password)
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			continue
+		}
+		if err != nil {
+			return nil, bindError(err)
+		}
+		// Запись читаем от имени самого пользователя
+		if !ldapDNTemplate(template) {
+			return p.search(conn, email)
+		}
+		return p.read(conn, dn)
+	}
+	return nil, models.ErrInvalidCredentials
+}
+
+// read читает запись по DN, с которым прошел bind
+func (p *LDAPAuthProvider) read(conn *ldap.Conn, dn string) (*ldap.Entry, error) {
+	result, err := conn.Search(ldap.NewSearchRequest(
+		dn, ldap.ScopeBaseObject, ldap.NeverDerefAliases,
+		1, int(p.cfg.Timeout.Seconds()), false,
+		"(objectClass=*)", p.attributes(), nil,
+	))
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) || ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidDNSyntax) {
+		return nil, models.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "ldap read failed")
+	}
+	if len(result.Entries) != 1 {
+		return nil, models.ErrInvalidCredentials
+	}
+	return result.Entries[0], nil
+}
+
+func (p *LDAPAuthProvider) search(conn *ldap.Conn, email string) (*ldap.Entry, error) {
+	filter := expandLDAPTemplate(p.cfg.UserFilter, email, ldap.EscapeFilter)
+	result, err := conn.Search(ldap.NewSearchRequest(
+		p.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(p.cfg.Timeout.Seconds()), false,
+		filter, p.attributes(), nil,
+	))
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultNoSuchObject) || ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, models.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "ldap search failed")
+	}
+	// Неоднозначный email не принимаем
+	if len(result.Entries) != 1 {
+		return nil, models.ErrInvalidCredentials
+	}
+	return result.Entries[0], nil
+}
+
+// authentication переводит запись каталога в результат входа
+func (p *LDAPAuthProvider) authentication(entry *ldap.Entry, email string) (*models.Authentication, error) {
+	external := &models.ExternalIdentity{
+		Provider: models.AuthProviderLDAP,
+		Subject:  entry.DN,
+		Claims:   ldapClaims(entry),
+	}
+	p.cfg.Mapping.Apply(external)
+	if !strings.EqualFold(external.Email, strings.TrimSpace(email)) {
+		return nil, models.ErrInvalidCredentials
+	}
+
+	return &models.Authentication{
+		Provider:     models.AuthProviderLDAP,
+		Subject:      entry.DN,
+		FirstName:    external.GivenName,
+		Surname:      external.FamilyName,
+		Roles:        p.cfg.Mapping.Roles(ldapGroups(external.Groups)),
+		ManagedRoles: p.cfg.Mapping.ManagedRoles(),
+	}, nil
+}
+
+// Кроме обычных атрибутов запрашиваются memberOf (в OpenLDAP он операционный)
+// и атрибуты из сопоставления
+func (p *LDAPAuthProvider) attributes() []string {
+	attributes := []string{"*", "memberOf"}
+	mapping := p.cfg.Mapping
+	for _, name := range []string{mapping.Email, mapping.FirstName, mapping.Surname, mapping.Groups} {
+		if name != "" {
+			attributes = append(attributes, name)
+		}
+	}
+	return uniqueSorted(attributes)
+}
+
+func (p *LDAPAuthProvider) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(p.cfg.URL,
+		ldap.DialWithTLSConfig(p.tls),
+		ldap.DialWithDialer(&net.Dialer{Timeout: p.cfg.Timeout}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	conn.SetTimeout(p.cfg.Timeout)
+
+	if p.cfg.StartTLS {
+		if err := conn.StartTLS(p.tls); err != nil {
+			conn.Close()
+			return nil, errors.Wrap(err, "ldap starttls failed")
+		}
+	}
+	return conn, nil
+}
+
+func bindError(err error) error {
+	if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+		return models.ErrInvalidCredentials
+	}
+	return errors.Wrap(err, "ldap bind failed")
+}
+
+// Шаблон вида {email} дает UPN для AD, а не DN записи
+func ldapDNTemplate(template string) bool {
+	return strings.Contains(template, "=")
+}
+
+// expandLDAPTemplate подставляет {email} и {username} (часть email до @)
+func expandLDAPTemplate(template, email string, escape func(string) string) string {
+	email = strings.TrimSpace(email)
+	username := email
+	if at := strings.LastIndex(email, "@"); at >= 0 {
+		username = email[:at]
+	}
+	return strings.NewReplacer("{email}", escape(email), "{username}", escape(username)).Replace(template)
+}
+
+func ldapClaims(entry *ldap.Entry) map[string]interface{} {
+	claims := make(map[string]interface{})
+	set := func(name string, value interface{}) {
+		claims[name] = value
+		if standard, ok := ldapStandardAttributes[name]; ok {
+			if _, exists := claims[standard]; !exists {
+				claims[standard] = value
+			}
+		}
+	}
+
+	for _, attribute := range entry.Attributes {
+		if len(attribute.Values) == 0 {
+			continue
+		}
+		// Группы всегда списком, даже из одного значения
+		if len(attribute.Values) == 1 && attribute.Name != "memberOf" {
+			set(attribute.Name, attribute.Values[0])
+			continue
+		}
+		values := make([]interface{}, len(attribute.Values))
+		for i, value := range attribute.Values {
+			values[i] = value
+		}
+		set(attribute.Name, values)
+	}
+	return claims
+}
+
+// ldapGroups дополняет DN групп их CN, чтобы в сопоставлении можно было
+// писать и "cn=admins,ou=groups,dc=example,dc=com", и просто "admins"
+func ldapGroups(groups []string) []string {
+	result := append([]string(nil), groups...)
+	for _, group := range groups {
+		dn, err := ldap.ParseDN(group)
+		if err != nil || len(dn.RDNs) == 0 {
+			continue
+		}
+		for _, attribute := range dn.RDNs[0].Attributes {
+			if strings.EqualFold(attribute.Type, "cn") {
+				result = append(result, attribute.Value)
+			}
+		}
+	}
+	return result
+}
+
+// ldapPool ограничивает число одновременных соединений с каталогом и держит
+// свободные открытыми. Каждая операция начинается с bind, поэтому соединение
+// после чужого bind можно использовать повторно.
+type ldapPool struct {
+	dial  func() (*ldap.Conn, error)
+	idle  chan *ldap.Conn
+	slots chan struct{}
+}
+
+func newLDAPPool(size int, dial func() (*ldap.Conn, error)) *ldapPool {
+	return &ldapPool{
+		dial:  dial,
+		idle:  make(chan *ldap.Conn, size),
+		slots: make(chan struct{}, size),
+	}
+}
+
+func (p *ldapPool) get(ctx context.Context) (*ldap.Conn, error) {
+	select {
+	case p.slots <- struct{}{}:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	for {
+		select {
+		case conn := <-p.idle:
+			if !conn.IsClosing() {
+				return conn, nil
+			}
+			conn.Close()
+		default:
+			conn, err := p.dial()
+			if err != nil {
+				<-p.slots
+				return nil, err
+			}
+			return conn, nil
+		}
+	}
+}
+
+func (p *ldapPool) put(conn *ldap.Conn, broken bool) {
+	defer func() { <-p.slots }()
+	if broken || conn.IsClosing() {
+		conn.Close()
+		return
+	}
+	select {
+	case p.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
@@ -0,0 +1,498 @@
+package service
+
+import (
+	"context"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+	"github.com/go-ldap/ldap/v3"
+)
+
+const (
+	ldapTestBaseDN     = "dc=example,dc=com"
+	ldapTestServiceDN  = "cn=auth,ou=services,dc=example,dc=com"
+	ldapTestServicePwd = "service-secret"
+	ldapTestAliceDN    = "uid=alice,ou=people,dc=example,dc=com"
+	ldapTestAlicePwd   = "alice-secret"
+)
+
+type ldapTestEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+// ldapStandIn - каталог в памяти процесса: понимает bind, поиск по равенству
+// и unbind. Запоминает DN всех bind и фильтры поиска, чтобы проверять
+// экранирование на стороне сервера.
+type ldapStandIn struct {
+	listener net.Listener
+	entries  []ldapTestEntry
+
+	mu       sync.Mutex
+	binds    []string
+	searches []*ber.Packet
+	conns    int
+	// Номер соединения, которое сервер оборвет на следующем запросе
+	dropConn int
+}
+
+func newLDAPStandIn(t *testing.T, entries ...ldapTestEntry) *ldapStandIn {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &ldapStandIn{listener: listener, entries: entries}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.conns++
+			number := s.conns
+			s.mu.Unlock()
+			go s.serve(conn, number)
+		}
+	}()
+	return s
+}
+
+func (s *ldapStandIn) url() string {
+	return "ldap://" + s.listener.Addr().String()
+}
+
+func (s *ldapStandIn) serve(conn net.Conn, number int) {
+	defer conn.Close()
+
+	var boundDN string
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+
+		s.mu.Lock()
+		drop := s.dropConn == number
+		if drop {
+			s.dropConn = 0
+		}
+		s.mu.Unlock()
+		if drop {
+			return
+		}
+
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case ldap.ApplicationBindRequest:
+			dn := op.Children[1].Value.(string)
+			password := op.Children[2].Data.String()
+
+			s.mu.Lock()
+			s.binds = append(s.binds, dn)
+			s.mu.Unlock()
+
+			var code uint16 = ldap.LDAPResultInvalidCredentials
+			if entry := s.find(dn); entry != nil && password != "" && entry.password == password {
+				code = ldap.LDAPResultSuccess
+				boundDN = dn
+			}
+			conn.Write(ldapMessage(id, ldapResult(ldap.ApplicationBindResponse, code)))
+
+		case ldap.ApplicationSearchRequest:
+			base := op.Children[0].Value.(string)
+			scope := op.Children[1].Value.(int64)
+			filter := op.Children[6]
+
+			s.mu.Lock()
+			s.searches = append(s.searches, filter)
+			s.mu.Unlock()
+
+			// Как настоящий каталог, анонимам искать не даем
+			if boundDN == "" {
+				conn.Write(ldapMessage(id, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultInsufficientAccessRights)))
+				continue
+			}
+			// Как и настоящий каталог, поиск от пустой базы ничего не находит
+			if base == "" {
+				conn.Write(ldapMessage(id, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultNoSuchObject)))
+				continue
+			}
+			for _, entry := range s.search(base, scope, filter) {
+				conn.Write(ldapMessage(id, ldapSearchEntry(entry)))
+			}
+			conn.Write(ldapMessage(id, ldapResult(ldap.ApplicationSearchResultDone, ldap.LDAPResultSuccess)))
+
+		case ldap.ApplicationUnbindRequest:
+			return
+		}
+	}
+}
+
+func (s *ldapStandIn) find(dn string) *ldapTestEntry {
+	for i := range s.entries {
+		if strings.EqualFold(s.entries[i].dn, dn) {
+			return &s.entries[i]
+		}
+	}
+	return nil
+}
+
+// Чтение записи по DN принимает любой фильтр, поиск по поддереву - только
+// фильтр равенства
+func (s *ldapStandIn) search(base string, scope int64, filter *ber.Packet) []ldapTestEntry {
+	if scope == ldap.ScopeBaseObject {
+		if entry := s.find(base); entry != nil {
+			return []ldapTestEntry{*entry}
+		}
+		return nil
+	}
+	if filter.ClassType != ber.ClassContext || filter.Tag != ldap.FilterEqualityMatch {
+		return nil
+	}
+	attribute := filter.Children[0].Value.(string)
+	value := filter.Children[1].Value.(string)
+
+	var entries []ldapTestEntry
+	for _, entry := range s.entries {
+		if !strings.HasSuffix(strings.ToLower(entry.dn), strings.ToLower(base)) {
+			continue
+		}
+		for name, values := range entry.attributes {
+			if !strings.EqualFold(name, attribute) {
+				continue
+			}
+			for _, v := range values {
+				if strings.EqualFold(v, value) {
+					entries = append(entries, entry)
+				}
+			}
+		}
+	}
+	return entries
+}
+
+func (s *ldapStandIn) drop(number int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.dropConn = number
+}
+
+func (s *ldapStandIn) recorded() (binds []string, searches []*ber.Packet, conns int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]string(nil), s.binds...), append([]*ber.Packet(nil), s.searches...), s.conns
+}
+
+func ldapMessage(id int64, op *ber.Packet) []byte {
+	envelope := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "LDAP Response")
+	envelope.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, "Message ID"))
+	envelope.AppendChild(op)
+	return envelope.Bytes()
+}
+
+func ldapResult(tag ber.Tag, code uint16) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "Result")
+	op.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, int64(code), "Result Code"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Matched DN"))
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", "Diagnostic Message"))
+	return op
+}
+
+func ldapSearchEntry(entry ldapTestEntry) *ber.Packet {
+	op := ber.Encode(ber.ClassApplication, ber.TypeConstructed, ldap.ApplicationSearchResultEntry, nil, "Search Result Entry")
+	op.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, "DN"))
+
+	attributes := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attributes")
+	for name, values := range entry.attributes {
+		attribute := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSequence, nil, "Attribute")
+		attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, "Type"))
+		set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "Values")
+		for _, value := range values {
+			set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, "Value"))
+		}
+		attribute.AppendChild(set)
+		attributes.AppendChild(attribute)
+	}
+	op.AppendChild(attributes)
+	return op
+}
+
+func ldapTestDirectory() []ldapTestEntry {
+	return []ldapTestEntry{
+		{dn: ldapTestServiceDN, password: ldapTestServicePwd},
+		{
+			dn:       ldapTestAliceDN,
+			password: ldapTestAlicePwd,
+			attributes: map[string][]string{
+				"uid":       {"alice"},
+				"mail":      {"alice@example.com"},
+				"givenName": {"Alice"},
+				"sn":        {"Liddell"},
+				"memberOf": {
+					"cn=admins,ou=groups,dc=example,dc=com",
+					"cn=staff,ou=groups,dc=example,dc=com",
+				},
+			},
+		},
+	}
+}
+
+func newTestLDAPProvider(t *testing.T, server *ldapStandIn, cfg LDAPConfig) *LDAPAuthProvider {
+	t.Helper()
+
+	cfg.URL = server.url()
+	cfg.AllowCleartext = true
+	cfg.Timeout = 5 * time.Second
+	provider, err := NewLDAPAuthProvider(cfg)
+	if err != nil {
+		t.Fatalf("NewLDAPAuthProvider: %v", err)
+	}
+	return provider
+}
+
+func searchConfig() LDAPConfig {
+	return LDAPConfig{
+		BindDN:       ldapTestServiceDN,
+		BindPassword: ldapTestServicePwd,
+		BaseDN:       ldapTestBaseDN,
+	}
+}
+
+func TestLDAPBindWithDNTemplate(t *testing.T) {
+	server := newLDAPStandIn(t, ldapTestDirectory()...)
+	provider := newTestLDAPProvider(t, server, LDAPConfig{
+		// Первый шаблон не подходит - провайдер переходит ко второму
+		UserDNTemplates: []string{
+			"uid={username},ou=contractors,dc=example,dc=com",
+			"uid={username},ou=people,dc=example,dc=com",
+		},
+		// BaseDN не задан: запись читается по DN, с которым прошел bind
+	})
+
+	authn, err := provider.Authenticate(context.Background(), "alice@example.com", ldapTestAlicePwd, nil)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if authn.Subject != ldapTestAliceDN || authn.FirstName != "Alice" || authn.Surname != "Liddell" {
+		t.Fatalf("unexpected authentication: %+v", authn)
+	}
+
+	binds, searches, _ := server.recorded()
+	want := []string{"uid=alice,ou=contractors,dc=example,dc=com", ldapTestAliceDN}
+	if strings.Join(binds, ";") != strings.Join(want, ";") {
+		t.Fatalf("binds = %q, want %q", binds, want)
+	}
+	if len(searches) != 1 {
+		t.Fatalf("searches = %d, want 1", len(searches))
+	}
+
+	if _, err := provider.Authenticate(context.Background(), "alice@example.com", "wrong", nil); err != models.ErrInvalidCredentials {
+		t.Fatalf("Authenticate with wrong password: got %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestLDAPSearchThenBind(t *testing.T) {
+	server := newLDAPStandIn(t, ldapTestDirectory()...)
+	provider := newTestLDAPProvider(t, server, searchConfig())
+
+	authn, err := provider.Authenticate(context.Background(), "Alice@Example.com", ldapTestAlicePwd, nil)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+	if authn.Subject != ldapTestAliceDN {
+		t.Fatalf("subject = %q, want %q", authn.Subject, ldapTestAliceDN)
+	}
+
+	binds, searches, _ := server.recorded()
+	if len(binds) != 2 || binds[0] != ldapTestServiceDN || binds[1] != ldapTestAliceDN {
+		t.Fatalf("binds = %q, want service bind then user bind", binds)
+	}
+	if len(searches) != 1 {
+		t.Fatalf("searches = %d, want 1", len(searches))
+	}
+
+	if _, err := provider.Authenticate(context.Background(), "alice@example.com", "wrong", nil); err != models.ErrInvalidCredentials {
+		t.Fatalf("Authenticate with wrong password: got %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := provider.Authenticate(context.Background(), "nobody@example.com", "secret", nil); err != models.ErrInvalidCredentials {
+		t.Fatalf("Authenticate unknown user: got %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestLDAPEscapesFilter(t *testing.T) {
+	directory := append(ldapTestDirectory(), ldapTestEntry{
+		dn:         "uid=mallory,ou=people,dc=example,dc=com",
+		password:   "mallory-secret",
+		attributes: map[string][]string{"mail": {"mallory@example.com"}},
+	})
+	server := newLDAPStandIn(t, directory...)
+	provider := newTestLDAPProvider(t, server, searchConfig())
+
+	// Без экранирования получился бы фильтр (mail=*)(uid=*), находящий всех
+	email := "*)(uid=*"
+	if _, err := provider.Authenticate(context.Background(), email, "mallory-secret", nil); err != models.ErrInvalidCredentials {
+		t.Fatalf("Authenticate: got %v, want ErrInvalidCredentials", err)
+	}
+
+	_, searches, _ := server.recorded()
+	if len(searches) != 1 {
+		t.Fatalf("searches = %d, want 1", len(searches))
+	}
+	filter := searches[0]
+	if filter.Tag != ldap.FilterEqualityMatch {
+		t.Fatalf("filter tag = %d, want equality match", filter.Tag)
+	}
+	if attribute, value := filter.Children[0].Value, filter.Children[1].Value; attribute != "mail" || value != email {
+		t.Fatalf("filter = (%v=%v), want (mail=%s) as a literal value", attribute, value, email)
+	}
+}
+
+func TestLDAPEscapesDN(t *testing.T) {
+	server := newLDAPStandIn(t, ldapTestDirectory()...)
+	provider := newTestLDAPProvider(t, server, LDAPConfig{
+		UserDNTemplates: []string{"uid={username},ou=people,dc=example,dc=com"},
+		BaseDN:          ldapTestBaseDN,
+	})
+
+	// Запятая в имени не должна превратиться в новый компонент DN
+	if _, err := provider.Authenticate(context.Background(), "alice,ou=people@example.com", ldapTestAlicePwd, nil); err != models.ErrInvalidCredentials {
+		t.Fatalf("Authenticate: got %v, want ErrInvalidCredentials", err)
+	}
+
+	binds, _, _ := server.recorded()
+	want := `uid=alice\,ou=people,ou=people,dc=example,dc=com`
+	if len(binds) != 1 || binds[0] != want {
+		t.Fatalf("binds = %q, want %q", binds, want)
+	}
+}
+
+func TestLDAPRejectsEmptyPassword(t *testing.T) {
+	server := newLDAPStandIn(t, ldapTestDirectory()...)
+	provider := newTestLDAPProvider(t, server, searchConfig())
+
+	if _, err := provider.Authenticate(context.Background(), "alice@example.com", "", nil); err != models.ErrInvalidCredentials {
+		t.Fatalf("Authenticate: got %v, want ErrInvalidCredentials", err)
+	}
+
+	// Анонимный bind не должен даже уйти в каталог
+	if binds, _, conns := server.recorded(); len(binds) != 0 || conns != 0 {
+		t.Fatalf("directory was contacted: binds %q, connections %d", binds, conns)
+	}
+}
+
+func TestLDAPRejectsEntryWithOtherEmail(t *testing.T) {
+	server := newLDAPStandIn(t, ldapTestDirectory()...)
+	provider := newTestLDAPProvider(t, server, LDAPConfig{
+		UserDNTemplates: []string{"uid={username},ou=people,dc=example,dc=com"},
+		BaseDN:          ldapTestBaseDN,
+	})
+
+	// uid=alice из каталога не входит в аккаунт alice@другой-домен
+	if _, err := provider.Authenticate(context.Background(), "alice@other.com", ldapTestAlicePwd, nil); err != models.ErrInvalidCredentials {
+		t.Fatalf("Authenticate: got %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestLDAPGroupRoles(t *testing.T) {
+	server := newLDAPStandIn(t, ldapTestDirectory()...)
+
+	cfg := searchConfig()
+	cfg.Mapping = models.AttributeMapping{
+		GroupRoles: map[string][]string{
+			// По CN группы и по полному DN
+			"admins":                               {"admin"},
+			"cn=staff,ou=groups,dc=example,dc=com": {"staff"},
+			"contractors":                          {"contractor"},
+		},
+		DefaultRoles: []string{"employee"},
+	}
+	provider := newTestLDAPProvider(t, server, cfg)
+
+	authn, err := provider.Authenticate(context.Background(), "alice@example.com", ldapTestAlicePwd, nil)
+	if err != nil {
+		t.Fatalf("Authenticate: %v", err)
+	}
+
+	roles := append([]string(nil), authn.Roles...)
+	sort.Strings(roles)
+	if strings.Join(roles, ",") != "admin,employee,staff" {
+		t.Fatalf("roles = %v, want [admin employee staff]", roles)
+	}
+
+	managed := append([]string(nil), authn.ManagedRoles...)
+	sort.Strings(managed)
+	if strings.Join(managed, ",") != "admin,contractor,employee,staff" {
+		t.Fatalf("managed roles = %v, want [admin contractor employee staff]", managed)
+	}
+}
+
+func TestLDAPPoolRetriesDroppedConnection(t *testing.T) {
+	server := newLDAPStandIn(t, ldapTestDirectory()...)
+	provider := newTestLDAPProvider(t, server, searchConfig())
+
+	if _, err := provider.Authenticate(context.Background(), "alice@example.com", ldapTestAlicePwd, nil); err != nil {
+		t.Fatalf("first Authenticate: %v", err)
+	}
+	if _, _, conns := server.recorded(); conns != 1 {
+		t.Fatalf("connections = %d, want 1", conns)
+	}
+
+	// Каталог обрывает соединение, которое лежит в пуле
+	server.drop(1)
+
+	if _, err := provider.Authenticate(context.Background(), "alice@example.com", ldapTestAlicePwd, nil); err != nil {
+		t.Fatalf("Authenticate after dropped connection: %v", err)
+	}
+	if _, _, conns := server.recorded(); conns != 2 {
+		t.Fatalf("connections = %d, want 2 (retry on a fresh connection)", conns)
+	}
+
+	// Новое соединение вернулось в пул и используется дальше
+	if _, err := provider.Authenticate(context.Background(), "alice@example.com", ldapTestAlicePwd, nil); err != nil {
+		t.Fatalf("Authenticate on pooled connection: %v", err)
+	}
+	if _, _, conns := server.recorded(); conns != 2 {
+		t.Fatalf("connections = %d, want 2", conns)
+	}
+}
+
+func TestNewLDAPAuthProviderConfig(t *testing.T) {
+	templates := []string{"uid={username},ou=people,dc=example,dc=com"}
+
+	tests := []struct {
+		name string
+		cfg  LDAPConfig
+		ok   bool
+	}{
+		// Пароли открытым текстом - только по явному разрешению
+		{"cleartext", LDAPConfig{URL: "ldap://ldap.example.com", UserDNTemplates: templates}, false},
+		{"cleartext allowed", LDAPConfig{URL: "ldap://ldap.example.com", UserDNTemplates: templates, AllowCleartext: true}, true},
+		{"starttls", LDAPConfig{URL: "ldap://ldap.example.com", UserDNTemplates: templates, StartTLS: true}, true},
+		{"ldaps", LDAPConfig{URL: "ldaps://ldap.example.com", UserDNTemplates: templates}, true},
+		// Запись пользователя, вошедшего по UPN, ищется под BaseDN
+		{"upn without base", LDAPConfig{URL: "ldaps://ldap.example.com", UserDNTemplates: []string{"{email}"}}, false},
+		{"upn with base", LDAPConfig{URL: "ldaps://ldap.example.com", UserDNTemplates: []string{"{email}"}, BaseDN: ldapTestBaseDN}, true},
+		{"search without bind dn", LDAPConfig{URL: "ldaps://ldap.example.com", BaseDN: ldapTestBaseDN}, false},
+	}
+	for _, tt := range tests {
+		_, err := NewLDAPAuthProvider(tt.cfg)
+		if (err == nil) != tt.ok {
+			t.Errorf("%s: err = %v, want ok = %v", tt.name, err, tt.ok)
+		}
+	}
+}
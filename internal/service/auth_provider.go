@@ -0,0 +1,34 @@
+package service
+
+import (
+	"context"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+)
+
+// AuthProvider проверяет email и пароль при входе. RegistrService опрашивает
+// провайдеров по порядку, пока один из них не примет пароль.
+type AuthProvider interface {
+	Name() string
+	// Authenticate возвращает models.ErrInvalidCredentials, если пароль не подошел
+	// или пользователь провайдеру неизвестен. user - локальный пользователь
+	// с этим email или nil.
+	Authenticate(ctx context.Context, email, password string, user *models.User) (*models.Authentication, error)
+}
+
+// PasswordAuthProvider проверяет хеш пароля, сохраненный у нас в базе
+type PasswordAuthProvider struct{}
+
+func (PasswordAuthProvider) Name() string {
+	return models.AuthProviderPassword
+}
+
+func (PasswordAuthProvider) Authenticate(ctx context.Context, email, password string, user *models.User) (*models.Authentication, error) {
+	if user == nil || !user.CheckPassword(password) {
+		return nil, models.ErrInvalidCredentials
+	}
+	return &models.Authentication{
+		Provider: models.AuthProviderPassword,
+		Subject:  user.Email,
+	}, nil
+}
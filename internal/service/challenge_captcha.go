@@ -0,0 +1,83 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+
+	"github.com/pkg/errors"
+)
+
+// CaptchaVerifier проверяет токен виджета hCaptcha / Cloudflare Turnstile
+// через siteverify-эндпоинт провайдера. Адрес настраивается, поэтому локально
+// его можно заменить заглушкой.
+type CaptchaVerifier struct {
+	verifyURL string
+	siteKey   string
+	secret    string
+	client    *http.Client
+}
+
+func NewCaptchaVerifier(verifyURL, siteKey, secret string) *CaptchaVerifier {
+	return &CaptchaVerifier{
+		verifyURL: verifyURL,
+		siteKey:   siteKey,
+		secret:    secret,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+// Ответ siteverify одинаков у hCaptcha и Turnstile
+type siteVerifyResponse struct {
+	Success    bool     `json:"success"`
+	ErrorCodes []string `json:"error-codes"`
+}
+
+func (v *CaptchaVerifier) Issue(ctx context.Context) (*models.Challenge, error) {
+	return &models.Challenge{
+		Type:    models.ChallengeCaptcha,
+		SiteKey: v.siteKey,
+	}, nil
+}
+
+func (v *CaptchaVerifier) Verify(ctx context.Context, solution models.ChallengeSolution, client models.ClientInfo) error {
+	form := url.Values{
+		"secret":   {v.secret},
+		"response": {solution.Solution},
+		"sitekey":  {v.siteKey},
+	}
+	if client.IP != "" {
+		form.Set("remoteip", client.IP)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.verifyURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return errors.Wrap(err, "failed to build captcha verify request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return errors.Wrap(err, "failed to call captcha verify endpoint")
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.Errorf("captcha verify endpoint returned %d", resp.StatusCode)
+	}
+
+	var result siteVerifyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return errors.Wrap(err, "failed to decode captcha verify response")
+	}
+
+	if !result.Success {
+		return models.ErrChallengeFailed
+	}
+	return nil
+}
@@ -0,0 +1,118 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/replay"
+)
+
+const powChallengeVersion = "pow1"
+
+// ProofOfWorkVerifier - встроенный hashcash. Задание не хранится на сервере:
+// оно защищено HMAC на отдельном ключе (не ключе токенов), а использованные
+// задания помнит replay-кэш.
+//
+// Кэш живет в памяти процесса: за балансировщиком решенное задание можно
+// предъявить по разу на каждой реплике. Задание стоит сложности и живет ttl,
+// поэтому это лишь кратно удешевляет обход, но не отменяет его.
+//
+// Задание:  pow1:<difficulty>:<expires_unix>:<nonce>:<mac>
+// Решение:  счетчик, при котором SHA-256("<задание>:<счетчик>") начинается с difficulty нулевых бит
+type ProofOfWorkVerifier struct {
+	key        []byte
+	difficulty int
+	ttl        time.Duration
+	used       *replay.Cache
+}
+
+func NewProofOfWorkVerifier(key []byte, difficulty int, ttl time.Duration) *ProofOfWorkVerifier {
+	return &ProofOfWorkVerifier{
+		key:        key,
+		difficulty: difficulty,
+		ttl:        ttl,
+		used:       replay.New(),
+	}
+}
+
+func (v *ProofOfWorkVerifier) Issue(ctx context.Context) (*models.Challenge, error) {
+	nonce, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(v.ttl)
+	payload := fmt.Sprintf("%s:%d:%d:%s", powChallengeVersion, v.difficulty, expiresAt.Unix(), nonce)
+	mac := base64.RawURLEncoding.EncodeToString(v.mac(payload))
+
+	return &models.Challenge{
+		Type:       models.ChallengeProofOfWork,
+		Challenge:  payload + ":" + mac,
+		Difficulty: v.difficulty,
+		ExpiresAt:  expiresAt,
+	}, nil
+}
+
+func (v *ProofOfWorkVerifier) Verify(ctx context.Context, solution models.ChallengeSolution, client models.ClientInfo) error {
+	parts := strings.Split(solution.Challenge, ":")
+	if len(parts) != 5 || parts[0] != powChallengeVersion {
+		return models.ErrChallengeFailed
+	}
+
+	payload := strings.Join(parts[:4], ":")
+	mac, err := base64.RawURLEncoding.DecodeString(parts[4])
+	if err != nil || !hmac.Equal(mac, v.mac(payload)) {
+		return models.ErrChallengeFailed
+	}
+
+	difficulty, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return models.ErrChallengeFailed
+	}
+
+	expiresUnix, err := strconv.ParseInt(parts[2], 10, 64)
+	if err != nil {
+		return models.ErrChallengeFailed
+	}
+	expiresAt := time.Unix(expiresUnix, 0)
+	if time.Now().After(expiresAt) {
+		return models.ErrChallengeFailed
+	}
+
+	sum := sha256.Sum256([]byte(solution.Challenge + ":" + solution.Solution))
+	if leadingZeroBits(sum[:]) < difficulty {
+		return models.ErrChallengeFailed
+	}
+
+	// Каждое задание решается один раз
+	if !v.used.Use(parts[3], expiresAt) {
+		return models.ErrChallengeFailed
+	}
+
+	return nil
+}
+
+func (v *ProofOfWorkVerifier) mac(payload string) []byte {
+	h := hmac.New(sha256.New, v.key)
+	h.Write([]byte(payload))
+	return h.Sum(nil)
+}
+
+func leadingZeroBits(hash []byte) int {
+	count := 0
+	for _, b := range hash {
+		if b != 0 {
+			return count + bits.LeadingZeros8(b)
+		}
+		count += 8
+	}
+	return count
+}
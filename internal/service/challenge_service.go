@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+
+	"github.com/pkg/errors"
+)
+
+// ChallengeVerifier выдает и проверяет задания против ботов
+type ChallengeVerifier interface {
+	Issue(ctx context.Context) (*models.Challenge, error)
+	// Verify возвращает models.ErrChallengeFailed, если решение неверное
+	Verify(ctx context.Context, solution models.ChallengeSolution, client models.ClientInfo) error
+}
+
+// Когда требовать решение задания
+type ChallengePolicy struct {
+	// Всегда на Register
+	OnRegister bool
+	// На Login после стольких неверных паролей с IP за окно (0 - никогда).
+	// Счет идет только по IP: ограничения на отдельный аккаунт здесь нет
+	LoginAfterFailures int
+	LoginWindow        time.Duration
+}
+
+type ChallengeService struct {
+	verifier  ChallengeVerifier
+	auditRepo repository.AuditRepository
+	policy    ChallengePolicy
+}
+
+// NewChallengeService: verifier == nil означает, что задания выключены
+func NewChallengeService(verifier ChallengeVerifier, auditRepo repository.AuditRepository, policy ChallengePolicy) *ChallengeService {
+	return &ChallengeService{
+		verifier:  verifier,
+		auditRepo: auditRepo,
+		policy:    policy,
+	}
+}
+
+func (s *ChallengeService) Issue(ctx context.Context) (*models.Challenge, error) {
+	if s.verifier == nil {
+		return &models.Challenge{Type: models.ChallengeNone}, nil
+	}
+	return s.verifier.Issue(ctx)
+}
+
+func (s *ChallengeService) CheckRegister(ctx context.Context, solution models.ChallengeSolution, client models.ClientInfo) error {
+	if s.verifier == nil || !s.policy.OnRegister {
+		return nil
+	}
+	return s.verify(ctx, solution, client)
+}
+
+// CheckLogin требует решение после серии неудачных входов или по решению риск-движка
+func (s *ChallengeService) CheckLogin(ctx context.Context, attempt *models.LoginAttempt, solution models.ChallengeSolution, riskRequired bool) error {
+	required := riskRequired
+
+	if !required && s.verifier != nil && s.policy.LoginAfterFailures > 0 {
+		failures, err := s.auditRepo.CountFailedLogins(ctx, attempt.Client.IP, attempt.Time.Add(-s.policy.LoginWindow))
+		if err != nil {
+			return errors.Wrap(err, "failed to count failed logins")
+		}
+		required = failures >= s.policy.LoginAfterFailures
+	}
+
+	if !required {
+		return nil
+	}
+
+	// Риск-движок может потребовать CAPTCHA, даже если задания не настроены -
+	// тогда вход невозможен, пока риск не снизится
+	if s.verifier == nil {
+		return models.ErrCaptchaRequired
+	}
+	return s.verify(ctx, solution, attempt.Client)
+}
+
+func (s *ChallengeService) verify(ctx context.Context, solution models.ChallengeSolution, client models.ClientInfo) error {
+	if solution.IsEmpty() {
+		return models.ErrCaptchaRequired
+	}
+	return s.verifier.Verify(ctx, solution, client)
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	// Сколько живет ссылка "это был не я" из письма
+	reportTokenTTL = 7 * 24 * time.Hour
+	// Сколько живет токен смены пароля, выданный после жалобы
+	passwordResetTTL = time.Hour
+)
+
+type DeviceService struct {
+	userRepo    repository.UserRepository
+	deviceRepo  repository.DeviceRepository
+	sessionRepo repository.SessionRepository
+	resetRepo   repository.PasswordResetRepository
+	notifier    *NotificationService
+	audit       Audit
+}
+
+func NewDeviceService(
+	userRepo repository.UserRepository,
+	deviceRepo repository.DeviceRepository,
+	sessionRepo repository.SessionRepository,
+	resetRepo repository.PasswordResetRepository,
+	notifier *NotificationService,
+	audit Audit,
+) *DeviceService {
+	return &DeviceService{
+		userRepo:    userRepo,
+		deviceRepo:  deviceRepo,
+		sessionRepo: sessionRepo,
+		resetRepo:   resetRepo,
+		notifier:    notifier,
+		audit:       audit,
+	}
+}
+
+// CheckLogin запоминает контекст успешного входа и уведомляет пользователя,
+// если контекст ему незнаком
+func (s *DeviceService) CheckLogin(ctx context.Context, user *models.User, session *models.Session, client models.ClientInfo) error {
+	now := time.Now()
+	fingerprint := client.Fingerprint()
+
+	device, err := s.deviceRepo.GetKnownDevice(ctx, user.ID, fingerprint)
+	if err != nil {
+		return errors.Wrap(err, "failed to get known device")
+	}
+	if device != nil {
+		return errors.Wrap(s.deviceRepo.TouchKnownDevice(ctx, device.ID, session.ID, now), "failed to touch known device")
+	}
+
+	// Первое устройство пользователя считаем доверенным - уведомлять не о чем
+	known, err := s.deviceRepo.CountKnownDevices(ctx, user.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to count known devices")
+	}
+
+	reportToken, err := randomToken(32)
+	if err != nil {
+		return err
+	}
+
+	device = &models.KnownDevice{
+		UserID:          user.ID,
+		Fingerprint:     fingerprint,
+		UserAgentFamily: models.UserAgentFamily(client.UserAgent),
+		IPPrefix:        models.IPPrefix(client.IP),
+		DeviceID:        client.DeviceID,
+		SessionID:       session.ID,
+		ReportTokenHash: hashToken(reportToken),
+		FirstSeenAt:     now,
+		LastSeenAt:      now,
+	}
+	if err := s.deviceRepo.CreateKnownDevice(ctx, device); err != nil {
+		return errors.Wrap(err, "failed to create known device")
+	}
+
+	if known == 0 {
+		return nil
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditNewDeviceLogin,
+		UserID: &user.ID,
+		Email:  user.Email,
+		Metadata: map[string]string{
+			"session_id":        session.ID,
+			"user_agent_family": device.UserAgentFamily,
+			"ip_prefix":         device.IPPrefix,
+			"device_id":         device.DeviceID,
+		},
+	}); err != nil {
+		return errors.Wrap(err, "failed to record audit event")
+	}
+
+	s.notifier.NotifyNewDevice(user, device, client, reportToken)
+	return nil
+}
+
+// ReportUnrecognizedLogin обрабатывает ссылку "это был не я": отзывает сессию,
+// забывает устройство и требует смены пароля. Возвращает токен смены пароля.
+func (s *DeviceService) ReportUnrecognizedLogin(ctx context.Context, reportToken string) (string, error) {
+	now := time.Now()
+
+	device, err := s.deviceRepo.GetKnownDeviceByReportToken(ctx, hashToken(reportToken))
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get known device")
+	}
+	if device == nil || now.Sub(device.FirstSeenAt) > reportTokenTTL {
+		return "", models.ErrInvalidReportToken
+	}
+
+	if err := s.sessionRepo.RevokeSession(ctx, device.SessionID, now); err != nil {
+		return "", errors.Wrap(err, "failed to revoke session")
+	}
+	if err := s.deviceRepo.DeleteKnownDevice(ctx, device.ID); err != nil {
+		return "", errors.Wrap(err, "failed to delete known device")
+	}
+	if err := s.userRepo.SetPasswordResetRequired(ctx, device.UserID, true); err != nil {
+		return "", errors.Wrap(err, "failed to require password reset")
+	}
+
+	resetToken, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+	if err := s.resetRepo.CreatePasswordResetToken(ctx, device.UserID, hashToken(resetToken), now.Add(passwordResetTTL)); err != nil {
+		return "", errors.Wrap(err, "failed to create password reset token")
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditUnrecognizedLogin,
+		UserID: &device.UserID,
+		Metadata: map[string]string{
+			"session_id":        device.SessionID,
+			"user_agent_family": device.UserAgentFamily,
+			"ip_prefix":         device.IPPrefix,
+		},
+	}); err != nil {
+		return "", errors.Wrap(err, "failed to record audit event")
+	}
+
+	return resetToken, nil
+}
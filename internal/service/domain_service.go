@@ -0,0 +1,279 @@
+package service
+
+import (
+	"context"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+	"github.com/DailyPepper/auth-service/pkg/dnstxt"
+
+	"github.com/pkg/errors"
+)
+
+// DomainService - подтвержденные домены email и home-realm discovery:
+// пользователей подтвержденного домена можно направлять к провайдеру организации
+// и запрещать им вход по паролю.
+type DomainService struct {
+	domainRepo   repository.DomainRepository
+	providerRepo repository.IdentityProviderRepository
+	orgRepo      repository.OrganizationRepository
+	rbac         RBAC
+	resolver     dnstxt.Resolver
+	audit        Audit
+}
+
+func NewDomainService(
+	domainRepo repository.DomainRepository,
+	providerRepo repository.IdentityProviderRepository,
+	orgRepo repository.OrganizationRepository,
+	rbac RBAC,
+	resolver dnstxt.Resolver,
+	audit Audit,
+) *DomainService {
+	return &DomainService{
+		domainRepo:   domainRepo,
+		providerRepo: providerRepo,
+		orgRepo:      orgRepo,
+		rbac:         rbac,
+		resolver:     resolver,
+		audit:        audit,
+	}
+}
+
+// AddDomain регистрирует заявку на домен. Действовать она начнет после
+// VerifyDomain, когда владелец опубликует TXT-запись из ответа.
+func (s *DomainService) AddDomain(ctx context.Context, actorID int64, domain *models.Domain) (*models.Domain, error) {
+	if err := domain.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.authorize(ctx, actorID, domain.OrganizationID); err != nil {
+		return nil, err
+	}
+	if err := s.requireProvider(ctx, domain.Provider); err != nil {
+		return nil, err
+	}
+
+	token, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	domain.VerificationToken = token
+	domain.CreatedBy = &actorID
+	domain.CreatedAt = time.Now()
+
+	if err := s.domainRepo.CreateDomain(ctx, domain); err != nil {
+		return nil, err
+	}
+
+	if err := s.recordAudit(ctx, models.AuditDomainAdded, actorID, domain); err != nil {
+		return nil, err
+	}
+	return domain, nil
+}
+
+// VerifyDomain ищет TXT-запись подтверждения. Повторная проверка
+// подтвержденного домена ничего не делает.
+func (s *DomainService) VerifyDomain(ctx context.Context, actorID, id int64) (*models.Domain, error) {
+	domain, err := s.manageableDomain(ctx, actorID, id)
+	if err != nil {
+		return nil, err
+	}
+	if domain.Verified() {
+		return domain, nil
+	}
+
+	records, err := s.resolver.LookupTXT(ctx, domain.ChallengeName())
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil, models.ErrDomainNotVerified
+	}
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to look up TXT records")
+	}
+	if !containsRecord(records, domain.ChallengeValue()) {
+		return nil, models.ErrDomainNotVerified
+	}
+
+	now := time.Now()
+	if err := s.domainRepo.MarkDomainVerified(ctx, domain.ID, now); err != nil {
+		return nil, err
+	}
+	domain.VerifiedAt = &now
+
+	if err := s.recordAudit(ctx, models.AuditDomainVerified, actorID, domain); err != nil {
+		return nil, err
+	}
+	return domain, nil
+}
+
+// ListDomains возвращает домены организации или, при nil, все домены тенанта
+func (s *DomainService) ListDomains(ctx context.Context, actorID int64, organizationID *int64) ([]*models.Domain, error) {
+	if err := s.authorize(ctx, actorID, organizationID); err != nil {
+		return nil, err
+	}
+
+	domains, err := s.domainRepo.ListDomains(ctx, organizationID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list domains")
+	}
+	return domains, nil
+}
+
+// ConfigureSSO меняет провайдера домена и обязательность SSO
+func (s *DomainService) ConfigureSSO(ctx context.Context, actorID, id int64, sso models.DomainSSO) (*models.Domain, error) {
+	domain, err := s.manageableDomain(ctx, actorID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	domain.Provider = sso.Provider
+	domain.SSOEnforced = sso.SSOEnforced
+	if err := domain.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.requireProvider(ctx, domain.Provider); err != nil {
+		return nil, err
+	}
+
+	if err := s.domainRepo.UpdateDomainSSO(ctx, domain.ID, domain.Provider, domain.SSOEnforced); err != nil {
+		return nil, err
+	}
+
+	if err := s.recordAudit(ctx, models.AuditDomainSSOChanged, actorID, domain); err != nil {
+		return nil, err
+	}
+	return domain, nil
+}
+
+// DiscoverLoginMethod сообщает клиенту, показать поле пароля или отправить
+// пользователя к провайдеру. Ответ зависит только от домена email, поэтому
+// не раскрывает, есть ли такой пользователь.
+func (s *DomainService) DiscoverLoginMethod(ctx context.Context, email string) (*models.LoginDiscovery, error) {
+	discovery := &models.LoginDiscovery{Method: models.LoginMethodPassword}
+
+	domain, err := s.domainRepo.FindVerifiedDomain(ctx, models.DomainCandidates(email))
+	if err != nil {
+		return nil, err
+	}
+	if domain == nil || domain.Provider == "" {
+		return discovery, nil
+	}
+
+	provider, err := s.providerRepo.GetIdentityProvider(ctx, domain.Provider)
+	if err != nil {
+		return nil, err
+	}
+	// Провайдер отключен: если SSO обязателен, войти все равно нельзя
+	if (provider == nil || provider.DisabledAt != nil) && !domain.SSOEnforced {
+		return discovery, nil
+	}
+
+	discovery.Method = models.LoginMethodSSO
+	discovery.Provider = domain.Provider
+	discovery.SSOEnforced = domain.SSOEnforced
+	discovery.OrganizationID = domain.OrganizationID
+	if provider != nil {
+		discovery.ProviderName = provider.Name
+	}
+	return discovery, nil
+}
+
+// CheckLogin возвращает models.ErrSSORequired, если домен email требует входа
+// через своего провайдера, а вход идет иначе (provider пустой - вход по паролю)
+func (s *DomainService) CheckLogin(ctx context.Context, email, provider string) error {
+	domain, err := s.domainRepo.FindVerifiedDomain(ctx, models.DomainCandidates(email))
+	if err != nil {
+		return err
+	}
+	if domain == nil || !domain.SSOEnforced || domain.Provider == provider {
+		return nil
+	}
+	return models.ErrSSORequired
+}
+
+func (s *DomainService) manageableDomain(ctx context.Context, actorID, id int64) (*models.Domain, error) {
+	domain, err := s.domainRepo.GetDomain(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if domain == nil {
+		return nil, models.ErrDomainNotFound
+	}
+	if err := s.authorize(ctx, actorID, domain.OrganizationID); err != nil {
+		return nil, err
+	}
+	return domain, nil
+}
+
+// Домены организации настраивают ее владельцы и администраторы,
+// любые домены тенанта - обладатели разрешения domains:manage
+func (s *DomainService) authorize(ctx context.Context, actorID int64, organizationID *int64) error {
+	if organizationID != nil {
+		org, err := s.orgRepo.GetOrganization(ctx, *organizationID)
+		if err != nil {
+			return errors.Wrap(err, "failed to get organization")
+		}
+		if org == nil {
+			return models.ErrOrganizationNotFound
+		}
+
+		membership, err := s.orgRepo.GetOrganizationMembership(ctx, *organizationID, actorID)
+		if err != nil {
+			return errors.Wrap(err, "failed to get organization membership")
+		}
+		if membership != nil && membership.Role.CanManageMembers() {
+			return nil
+		}
+	}
+	return s.rbac.RequirePermission(ctx, actorID, models.PermissionDomainsManage)
+}
+
+func (s *DomainService) requireProvider(ctx context.Context, slug string) error {
+	if slug == "" {
+		return nil
+	}
+	provider, err := s.providerRepo.GetIdentityProvider(ctx, slug)
+	if err != nil {
+		return err
+	}
+	if provider == nil || provider.DisabledAt != nil {
+		return models.ErrIdentityProviderNotFound
+	}
+	return nil
+}
+
+func (s *DomainService) recordAudit(ctx context.Context, eventType models.AuditEventType, actorID int64, domain *models.Domain) error {
+	metadata := map[string]string{
+		"domain_id":    strconv.FormatInt(domain.ID, 10),
+		"domain":       domain.Domain,
+		"provider":     domain.Provider,
+		"sso_enforced": strconv.FormatBool(domain.SSOEnforced),
+		"actor_id":     strconv.FormatInt(actorID, 10),
+	}
+	if domain.OrganizationID != nil {
+		metadata["organization_id"] = strconv.FormatInt(*domain.OrganizationID, 10)
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:     eventType,
+		UserID:   &actorID,
+		Metadata: metadata,
+	}); err != nil {
+		return errors.Wrap(err, "failed to record audit event")
+	}
+	return nil
+}
+
+// DNS-провайдеры по-разному экранируют значения, поэтому кавычки и пробелы отбрасываем
+func containsRecord(records []string, expected string) bool {
+	for _, record := range records {
+		if strings.Trim(strings.TrimSpace(record), `"`) == expected {
+			return true
+		}
+	}
+	return false
+}
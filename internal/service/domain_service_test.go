@@ -0,0 +1,276 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/dnstxt"
+)
+
+// memoryDomainRepository хранит домены одного тенанта
+type memoryDomainRepository struct {
+	domains []*models.Domain
+}
+
+func (r *memoryDomainRepository) CreateDomain(ctx context.Context, domain *models.Domain) error {
+	domain.ID = int64(len(r.domains) + 1)
+	stored := *domain
+	r.domains = append(r.domains, &stored)
+	return nil
+}
+
+func (r *memoryDomainRepository) GetDomain(ctx context.Context, id int64) (*models.Domain, error) {
+	for _, domain := range r.domains {
+		if domain.ID == id {
+			found := *domain
+			return &found, nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *memoryDomainRepository) ListDomains(ctx context.Context, organizationID *int64) ([]*models.Domain, error) {
+	return r.domains, nil
+}
+
+func (r *memoryDomainRepository) MarkDomainVerified(ctx context.Context, id int64, at time.Time) error {
+	for _, domain := range r.domains {
+		if domain.ID == id {
+			domain.VerifiedAt = &at
+		}
+	}
+	return nil
+}
+
+func (r *memoryDomainRepository) UpdateDomainSSO(ctx context.Context, id int64, provider string, enforced bool) error {
+	for _, domain := range r.domains {
+		if domain.ID == id {
+			domain.Provider = provider
+			domain.SSOEnforced = enforced
+		}
+	}
+	return nil
+}
+
+func (r *memoryDomainRepository) FindVerifiedDomain(ctx context.Context, candidates []string) (*models.Domain, error) {
+	var best *models.Domain
+	for _, domain := range r.domains {
+		if !domain.Verified() {
+			continue
+		}
+		for _, candidate := range candidates {
+			if domain.Domain == candidate && (best == nil || len(domain.Domain) > len(best.Domain)) {
+				best = domain
+			}
+		}
+	}
+	return best, nil
+}
+
+type memoryIdentityProviderRepository struct {
+	providers map[string]*models.IdentityProvider
+}
+
+func (r *memoryIdentityProviderRepository) CreateIdentityProvider(ctx context.Context, provider *models.IdentityProvider) error {
+	r.providers[provider.Slug] = provider
+	return nil
+}
+
+func (r *memoryIdentityProviderRepository) GetIdentityProvider(ctx context.Context, slug string) (*models.IdentityProvider, error) {
+	return r.providers[slug], nil
+}
+
+func (r *memoryIdentityProviderRepository) ListIdentityProviders(ctx context.Context) ([]*models.IdentityProvider, error) {
+	var providers []*models.IdentityProvider
+	for _, provider := range r.providers {
+		providers = append(providers, provider)
+	}
+	return providers, nil
+}
+
+// Домены тенанта в тестах настраивает администратор с domains:manage
+type allowAllRBAC struct {
+	RBAC
+}
+
+func (allowAllRBAC) RequirePermission(ctx context.Context, userID int64, permission string) error {
+	return nil
+}
+
+type memoryAudit struct {
+	events []*models.AuditEvent
+}
+
+func (a *memoryAudit) Record(ctx context.Context, event *models.AuditEvent) error {
+	a.events = append(a.events, event)
+	return nil
+}
+
+func (a *memoryAudit) Verify(ctx context.Context) (*models.AuditVerification, error) {
+	return &models.AuditVerification{}, nil
+}
+
+type domainTest struct {
+	service *DomainService
+	domains *memoryDomainRepository
+	audit   *memoryAudit
+	records string
+}
+
+func newDomainTest(t *testing.T) *domainTest {
+	t.Helper()
+
+	records := filepath.Join(t.TempDir(), "txt.json")
+	providers := &memoryIdentityProviderRepository{providers: map[string]*models.IdentityProvider{
+		"corp": {Slug: "corp", Name: "Corp SSO", Protocol: models.IdentityProviderOIDC},
+	}}
+
+	test := &domainTest{domains: &memoryDomainRepository{}, audit: &memoryAudit{}, records: records}
+	test.publish(t, map[string][]string{})
+	test.service = NewDomainService(test.domains, providers, nil, allowAllRBAC{}, dnstxt.NewStaticResolver(records), test.audit)
+	return test
+}
+
+// publish заменяет TXT-записи, которые видит резолвер
+func (d *domainTest) publish(t *testing.T, records map[string][]string) {
+	t.Helper()
+
+	data, err := json.Marshal(records)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(d.records, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func (d *domainTest) add(t *testing.T, name string, sso bool) *models.Domain {
+	t.Helper()
+
+	domain, err := d.service.AddDomain(context.Background(), 1, &models.Domain{Domain: name, Provider: "corp", SSOEnforced: sso})
+	if err != nil {
+		t.Fatalf("AddDomain: %v", err)
+	}
+	return domain
+}
+
+func TestVerifyDomain(t *testing.T) {
+	d := newDomainTest(t)
+	domain := d.add(t, "Example.com", false)
+
+	if domain.Domain != "example.com" || domain.VerificationToken == "" {
+		t.Fatalf("domain = %q, token = %q", domain.Domain, domain.VerificationToken)
+	}
+
+	// Записи еще нет
+	if _, err := d.service.VerifyDomain(context.Background(), 1, domain.ID); err != models.ErrDomainNotVerified {
+		t.Fatalf("VerifyDomain without record: got %v, want ErrDomainNotVerified", err)
+	}
+
+	// Запись с чужим токеном не подтверждает домен
+	d.publish(t, map[string][]string{
+		domain.ChallengeName(): {models.DomainChallengeValuePrefix + "someone-else"},
+	})
+	if _, err := d.service.VerifyDomain(context.Background(), 1, domain.ID); err != models.ErrDomainNotVerified {
+		t.Fatalf("VerifyDomain with other token: got %v, want ErrDomainNotVerified", err)
+	}
+
+	// Кавычки вокруг значения добавляют некоторые DNS-провайдеры
+	d.publish(t, map[string][]string{
+		domain.ChallengeName(): {"v=spf1 -all", `"` + domain.ChallengeValue() + `"`},
+	})
+	verified, err := d.service.VerifyDomain(context.Background(), 1, domain.ID)
+	if err != nil {
+		t.Fatalf("VerifyDomain: %v", err)
+	}
+	if !verified.Verified() {
+		t.Fatal("domain is not verified")
+	}
+
+	stored, _ := d.domains.GetDomain(context.Background(), domain.ID)
+	if !stored.Verified() {
+		t.Fatal("verification is not stored")
+	}
+	last := d.audit.events[len(d.audit.events)-1]
+	if last.Type != models.AuditDomainVerified || last.Metadata["domain"] != "example.com" {
+		t.Fatalf("last audit event = %s %v", last.Type, last.Metadata)
+	}
+}
+
+func TestDiscoverLoginMethod(t *testing.T) {
+	d := newDomainTest(t)
+	ctx := context.Background()
+
+	verified := d.add(t, "example.com", true)
+	d.publish(t, map[string][]string{verified.ChallengeName(): {verified.ChallengeValue()}})
+	if _, err := d.service.VerifyDomain(ctx, 1, verified.ID); err != nil {
+		t.Fatalf("VerifyDomain: %v", err)
+	}
+	// Заявка без TXT-записи ни на что не влияет
+	d.add(t, "unverified.com", true)
+
+	// Поддомены наследуют домен
+	for _, email := range []string{"alice@example.com", "bob@eu.example.com"} {
+		discovery, err := d.service.DiscoverLoginMethod(ctx, email)
+		if err != nil {
+			t.Fatalf("DiscoverLoginMethod(%s): %v", email, err)
+		}
+		if discovery.Method != models.LoginMethodSSO || discovery.Provider != "corp" || !discovery.SSOEnforced || discovery.ProviderName != "Corp SSO" {
+			t.Fatalf("DiscoverLoginMethod(%s) = %+v", email, discovery)
+		}
+	}
+
+	for _, email := range []string{"carol@unverified.com", "dave@other.com", "eve@notexample.com"} {
+		discovery, err := d.service.DiscoverLoginMethod(ctx, email)
+		if err != nil {
+			t.Fatalf("DiscoverLoginMethod(%s): %v", email, err)
+		}
+		if discovery.Method != models.LoginMethodPassword || discovery.Provider != "" {
+			t.Fatalf("DiscoverLoginMethod(%s) = %+v, want password", email, discovery)
+		}
+	}
+}
+
+func TestCheckLoginSSORequired(t *testing.T) {
+	d := newDomainTest(t)
+	ctx := context.Background()
+
+	enforced := d.add(t, "example.com", true)
+	optional := d.add(t, "optional.com", false)
+	unverified := d.add(t, "unverified.com", true)
+	d.publish(t, map[string][]string{
+		enforced.ChallengeName(): {enforced.ChallengeValue()},
+		optional.ChallengeName(): {optional.ChallengeValue()},
+	})
+	for _, domain := range []*models.Domain{enforced, optional} {
+		if _, err := d.service.VerifyDomain(ctx, 1, domain.ID); err != nil {
+			t.Fatalf("VerifyDomain(%s): %v", domain.Domain, err)
+		}
+	}
+	if _, err := d.service.VerifyDomain(ctx, 1, unverified.ID); err != models.ErrDomainNotVerified {
+		t.Fatalf("VerifyDomain(%s): got %v, want ErrDomainNotVerified", unverified.Domain, err)
+	}
+
+	tests := []struct {
+		email    string
+		provider string
+		want     error
+	}{
+		// Пустой провайдер - вход по паролю, так его проверяет RegistrService.Login
+		{"alice@example.com", "", models.ErrSSORequired},
+		{"alice@example.com", "github", models.ErrSSORequired},
+		{"alice@example.com", "corp", nil},
+		{"alice@optional.com", "", nil},
+		{"alice@unverified.com", "", nil},
+	}
+	for _, tt := range tests {
+		if err := d.service.CheckLogin(ctx, tt.email, tt.provider); err != tt.want {
+			t.Errorf("CheckLogin(%s, %q) = %v, want %v", tt.email, tt.provider, err, tt.want)
+		}
+	}
+}
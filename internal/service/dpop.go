@@ -0,0 +1,115 @@
+package service
+
+import (
+	"crypto"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/replay"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type DPoPService struct {
+	used *replay.Cache
+}
+
+func NewDPoPService() *DPoPService {
+	return &DPoPService{used: replay.New()}
+}
+
+type dpopClaims struct {
+	jwt.RegisteredClaims
+	Method          string `json:"htm"`
+	URI             string `json:"htu"`
+	AccessTokenHash string `json:"ath,omitempty"`
+}
+
+// Verify проверяет DPoP-доказательство (RFC 9449, 4.3) и возвращает отпечаток
+// его ключа (RFC 7638) - с ним сравнивается cnf.jkt токена
+func (s *DPoPService) Verify(req *models.DPoPRequest) (string, error) {
+	var claims dpopClaims
+	var key *jose.JSONWebKey
+	_, err := jwt.ParseWithClaims(req.Proof, &claims, func(t *jwt.Token) (interface{}, error) {
+		if t.Header["typ"] != "dpop+jwt" {
+			return nil, models.ErrInvalidDPoPProof
+		}
+		var err error
+		if key, err = proofKey(t.Header["jwk"]); err != nil {
+			return nil, err
+		}
+		return key.Key, nil
+	},
+		jwt.WithValidMethods(models.DPoPSigningAlgorithms),
+		jwt.WithoutClaimsValidation(),
+	)
+	if err != nil || claims.ID == "" || claims.IssuedAt == nil {
+		return "", models.ErrInvalidDPoPProof
+	}
+
+	now := time.Now()
+	issuedAt := claims.IssuedAt.Time
+	if now.Sub(issuedAt) > models.DPoPProofMaxAge || issuedAt.Sub(now) > models.DPoPProofClockSkew {
+		return "", models.ErrInvalidDPoPProof
+	}
+	if !strings.EqualFold(claims.Method, req.Method) || !proofTargetMatches(claims.URI, req) {
+		return "", models.ErrInvalidDPoPProof
+	}
+	if req.AccessToken != "" {
+		sum := sha256.Sum256([]byte(req.AccessToken))
+		if claims.AccessTokenHash != base64.RawURLEncoding.EncodeToString(sum[:]) {
+			return "", models.ErrInvalidDPoPProof
+		}
+	}
+
+	thumbprint, err := key.Thumbprint(crypto.SHA256)
+	if err != nil {
+		return "", models.ErrInvalidDPoPProof
+	}
+	jkt := base64.RawURLEncoding.EncodeToString(thumbprint)
+
+	// jti уникален в пределах ключа; помним его, пока iat считается свежим
+	if !s.used.Use(jkt+"/"+claims.ID, issuedAt.Add(models.DPoPProofMaxAge+models.DPoPProofClockSkew)) {
+		return "", models.ErrInvalidDPoPProof
+	}
+	return jkt, nil
+}
+
+// proofKey - открытый ключ из заголовка jwk; закрытый ключ там - ошибка клиента
+func proofKey(header interface{}) (*jose.JSONWebKey, error) {
+	raw, err := json.Marshal(header)
+	if err != nil {
+		return nil, models.ErrInvalidDPoPProof
+	}
+	var key jose.JSONWebKey
+	if err := key.UnmarshalJSON(raw); err != nil || !key.Valid() || !key.IsPublic() {
+		return nil, models.ErrInvalidDPoPProof
+	}
+	return &key, nil
+}
+
+// proofTargetMatches сравнивает htu с адресом запроса без query и fragment
+// (RFC 9449, 4.3), а для gRPC - путь htu с полным именем метода
+func proofTargetMatches(htu string, req *models.DPoPRequest) bool {
+	target, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+	if req.GRPCMethod != "" {
+		return target.Path == req.GRPCMethod
+	}
+
+	expected, err := url.Parse(req.URI)
+	if err != nil || !target.IsAbs() {
+		return false
+	}
+	return strings.EqualFold(target.Scheme, expected.Scheme) &&
+		strings.EqualFold(target.Host, expected.Host) &&
+		target.Path == expected.Path
+}
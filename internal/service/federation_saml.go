@@ -0,0 +1,283 @@
+package service
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/xml"
+	"net/url"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+
+	"github.com/crewjam/saml"
+	"github.com/pkg/errors"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+// SAMLServiceProvider - наша сторона SAML 2.0: ключ, которым подписываются запросы
+// AuthnRequest и расшифровываются утверждения, и адреса метаданных и ACS.
+// Один на весь сервис: IdP всех тенантов видят одного и того же SP.
+type SAMLServiceProvider struct {
+	Key         *rsa.PrivateKey
+	Certificate *x509.Certificate
+	MetadataURL url.URL
+	ACSURL      url.URL
+}
+
+// LoadSAMLServiceProvider читает PEM-сертификат и RSA-ключ SP
+func LoadSAMLServiceProvider(certPath, keyPath, baseURL string) (*SAMLServiceProvider, error) {
+	pair, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load saml key pair")
+	}
+	key, ok := pair.PrivateKey.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("saml key must be an RSA key")
+	}
+	certificate, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse saml certificate")
+	}
+
+	metadataURL, err := url.Parse(baseURL + "/oauth2/saml/metadata")
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid saml metadata url")
+	}
+	acsURL, err := url.Parse(baseURL + "/oauth2/saml/acs")
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid saml acs url")
+	}
+
+	return &SAMLServiceProvider{
+		Key:         key,
+		Certificate: certificate,
+		MetadataURL: *metadataURL,
+		ACSURL:      *acsURL,
+	}, nil
+}
+
+// Metadata возвращает метаданные SP, которые загружаются в настройки IdP
+func (p *SAMLServiceProvider) Metadata() ([]byte, error) {
+	descriptor := p.serviceProvider(&saml.EntityDescriptor{}).Metadata()
+
+	// Ответы принимаются только через HTTP-POST binding
+	for i := range descriptor.SPSSODescriptors {
+		sso := &descriptor.SPSSODescriptors[i]
+		var services []saml.IndexedEndpoint
+		for _, service := range sso.AssertionConsumerServices {
+			if service.Binding == saml.HTTPPostBinding {
+				services = append(services, service)
+			}
+		}
+		sso.AssertionConsumerServices = services
+	}
+
+	data, err := xml.MarshalIndent(descriptor, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to marshal saml metadata")
+	}
+	return append([]byte(xml.Header), data...), nil
+}
+
+// serviceProvider связывает SP с метаданными конкретного IdP. Подпись,
+// Destination, Recipient, Audience, сроки и InResponseTo проверяет crewjam/saml.
+func (p *SAMLServiceProvider) serviceProvider(idp *saml.EntityDescriptor) *saml.ServiceProvider {
+	return &saml.ServiceProvider{
+		Key:         p.Key,
+		Certificate: p.Certificate,
+		MetadataURL: p.MetadataURL,
+		AcsURL:      p.ACSURL,
+		IDPMetadata: idp,
+		// Формат выбирает IdP; transient отклоняется при разборе ответа
+		AuthnNameIDFormat: saml.UnspecifiedNameIDFormat,
+		SignatureMethod:   dsig.RSASHA256SignatureMethod,
+	}
+}
+
+// Стандартные имена атрибутов ADFS/Azure AD и LDAP (OID и короткие) в терминах
+// утверждений OpenID Connect, чтобы сопоставление по умолчанию работало и для SAML
+var samlStandardAttributes = map[string]string{
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/emailaddress": "email",
+	"urn:oid:0.9.2342.19200300.100.1.3":                                  "email",
+	"mail":                                                               "email",
+
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/givenname": "given_name",
+	"urn:oid:2.5.4.42": "given_name",
+	"givenName":        "given_name",
+
+	"http://schemas.xmlsoap.org/ws/2005/05/identity/claims/surname": "family_name",
+	"urn:oid:2.5.4.4": "family_name",
+	"sn":              "family_name",
+
+	"http://schemas.microsoft.com/ws/2008/06/identity/claims/groups": "groups",
+	"http://schemas.xmlsoap.org/claims/Group":                        "groups",
+	"memberOf": "groups",
+}
+
+// prepareSAMLProvider проверяет метаданные IdP и берет из них issuer
+func (s *FederationService) prepareSAMLProvider(provider *models.IdentityProvider) error {
+	if s.saml == nil {
+		return models.ErrSAMLNotConfigured
+	}
+
+	idp, err := parseSAMLMetadata(provider.SAMLMetadata)
+	if err != nil {
+		return models.ErrInvalidIdentityProvider
+	}
+	// Запрос отправляется через HTTP-Redirect, а утверждения должны быть подписаны
+	if s.saml.serviceProvider(idp).GetSSOBindingLocation(saml.HTTPRedirectBinding) == "" || !hasSAMLSigningCertificate(idp) {
+		return models.ErrInvalidIdentityProvider
+	}
+
+	provider.Issuer = idp.EntityID
+	provider.ClientID = ""
+	provider.ClientSecret = ""
+	provider.Scopes = nil
+	return nil
+}
+
+// samlRedirect готовит AuthnRequest (HTTP-Redirect binding); state уходит в RelayState
+func (s *FederationService) samlRedirect(provider *models.IdentityProvider, stored *models.FederationState, state string) (string, error) {
+	sp, err := s.samlServiceProvider(provider)
+	if err != nil {
+		return "", err
+	}
+
+	request, err := sp.MakeAuthenticationRequest(sp.GetSSOBindingLocation(saml.HTTPRedirectBinding), saml.HTTPRedirectBinding, saml.HTTPPostBinding)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to create saml authentication request")
+	}
+	redirectURL, err := request.Redirect(state, sp)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign saml authentication request")
+	}
+
+	stored.Nonce = request.ID
+	return redirectURL.String(), nil
+}
+
+// samlIdentity проверяет ответ IdP, пришедший на ACS. Ответ принимается только
+// на наш запрос (InResponseTo), а state одноразовый, поэтому повтор не пройдет.
+func (s *FederationService) samlIdentity(provider *models.IdentityProvider, stored *models.FederationState, callback *models.FederationCallback) (*models.ExternalIdentity, error) {
+	if callback.SAMLResponse == "" {
+		return nil, errors.New("missing_saml_response")
+	}
+	raw, err := base64.StdEncoding.DecodeString(callback.SAMLResponse)
+	if err != nil {
+		return nil, errors.New("invalid_saml_response")
+	}
+
+	sp, err := s.samlServiceProvider(provider)
+	if err != nil {
+		return nil, err
+	}
+	assertion, err := sp.ParseXMLResponse(raw, []string{stored.Nonce})
+	if err != nil {
+		// Error() у InvalidResponseError намеренно не раскрывает причину
+		var invalid *saml.InvalidResponseError
+		if errors.As(err, &invalid) && invalid.PrivateErr != nil {
+			err = invalid.PrivateErr
+		}
+		return nil, errors.Wrap(err, "invalid_saml_response")
+	}
+
+	if assertion.Subject == nil || assertion.Subject.NameID == nil || assertion.Subject.NameID.Value == "" {
+		return nil, errors.New("missing_name_id")
+	}
+	nameID := assertion.Subject.NameID
+	// Transient NameID меняется при каждом входе - привязать к нему пользователя нельзя
+	if nameID.Format == string(saml.TransientNameIDFormat) {
+		return nil, errors.New("transient_name_id")
+	}
+
+	claims := samlClaims(assertion)
+	if _, ok := claims["email"]; !ok && nameID.Format == string(saml.EmailAddressNameIDFormat) {
+		claims["email"] = nameID.Value
+	}
+
+	external := &models.ExternalIdentity{
+		Provider: provider.Slug,
+		Subject:  nameID.Value,
+		Claims:   claims,
+	}
+	provider.AttributeMapping.Apply(external)
+	return external, nil
+}
+
+func (s *FederationService) samlServiceProvider(provider *models.IdentityProvider) (*saml.ServiceProvider, error) {
+	if s.saml == nil {
+		return nil, models.ErrSAMLNotConfigured
+	}
+	idp, err := parseSAMLMetadata(provider.SAMLMetadata)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid_saml_metadata")
+	}
+	return s.saml.serviceProvider(idp), nil
+}
+
+// Метаданные IdP бывают и отдельным EntityDescriptor, и внутри EntitiesDescriptor
+func parseSAMLMetadata(data string) (*saml.EntityDescriptor, error) {
+	var descriptor saml.EntityDescriptor
+	if err := xml.Unmarshal([]byte(data), &descriptor); err == nil && len(descriptor.IDPSSODescriptors) > 0 {
+		return &descriptor, nil
+	}
+
+	var entities saml.EntitiesDescriptor
+	if err := xml.Unmarshal([]byte(data), &entities); err != nil {
+		return nil, errors.Wrap(err, "failed to parse saml metadata")
+	}
+	for i := range entities.EntityDescriptors {
+		if len(entities.EntityDescriptors[i].IDPSSODescriptors) > 0 {
+			return &entities.EntityDescriptors[i], nil
+		}
+	}
+	return nil, errors.New("saml metadata has no IdP SSO descriptor")
+}
+
+func hasSAMLSigningCertificate(idp *saml.EntityDescriptor) bool {
+	for _, sso := range idp.IDPSSODescriptors {
+		for _, key := range sso.KeyDescriptors {
+			if (key.Use == "" || key.Use == "signing") && len(key.KeyInfo.X509Data.X509Certificates) > 0 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// samlClaims превращает атрибуты утверждения в утверждения в духе ID-токена:
+// по Name и FriendlyName, многозначные атрибуты - массивами
+func samlClaims(assertion *saml.Assertion) map[string]interface{} {
+	claims := make(map[string]interface{})
+	set := func(name string, value interface{}) {
+		if name == "" {
+			return
+		}
+		claims[name] = value
+		if standard, ok := samlStandardAttributes[name]; ok {
+			if _, exists := claims[standard]; !exists {
+				claims[standard] = value
+			}
+		}
+	}
+
+	for _, statement := range assertion.AttributeStatements {
+		for _, attribute := range statement.Attributes {
+			if len(attribute.Values) == 0 {
+				continue
+			}
+			var value interface{} = attribute.Values[0].Value
+			if len(attribute.Values) > 1 {
+				values := make([]interface{}, len(attribute.Values))
+				for i, v := range attribute.Values {
+					values[i] = v.Value
+				}
+				value = values
+			}
+			set(attribute.Name, value)
+			set(attribute.FriendlyName, value)
+		}
+	}
+	return claims
+}
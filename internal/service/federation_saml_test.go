@@ -0,0 +1,297 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/xml"
+	"math/big"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+
+	"github.com/crewjam/saml"
+	dsig "github.com/russellhaering/goxmldsig"
+)
+
+const samlTestRequestID = "id-3f1a7c0e5b9d4a2e8c6f"
+
+// Самоподписанный сертификат для SP и IdP тестов
+func newTestCertificate(t *testing.T, commonName string) (*rsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, certificate
+}
+
+// samlTestIdP - IdP на crewjam/saml с локально сгенерированным ключом:
+// выпускает подписанные ответы на ACS нашего SP
+type samlTestIdP struct {
+	idp      *saml.IdentityProvider
+	sp       *SAMLServiceProvider
+	provider *models.IdentityProvider
+	service  *FederationService
+}
+
+func newSAMLTestIdP(t *testing.T) *samlTestIdP {
+	t.Helper()
+
+	spKey, spCert := newTestCertificate(t, "sp.example.com")
+	metadataURL, _ := url.Parse("https://auth.example.com/oauth2/saml/metadata")
+	acsURL, _ := url.Parse("https://auth.example.com/oauth2/saml/acs")
+	sp := &SAMLServiceProvider{Key: spKey, Certificate: spCert, MetadataURL: *metadataURL, ACSURL: *acsURL}
+
+	idpKey, idpCert := newTestCertificate(t, "idp.example.com")
+	idpMetadataURL, _ := url.Parse("https://idp.example.com/metadata")
+	idpSSOURL, _ := url.Parse("https://idp.example.com/sso")
+	idp := &saml.IdentityProvider{
+		Key:             idpKey,
+		Certificate:     idpCert,
+		MetadataURL:     *idpMetadataURL,
+		SSOURL:          *idpSSOURL,
+		SignatureMethod: dsig.RSASHA256SignatureMethod,
+	}
+
+	metadata, err := xml.Marshal(idp.Metadata())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := &models.IdentityProvider{
+		Slug:         "corp-saml",
+		Name:         "Corp SAML",
+		Protocol:     models.IdentityProviderSAML,
+		SAMLMetadata: string(metadata),
+	}
+	service := &FederationService{saml: sp}
+	if err := service.prepareSAMLProvider(provider); err != nil {
+		t.Fatalf("prepareSAMLProvider: %v", err)
+	}
+
+	return &samlTestIdP{idp: idp, sp: sp, provider: provider, service: service}
+}
+
+type samlTestResponse struct {
+	session *saml.Session
+	// Шифровать утверждение ключом SP из его метаданных
+	encrypt bool
+	// Меняет метаданные SP, которые видит IdP (audience)
+	spMetadata func(*saml.EntityDescriptor)
+	// Меняет утверждение перед подписью
+	assertion func(*saml.Assertion)
+}
+
+func aliceSAMLSession() *saml.Session {
+	return &saml.Session{
+		ID:            "session-1",
+		CreateTime:    time.Now(),
+		NameID:        "alice@example.com",
+		NameIDFormat:  string(saml.EmailAddressNameIDFormat),
+		UserGivenName: "Alice",
+		UserSurname:   "Liddell",
+	}
+}
+
+// respond возвращает SAMLResponse (base64), как его прислал бы браузер на ACS
+func (f *samlTestIdP) respond(t *testing.T, opts samlTestResponse) string {
+	t.Helper()
+
+	idpDescriptor, err := parseSAMLMetadata(f.provider.SAMLMetadata)
+	if err != nil {
+		t.Fatal(err)
+	}
+	spMetadata := f.sp.serviceProvider(idpDescriptor).Metadata()
+	if !opts.encrypt {
+		for i := range spMetadata.SPSSODescriptors {
+			sso := &spMetadata.SPSSODescriptors[i]
+			var keys []saml.KeyDescriptor
+			for _, key := range sso.KeyDescriptors {
+				if key.Use != "encryption" {
+					keys = append(keys, key)
+				}
+			}
+			sso.KeyDescriptors = keys
+		}
+	}
+	if opts.spMetadata != nil {
+		opts.spMetadata(spMetadata)
+	}
+
+	acs := f.sp.ACSURL.String()
+	req := &saml.IdpAuthnRequest{
+		IDP:                     f.idp,
+		HTTPRequest:             httptest.NewRequest("POST", acs, nil),
+		Request:                 saml.AuthnRequest{ID: samlTestRequestID, IssueInstant: time.Now()},
+		ServiceProviderMetadata: spMetadata,
+		SPSSODescriptor:         &spMetadata.SPSSODescriptors[0],
+		ACSEndpoint:             &saml.IndexedEndpoint{Binding: saml.HTTPPostBinding, Location: acs},
+		Now:                     time.Now(),
+	}
+
+	if err := (saml.DefaultAssertionMaker{}).MakeAssertion(req, opts.session); err != nil {
+		t.Fatalf("MakeAssertion: %v", err)
+	}
+	if opts.assertion != nil {
+		opts.assertion(req.Assertion)
+	}
+
+	form, err := req.PostBinding()
+	if err != nil {
+		t.Fatalf("PostBinding: %v", err)
+	}
+	return form.SAMLResponse
+}
+
+func (f *samlTestIdP) identity(samlResponse, requestID string) (*models.ExternalIdentity, error) {
+	stored := &models.FederationState{Provider: f.provider.Slug, Nonce: requestID}
+	return f.service.samlIdentity(f.provider, stored, &models.FederationCallback{SAMLResponse: samlResponse})
+}
+
+func expectSAMLError(t *testing.T, err error, reason string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("response accepted, want error containing %q", reason)
+	}
+	if !strings.Contains(err.Error(), reason) {
+		t.Fatalf("error = %v, want it to contain %q", err, reason)
+	}
+}
+
+func TestSAMLIdentityValidResponse(t *testing.T) {
+	f := newSAMLTestIdP(t)
+
+	if f.provider.Issuer != f.idp.Metadata().EntityID {
+		t.Fatalf("provider issuer = %q, want IdP entity ID %q", f.provider.Issuer, f.idp.Metadata().EntityID)
+	}
+
+	for _, encrypt := range []bool{false, true} {
+		response := f.respond(t, samlTestResponse{session: aliceSAMLSession(), encrypt: encrypt})
+
+		external, err := f.identity(response, samlTestRequestID)
+		if err != nil {
+			t.Fatalf("encrypt=%v: samlIdentity: %v", encrypt, err)
+		}
+		if external.Provider != f.provider.Slug || external.Subject != "alice@example.com" {
+			t.Fatalf("encrypt=%v: identity = %s/%s", encrypt, external.Provider, external.Subject)
+		}
+		// Email берется из NameID формата emailAddress, имена - из атрибутов по OID
+		if external.Email != "alice@example.com" || external.GivenName != "Alice" || external.FamilyName != "Liddell" {
+			t.Fatalf("encrypt=%v: attributes = %q %q %q", encrypt, external.Email, external.GivenName, external.FamilyName)
+		}
+	}
+}
+
+func TestSAMLIdentityTamperedSignature(t *testing.T) {
+	f := newSAMLTestIdP(t)
+	response := f.respond(t, samlTestResponse{session: aliceSAMLSession()})
+
+	raw, err := base64.StdEncoding.DecodeString(response)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := strings.Replace(string(raw), "alice@example.com", "mallory@example.com", 1)
+	if tampered == string(raw) {
+		t.Fatal("NameID not found in response")
+	}
+
+	_, err = f.identity(base64.StdEncoding.EncodeToString([]byte(tampered)), samlTestRequestID)
+	expectSAMLError(t, err, "signature")
+}
+
+func TestSAMLIdentityForeignSigner(t *testing.T) {
+	f := newSAMLTestIdP(t)
+
+	// Тот же IdP по метаданным, но ответ подписан чужим ключом
+	key, certificate := newTestCertificate(t, "idp.example.com")
+	f.idp.Key = key
+	f.idp.Certificate = certificate
+
+	_, err := f.identity(f.respond(t, samlTestResponse{session: aliceSAMLSession()}), samlTestRequestID)
+	expectSAMLError(t, err, "signature")
+}
+
+func TestSAMLIdentityWrongAudience(t *testing.T) {
+	f := newSAMLTestIdP(t)
+	response := f.respond(t, samlTestResponse{
+		session: aliceSAMLSession(),
+		spMetadata: func(metadata *saml.EntityDescriptor) {
+			metadata.EntityID = "https://other.example.com/saml/metadata"
+		},
+	})
+
+	_, err := f.identity(response, samlTestRequestID)
+	expectSAMLError(t, err, "AudienceRestriction")
+}
+
+func TestSAMLIdentityWrongRecipient(t *testing.T) {
+	f := newSAMLTestIdP(t)
+	response := f.respond(t, samlTestResponse{
+		session: aliceSAMLSession(),
+		assertion: func(assertion *saml.Assertion) {
+			assertion.Subject.SubjectConfirmations[0].SubjectConfirmationData.Recipient = "https://other.example.com/saml/acs"
+		},
+	})
+
+	_, err := f.identity(response, samlTestRequestID)
+	expectSAMLError(t, err, "Recipient")
+}
+
+func TestSAMLIdentityExpiredAssertion(t *testing.T) {
+	f := newSAMLTestIdP(t)
+	response := f.respond(t, samlTestResponse{
+		session: aliceSAMLSession(),
+		assertion: func(assertion *saml.Assertion) {
+			expired := time.Now().Add(-time.Hour)
+			assertion.Conditions.NotBefore = expired.Add(-time.Hour)
+			assertion.Conditions.NotOnOrAfter = expired
+			assertion.Subject.SubjectConfirmations[0].SubjectConfirmationData.NotOnOrAfter = expired
+		},
+	})
+
+	_, err := f.identity(response, samlTestRequestID)
+	expectSAMLError(t, err, "expired")
+}
+
+func TestSAMLIdentityInResponseToMismatch(t *testing.T) {
+	f := newSAMLTestIdP(t)
+	response := f.respond(t, samlTestResponse{session: aliceSAMLSession()})
+
+	// Ответ на чужой AuthnRequest (другой state) не принимается
+	_, err := f.identity(response, "id-other-request")
+	expectSAMLError(t, err, "InResponseTo")
+}
+
+func TestSAMLIdentityTransientNameID(t *testing.T) {
+	f := newSAMLTestIdP(t)
+	session := aliceSAMLSession()
+	session.NameID = "_8f2c1d9e4b7a"
+	session.NameIDFormat = string(saml.TransientNameIDFormat)
+
+	_, err := f.identity(f.respond(t, samlTestResponse{session: session}), samlTestRequestID)
+	expectSAMLError(t, err, "transient_name_id")
+}
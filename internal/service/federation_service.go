@@ -0,0 +1,552 @@
+package service
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/pkg/errors"
+	"golang.org/x/oauth2"
+)
+
+// Области, которые запрашиваются у провайдера, если при регистрации не указаны другие
+var defaultFederationScopes = []string{models.ScopeOpenID, models.ScopeEmail, models.ScopeProfile}
+
+// FederationService - вход через внешних провайдеров OpenID Connect (Google,
+// Azure AD, Okta и т.п.) и SAML 2.0. Провайдеры настраиваются для каждого тенанта отдельно.
+type FederationService struct {
+	providerRepo repository.IdentityProviderRepository
+	identityRepo repository.IdentityRepository
+	userRepo     repository.UserRepository
+	registr      Registr
+	rbac         RBAC
+	audit        Audit
+	saml         *SAMLServiceProvider
+	callbackURL  string
+	stateTTL     time.Duration
+	httpClient   *http.Client
+
+	// Документы discovery и ключи провайдеров, по issuer
+	mu        sync.Mutex
+	discovery map[string]*oidc.Provider
+}
+
+func NewFederationService(
+	providerRepo repository.IdentityProviderRepository,
+	identityRepo repository.IdentityRepository,
+	userRepo repository.UserRepository,
+	registr Registr,
+	rbac RBAC,
+	audit Audit,
+	saml *SAMLServiceProvider,
+	callbackURL string,
+	stateTTL time.Duration,
+) *FederationService {
+	return &FederationService{
+		providerRepo: providerRepo,
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		registr:      registr,
+		rbac:         rbac,
+		audit:        audit,
+		saml:         saml,
+		callbackURL:  callbackURL,
+		stateTTL:     stateTTL,
+		httpClient:   &http.Client{Timeout: 10 * time.Second},
+		discovery:    make(map[string]*oidc.Provider),
+	}
+}
+
+// RegisterProvider сохраняет провайдера, предварительно проверив его discovery-документ
+// (OIDC) или метаданные (SAML)
+func (s *FederationService) RegisterProvider(ctx context.Context, provider *models.IdentityProvider) error {
+	provider.Name = strings.TrimSpace(provider.Name)
+	if provider.Protocol == "" {
+		provider.Protocol = models.IdentityProviderOIDC
+	}
+	if provider.SyncPolicy == "" {
+		provider.SyncPolicy = models.SyncNever
+	}
+
+	switch provider.Protocol {
+	case models.IdentityProviderSAML:
+		if err := s.prepareSAMLProvider(provider); err != nil {
+			return err
+		}
+		if err := provider.Validate(); err != nil {
+			return err
+		}
+	default:
+		provider.Issuer = strings.TrimSuffix(provider.Issuer, "/")
+		if len(provider.Scopes) == 0 {
+			provider.Scopes = defaultFederationScopes
+		}
+		provider.Scopes = uniqueSorted(append(provider.Scopes, models.ScopeOpenID))
+		if err := provider.Validate(); err != nil {
+			return err
+		}
+		if _, err := s.discover(ctx, provider.Issuer); err != nil {
+			return errors.Wrap(err, "failed to fetch provider discovery document")
+		}
+	}
+
+	provider.CreatedAt = time.Now()
+	if err := s.providerRepo.CreateIdentityProvider(ctx, provider); err != nil {
+		return err
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type: models.AuditIdentityProviderRegistered,
+		Metadata: map[string]string{
+			"provider": provider.Slug,
+			"protocol": provider.Protocol,
+			"issuer":   provider.Issuer,
+			"scopes":   strings.Join(provider.Scopes, " "),
+			"jit":      strconv.FormatBool(provider.JITProvisioning),
+			"sync":     provider.SyncPolicy,
+		},
+	}); err != nil {
+		return errors.Wrap(err, "failed to record audit event")
+	}
+	return nil
+}
+
+// ListProviders возвращает включенных провайдеров тенанта для страницы входа
+func (s *FederationService) ListProviders(ctx context.Context) ([]*models.IdentityProvider, error) {
+	providers, err := s.providerRepo.ListIdentityProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := providers[:0]
+	for _, provider := range providers {
+		if provider.DisabledAt == nil {
+			enabled = append(enabled, provider)
+		}
+	}
+	return enabled, nil
+}
+
+// Begin начинает вход через провайдера: возвращает адрес, на который нужно
+// отправить браузер, и state, который браузер должен принести обратно.
+// returnTo сохраняется и возвращается из Complete без изменений.
+func (s *FederationService) Begin(ctx context.Context, slug, returnTo string) (string, string, error) {
+	provider, err := s.provider(ctx, slug)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := randomToken(32)
+	if err != nil {
+		return "", "", err
+	}
+	now := time.Now()
+	stored := &models.FederationState{
+		StateHash: hashToken(state),
+		Provider:  provider.Slug,
+		ReturnTo:  returnTo,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.stateTTL),
+	}
+
+	var redirectURL string
+	switch provider.Protocol {
+	case models.IdentityProviderSAML:
+		redirectURL, err = s.samlRedirect(provider, stored, state)
+	default:
+		redirectURL, err = s.oidcRedirect(ctx, provider, stored, state)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	if err := s.identityRepo.CreateFederationState(ctx, stored); err != nil {
+		return "", "", err
+	}
+	return redirectURL, state, nil
+}
+
+func (s *FederationService) oidcRedirect(ctx context.Context, provider *models.IdentityProvider, stored *models.FederationState, state string) (string, error) {
+	discovered, err := s.discover(ctx, provider.Issuer)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to fetch provider discovery document")
+	}
+
+	nonce, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+	stored.Nonce = nonce
+	stored.CodeVerifier = oauth2.GenerateVerifier()
+
+	return s.oauthConfig(provider, discovered).AuthCodeURL(state, oidc.Nonce(nonce), oauth2.S256ChallengeOption(stored.CodeVerifier)), nil
+}
+
+// SAMLMetadata возвращает метаданные нашего SAML SP
+func (s *FederationService) SAMLMetadata() ([]byte, error) {
+	if s.saml == nil {
+		return nil, models.ErrSAMLNotConfigured
+	}
+	return s.saml.Metadata()
+}
+
+// Complete проверяет ответ провайдера (код OIDC или SAML-утверждение), находит
+// связанного пользователя и открывает ему сессию. returnTo возвращается и при
+// ошибке, если state был верным.
+func (s *FederationService) Complete(ctx context.Context, callback *models.FederationCallback) (*models.LoginResponse, string, error) {
+	stored, err := s.identityRepo.ConsumeFederationState(ctx, hashToken(callback.State), time.Now())
+	if err != nil {
+		return nil, "", err
+	}
+	if stored == nil {
+		return nil, "", models.ErrFederationFailed
+	}
+
+	provider, err := s.provider(ctx, stored.Provider)
+	if err != nil {
+		return nil, stored.ReturnTo, err
+	}
+	method := provider.Protocol + ":" + provider.Slug
+
+	var external *models.ExternalIdentity
+	switch provider.Protocol {
+	case models.IdentityProviderSAML:
+		external, err = s.samlIdentity(provider, stored, callback)
+	default:
+		external, err = s.exchange(ctx, provider, stored, callback)
+	}
+	if err != nil {
+		if err == models.ErrSAMLNotConfigured {
+			return nil, stored.ReturnTo, err
+		}
+		return nil, stored.ReturnTo, s.federationFailed(ctx, method, callback.Client, err.Error())
+	}
+
+	user, identity, err := s.resolveUser(ctx, provider, external)
+	if err != nil {
+		if err == models.ErrIdentityNotLinked {
+			if auditErr := s.recordFederationFailure(ctx, method, callback.Client, "identity_not_linked", external.Email); auditErr != nil {
+				return nil, stored.ReturnTo, auditErr
+			}
+		}
+		return nil, stored.ReturnTo, err
+	}
+
+	resp, err := s.registr.LoginExternal(ctx, &models.ExternalLoginRequest{
+		User:     user,
+		Provider: stored.Provider,
+		Method:   method,
+		Client:   callback.Client,
+	})
+	if err != nil {
+		return nil, stored.ReturnTo, err
+	}
+
+	if err := s.identityRepo.TouchIdentity(ctx, identity.ID, time.Now()); err != nil {
+		return nil, stored.ReturnTo, errors.Wrap(err, "failed to update identity")
+	}
+	return resp, stored.ReturnTo, nil
+}
+
+// exchange получает и проверяет ID-токен провайдера. Ошибки проверки возвращаются
+// как обычные ошибки с причиной: Complete записывает ее в аудит.
+func (s *FederationService) exchange(ctx context.Context, provider *models.IdentityProvider, stored *models.FederationState, callback *models.FederationCallback) (*models.ExternalIdentity, error) {
+	if callback.Error != "" {
+		return nil, errors.New("provider_error:" + callback.Error)
+	}
+	if callback.Code == "" {
+		return nil, errors.New("missing_code")
+	}
+
+	discovered, err := s.discover(ctx, provider.Issuer)
+	if err != nil {
+		return nil, errors.Wrap(err, "discovery_failed")
+	}
+
+	token, err := s.oauthConfig(provider, discovered).Exchange(oidc.ClientContext(ctx, s.httpClient), callback.Code, oauth2.VerifierOption(stored.CodeVerifier))
+	if err != nil {
+		return nil, errors.Wrap(err, "code_exchange_failed")
+	}
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("missing_id_token")
+	}
+
+	idToken, external, err := s.verifyIDToken(ctx, provider, discovered, rawIDToken)
+	if err != nil {
+		return nil, err
+	}
+	if idToken.Nonce != stored.Nonce {
+		return nil, errors.New("nonce_mismatch")
+	}
+	return external, nil
+}
+
+// VerifyIdentity проверяет ID-токен, который клиент сам получил у провайдера
+// (например, через его SDK) для нашего client_id. Nonce клиента нам неизвестен,
+// поэтому принимаются только токены, выпущенные не раньше maxAge назад.
+func (s *FederationService) VerifyIdentity(ctx context.Context, slug, rawIDToken string, maxAge time.Duration) (*models.ExternalIdentity, error) {
+	provider, err := s.provider(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	// У SAML-провайдеров ID-токенов нет
+	if provider.Protocol != models.IdentityProviderOIDC {
+		return nil, models.ErrInvalidToken
+	}
+	discovered, err := s.discover(ctx, provider.Issuer)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to fetch provider discovery document")
+	}
+
+	idToken, external, err := s.verifyIDToken(ctx, provider, discovered, rawIDToken)
+	if err != nil || time.Since(idToken.IssuedAt) > maxAge {
+		return nil, models.ErrInvalidToken
+	}
+	return external, nil
+}
+
+func (s *FederationService) verifyIDToken(ctx context.Context, provider *models.IdentityProvider, discovered *oidc.Provider, rawIDToken string) (*oidc.IDToken, *models.ExternalIdentity, error) {
+	idToken, err := discovered.Verifier(&oidc.Config{ClientID: provider.ClientID}).Verify(oidc.ClientContext(ctx, s.httpClient), rawIDToken)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "invalid_id_token")
+	}
+
+	external := &models.ExternalIdentity{
+		Provider: provider.Slug,
+		Subject:  idToken.Subject,
+		Claims:   make(map[string]interface{}),
+	}
+	if err := idToken.Claims(&external.Claims); err != nil {
+		return nil, nil, errors.Wrap(err, "invalid_id_token")
+	}
+	provider.AttributeMapping.Apply(external)
+
+	return idToken, external, nil
+}
+
+// resolveUser находит пользователя по связке с провайдером. Новая связка создается
+// только по email, подтвержденному и провайдером, и у нас: иначе чужая учетная
+// запись у провайдера или незавершенная регистрация дали бы доступ к аккаунту.
+// Если пользователя нет, а у провайдера включено JIT-создание, он создается.
+func (s *FederationService) resolveUser(ctx context.Context, provider *models.IdentityProvider, external *models.ExternalIdentity) (*models.User, *models.Identity, error) {
+	identity, err := s.identityRepo.GetIdentity(ctx, external.Provider, external.Subject)
+	if err != nil {
+		return nil, nil, err
+	}
+	if identity != nil {
+		user, err := s.userRepo.GetUserByID(ctx, identity.UserID)
+		if err != nil {
+			return nil, nil, errors.Wrap(err, "failed to get user")
+		}
+		if user == nil {
+			return nil, nil, models.ErrIdentityNotLinked
+		}
+		if err := s.syncUser(ctx, provider, user, external); err != nil {
+			return nil, nil, err
+		}
+		return user, identity, nil
+	}
+
+	if external.Email == "" {
+		return nil, nil, models.ErrIdentityNotLinked
+	}
+	user, err := s.userRepo.GetUserByEmail(ctx, external.Email)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get user by email")
+	}
+	if user == nil && provider.JITProvisioning {
+		return s.provisionUser(ctx, provider, external)
+	}
+	if user == nil || !user.IsVerified || !external.EmailVerified {
+		return nil, nil, models.ErrIdentityNotLinked
+	}
+
+	identity, err = s.linkIdentity(ctx, user, external, "verified_email")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.syncUser(ctx, provider, user, external); err != nil {
+		return nil, nil, err
+	}
+	return user, identity, nil
+}
+
+// provisionUser создает пользователя по атрибутам провайдера, без пароля,
+// и выдает ему роли по сопоставлению групп
+func (s *FederationService) provisionUser(ctx context.Context, provider *models.IdentityProvider, external *models.ExternalIdentity) (*models.User, *models.Identity, error) {
+	user, err := s.registr.RegisterExternal(ctx, &models.Registr{
+		FirstName: external.GivenName,
+		Surname:   external.FamilyName,
+		Email:     external.Email,
+	}, external.EmailVerified)
+	if err == models.ErrUserAlreadyExists {
+		// Пользователь появился между проверкой и созданием
+		return nil, nil, models.ErrIdentityNotLinked
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	identity, err := s.linkIdentity(ctx, user, external, "provisioned")
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := s.syncRoles(ctx, provider, user.ID, external.Groups, false); err != nil {
+		return nil, nil, err
+	}
+	return user, identity, nil
+}
+
+func (s *FederationService) linkIdentity(ctx context.Context, user *models.User, external *models.ExternalIdentity, reason string) (*models.Identity, error) {
+	identity := &models.Identity{
+		UserID:    user.ID,
+		Provider:  external.Provider,
+		Subject:   external.Subject,
+		Email:     external.Email,
+		CreatedAt: time.Now(),
+	}
+	if err := s.identityRepo.CreateIdentity(ctx, identity); err != nil {
+		return nil, err
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditIdentityLinked,
+		UserID: &user.ID,
+		Email:  user.Email,
+		Metadata: map[string]string{
+			"provider": external.Provider,
+			"subject":  external.Subject,
+			"reason":   reason,
+		},
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to record audit event")
+	}
+	return identity, nil
+}
+
+// syncUser обновляет профиль и роли пользователя по политике провайдера.
+// Email не меняется: он остается идентификатором входа по паролю.
+func (s *FederationService) syncUser(ctx context.Context, provider *models.IdentityProvider, user *models.User, external *models.ExternalIdentity) error {
+	if provider.SyncPolicy != models.SyncProfile && provider.SyncPolicy != models.SyncAll {
+		return nil
+	}
+
+	var changed []string
+	if external.GivenName != "" && external.GivenName != user.FirstName {
+		user.FirstName = external.GivenName
+		changed = append(changed, "first_name")
+	}
+	if external.FamilyName != "" && external.FamilyName != user.Surname {
+		user.Surname = external.FamilyName
+		changed = append(changed, "surname")
+	}
+	// Подтверждение только добавляется: провайдер не может отменить наше
+	if external.EmailVerified && external.Email == user.Email && !user.IsVerified {
+		user.IsVerified = true
+		changed = append(changed, "is_verified")
+	}
+
+	if len(changed) > 0 {
+		user.UpdatedAt = time.Now()
+		if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+			return err
+		}
+		if err := s.audit.Record(ctx, &models.AuditEvent{
+			Type:   models.AuditProfileSynced,
+			UserID: &user.ID,
+			Email:  user.Email,
+			Metadata: map[string]string{
+				"provider": provider.Slug,
+				"fields":   strings.Join(changed, ","),
+			},
+		}); err != nil {
+			return errors.Wrap(err, "failed to record audit event")
+		}
+	}
+
+	if provider.SyncPolicy == models.SyncAll {
+		return s.syncRoles(ctx, provider, user.ID, external.Groups, true)
+	}
+	return nil
+}
+
+// syncRoles выдает роли по группам пользователя у провайдера; с revoke
+// отзывает роли из сопоставления, которые группам больше не соответствуют
+func (s *FederationService) syncRoles(ctx context.Context, provider *models.IdentityProvider, userID int64, groups []string, revoke bool) error {
+	mapping := provider.AttributeMapping
+	return syncManagedRoles(ctx, s.rbac, "Identity provider "+provider.Slug, userID, mapping.Roles(groups), mapping.ManagedRoles(), revoke)
+}
+
+// Фиксирует неудачный вход через провайдера и возвращает ErrFederationFailed:
+// подробности ошибки остаются в аудите и не показываются пользователю
+func (s *FederationService) federationFailed(ctx context.Context, method string, client models.ClientInfo, reason string) error {
+	if err := s.recordFederationFailure(ctx, method, client, reason, ""); err != nil {
+		return err
+	}
+	return models.ErrFederationFailed
+}
+
+func (s *FederationService) recordFederationFailure(ctx context.Context, method string, client models.ClientInfo, reason, email string) error {
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:  models.AuditLoginFailed,
+		Email: email,
+		Metadata: map[string]string{
+			"reason": reason,
+			"method": method,
+			"ip":     client.IP,
+		},
+	}); err != nil {
+		return errors.Wrap(err, "failed to record audit event")
+	}
+	return nil
+}
+
+func (s *FederationService) provider(ctx context.Context, slug string) (*models.IdentityProvider, error) {
+	provider, err := s.providerRepo.GetIdentityProvider(ctx, slug)
+	if err != nil {
+		return nil, err
+	}
+	if provider == nil || provider.DisabledAt != nil {
+		return nil, models.ErrIdentityProviderNotFound
+	}
+	return provider, nil
+}
+
+// discover загружает discovery-документ провайдера один раз на время жизни процесса;
+// ключи подписи go-oidc обновляет сам при появлении неизвестного kid
+func (s *FederationService) discover(ctx context.Context, issuer string) (*oidc.Provider, error) {
+	s.mu.Lock()
+	discovered, ok := s.discovery[issuer]
+	s.mu.Unlock()
+	if ok {
+		return discovered, nil
+	}
+
+	discovered, err := oidc.NewProvider(oidc.ClientContext(ctx, s.httpClient), issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.discovery[issuer] = discovered
+	s.mu.Unlock()
+	return discovered, nil
+}
+
+func (s *FederationService) oauthConfig(provider *models.IdentityProvider, discovered *oidc.Provider) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     provider.ClientID,
+		ClientSecret: provider.ClientSecret,
+		Endpoint:     discovered.Endpoint(),
+		RedirectURL:  s.callbackURL,
+		Scopes:       provider.Scopes,
+	}
+}
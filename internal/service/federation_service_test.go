@@ -0,0 +1,297 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	oidcTestClientID = "auth-service"
+	oidcTestCode     = "code-5d41402abc4b2a76"
+	oidcTestNonce    = "nonce-7d793037a0760186"
+)
+
+type oidcTestKey struct {
+	id  string
+	key *rsa.PrivateKey
+}
+
+func newOIDCTestKey(t *testing.T, id string) *oidcTestKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &oidcTestKey{id: id, key: key}
+}
+
+// oidcTestIdP - провайдер OpenID Connect на httptest: discovery, JWKS и
+// token-эндпоинт, который отдает заранее подписанный ID-токен
+type oidcTestIdP struct {
+	server *httptest.Server
+
+	mu      sync.Mutex
+	keys    []*oidcTestKey
+	idToken string
+}
+
+func newOIDCTestIdP(t *testing.T, keys ...*oidcTestKey) *oidcTestIdP {
+	t.Helper()
+
+	idp := &oidcTestIdP{keys: keys}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", idp.discovery)
+	mux.HandleFunc("/jwks", idp.jwks)
+	mux.HandleFunc("/token", idp.token)
+	idp.server = httptest.NewServer(mux)
+	t.Cleanup(idp.server.Close)
+	return idp
+}
+
+func (idp *oidcTestIdP) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := idp.server.URL
+	writeTestJSON(w, map[string]interface{}{
+		"issuer":                                issuer,
+		"authorization_endpoint":                issuer + "/authorize",
+		"token_endpoint":                        issuer + "/token",
+		"jwks_uri":                              issuer + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (idp *oidcTestIdP) jwks(w http.ResponseWriter, r *http.Request) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+
+	var set jose.JSONWebKeySet
+	for _, key := range idp.keys {
+		set.Keys = append(set.Keys, jose.JSONWebKey{Key: &key.key.PublicKey, KeyID: key.id, Algorithm: "RS256", Use: "sig"})
+	}
+	writeTestJSON(w, set)
+}
+
+func (idp *oidcTestIdP) token(w http.ResponseWriter, r *http.Request) {
+	// Код одноразовый и выдан под PKCE - без верификатора не обмениваем
+	if r.PostFormValue("code") != oidcTestCode || r.PostFormValue("code_verifier") == "" {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	writeTestJSON(w, map[string]interface{}{
+		"access_token": "access-token",
+		"token_type":   "Bearer",
+		"expires_in":   300,
+		"id_token":     idp.idToken,
+	})
+}
+
+// rotate публикует новый набор ключей вместо старого
+func (idp *oidcTestIdP) rotate(keys ...*oidcTestKey) {
+	idp.mu.Lock()
+	defer idp.mu.Unlock()
+	idp.keys = keys
+}
+
+func (idp *oidcTestIdP) claims() map[string]interface{} {
+	now := time.Now()
+	return map[string]interface{}{
+		"iss":            idp.server.URL,
+		"sub":            "user-123",
+		"aud":            oidcTestClientID,
+		"iat":            now.Unix(),
+		"exp":            now.Add(5 * time.Minute).Unix(),
+		"nonce":          oidcTestNonce,
+		"email":          "Alice@Example.com",
+		"email_verified": true,
+		"given_name":     "Alice",
+	}
+}
+
+func signTestIDToken(t *testing.T, key *oidcTestKey, claims map[string]interface{}) string {
+	t.Helper()
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: jose.JSONWebKey{Key: key.key, KeyID: key.id}},
+		(&jose.SignerOptions{}).WithType("JWT"),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signed, err := signer.Sign(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := signed.CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func writeTestJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(value)
+}
+
+type oidcTestFederation struct {
+	idp      *oidcTestIdP
+	service  *FederationService
+	provider *models.IdentityProvider
+}
+
+func newOIDCTestFederation(t *testing.T, keys ...*oidcTestKey) *oidcTestFederation {
+	t.Helper()
+
+	idp := newOIDCTestIdP(t, keys...)
+	return &oidcTestFederation{
+		idp:     idp,
+		service: NewFederationService(nil, nil, nil, nil, nil, nil, nil, "https://auth.example.com/oauth2/federation/callback", time.Minute),
+		provider: &models.IdentityProvider{
+			Slug:         "corp",
+			Protocol:     models.IdentityProviderOIDC,
+			Issuer:       idp.server.URL,
+			ClientID:     oidcTestClientID,
+			ClientSecret: "client-secret",
+			Scopes:       []string{models.ScopeOpenID, "email", "profile"},
+		},
+	}
+}
+
+// complete проходит обмен кода, как после возврата браузера на callback
+func (f *oidcTestFederation) complete(idToken string) (*models.ExternalIdentity, error) {
+	f.idp.mu.Lock()
+	f.idp.idToken = idToken
+	f.idp.mu.Unlock()
+
+	stored := &models.FederationState{Provider: f.provider.Slug, Nonce: oidcTestNonce, CodeVerifier: "verifier-0123456789abcdef0123456789abcdef0123"}
+	return f.service.exchange(context.Background(), f.provider, stored, &models.FederationCallback{Code: oidcTestCode})
+}
+
+func expectFederationError(t *testing.T, err error, reasons ...string) {
+	t.Helper()
+
+	if err == nil {
+		t.Fatalf("ID token accepted, want error containing %q", reasons)
+	}
+	for _, reason := range reasons {
+		if !strings.Contains(err.Error(), reason) {
+			t.Fatalf("error = %v, want it to contain %q", err, reason)
+		}
+	}
+}
+
+func TestOIDCExchangeValidToken(t *testing.T) {
+	key := newOIDCTestKey(t, "key-1")
+	f := newOIDCTestFederation(t, key)
+
+	external, err := f.complete(signTestIDToken(t, key, f.idp.claims()))
+	if err != nil {
+		t.Fatalf("exchange: %v", err)
+	}
+	if external.Provider != "corp" || external.Subject != "user-123" {
+		t.Fatalf("identity = %s/%s", external.Provider, external.Subject)
+	}
+	if external.Email != "alice@example.com" || !external.EmailVerified || external.GivenName != "Alice" {
+		t.Fatalf("attributes = %q %v %q", external.Email, external.EmailVerified, external.GivenName)
+	}
+}
+
+func TestOIDCExchangeRejectsWrongIssuer(t *testing.T) {
+	key := newOIDCTestKey(t, "key-1")
+	f := newOIDCTestFederation(t, key)
+
+	claims := f.idp.claims()
+	claims["iss"] = "https://evil.example.com"
+
+	_, err := f.complete(signTestIDToken(t, key, claims))
+	expectFederationError(t, err, "invalid_id_token", "different provider")
+}
+
+func TestOIDCExchangeRejectsWrongAudience(t *testing.T) {
+	key := newOIDCTestKey(t, "key-1")
+	f := newOIDCTestFederation(t, key)
+
+	// Токен, выпущенный провайдером для другого клиента
+	claims := f.idp.claims()
+	claims["aud"] = "other-client"
+
+	_, err := f.complete(signTestIDToken(t, key, claims))
+	expectFederationError(t, err, "invalid_id_token", "audience")
+}
+
+func TestOIDCExchangeRejectsWrongNonce(t *testing.T) {
+	key := newOIDCTestKey(t, "key-1")
+	f := newOIDCTestFederation(t, key)
+
+	// Токен из чужого входа, подставленный в наш callback
+	claims := f.idp.claims()
+	claims["nonce"] = "nonce-of-another-login"
+
+	_, err := f.complete(signTestIDToken(t, key, claims))
+	expectFederationError(t, err, "nonce_mismatch")
+}
+
+func TestOIDCExchangeRejectsExpiredToken(t *testing.T) {
+	key := newOIDCTestKey(t, "key-1")
+	f := newOIDCTestFederation(t, key)
+
+	claims := f.idp.claims()
+	claims["iat"] = time.Now().Add(-2 * time.Hour).Unix()
+	claims["exp"] = time.Now().Add(-time.Hour).Unix()
+
+	_, err := f.complete(signTestIDToken(t, key, claims))
+	expectFederationError(t, err, "invalid_id_token", "expired")
+}
+
+func TestOIDCExchangeRejectsForeignSignature(t *testing.T) {
+	key := newOIDCTestKey(t, "key-1")
+	f := newOIDCTestFederation(t, key)
+
+	// Чужой ключ с тем же kid
+	forged := newOIDCTestKey(t, "key-1")
+
+	_, err := f.complete(signTestIDToken(t, forged, f.idp.claims()))
+	expectFederationError(t, err, "invalid_id_token")
+}
+
+func TestOIDCExchangeKeyRotation(t *testing.T) {
+	oldKey := newOIDCTestKey(t, "key-1")
+	f := newOIDCTestFederation(t, oldKey)
+
+	if _, err := f.complete(signTestIDToken(t, oldKey, f.idp.claims())); err != nil {
+		t.Fatalf("exchange with original key: %v", err)
+	}
+
+	// Провайдер сменил ключ: неизвестный kid заставляет перечитать JWKS
+	newKey := newOIDCTestKey(t, "key-2")
+	f.idp.rotate(newKey)
+
+	if _, err := f.complete(signTestIDToken(t, newKey, f.idp.claims())); err != nil {
+		t.Fatalf("exchange with rotated key: %v", err)
+	}
+
+	// Отозванный ключ больше не принимается
+	_, err := f.complete(signTestIDToken(t, oldKey, f.idp.claims()))
+	expectFederationError(t, err, "invalid_id_token")
+}
@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+	"github.com/DailyPepper/auth-service/pkg/geoip"
+
+	"github.com/pkg/errors"
+)
+
+// Погрешность GeoIP-баз - десятки километров, на малых расстояниях скорость не считаем
+const minTravelDistanceKm = 100
+
+type GeoService struct {
+	reader      *geoip.Reader
+	sessionRepo repository.SessionRepository
+	maxSpeedKmh float64
+}
+
+func NewGeoService(reader *geoip.Reader, sessionRepo repository.SessionRepository, maxSpeedKmh float64) *GeoService {
+	return &GeoService{
+		reader:      reader,
+		sessionRepo: sessionRepo,
+		maxSpeedKmh: maxSpeedKmh,
+	}
+}
+
+// Locate возвращает nil, если адрес не удалось геолоцировать
+func (s *GeoService) Locate(ip string) (*models.GeoLocation, error) {
+	location, err := s.reader.Lookup(ip)
+	if err != nil || location == nil {
+		return nil, err
+	}
+
+	return &models.GeoLocation{
+		CountryCode: location.CountryCode,
+		Country:     location.Country,
+		City:        location.City,
+		Latitude:    location.Latitude,
+		Longitude:   location.Longitude,
+	}, nil
+}
+
+// IsImpossibleTravel сравнивает место входа с предыдущим входом пользователя:
+// если для перемещения нужна скорость выше порога, вход подозрительный
+func (s *GeoService) IsImpossibleTravel(ctx context.Context, userID int64, location *models.GeoLocation, at time.Time) (bool, error) {
+	if location == nil {
+		return false, nil
+	}
+
+	previous, err := s.sessionRepo.GetLastLocatedSession(ctx, userID)
+	if err != nil {
+		return false, errors.Wrap(err, "failed to get previous login location")
+	}
+	if previous == nil {
+		return false, nil
+	}
+
+	distance := geoip.DistanceKm(
+		previous.Location.Latitude, previous.Location.Longitude,
+		location.Latitude, location.Longitude,
+	)
+	if distance < minTravelDistanceKm {
+		return false, nil
+	}
+
+	elapsed := at.Sub(previous.CreatedAt).Hours()
+	if elapsed <= 0 {
+		return true, nil
+	}
+
+	return distance/elapsed > s.maxSpeedKmh, nil
+}
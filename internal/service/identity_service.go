@@ -0,0 +1,231 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+
+	"github.com/pkg/errors"
+)
+
+// IdentityService управляет способами входа пользователя: привязкой внешних
+// учетных записей и слиянием дубликатов, появившихся до привязки
+type IdentityService struct {
+	identityRepo repository.IdentityRepository
+	providerRepo repository.IdentityProviderRepository
+	mergeRepo    repository.UserMergeRepository
+	userRepo     repository.UserRepository
+	sessionRepo  repository.SessionRepository
+	federation   Federation
+	audit        Audit
+	reauthWindow time.Duration
+}
+
+func NewIdentityService(
+	identityRepo repository.IdentityRepository,
+	providerRepo repository.IdentityProviderRepository,
+	mergeRepo repository.UserMergeRepository,
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	federation Federation,
+	audit Audit,
+	reauthWindow time.Duration,
+) *IdentityService {
+	return &IdentityService{
+		identityRepo: identityRepo,
+		providerRepo: providerRepo,
+		mergeRepo:    mergeRepo,
+		userRepo:     userRepo,
+		sessionRepo:  sessionRepo,
+		federation:   federation,
+		audit:        audit,
+		reauthWindow: reauthWindow,
+	}
+}
+
+func (s *IdentityService) ListIdentities(ctx context.Context, userID int64) (*models.LoginMethods, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	if user == nil {
+		return nil, models.ErrUserNotFound
+	}
+
+	identities, err := s.identityRepo.ListUserIdentities(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return &models.LoginMethods{Password: user.HasPassword(), Identities: identities}, nil
+}
+
+// LinkIdentity привязывает учетную запись провайдера после повторной аутентификации:
+// украденный access-токен не должен позволять добавить атакующему свой способ входа
+func (s *IdentityService) LinkIdentity(ctx context.Context, userID int64, sessionID string, req *models.LinkIdentityRequest) (*models.Identity, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user")
+	}
+	if user == nil {
+		return nil, models.ErrUserNotFound
+	}
+	if err := s.reauthenticate(ctx, user, sessionID, req.Password); err != nil {
+		return nil, err
+	}
+
+	external, err := s.federation.VerifyIdentity(ctx, req.Provider, req.IDToken, s.reauthWindow)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := &models.Identity{
+		UserID:    user.ID,
+		Provider:  external.Provider,
+		Subject:   external.Subject,
+		Email:     external.Email,
+		CreatedAt: time.Now(),
+	}
+	if err := s.identityRepo.CreateIdentity(ctx, identity); err != nil {
+		return nil, err
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditIdentityLinked,
+		UserID: &user.ID,
+		Email:  user.Email,
+		Metadata: map[string]string{
+			"provider": identity.Provider,
+			"subject":  identity.Subject,
+			"reason":   "user_request",
+		},
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to record audit event")
+	}
+	return identity, nil
+}
+
+// UnlinkIdentity отвязывает учетную запись провайдера, если после этого у
+// пользователя останется способ войти: пароль или привязка к включенному провайдеру
+func (s *IdentityService) UnlinkIdentity(ctx context.Context, userID, identityID int64) error {
+	methods, err := s.ListIdentities(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	var removed *models.Identity
+	for _, identity := range methods.Identities {
+		if identity.ID == identityID {
+			removed = identity
+		}
+	}
+	if removed == nil {
+		return models.ErrIdentityNotFound
+	}
+
+	if !methods.Password {
+		enabled, err := s.enabledProviders(ctx)
+		if err != nil {
+			return err
+		}
+
+		usable := false
+		for _, identity := range methods.Identities {
+			if identity.ID != identityID && enabled[identity.Provider] {
+				usable = true
+			}
+		}
+		if !usable {
+			return models.ErrLastLoginMethod
+		}
+	}
+
+	deleted, err := s.identityRepo.DeleteIdentity(ctx, userID, identityID)
+	if err != nil {
+		return err
+	}
+	if !deleted {
+		return models.ErrIdentityNotFound
+	}
+
+	return errors.Wrap(s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditIdentityUnlinked,
+		UserID: &userID,
+		Metadata: map[string]string{
+			"provider": removed.Provider,
+			"subject":  removed.Subject,
+		},
+	}), "failed to record audit event")
+}
+
+// MergeUsers объединяет дубликат source с основной учетной записью target
+func (s *IdentityService) MergeUsers(ctx context.Context, actorID, sourceID, targetID int64) error {
+	if sourceID == targetID {
+		return models.ErrInvalidMerge
+	}
+	for _, id := range []int64{sourceID, targetID} {
+		user, err := s.userRepo.GetUserByID(ctx, id)
+		if err != nil {
+			return errors.Wrap(err, "failed to get user")
+		}
+		if user == nil {
+			return models.ErrUserNotFound
+		}
+		if user.MergedInto != nil {
+			return models.ErrInvalidMerge
+		}
+	}
+
+	if err := s.mergeRepo.MergeUsers(ctx, sourceID, targetID, time.Now()); err != nil {
+		if err == models.ErrInvalidMerge {
+			return err
+		}
+		return errors.Wrap(err, "failed to merge users")
+	}
+
+	return errors.Wrap(s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditUsersMerged,
+		UserID: &targetID,
+		Metadata: map[string]string{
+			"source_user_id": strconv.FormatInt(sourceID, 10),
+			"actor_id":       strconv.FormatInt(actorID, 10),
+		},
+	}), "failed to record audit event")
+}
+
+// reauthenticate: верный текущий пароль или вход не раньше reauthWindow назад.
+// Второй вариант нужен пользователям без пароля, вошедшим через провайдера.
+func (s *IdentityService) reauthenticate(ctx context.Context, user *models.User, sessionID, password string) error {
+	if password != "" {
+		if !user.HasPassword() || !user.CheckPassword(password) {
+			return models.ErrInvalidCredentials
+		}
+		return nil
+	}
+
+	session, err := s.sessionRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		return errors.Wrap(err, "failed to get session")
+	}
+	if session == nil || time.Since(session.CreatedAt) > s.reauthWindow {
+		return models.ErrReauthenticationRequired
+	}
+	return nil
+}
+
+func (s *IdentityService) enabledProviders(ctx context.Context) (map[string]bool, error) {
+	providers, err := s.providerRepo.ListIdentityProviders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	enabled := make(map[string]bool, len(providers))
+	for _, provider := range providers {
+		if provider.DisabledAt == nil {
+			enabled[provider.Slug] = true
+		}
+	}
+	return enabled, nil
+}
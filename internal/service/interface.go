@@ -2,13 +2,147 @@ package service
 
 import (
 	"context"
+	"time"
 
 	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/signing"
 )
 
 type Registr interface {
 	Registration(ctx context.Context, req *models.Registr) (*models.User, error)
+	RegisterInvited(ctx context.Context, req *models.Registr) (*models.User, error)
+	RegisterExternal(ctx context.Context, req *models.Registr, verified bool) (*models.User, error)
 	Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error)
+	LoginExternal(ctx context.Context, req *models.ExternalLoginRequest) (*models.LoginResponse, error)
+	LoginDevice(ctx context.Context, user *models.User, client models.ClientInfo) (*models.LoginResponse, error)
+	Impersonate(ctx context.Context, admin *models.User, targetID int64, reason string, client models.ClientInfo) (*models.LoginResponse, error)
 	GetUserProfile(ctx context.Context, userID int64) (*models.User, error)
 	ValidateToken(ctx context.Context, token string) (*models.User, error)
+	Authenticate(ctx context.Context, token string) (*models.TokenClaims, *models.User, error)
+	AuthenticateRequest(ctx context.Context, token string, proof *models.DPoPRequest) (*models.TokenClaims, *models.User, error)
+	ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) error
+}
+
+type DPoP interface {
+	Verify(req *models.DPoPRequest) (string, error)
+}
+
+type Devices interface {
+	CheckLogin(ctx context.Context, user *models.User, session *models.Session, client models.ClientInfo) error
+	ReportUnrecognizedLogin(ctx context.Context, reportToken string) (string, error)
+}
+
+type Audit interface {
+	Record(ctx context.Context, event *models.AuditEvent) error
+	Verify(ctx context.Context) (*models.AuditVerification, error)
+}
+
+type RiskEngine interface {
+	Assess(ctx context.Context, attempt *models.LoginAttempt) (*models.RiskAssessment, error)
+}
+
+type Geo interface {
+	Locate(ip string) (*models.GeoLocation, error)
+	IsImpossibleTravel(ctx context.Context, userID int64, location *models.GeoLocation, at time.Time) (bool, error)
+}
+
+type Sessions interface {
+	ListSessions(ctx context.Context, userID int64) ([]*models.Session, error)
+}
+
+type Challenges interface {
+	Issue(ctx context.Context) (*models.Challenge, error)
+	CheckRegister(ctx context.Context, solution models.ChallengeSolution, client models.ClientInfo) error
+	CheckLogin(ctx context.Context, attempt *models.LoginAttempt, solution models.ChallengeSolution, riskRequired bool) error
+}
+
+type RBAC interface {
+	CreateRole(ctx context.Context, actorID int64, role *models.Role) (*models.Role, error)
+	GrantRole(ctx context.Context, actorID *int64, userID int64, roleName string) error
+	RevokeRole(ctx context.Context, actorID *int64, userID int64, roleName string) error
+	GetUserAccess(ctx context.Context, userID int64) (*models.UserAccess, error)
+	RequirePermission(ctx context.Context, userID int64, permission string) error
+}
+
+type Relations interface {
+	WriteRelationships(ctx context.Context, updates []models.RelationUpdate) (models.RelationRevision, error)
+	CheckPermission(ctx context.Context, check models.RelationTuple, consistency models.Consistency) (bool, models.RelationRevision, error)
+	LookupResources(ctx context.Context, namespace, permission string, subject models.SubjectRef, consistency models.Consistency) ([]string, models.RelationRevision, error)
+}
+
+type Policies interface {
+	SavePolicy(ctx context.Context, actorID int64, policy *models.Policy) (*models.Policy, error)
+	DeletePolicy(ctx context.Context, actorID int64, name string) error
+	ListPolicies(ctx context.Context) ([]*models.Policy, error)
+	CheckLogin(ctx context.Context, attempt *models.LoginAttempt) (*models.PolicyDecision, error)
+	Authorize(ctx context.Context, input *models.PolicyInput) (*models.PolicyDecision, error)
+}
+
+type Tenants interface {
+	Resolve(ctx context.Context, slug string) (*models.Tenant, error)
+	CreateTenant(ctx context.Context, tenant *models.Tenant) (*models.Tenant, error)
+	ListTenants(ctx context.Context) ([]*models.Tenant, error)
+}
+
+type Organizations interface {
+	CreateOrganization(ctx context.Context, actorID int64, org *models.Organization) (*models.Organization, error)
+	InviteMember(ctx context.Context, actorID, orgID int64, email string, role models.OrganizationRole) (*models.Invitation, error)
+	AcceptInvitation(ctx context.Context, req *models.AcceptInvitationRequest) (*models.OrganizationMembership, error)
+	RemoveMember(ctx context.Context, actorID, orgID, userID int64) error
+	ChangeMemberRole(ctx context.Context, actorID, orgID, userID int64, role models.OrganizationRole) error
+}
+
+type Domains interface {
+	AddDomain(ctx context.Context, actorID int64, domain *models.Domain) (*models.Domain, error)
+	VerifyDomain(ctx context.Context, actorID, id int64) (*models.Domain, error)
+	ListDomains(ctx context.Context, actorID int64, organizationID *int64) ([]*models.Domain, error)
+	ConfigureSSO(ctx context.Context, actorID, id int64, sso models.DomainSSO) (*models.Domain, error)
+	DiscoverLoginMethod(ctx context.Context, email string) (*models.LoginDiscovery, error)
+	CheckLogin(ctx context.Context, email, provider string) error
+}
+
+type APIKeys interface {
+	CreateServiceAccount(ctx context.Context, actorID int64, account *models.ServiceAccount) (*models.ServiceAccount, error)
+	CreateAPIKey(ctx context.Context, actorID int64, key *models.APIKey) (*models.APIKey, string, error)
+	ListAPIKeys(ctx context.Context, serviceAccountID int64) ([]*models.APIKey, error)
+	RotateAPIKey(ctx context.Context, actorID, keyID int64, grace time.Duration) (*models.APIKey, string, error)
+	RevokeAPIKey(ctx context.Context, actorID, keyID int64) error
+	ValidateAPIKey(ctx context.Context, rawKey, ip string) (*models.APIKeyPrincipal, error)
+}
+
+type OAuth interface {
+	RegisterClient(ctx context.Context, client *models.OAuthClient) (*models.OAuthClient, string, error)
+	Token(ctx context.Context, req *models.TokenRequest) (*models.TokenResponse, error)
+	StartDeviceAuthorization(ctx context.Context, req *models.TokenRequest, device models.ClientInfo) (*models.DeviceAuthorizationResponse, error)
+	PendingDeviceAuthorization(ctx context.Context, userID int64, userCode string, browser models.ClientInfo) (*models.DeviceAuthorization, *models.OAuthClient, error)
+	DecideDeviceAuthorization(ctx context.Context, userID int64, userCode string, approved bool, browser models.ClientInfo) (*models.DeviceAuthorization, error)
+}
+
+type OIDC interface {
+	Issuer() string
+	JWKS() []signing.JWK
+	Login(ctx context.Context, email, password string, client models.ClientInfo) (*models.Session, string, error)
+	BrowserSession(ctx context.Context, cookie string) (*models.Session, error)
+	Authorize(ctx context.Context, req *models.AuthorizationRequest, session *models.Session) (*models.AuthorizationResult, error)
+	Consent(ctx context.Context, req *models.AuthorizationRequest, session *models.Session, approved bool) (*models.AuthorizationResult, error)
+	UserInfo(ctx context.Context, accessToken string, proof *models.DPoPRequest) (map[string]interface{}, error)
+	EndSession(ctx context.Context, req *models.EndSessionRequest) (*models.EndSession, error)
+	Logout(ctx context.Context, session *models.Session) error
+	StartSession(ctx context.Context, resp *models.LoginResponse) (*models.Session, string, error)
+}
+
+type Federation interface {
+	RegisterProvider(ctx context.Context, provider *models.IdentityProvider) error
+	ListProviders(ctx context.Context) ([]*models.IdentityProvider, error)
+	Begin(ctx context.Context, slug, returnTo string) (string, string, error)
+	Complete(ctx context.Context, callback *models.FederationCallback) (*models.LoginResponse, string, error)
+	VerifyIdentity(ctx context.Context, slug, rawIDToken string, maxAge time.Duration) (*models.ExternalIdentity, error)
+	SAMLMetadata() ([]byte, error)
+}
+
+type Identities interface {
+	ListIdentities(ctx context.Context, userID int64) (*models.LoginMethods, error)
+	LinkIdentity(ctx context.Context, userID int64, sessionID string, req *models.LinkIdentityRequest) (*models.Identity, error)
+	UnlinkIdentity(ctx context.Context, userID, identityID int64) error
+	MergeUsers(ctx context.Context, actorID, sourceID, targetID int64) error
 }
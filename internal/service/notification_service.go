@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/mailer"
+)
+
+const notificationTimeout = 30 * time.Second
+
+type NotificationService struct {
+	mailer    mailer.Mailer
+	publicURL string
+}
+
+func NewNotificationService(mailer mailer.Mailer, publicURL string) *NotificationService {
+	return &NotificationService{
+		mailer:    mailer,
+		publicURL: strings.TrimRight(publicURL, "/"),
+	}
+}
+
+// NotifyNewDevice сообщает о входе с незнакомого устройства.
+// Письмо уходит в фоне, чтобы не задерживать Login.
+func (s *NotificationService) NotifyNewDevice(user *models.User, device *models.KnownDevice, client models.ClientInfo, reportToken string) {
+	link := s.publicURL + "/security/not-me?token=" + url.QueryEscape(reportToken)
+
+	body := fmt.Sprintf(`Hello, %s!
+
+We noticed a new sign-in to your account.
+
+  Time:    %s
+  Browser: %s
+  Network: %s
+  IP:      %s
+
+If this was you, no action is needed.
+
+If this wasn't you, open the link below. We will sign out that session
+and ask you to choose a new password:
+
+%s
+`,
+		user.FirstName,
+		device.FirstSeenAt.UTC().Format(time.RFC1123),
+		device.UserAgentFamily,
+		device.IPPrefix,
+		client.IP,
+		link,
+	)
+
+	go s.send(user.Email, "New sign-in to your account", body)
+}
+
+func (s *NotificationService) send(to, subject, body string) {
+	ctx, cancel := context.WithTimeout(context.Background(), notificationTimeout)
+	defer cancel()
+
+	if err := s.mailer.Send(ctx, to, subject, body); err != nil {
+		log.Printf("Failed to send notification %q: %v", subject, err)
+	}
+}
+
+// NotifyInvitation отправляет приглашение в организацию.
+// Тенант входит в ссылку: без него фронтенд не найдет приглашение.
+func (s *NotificationService) NotifyInvitation(invitation *models.Invitation, org *models.Organization, inviter *models.User, tenantSlug, token string) {
+	link := s.publicURL + "/invitations/accept?tenant=" + url.QueryEscape(tenantSlug) + "&token=" + url.QueryEscape(token)
+
+	body := fmt.Sprintf(`Hello!
+
+%s %s invited you to join %s.
+
+Open the link below to accept the invitation. You can sign in with an
+existing account or create a new one:
+
+%s
+
+The invitation expires on %s.
+`,
+		inviter.FirstName,
+		inviter.Surname,
+		org.Name,
+		link,
+		invitation.ExpiresAt.UTC().Format(time.RFC1123),
+	)
+
+	go s.send(invitation.Email, "Invitation to join "+org.Name, body)
+}
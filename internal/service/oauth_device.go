@@ -0,0 +1,273 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"log"
+	"math/big"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+
+	"github.com/pkg/errors"
+)
+
+// Запас на сетевые задержки: опрос чуть раньше интервала не считается слишком частым
+const devicePollLeeway = time.Second
+
+// StartDeviceAuthorization - device authorization endpoint (RFC 8628, 3.1-3.2).
+// Клиент аутентифицируется так же, как на token endpoint; device - IP и User-Agent
+// устройства, с ними после подтверждения создается его сессия.
+func (s *OAuthService) StartDeviceAuthorization(ctx context.Context, req *models.TokenRequest, device models.ClientInfo) (*models.DeviceAuthorizationResponse, error) {
+	client, err := s.authenticateClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrant(models.GrantDeviceCode) {
+		return nil, models.NewOAuthError(models.OAuthUnauthorizedClient, "client is not allowed to use device_code")
+	}
+
+	scopes, err := grantedValues(strings.Fields(req.Scope), client.Scopes)
+	if err != nil {
+		return nil, models.NewOAuthError(models.OAuthInvalidScope, "requested scope is not allowed for this client")
+	}
+
+	deviceCode, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	userCode, err := randomUserCode()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	authorization := &models.DeviceAuthorization{
+		DeviceCodeHash: hashToken(deviceCode),
+		UserCodeHash:   hashToken(userCode),
+		ClientID:       client.ClientID,
+		Scopes:         scopes,
+		Status:         models.DeviceAuthorizationPending,
+		Client:         device,
+		PollInterval:   models.DevicePollInterval,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(s.deviceCodeTTL),
+	}
+	if err := s.deviceRepo.CreateDeviceAuthorization(ctx, authorization); err != nil {
+		return nil, err
+	}
+
+	verificationURI := s.issuer + "/oauth2/device"
+	formatted := models.FormatUserCode(userCode)
+	return &models.DeviceAuthorizationResponse{
+		DeviceCode:              deviceCode,
+		UserCode:                formatted,
+		VerificationURI:         verificationURI,
+		VerificationURIComplete: verificationURI + "?user_code=" + url.QueryEscape(formatted),
+		ExpiresIn:               s.deviceCodeTTL,
+		Interval:                authorization.PollInterval,
+	}, nil
+}
+
+// PendingDeviceAuthorization находит запрос устройства по коду, который ввел
+// пользователь userID с адреса browser. user_code - единственный секрет, который
+// связывает устройство с аккаунтом, поэтому неверные коды считаются и после
+// models.UserCodeMaxFailures за окно ввод отклоняется (RFC 8628, 5.1).
+func (s *OAuthService) PendingDeviceAuthorization(ctx context.Context, userID int64, userCode string, browser models.ClientInfo) (*models.DeviceAuthorization, *models.OAuthClient, error) {
+	now := time.Now()
+	failures, err := s.auditRepo.CountFailedUserCodes(ctx, userID, browser.IP, now.Add(-models.UserCodeFailureWindow))
+	if err != nil {
+		return nil, nil, err
+	}
+	if failures >= models.UserCodeMaxFailures {
+		return nil, nil, models.ErrTooManyUserCodeAttempts
+	}
+
+	authorization, client, err := s.findDeviceAuthorization(ctx, userCode, now)
+	if err == models.ErrInvalidUserCode {
+		if err := s.audit.Record(ctx, &models.AuditEvent{
+			Type:     models.AuditOAuthDeviceCodeFailed,
+			UserID:   &userID,
+			Metadata: map[string]string{"ip": browser.IP},
+		}); err != nil {
+			return nil, nil, errors.Wrap(err, "failed to record audit event")
+		}
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return authorization, client, nil
+}
+
+func (s *OAuthService) findDeviceAuthorization(ctx context.Context, userCode string, now time.Time) (*models.DeviceAuthorization, *models.OAuthClient, error) {
+	code := models.NormalizeUserCode(userCode)
+	if code == "" {
+		return nil, nil, models.ErrInvalidUserCode
+	}
+
+	authorization, err := s.deviceRepo.FindPendingDeviceAuthorization(ctx, hashToken(code), now)
+	if err != nil {
+		return nil, nil, err
+	}
+	if authorization == nil {
+		return nil, nil, models.ErrInvalidUserCode
+	}
+
+	client, err := s.clientRepo.GetOAuthClient(ctx, authorization.ClientID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get oauth client")
+	}
+	if client == nil || client.DisabledAt != nil {
+		return nil, nil, models.ErrInvalidUserCode
+	}
+	return authorization, client, nil
+}
+
+// DecideDeviceAuthorization сохраняет ответ пользователя на запрос устройства.
+// Одобренное устройство получает собственную сессию: она видна в списке сессий
+// и завершается независимо от сессии, из которой вход подтвердили.
+func (s *OAuthService) DecideDeviceAuthorization(ctx context.Context, userID int64, userCode string, approved bool, browser models.ClientInfo) (*models.DeviceAuthorization, error) {
+	authorization, _, err := s.PendingDeviceAuthorization(ctx, userID, userCode, browser)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user by ID")
+	}
+	if user == nil {
+		return nil, models.ErrUserNotFound
+	}
+
+	now := time.Now()
+	authorization.UserID = &user.ID
+	authorization.DecidedAt = &now
+	authorization.Status = models.DeviceAuthorizationDenied
+	eventType := models.AuditOAuthDeviceDenied
+	if approved {
+		// Риск и политики входа оцениваются по адресу устройства, а не браузера
+		resp, err := s.registr.LoginDevice(ctx, user, authorization.Client)
+		if err != nil {
+			return nil, err
+		}
+		authorization.Status = models.DeviceAuthorizationApproved
+		authorization.SessionID = resp.SessionID
+		eventType = models.AuditOAuthDeviceApproved
+	}
+
+	decided, err := s.deviceRepo.DecideDeviceAuthorization(ctx, authorization)
+	if err != nil {
+		return nil, err
+	}
+	if !decided {
+		// Запрос успели решить в другой вкладке или он истек: новая сессия не нужна
+		if authorization.SessionID != "" {
+			if err := s.sessionRepo.RevokeSession(ctx, authorization.SessionID, now); err != nil {
+				log.Printf("Failed to revoke unused device session: %v", err)
+			}
+		}
+		return nil, models.ErrInvalidUserCode
+	}
+
+	metadata := map[string]string{
+		"client_id": authorization.ClientID,
+		"scope":     strings.Join(authorization.Scopes, " "),
+		"device_ip": authorization.Client.IP,
+	}
+	if authorization.SessionID != "" {
+		metadata["session_id"] = authorization.SessionID
+	}
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:     eventType,
+		UserID:   &user.ID,
+		Email:    user.Email,
+		Metadata: metadata,
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to record audit event")
+	}
+
+	return authorization, nil
+}
+
+// deviceCode отвечает на опрос token endpoint устройством (RFC 8628, 3.4-3.5).
+// Токены по одобренному запросу выдаются один раз.
+func (s *OAuthService) deviceCode(ctx context.Context, req *models.TokenRequest, jkt string) (*models.TokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrant(models.GrantDeviceCode) {
+		return nil, models.NewOAuthError(models.OAuthUnauthorizedClient, "client is not allowed to use device_code")
+	}
+	if req.DeviceCode == "" {
+		return nil, models.NewOAuthError(models.OAuthInvalidRequest, "device_code is required")
+	}
+
+	now := time.Now()
+	invalidGrant := models.NewOAuthError(models.OAuthInvalidGrant, "invalid device code")
+
+	deviceCodeHash := hashToken(req.DeviceCode)
+	authorization, err := s.deviceRepo.GetDeviceAuthorization(ctx, deviceCodeHash)
+	if err != nil {
+		return nil, err
+	}
+	if authorization == nil || authorization.ClientID != client.ClientID || authorization.UsedAt != nil {
+		return nil, invalidGrant
+	}
+	if !now.Before(authorization.ExpiresAt) {
+		return nil, models.NewOAuthError(models.OAuthExpiredToken, "the device code has expired")
+	}
+
+	// Слишком частый опрос замедляем: интервал растет на 5 секунд и запоминается
+	interval := authorization.PollInterval
+	tooFast := authorization.LastPolledAt != nil && now.Sub(*authorization.LastPolledAt) < interval-devicePollLeeway
+	if tooFast {
+		interval += models.DeviceSlowDownDelta
+	}
+	if err := s.deviceRepo.RecordDevicePoll(ctx, deviceCodeHash, now, interval); err != nil {
+		return nil, err
+	}
+	if tooFast {
+		return nil, models.NewOAuthError(models.OAuthSlowDown, "")
+	}
+
+	switch authorization.Status {
+	case models.DeviceAuthorizationPending:
+		return nil, models.NewOAuthError(models.OAuthAuthorizationPending, "")
+	case models.DeviceAuthorizationDenied:
+		return nil, models.NewOAuthError(models.OAuthAccessDenied, "the user denied the request")
+	}
+
+	consumed, err := s.deviceRepo.ConsumeDeviceAuthorization(ctx, deviceCodeHash, now)
+	if err != nil {
+		return nil, err
+	}
+	if !consumed {
+		return nil, invalidGrant
+	}
+
+	grant := &models.OAuthGrant{
+		ClientID:  authorization.ClientID,
+		UserID:    *authorization.UserID,
+		SessionID: authorization.SessionID,
+		Scopes:    authorization.Scopes,
+		AuthTime:  *authorization.DecidedAt,
+	}
+	return s.issueGrantTokens(ctx, client, grant, authorization.Scopes, models.GrantDeviceCode, jkt, now)
+}
+
+func randomUserCode() (string, error) {
+	alphabet := big.NewInt(int64(len(models.UserCodeAlphabet)))
+	code := make([]byte, models.UserCodeLength)
+	for i := range code {
+		n, err := rand.Int(rand.Reader, alphabet)
+		if err != nil {
+			return "", errors.Wrap(err, "failed to generate user code")
+		}
+		code[i] = models.UserCodeAlphabet[n.Int64()]
+	}
+	return string(code), nil
+}
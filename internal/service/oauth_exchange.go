@@ -0,0 +1,165 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+
+	"github.com/pkg/errors"
+)
+
+// Длиннее цепочка делегирования не бывает на практике, а act растет с каждым обменом
+const maxDelegationDepth = 5
+
+// tokenExchange обменивает токен пользователя на токен для конкретной аудитории
+// (RFC 8693). Так шлюз передает сервису не исходный токен пользователя, а токен,
+// который годится только этому сервису и помнит, кто действует от имени пользователя.
+// В какие аудитории клиент может обменивать токены, решают политики token_exchange.
+func (s *OAuthService) tokenExchange(ctx context.Context, req *models.TokenRequest, jkt string) (*models.TokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrant(models.GrantTokenExchange) {
+		return nil, models.NewOAuthError(models.OAuthUnauthorizedClient, "client is not allowed to use token exchange")
+	}
+
+	if req.SubjectToken == "" || !exchangeableTokenType(req.SubjectTokenType) {
+		return nil, models.NewOAuthError(models.OAuthInvalidRequest, "subject_token must be an access token")
+	}
+	if req.ActorToken != "" && !exchangeableTokenType(req.ActorTokenType) {
+		return nil, models.NewOAuthError(models.OAuthInvalidRequest, "actor_token must be an access token")
+	}
+	if req.RequestedTokenType != "" && req.RequestedTokenType != models.TokenTypeAccessToken {
+		return nil, models.NewOAuthError(models.OAuthInvalidRequest, "only access tokens can be requested")
+	}
+	audience := uniqueSorted(req.Audience)
+	if len(audience) == 0 {
+		return nil, models.NewOAuthError(models.OAuthInvalidRequest, "audience or resource is required")
+	}
+
+	// Привязку subject_token к ключу DPoP не проверяем: его предъявляет не владелец,
+	// а аутентифицированный клиент, которому владелец токен передал
+	subject, user, err := s.registr.Authenticate(ctx, req.SubjectToken)
+	if err == models.ErrInvalidToken || err == models.ErrSessionRevoked {
+		return nil, models.NewOAuthError(models.OAuthInvalidGrant, "invalid subject token")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Без actor_token действует сам клиент
+	actorID := client.ClientID
+	if req.ActorToken != "" {
+		if actorID, err = s.actorClient(ctx, req.ActorToken); err != nil {
+			return nil, err
+		}
+	}
+	actor := &models.Actor{Subject: actorID, Act: subject.Actor}
+	if actor.Depth() > maxDelegationDepth {
+		return nil, models.NewOAuthError(models.OAuthInvalidRequest, "delegation chain is too long")
+	}
+
+	// Области только сужаются: токен прямого входа ограничен областями клиента
+	allowed := subject.Scopes
+	if len(allowed) == 0 {
+		allowed = client.Scopes
+	}
+	scopes, err := grantedValues(strings.Fields(req.Scope), allowed)
+	if err != nil {
+		return nil, models.NewOAuthError(models.OAuthInvalidScope, "requested scope exceeds the subject token")
+	}
+
+	now := time.Now()
+	var policies []string
+	for _, aud := range audience {
+		decision, err := s.policies.Authorize(ctx, &models.PolicyInput{
+			Action:   models.PolicyActionTokenExchange,
+			Resource: aud,
+			User:     user,
+			Claims:   subject,
+			Attributes: map[string]string{
+				"client_id":         client.ClientID,
+				"actor":             actorID,
+				"subject_client_id": subject.ClientID,
+				"scope":             strings.Join(scopes, " "),
+			},
+			Time: now,
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to evaluate token exchange policies")
+		}
+		if !decision.Allowed {
+			return nil, models.NewOAuthError(models.OAuthInvalidTarget, "exchange into "+aud+" is not allowed")
+		}
+		policies = append(policies, decision.Policy)
+	}
+
+	accessToken, expiresAt, err := s.tokens.IssueExchangedToken(user, subject, client, audience, scopes, actor, jkt, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditOAuthTokenExchanged,
+		UserID: &user.ID,
+		Email:  user.Email,
+		Metadata: map[string]string{
+			"client_id":  client.ClientID,
+			"actor":      actorID,
+			"chain":      actorChain(actor),
+			"audience":   strings.Join(audience, " "),
+			"scope":      strings.Join(scopes, " "),
+			"session_id": subject.SessionID,
+			"policies":   strings.Join(uniqueSorted(policies), " "),
+		},
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to record audit event")
+	}
+
+	return &models.TokenResponse{
+		AccessToken:     accessToken,
+		TokenType:       tokenType(jkt),
+		ExpiresIn:       expiresAt.Sub(now),
+		Scope:           strings.Join(scopes, " "),
+		IssuedTokenType: models.TokenTypeAccessToken,
+	}, nil
+}
+
+// actorClient проверяет actor_token. Действующей стороной может быть только
+// клиент этого тенанта со своим токеном client_credentials.
+func (s *OAuthService) actorClient(ctx context.Context, token string) (string, error) {
+	invalidActor := models.NewOAuthError(models.OAuthInvalidGrant, "invalid actor token")
+
+	clientID, tenantID, err := s.tokens.ParseClientToken(token)
+	if err != nil {
+		return "", invalidActor
+	}
+	if tenant, ok := models.TenantFromContext(ctx); !ok || tenant.ID != tenantID {
+		return "", invalidActor
+	}
+
+	actor, err := s.clientRepo.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to get oauth client")
+	}
+	if actor == nil || actor.DisabledAt != nil {
+		return "", invalidActor
+	}
+	return actor.ClientID, nil
+}
+
+func exchangeableTokenType(tokenType string) bool {
+	return tokenType == models.TokenTypeAccessToken || tokenType == models.TokenTypeJWT
+}
+
+// actorChain - цепочка для аудита, от текущей стороны к первой: "gateway > web"
+func actorChain(actor *models.Actor) string {
+	var chain []string
+	for ; actor != nil; actor = actor.Act {
+		chain = append(chain, actor.Subject)
+	}
+	return strings.Join(chain, " > ")
+}
@@ -0,0 +1,556 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+	"github.com/DailyPepper/auth-service/pkg/replay"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+// Дольше client_assertion не живет: ограничивает размер кеша использованных jti
+const clientAssertionMaxAge = 5 * time.Minute
+
+type OAuthService struct {
+	clientRepo  repository.OAuthClientRepository
+	oidcRepo    repository.OIDCRepository
+	deviceRepo  repository.DeviceAuthorizationRepository
+	auditRepo   repository.AuditRepository
+	userRepo    repository.UserRepository
+	sessionRepo repository.SessionRepository
+	rbac        RBAC
+	policies    Policies
+	registr     Registr
+	tokens      *TokenService
+	dpop        DPoP
+	audit       Audit
+	assertions  *replay.Cache
+	// Издатель ID-токенов (внешний URL провайдера)
+	issuer        string
+	deviceCodeTTL time.Duration
+	// Допустимые aud в client_assertion: адрес token endpoint и издатель
+	assertionAudiences []string
+}
+
+func NewOAuthService(
+	clientRepo repository.OAuthClientRepository,
+	oidcRepo repository.OIDCRepository,
+	deviceRepo repository.DeviceAuthorizationRepository,
+	auditRepo repository.AuditRepository,
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	rbac RBAC,
+	policies Policies,
+	registr Registr,
+	tokens *TokenService,
+	dpop DPoP,
+	audit Audit,
+	issuer string,
+	deviceCodeTTL time.Duration,
+	assertionAudiences ...string,
+) *OAuthService {
+	return &OAuthService{
+		clientRepo:         clientRepo,
+		oidcRepo:           oidcRepo,
+		deviceRepo:         deviceRepo,
+		auditRepo:          auditRepo,
+		userRepo:           userRepo,
+		sessionRepo:        sessionRepo,
+		rbac:               rbac,
+		policies:           policies,
+		registr:            registr,
+		tokens:             tokens,
+		dpop:               dpop,
+		audit:              audit,
+		assertions:         replay.New(),
+		issuer:             issuer,
+		deviceCodeTTL:      deviceCodeTTL,
+		assertionAudiences: assertionAudiences,
+	}
+}
+
+// RegisterClient создает клиента. Для client_secret секрет генерируется
+// и возвращается один раз; для private_key_jwt секрета нет.
+func (s *OAuthService) RegisterClient(ctx context.Context, client *models.OAuthClient) (*models.OAuthClient, string, error) {
+	client.Name = strings.TrimSpace(client.Name)
+	client.GrantTypes = uniqueSorted(client.GrantTypes)
+	client.Scopes = uniqueSorted(client.Scopes)
+	client.Audiences = uniqueSorted(client.Audiences)
+	if err := client.Validate(); err != nil {
+		return nil, "", err
+	}
+	if client.AuthMethod == models.ClientAuthPrivateKeyJWT {
+		if _, err := parseClientPublicKey(client.PublicKeyPEM); err != nil {
+			return nil, "", models.ErrInvalidOAuthClient
+		}
+	}
+
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, "", errors.Wrap(err, "failed to generate client id")
+	}
+	client.ClientID = hex.EncodeToString(id)
+	client.CreatedAt = time.Now()
+
+	var secret string
+	if client.AuthMethod == models.ClientAuthSecret {
+		var err error
+		if secret, err = randomToken(32); err != nil {
+			return nil, "", err
+		}
+		client.SecretHash = hashToken(secret)
+	}
+
+	if err := s.clientRepo.CreateOAuthClient(ctx, client); err != nil {
+		if err == models.ErrOAuthClientExists {
+			return nil, "", err
+		}
+		return nil, "", errors.Wrap(err, "failed to create oauth client")
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type: models.AuditOAuthClientRegistered,
+		Metadata: map[string]string{
+			"client_id":   client.ClientID,
+			"name":        client.Name,
+			"auth_method": client.AuthMethod,
+			"grant_types": strings.Join(client.GrantTypes, " "),
+			"scopes":      strings.Join(client.Scopes, " "),
+		},
+	}); err != nil {
+		return nil, "", errors.Wrap(err, "failed to record audit event")
+	}
+
+	return client, secret, nil
+}
+
+// Token обрабатывает запрос к token endpoint. Ошибки протокола - *models.OAuthError.
+// С DPoP-доказательством выданные access-токены привязываются к ключу клиента.
+func (s *OAuthService) Token(ctx context.Context, req *models.TokenRequest) (*models.TokenResponse, error) {
+	var jkt string
+	if req.DPoP != nil {
+		var err error
+		if jkt, err = s.dpop.Verify(req.DPoP); err != nil {
+			return nil, models.NewOAuthError(models.OAuthInvalidDPoPProof, "invalid DPoP proof")
+		}
+	}
+
+	switch req.GrantType {
+	case models.GrantClientCredentials:
+		return s.clientCredentials(ctx, req, jkt)
+	case models.GrantAuthorizationCode:
+		return s.authorizationCode(ctx, req, jkt)
+	case models.GrantRefreshToken:
+		return s.refreshToken(ctx, req, jkt)
+	case models.GrantDeviceCode:
+		return s.deviceCode(ctx, req, jkt)
+	case models.GrantTokenExchange:
+		return s.tokenExchange(ctx, req, jkt)
+	case "":
+		return nil, models.NewOAuthError(models.OAuthInvalidRequest, "grant_type is required")
+	default:
+		return nil, models.NewOAuthError(models.OAuthUnsupportedGrantType, "")
+	}
+}
+
+func (s *OAuthService) clientCredentials(ctx context.Context, req *models.TokenRequest, jkt string) (*models.TokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrant(models.GrantClientCredentials) {
+		return nil, models.NewOAuthError(models.OAuthUnauthorizedClient, "client is not allowed to use client_credentials")
+	}
+
+	scopes, err := grantedValues(strings.Fields(req.Scope), client.Scopes)
+	if err != nil {
+		return nil, models.NewOAuthError(models.OAuthInvalidScope, "requested scope is not allowed for this client")
+	}
+	audience, err := grantedValues(req.Audience, client.Audiences)
+	if err != nil {
+		return nil, models.NewOAuthError(models.OAuthInvalidTarget, "requested audience is not allowed for this client")
+	}
+
+	now := time.Now()
+	accessToken, expiresAt, err := s.tokens.IssueClientToken(client, scopes, audience, jkt, now)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type: models.AuditOAuthTokenIssued,
+		Metadata: map[string]string{
+			"client_id":  client.ClientID,
+			"grant_type": models.GrantClientCredentials,
+			"scope":      strings.Join(scopes, " "),
+			"audience":   strings.Join(audience, " "),
+		},
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to record audit event")
+	}
+
+	return &models.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   tokenType(jkt),
+		ExpiresIn:   expiresAt.Sub(now),
+		Scope:       strings.Join(scopes, " "),
+	}, nil
+}
+
+// authorizationCode обменивает код авторизации на токены (RFC 6749, 4.1.3; RFC 7636, 4.6)
+func (s *OAuthService) authorizationCode(ctx context.Context, req *models.TokenRequest, jkt string) (*models.TokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrant(models.GrantAuthorizationCode) {
+		return nil, models.NewOAuthError(models.OAuthUnauthorizedClient, "client is not allowed to use authorization_code")
+	}
+	if req.Code == "" || req.CodeVerifier == "" {
+		return nil, models.NewOAuthError(models.OAuthInvalidRequest, "code and code_verifier are required")
+	}
+
+	now := time.Now()
+	code, err := s.oidcRepo.ConsumeAuthorizationCode(ctx, hashToken(req.Code), now)
+	if err != nil {
+		return nil, err
+	}
+	invalidGrant := models.NewOAuthError(models.OAuthInvalidGrant, "invalid or expired authorization code")
+	if code == nil || code.ClientID != client.ClientID || !verifyCodeChallenge(req.CodeVerifier, code.CodeChallenge) {
+		return nil, invalidGrant
+	}
+	// redirect_uri можно не повторять, только если у клиента единственный адрес
+	if req.RedirectURI != code.RedirectURI && (req.RedirectURI != "" || len(client.RedirectURIs) != 1) {
+		return nil, invalidGrant
+	}
+
+	return s.issueGrantTokens(ctx, client, &code.OAuthGrant, code.Scopes, models.GrantAuthorizationCode, jkt, now)
+}
+
+// refreshToken выдает новые токены по refresh-токену и ротирует его.
+// Повторное предъявление использованного токена означает кражу: завершаем всю сессию.
+// Токен публичного клиента, привязанный к ключу DPoP, обновляется только с доказательством этим ключом.
+func (s *OAuthService) refreshToken(ctx context.Context, req *models.TokenRequest, jkt string) (*models.TokenResponse, error) {
+	client, err := s.authenticateClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsGrant(models.GrantRefreshToken) {
+		return nil, models.NewOAuthError(models.OAuthUnauthorizedClient, "client is not allowed to use refresh_token")
+	}
+	if req.RefreshToken == "" {
+		return nil, models.NewOAuthError(models.OAuthInvalidRequest, "refresh_token is required")
+	}
+
+	now := time.Now()
+	invalidGrant := models.NewOAuthError(models.OAuthInvalidGrant, "invalid or expired refresh token")
+
+	tokenHash := hashToken(req.RefreshToken)
+	token, err := s.oidcRepo.GetRefreshToken(ctx, tokenHash)
+	if err != nil {
+		return nil, err
+	}
+	if token == nil || token.ClientID != client.ClientID || !now.Before(token.ExpiresAt) {
+		return nil, invalidGrant
+	}
+	if token.DPoPThumbprint != "" && token.DPoPThumbprint != jkt {
+		return nil, invalidGrant
+	}
+
+	used := token.UsedAt != nil
+	if !used {
+		marked, err := s.oidcRepo.MarkRefreshTokenUsed(ctx, tokenHash, now)
+		if err != nil {
+			return nil, err
+		}
+		used = !marked
+	}
+	if used {
+		if err := s.revokeReusedRefreshToken(ctx, token, now); err != nil {
+			return nil, err
+		}
+		return nil, invalidGrant
+	}
+
+	// Можно сузить области для access-токена; новый refresh-токен сохраняет исходные
+	scopes, err := grantedValues(strings.Fields(req.Scope), token.Scopes)
+	if err != nil {
+		return nil, models.NewOAuthError(models.OAuthInvalidScope, "requested scope exceeds the original grant")
+	}
+	grant := token.OAuthGrant
+	grant.Scopes = scopes
+	grant.Nonce = ""
+
+	return s.issueGrantTokens(ctx, client, &grant, token.Scopes, models.GrantRefreshToken, jkt, now)
+}
+
+func (s *OAuthService) revokeReusedRefreshToken(ctx context.Context, token *models.OAuthRefreshToken, now time.Time) error {
+	if err := s.sessionRepo.RevokeSession(ctx, token.SessionID, now); err != nil {
+		return errors.Wrap(err, "failed to revoke session")
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditOAuthRefreshReused,
+		UserID: &token.UserID,
+		Metadata: map[string]string{
+			"client_id":  token.ClientID,
+			"session_id": token.SessionID,
+		},
+	}); err != nil {
+		return errors.Wrap(err, "failed to record audit event")
+	}
+	return nil
+}
+
+// issueGrantTokens выпускает access-токен, ID-токен (для openid) и refresh-токен
+// (если клиенту разрешен этот грант). Все они живут, пока жива сессия пользователя.
+// Refresh-токен конфиденциального клиента к ключу DPoP не привязывается: его и так
+// защищает аутентификация клиента (RFC 9449, 5).
+func (s *OAuthService) issueGrantTokens(ctx context.Context, client *models.OAuthClient, grant *models.OAuthGrant, refreshScopes []string, grantType, jkt string, now time.Time) (*models.TokenResponse, error) {
+	invalidGrant := models.NewOAuthError(models.OAuthInvalidGrant, "the session has ended")
+
+	session, err := s.sessionRepo.GetSession(ctx, grant.SessionID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get session")
+	}
+	if session == nil || session.UserID != grant.UserID || !session.IsActive(now) {
+		return nil, invalidGrant
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, grant.UserID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user by ID")
+	}
+	if user == nil || !user.IsActive {
+		return nil, invalidGrant
+	}
+
+	// Роли и разрешения берем актуальные, а не на момент входа
+	access, err := s.rbac.GetUserAccess(ctx, user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user access")
+	}
+
+	accessToken, expiresAt, err := s.tokens.IssueGrantAccessToken(user, access, client, grant, jkt, now)
+	if err != nil {
+		return nil, err
+	}
+
+	resp := &models.TokenResponse{
+		AccessToken: accessToken,
+		TokenType:   tokenType(jkt),
+		ExpiresIn:   expiresAt.Sub(now),
+		Scope:       strings.Join(grant.Scopes, " "),
+	}
+
+	if grant.HasScope(models.ScopeOpenID) {
+		if resp.IDToken, err = s.tokens.IssueIDToken(s.issuer, user, grant, accessToken, now); err != nil {
+			return nil, err
+		}
+	}
+
+	if client.AllowsGrant(models.GrantRefreshToken) {
+		refreshToken, err := randomToken(32)
+		if err != nil {
+			return nil, err
+		}
+
+		stored := &models.OAuthRefreshToken{
+			OAuthGrant: *grant,
+			TokenHash:  hashToken(refreshToken),
+			CreatedAt:  now,
+			ExpiresAt:  session.ExpiresAt,
+		}
+		stored.Scopes = refreshScopes
+		stored.Nonce = ""
+		if client.AuthMethod == models.ClientAuthNone {
+			stored.DPoPThumbprint = jkt
+		}
+		if err := s.oidcRepo.CreateRefreshToken(ctx, stored); err != nil {
+			return nil, err
+		}
+		resp.RefreshToken = refreshToken
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditOAuthTokenIssued,
+		UserID: &user.ID,
+		Email:  user.Email,
+		Metadata: map[string]string{
+			"client_id":  client.ClientID,
+			"grant_type": grantType,
+			"scope":      resp.Scope,
+			"session_id": session.ID,
+		},
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to record audit event")
+	}
+
+	return resp, nil
+}
+
+// tokenType - DPoP для токена, привязанного к ключу, иначе Bearer
+func tokenType(jkt string) string {
+	if jkt != "" {
+		return models.DPoPTokenType
+	}
+	return "Bearer"
+}
+
+// verifyCodeChallenge сверяет code_verifier с S256-challenge (RFC 7636, 4.1 и 4.6)
+func verifyCodeChallenge(verifier, challenge string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	for _, c := range verifier {
+		if !(c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || strings.ContainsRune("-._~", c)) {
+			return false
+		}
+	}
+
+	sum := sha256.Sum256([]byte(verifier))
+	computed := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(computed), []byte(challenge)) == 1
+}
+
+// authenticateClient проверяет секрет или client_assertion (RFC 7523).
+// Любая ошибка выглядит для клиента одинаково - invalid_client.
+func (s *OAuthService) authenticateClient(ctx context.Context, req *models.TokenRequest) (*models.OAuthClient, error) {
+	invalidClient := models.NewOAuthError(models.OAuthInvalidClient, "client authentication failed")
+
+	clientID := req.ClientID
+	var assertion *jwt.Token
+	if req.ClientAssertion != "" {
+		if req.ClientAssertionType != models.ClientAssertionTypeJWTBearer {
+			return nil, models.NewOAuthError(models.OAuthInvalidRequest, "unsupported client_assertion_type")
+		}
+
+		// Клиента узнаем из непроверенного iss, подпись проверяем его ключом ниже
+		var claims jwt.RegisteredClaims
+		unverified, _, err := jwt.NewParser().ParseUnverified(req.ClientAssertion, &claims)
+		if err != nil || claims.Issuer == "" || (clientID != "" && clientID != claims.Issuer) {
+			return nil, invalidClient
+		}
+		clientID = claims.Issuer
+		assertion = unverified
+	}
+	if clientID == "" {
+		return nil, invalidClient
+	}
+
+	client, err := s.clientRepo.GetOAuthClient(ctx, clientID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get oauth client")
+	}
+	if client == nil || client.DisabledAt != nil {
+		return nil, invalidClient
+	}
+
+	switch client.AuthMethod {
+	case models.ClientAuthSecret:
+		if assertion != nil || req.ClientSecret == "" ||
+			subtle.ConstantTimeCompare([]byte(hashToken(req.ClientSecret)), []byte(client.SecretHash)) != 1 {
+			return nil, invalidClient
+		}
+	case models.ClientAuthPrivateKeyJWT:
+		if assertion == nil || req.ClientSecret != "" || !s.verifyAssertion(client, req.ClientAssertion) {
+			return nil, invalidClient
+		}
+	case models.ClientAuthNone:
+		// Публичный клиент только называет себя; код защищен PKCE, device_code - подтверждением пользователя
+		if assertion != nil || req.ClientSecret != "" {
+			return nil, invalidClient
+		}
+	default:
+		return nil, invalidClient
+	}
+
+	return client, nil
+}
+
+// verifyAssertion: подпись ключом клиента, iss = sub = client_id, aud - этот сервер,
+// короткий срок жизни и одноразовый jti
+func (s *OAuthService) verifyAssertion(client *models.OAuthClient, assertion string) bool {
+	key, err := parseClientPublicKey(client.PublicKeyPEM)
+	if err != nil {
+		return false
+	}
+
+	var claims jwt.RegisteredClaims
+	_, err = jwt.ParseWithClaims(assertion, &claims, func(*jwt.Token) (interface{}, error) {
+		return key, nil
+	},
+		jwt.WithValidMethods([]string{"RS256", "PS256", "ES256", "EdDSA"}),
+		jwt.WithIssuer(client.ClientID),
+		jwt.WithSubject(client.ClientID),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil || claims.ID == "" {
+		return false
+	}
+
+	if !audienceMatches(claims.Audience, s.assertionAudiences) {
+		return false
+	}
+
+	expiresAt := claims.ExpiresAt.Time
+	if time.Until(expiresAt) > clientAssertionMaxAge {
+		return false
+	}
+
+	return s.assertions.Use(client.ClientID+"/"+claims.ID, expiresAt)
+}
+
+func audienceMatches(audience jwt.ClaimStrings, accepted []string) bool {
+	for _, aud := range audience {
+		for _, want := range accepted {
+			if want != "" && aud == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// parseClientPublicKey принимает PKIX-ключ RSA, ECDSA или Ed25519 в PEM
+func parseClientPublicKey(data string) (interface{}, error) {
+	block, _ := pem.Decode([]byte(data))
+	if block == nil {
+		return nil, errors.New("client public key is not PEM")
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
+
+// grantedValues проверяет, что запрошенное входит в разрешенное; пустой запрос - все разрешенное
+func grantedValues(requested, allowed []string) ([]string, error) {
+	if len(requested) == 0 {
+		return allowed, nil
+	}
+
+	requested = uniqueSorted(requested)
+	for _, value := range requested {
+		found := false
+		for _, candidate := range allowed {
+			if candidate == value {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return nil, errors.Errorf("%q is not allowed", value)
+		}
+	}
+	return requested, nil
+}
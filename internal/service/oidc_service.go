@@ -0,0 +1,433 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+	"github.com/DailyPepper/auth-service/pkg/signing"
+
+	"github.com/pkg/errors"
+)
+
+// OIDCService - браузерная часть провайдера OpenID Connect: вход, согласие,
+// выдача кодов авторизации, userinfo и выход. Коды обменивает OAuthService.
+type OIDCService struct {
+	clientRepo  repository.OAuthClientRepository
+	oidcRepo    repository.OIDCRepository
+	sessionRepo repository.SessionRepository
+	registr     Registr
+	tokens      *TokenService
+	audit       Audit
+	issuer      string
+	codeTTL     time.Duration
+}
+
+func NewOIDCService(
+	clientRepo repository.OAuthClientRepository,
+	oidcRepo repository.OIDCRepository,
+	sessionRepo repository.SessionRepository,
+	registr Registr,
+	tokens *TokenService,
+	audit Audit,
+	issuer string,
+	codeTTL time.Duration,
+) *OIDCService {
+	return &OIDCService{
+		clientRepo:  clientRepo,
+		oidcRepo:    oidcRepo,
+		sessionRepo: sessionRepo,
+		registr:     registr,
+		tokens:      tokens,
+		audit:       audit,
+		issuer:      issuer,
+		codeTTL:     codeTTL,
+	}
+}
+
+func (s *OIDCService) Issuer() string {
+	return s.issuer
+}
+
+func (s *OIDCService) JWKS() []signing.JWK {
+	return s.tokens.JWKS()
+}
+
+// Login проверяет пароль тем же путем, что и RPC Login (риск, CAPTCHA, политики),
+// и возвращает сессию вместе со значением браузерной cookie
+func (s *OIDCService) Login(ctx context.Context, email, password string, client models.ClientInfo) (*models.Session, string, error) {
+	resp, err := s.registr.Login(ctx, &models.LoginRequest{
+		Email:    email,
+		Password: password,
+		Client:   client,
+	})
+	if err != nil {
+		return nil, "", err
+	}
+
+	return s.StartSession(ctx, resp)
+}
+
+// StartSession возвращает сессию успешного входа вместе со значением браузерной cookie
+func (s *OIDCService) StartSession(ctx context.Context, resp *models.LoginResponse) (*models.Session, string, error) {
+	session, err := s.sessionRepo.GetSession(ctx, resp.SessionID)
+	if err != nil {
+		return nil, "", errors.Wrap(err, "failed to get session")
+	}
+	if session == nil {
+		return nil, "", models.ErrSessionNotFound
+	}
+
+	// Refresh-токен сессии служит секретом cookie: по ID сессии ее не подделать
+	return session, resp.SessionID + "." + resp.RefreshToken, nil
+}
+
+// BrowserSession возвращает активную сессию по значению cookie или nil
+func (s *OIDCService) BrowserSession(ctx context.Context, cookie string) (*models.Session, error) {
+	sessionID, secret, ok := strings.Cut(cookie, ".")
+	if !ok || sessionID == "" || secret == "" {
+		return nil, nil
+	}
+
+	session, err := s.sessionRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get session")
+	}
+	if session == nil || !session.IsActive(time.Now()) ||
+		subtle.ConstantTimeCompare([]byte(hashToken(secret)), []byte(session.RefreshTokenHash)) != 1 {
+		return nil, nil
+	}
+	return session, nil
+}
+
+// Authorize обрабатывает запрос к authorization endpoint. Ошибка возвращается, только
+// если нельзя доверять redirect_uri; остальные ошибки уходят клиенту редиректом.
+func (s *OIDCService) Authorize(ctx context.Context, req *models.AuthorizationRequest, session *models.Session) (*models.AuthorizationResult, error) {
+	client, err := s.authorizationClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.AuthorizationResult{Client: client, Request: req}
+	if oauthErr := validateAuthorizationRequest(client, req); oauthErr != nil {
+		result.RedirectURL = s.authorizationRedirect(req, errorParams(oauthErr))
+		return result, nil
+	}
+
+	if !s.sessionSatisfies(req, session, time.Now()) {
+		if req.HasPrompt(models.PromptNone) {
+			result.RedirectURL = s.authorizationRedirect(req, errorParams(models.NewOAuthError(models.OAuthLoginRequired, "")))
+			return result, nil
+		}
+		result.NeedLogin = true
+		return result, nil
+	}
+
+	consent, err := s.oidcRepo.GetConsent(ctx, session.UserID, client.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if consent == nil || !consent.Covers(req.Scopes) || req.HasPrompt(models.PromptConsent) {
+		if req.HasPrompt(models.PromptNone) {
+			result.RedirectURL = s.authorizationRedirect(req, errorParams(models.NewOAuthError(models.OAuthConsentRequired, "")))
+			return result, nil
+		}
+		result.NeedConsent = true
+		return result, nil
+	}
+
+	result.RedirectURL, err = s.issueCode(ctx, req, session)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Consent применяет решение пользователя на странице согласия
+func (s *OIDCService) Consent(ctx context.Context, req *models.AuthorizationRequest, session *models.Session, approved bool) (*models.AuthorizationResult, error) {
+	client, err := s.authorizationClient(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &models.AuthorizationResult{Client: client, Request: req}
+	if oauthErr := validateAuthorizationRequest(client, req); oauthErr != nil {
+		result.RedirectURL = s.authorizationRedirect(req, errorParams(oauthErr))
+		return result, nil
+	}
+	if session == nil {
+		result.NeedLogin = true
+		return result, nil
+	}
+
+	if !approved {
+		result.RedirectURL = s.authorizationRedirect(req, errorParams(models.NewOAuthError(models.OAuthAccessDenied, "the user denied the request")))
+		return result, nil
+	}
+
+	// Новое согласие дополняет прежнее, а не заменяет его
+	consent, err := s.oidcRepo.GetConsent(ctx, session.UserID, client.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	scopes := req.Scopes
+	if consent != nil {
+		scopes = append(append([]string(nil), consent.Scopes...), req.Scopes...)
+	}
+
+	consent = &models.OAuthConsent{
+		UserID:    session.UserID,
+		ClientID:  client.ClientID,
+		Scopes:    uniqueSorted(scopes),
+		GrantedAt: time.Now(),
+	}
+	if err := s.oidcRepo.SaveConsent(ctx, consent); err != nil {
+		return nil, err
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditOAuthConsentGranted,
+		UserID: &session.UserID,
+		Metadata: map[string]string{
+			"client_id": client.ClientID,
+			"scope":     strings.Join(req.Scopes, " "),
+		},
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to record audit event")
+	}
+
+	result.RedirectURL, err = s.issueCode(ctx, req, session)
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// UserInfo возвращает утверждения о владельце access-токена, выданного с областью openid
+func (s *OIDCService) UserInfo(ctx context.Context, accessToken string, proof *models.DPoPRequest) (map[string]interface{}, error) {
+	claims, user, err := s.registr.AuthenticateRequest(ctx, accessToken, proof)
+	switch err {
+	case nil:
+	case models.ErrInvalidToken, models.ErrSessionRevoked:
+		return nil, models.NewOAuthError(models.OAuthInvalidToken, "invalid or expired access token")
+	case models.ErrInvalidDPoPProof:
+		return nil, models.NewOAuthError(models.OAuthInvalidDPoPProof, "invalid DPoP proof")
+	default:
+		return nil, err
+	}
+
+	if claims.ClientID == "" || !containsScope(claims.Scopes, models.ScopeOpenID) {
+		return nil, models.NewOAuthError(models.OAuthInsufficientScope, "the access token was not issued with the openid scope")
+	}
+
+	info := models.StandardClaims(user, claims.Scopes)
+	info["sub"] = strconv.FormatInt(user.ID, 10)
+	return info, nil
+}
+
+// EndSession проверяет запрос на выход (OpenID Connect RP-Initiated Logout 1.0)
+func (s *OIDCService) EndSession(ctx context.Context, req *models.EndSessionRequest) (*models.EndSession, error) {
+	result := &models.EndSession{}
+
+	clientID := req.ClientID
+	if req.IDTokenHint != "" {
+		hintClientID, sessionID, err := s.tokens.ParseIDTokenHint(s.issuer, req.IDTokenHint)
+		if err != nil {
+			return nil, models.NewOAuthError(models.OAuthInvalidRequest, "invalid id_token_hint")
+		}
+		if clientID != "" && clientID != hintClientID {
+			return nil, models.NewOAuthError(models.OAuthInvalidRequest, "client_id does not match id_token_hint")
+		}
+		clientID = hintClientID
+		result.HintSessionID = sessionID
+	}
+
+	if clientID != "" {
+		client, err := s.clientRepo.GetOAuthClient(ctx, clientID)
+		if err != nil {
+			return nil, err
+		}
+		if client == nil || client.DisabledAt != nil {
+			return nil, models.NewOAuthError(models.OAuthInvalidRequest, "unknown client")
+		}
+		result.Client = client
+	}
+
+	if req.PostLogoutRedirectURI != "" {
+		if result.Client == nil || !result.Client.AllowsPostLogoutRedirectURI(req.PostLogoutRedirectURI) {
+			return nil, models.NewOAuthError(models.OAuthInvalidRequest, "post_logout_redirect_uri is not registered for the client")
+		}
+
+		params := url.Values{}
+		if req.State != "" {
+			params.Set("state", req.State)
+		}
+		result.RedirectURL = appendQuery(req.PostLogoutRedirectURI, params)
+	}
+
+	return result, nil
+}
+
+// Logout завершает браузерную сессию, а с ней все выданные по ней токены клиентов
+func (s *OIDCService) Logout(ctx context.Context, session *models.Session) error {
+	if err := s.sessionRepo.RevokeSession(ctx, session.ID, time.Now()); err != nil {
+		return errors.Wrap(err, "failed to revoke session")
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditSessionEnded,
+		UserID: &session.UserID,
+		Metadata: map[string]string{
+			"session_id": session.ID,
+			"reason":     "logout",
+		},
+	}); err != nil {
+		return errors.Wrap(err, "failed to record audit event")
+	}
+	return nil
+}
+
+// authorizationClient находит клиента и проверяет redirect_uri. Пока адрес
+// не проверен, ошибку нельзя отправлять по нему (RFC 6749, 4.1.2.1).
+func (s *OIDCService) authorizationClient(ctx context.Context, req *models.AuthorizationRequest) (*models.OAuthClient, error) {
+	if req.ClientID == "" {
+		return nil, models.NewOAuthError(models.OAuthInvalidRequest, "client_id is required")
+	}
+
+	client, err := s.clientRepo.GetOAuthClient(ctx, req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if client == nil || client.DisabledAt != nil {
+		return nil, models.NewOAuthError(models.OAuthInvalidRequest, "unknown client")
+	}
+
+	if req.RedirectURI == "" && len(client.RedirectURIs) == 1 {
+		req.RedirectURI = client.RedirectURIs[0]
+	}
+	if !client.AllowsRedirectURI(req.RedirectURI) {
+		return nil, models.NewOAuthError(models.OAuthInvalidRequest, "redirect_uri is not registered for the client")
+	}
+
+	return client, nil
+}
+
+func validateAuthorizationRequest(client *models.OAuthClient, req *models.AuthorizationRequest) *models.OAuthError {
+	if req.ResponseType != "code" {
+		return models.NewOAuthError(models.OAuthUnsupportedResponseType, "only response_type=code is supported")
+	}
+	if !client.AllowsGrant(models.GrantAuthorizationCode) {
+		return models.NewOAuthError(models.OAuthUnauthorizedClient, "client is not allowed to use authorization_code")
+	}
+	if len(req.Scopes) == 0 {
+		return models.NewOAuthError(models.OAuthInvalidScope, "scope is required")
+	}
+	if _, err := grantedValues(req.Scopes, client.Scopes); err != nil {
+		return models.NewOAuthError(models.OAuthInvalidScope, "requested scope is not allowed for this client")
+	}
+
+	// PKCE обязателен для всех клиентов (OAuth 2.1)
+	if req.CodeChallengeMethod != models.CodeChallengeS256 {
+		return models.NewOAuthError(models.OAuthInvalidRequest, "code_challenge_method must be S256")
+	}
+	if len(req.CodeChallenge) != 43 {
+		return models.NewOAuthError(models.OAuthInvalidRequest, "code_challenge must be a base64url SHA-256 hash")
+	}
+
+	if req.HasPrompt(models.PromptNone) && len(req.Prompt) > 1 {
+		return models.NewOAuthError(models.OAuthInvalidRequest, "prompt=none cannot be combined with other values")
+	}
+	return nil
+}
+
+// sessionSatisfies: есть активная сессия, и она удовлетворяет prompt=login и max_age
+func (s *OIDCService) sessionSatisfies(req *models.AuthorizationRequest, session *models.Session, now time.Time) bool {
+	if session == nil || !session.IsActive(now) {
+		return false
+	}
+	if req.LoginCompleted {
+		return true
+	}
+	if req.HasPrompt(models.PromptLogin) {
+		return false
+	}
+	if req.MaxAge != nil && now.Sub(session.CreatedAt) > *req.MaxAge {
+		return false
+	}
+	return true
+}
+
+func (s *OIDCService) issueCode(ctx context.Context, req *models.AuthorizationRequest, session *models.Session) (string, error) {
+	code, err := randomToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	stored := &models.AuthorizationCode{
+		OAuthGrant: models.OAuthGrant{
+			ClientID:  req.ClientID,
+			UserID:    session.UserID,
+			SessionID: session.ID,
+			Scopes:    uniqueSorted(req.Scopes),
+			AuthTime:  session.CreatedAt,
+			Nonce:     req.Nonce,
+		},
+		CodeHash:      hashToken(code),
+		RedirectURI:   req.RedirectURI,
+		CodeChallenge: req.CodeChallenge,
+		CreatedAt:     now,
+		ExpiresAt:     now.Add(s.codeTTL),
+	}
+	if err := s.oidcRepo.CreateAuthorizationCode(ctx, stored); err != nil {
+		return "", err
+	}
+
+	return s.authorizationRedirect(req, url.Values{"code": {code}}), nil
+}
+
+// authorizationRedirect добавляет к redirect_uri параметры ответа, state и iss (RFC 9207)
+func (s *OIDCService) authorizationRedirect(req *models.AuthorizationRequest, params url.Values) string {
+	if req.State != "" {
+		params.Set("state", req.State)
+	}
+	params.Set("iss", s.issuer)
+	return appendQuery(req.RedirectURI, params)
+}
+
+func errorParams(oauthErr *models.OAuthError) url.Values {
+	params := url.Values{"error": {oauthErr.Code}}
+	if oauthErr.Description != "" {
+		params.Set("error_description", oauthErr.Description)
+	}
+	return params
+}
+
+// appendQuery дописывает параметры к адресу, сохраняя его собственный query
+func appendQuery(uri string, params url.Values) string {
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return uri
+	}
+
+	query := parsed.Query()
+	for key, values := range params {
+		query[key] = values
+	}
+	parsed.RawQuery = query.Encode()
+	return parsed.String()
+}
+
+func containsScope(scopes []string, scope string) bool {
+	for _, candidate := range scopes {
+		if candidate == scope {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,360 @@
+package service
+
+import (
+	"context"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+
+	"github.com/pkg/errors"
+)
+
+type OrganizationService struct {
+	orgRepo       repository.OrganizationRepository
+	userRepo      repository.UserRepository
+	registr       Registr
+	notifier      *NotificationService
+	audit         Audit
+	invitationTTL time.Duration
+}
+
+func NewOrganizationService(
+	orgRepo repository.OrganizationRepository,
+	userRepo repository.UserRepository,
+	registr Registr,
+	notifier *NotificationService,
+	audit Audit,
+	invitationTTL time.Duration,
+) *OrganizationService {
+	return &OrganizationService{
+		orgRepo:       orgRepo,
+		userRepo:      userRepo,
+		registr:       registr,
+		notifier:      notifier,
+		audit:         audit,
+		invitationTTL: invitationTTL,
+	}
+}
+
+// CreateOrganization создает организацию; создатель становится ее владельцем
+func (s *OrganizationService) CreateOrganization(ctx context.Context, actorID int64, org *models.Organization) (*models.Organization, error) {
+	if err := org.Validate(); err != nil {
+		return nil, err
+	}
+	org.CreatedBy = &actorID
+	org.CreatedAt = time.Now()
+
+	if err := s.orgRepo.CreateOrganization(ctx, org, actorID); err != nil {
+		return nil, errors.Wrap(err, "failed to create organization")
+	}
+
+	if err := s.recordAudit(ctx, models.AuditOrganizationCreated, actorID, org.ID, map[string]string{
+		"name": org.Name,
+	}); err != nil {
+		return nil, err
+	}
+
+	return org, nil
+}
+
+// InviteMember отправляет одноразовое приглашение на email.
+// Пригласить владельца может только владелец.
+func (s *OrganizationService) InviteMember(ctx context.Context, actorID, orgID int64, email string, role models.OrganizationRole) (*models.Invitation, error) {
+	// Только голый адрес: без отображаемого имени и переводов строк
+	email = strings.TrimSpace(email)
+	if address, err := mail.ParseAddress(email); err != nil || address.Address != email {
+		return nil, models.ErrInvalidInvitation
+	}
+	if !role.Valid() {
+		return nil, models.ErrInvalidOrganizationRole
+	}
+
+	actor, err := s.requireManager(ctx, orgID, actorID)
+	if err != nil {
+		return nil, err
+	}
+	if role == models.OrganizationOwner && actor.Role != models.OrganizationOwner {
+		return nil, models.ErrPermissionDenied
+	}
+
+	org, err := s.orgRepo.GetOrganization(ctx, orgID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get organization")
+	}
+	if org == nil {
+		return nil, models.ErrOrganizationNotFound
+	}
+
+	inviter, err := s.userRepo.GetUserByID(ctx, actorID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get inviter")
+	}
+	if inviter == nil {
+		return nil, models.ErrUserNotFound
+	}
+
+	token, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	invitation := &models.Invitation{
+		OrganizationID: orgID,
+		Email:          email,
+		Role:           role,
+		InvitedBy:      &actorID,
+		CreatedAt:      now,
+		ExpiresAt:      now.Add(s.invitationTTL),
+	}
+
+	if err := s.orgRepo.CreateInvitation(ctx, invitation, hashToken(token)); err != nil {
+		if err == models.ErrOrganizationNotFound {
+			return nil, err
+		}
+		return nil, errors.Wrap(err, "failed to create invitation")
+	}
+
+	if err := s.recordAudit(ctx, models.AuditOrganizationInvited, actorID, orgID, map[string]string{
+		"invitation_id": strconv.FormatInt(invitation.ID, 10),
+		"email":         email,
+		"role":          string(role),
+	}); err != nil {
+		return nil, err
+	}
+
+	var tenantSlug string
+	if tenant, ok := models.TenantFromContext(ctx); ok {
+		tenantSlug = tenant.Slug
+	}
+	s.notifier.NotifyInvitation(invitation, org, inviter, tenantSlug, token)
+
+	return invitation, nil
+}
+
+// AcceptInvitation добавляет в организацию вошедшего пользователя
+// или регистрирует новый аккаунт на email приглашения
+func (s *OrganizationService) AcceptInvitation(ctx context.Context, req *models.AcceptInvitationRequest) (*models.OrganizationMembership, error) {
+	now := time.Now()
+
+	invitation, err := s.orgRepo.GetInvitation(ctx, hashToken(req.Token), now)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get invitation")
+	}
+	if invitation == nil {
+		return nil, models.ErrInvalidInvitation
+	}
+
+	var userID int64
+	switch {
+	case req.UserID != nil:
+		userID = *req.UserID
+	case req.Registr != nil:
+		if len(req.Registr.Password) < 8 {
+			return nil, models.ErrPasswordTooWeak
+		}
+		// Регистрируем строго на адрес, по которому пришло приглашение
+		req.Registr.Email = invitation.Email
+		user, err := s.registr.RegisterInvited(ctx, req.Registr)
+		if err != nil {
+			return nil, err
+		}
+		userID = user.ID
+	default:
+		return nil, models.ErrInvalidInvitation
+	}
+
+	existing, err := s.orgRepo.GetOrganizationMembership(ctx, invitation.OrganizationID, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get organization membership")
+	}
+	if existing != nil {
+		return nil, models.ErrAlreadyOrganizationMember
+	}
+
+	// Приглашение одноразовое: из двух одновременных принятий проходит одно
+	consumed, err := s.orgRepo.ConsumeInvitation(ctx, invitation.ID, userID, now)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to consume invitation")
+	}
+	if !consumed {
+		return nil, models.ErrInvalidInvitation
+	}
+
+	membership := &models.OrganizationMembership{
+		OrganizationID: invitation.OrganizationID,
+		UserID:         userID,
+		Role:           invitation.Role,
+		JoinedAt:       now,
+	}
+
+	added, err := s.orgRepo.AddOrganizationMember(ctx, membership)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to add organization member")
+	}
+	if !added {
+		return nil, models.ErrAlreadyOrganizationMember
+	}
+
+	if err := s.recordAudit(ctx, models.AuditOrganizationJoined, userID, invitation.OrganizationID, map[string]string{
+		"invitation_id": strconv.FormatInt(invitation.ID, 10),
+		"role":          string(invitation.Role),
+	}); err != nil {
+		return nil, err
+	}
+
+	return membership, nil
+}
+
+// RemoveMember удаляет участника. Выйти из организации может любой участник,
+// удалить другого - владелец или администратор (владельца - только владелец).
+func (s *OrganizationService) RemoveMember(ctx context.Context, actorID, orgID, userID int64) error {
+	target, err := s.authorizeMemberChange(ctx, actorID, orgID, userID, actorID == userID)
+	if err != nil {
+		return err
+	}
+
+	if target.Role == models.OrganizationOwner {
+		if err := s.requireAnotherOwner(ctx, orgID); err != nil {
+			return err
+		}
+	}
+
+	removed, err := s.orgRepo.RemoveOrganizationMember(ctx, orgID, userID)
+	if err != nil {
+		return errors.Wrap(err, "failed to remove organization member")
+	}
+	if !removed {
+		return models.ErrNotOrganizationMember
+	}
+
+	return s.recordAudit(ctx, models.AuditOrganizationRemoved, actorID, orgID, map[string]string{
+		"target_user_id": strconv.FormatInt(userID, 10),
+		"role":           string(target.Role),
+	})
+}
+
+// ChangeMemberRole меняет роль участника; назначать и снимать владельцев может только владелец
+func (s *OrganizationService) ChangeMemberRole(ctx context.Context, actorID, orgID, userID int64, role models.OrganizationRole) error {
+	if !role.Valid() {
+		return models.ErrInvalidOrganizationRole
+	}
+
+	target, err := s.authorizeMemberChange(ctx, actorID, orgID, userID, false)
+	if err != nil {
+		return err
+	}
+	if target.Role == role {
+		return nil
+	}
+
+	if role == models.OrganizationOwner {
+		actor, err := s.orgRepo.GetOrganizationMembership(ctx, orgID, actorID)
+		if err != nil {
+			return errors.Wrap(err, "failed to get organization membership")
+		}
+		if actor == nil || actor.Role != models.OrganizationOwner {
+			return models.ErrPermissionDenied
+		}
+	}
+	if target.Role == models.OrganizationOwner {
+		if err := s.requireAnotherOwner(ctx, orgID); err != nil {
+			return err
+		}
+	}
+
+	updated, err := s.orgRepo.UpdateOrganizationMemberRole(ctx, orgID, userID, role)
+	if err != nil {
+		return errors.Wrap(err, "failed to update organization member role")
+	}
+	if !updated {
+		return models.ErrNotOrganizationMember
+	}
+
+	return s.recordAudit(ctx, models.AuditOrganizationRoleChanged, actorID, orgID, map[string]string{
+		"target_user_id": strconv.FormatInt(userID, 10),
+		"previous_role":  string(target.Role),
+		"role":           string(role),
+	})
+}
+
+// authorizeMemberChange проверяет права на изменение участника и возвращает его членство
+func (s *OrganizationService) authorizeMemberChange(ctx context.Context, actorID, orgID, userID int64, self bool) (*models.OrganizationMembership, error) {
+	var actor *models.OrganizationMembership
+	var err error
+	if self {
+		actor, err = s.requireMember(ctx, orgID, actorID)
+	} else {
+		actor, err = s.requireManager(ctx, orgID, actorID)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	target := actor
+	if !self {
+		target, err = s.orgRepo.GetOrganizationMembership(ctx, orgID, userID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get organization membership")
+		}
+		if target == nil {
+			return nil, models.ErrNotOrganizationMember
+		}
+		if target.Role == models.OrganizationOwner && actor.Role != models.OrganizationOwner {
+			return nil, models.ErrPermissionDenied
+		}
+	}
+
+	return target, nil
+}
+
+func (s *OrganizationService) requireMember(ctx context.Context, orgID, userID int64) (*models.OrganizationMembership, error) {
+	membership, err := s.orgRepo.GetOrganizationMembership(ctx, orgID, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get organization membership")
+	}
+	if membership == nil {
+		return nil, models.ErrNotOrganizationMember
+	}
+	return membership, nil
+}
+
+func (s *OrganizationService) requireManager(ctx context.Context, orgID, userID int64) (*models.OrganizationMembership, error) {
+	membership, err := s.requireMember(ctx, orgID, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !membership.Role.CanManageMembers() {
+		return nil, models.ErrPermissionDenied
+	}
+	return membership, nil
+}
+
+// Организация без владельца неуправляема
+func (s *OrganizationService) requireAnotherOwner(ctx context.Context, orgID int64) error {
+	owners, err := s.orgRepo.CountOrganizationOwners(ctx, orgID)
+	if err != nil {
+		return errors.Wrap(err, "failed to count organization owners")
+	}
+	if owners <= 1 {
+		return models.ErrLastOrganizationOwner
+	}
+	return nil
+}
+
+func (s *OrganizationService) recordAudit(ctx context.Context, eventType models.AuditEventType, actorID, orgID int64, metadata map[string]string) error {
+	metadata["organization_id"] = strconv.FormatInt(orgID, 10)
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:     eventType,
+		UserID:   &actorID,
+		Metadata: metadata,
+	}); err != nil {
+		return errors.Wrap(err, "failed to record audit event")
+	}
+	return nil
+}
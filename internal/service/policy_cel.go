@@ -0,0 +1,188 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+)
+
+// Ограничение стоимости вычисления одного выражения
+const policyCostLimit = 100_000
+
+// policyEngine компилирует CEL-выражения политик и кеширует программы.
+//
+// Переменные выражения:
+//
+//	user    - id, email, roles, permissions, is_verified, created_at
+//	claims  - sub, sid, email, roles, permissions, iat, exp, client_id, scopes и act
+//	          (цепочка делегирования, {sub, act}); пусто при входе
+//	request - action, resource, ip, user_agent, attributes
+//	now     - время запроса (timestamp)
+//
+// Функция ipInRange(ip, cidr) проверяет вхождение адреса в подсеть, например:
+//
+//	"contractor" in user.roles &&
+//	  !(ipInRange(request.ip, "10.0.0.0/8") && now.getDayOfWeek("Europe/Moscow") in [1, 2, 3, 4, 5])
+type policyEngine struct {
+	env *cel.Env
+
+	mu       sync.Mutex
+	programs map[int64]compiledPolicy
+}
+
+type compiledPolicy struct {
+	updatedAt time.Time
+	program   cel.Program
+}
+
+func newPolicyEngine() (*policyEngine, error) {
+	env, err := cel.NewEnv(
+		cel.Variable("user", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("claims", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("request", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Variable("now", cel.TimestampType),
+		cel.Function("ipInRange",
+			cel.Overload("ipInRange_string_string",
+				[]*cel.Type{cel.StringType, cel.StringType}, cel.BoolType,
+				cel.BinaryBinding(ipInRange),
+			),
+		),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &policyEngine{
+		env:      env,
+		programs: make(map[int64]compiledPolicy),
+	}, nil
+}
+
+// compile проверяет выражение: ошибки синтаксиса, типов и не-bool результат
+func (e *policyEngine) compile(expression string) (cel.Program, error) {
+	ast, issues := e.env.Compile(expression)
+	if issues != nil && issues.Err() != nil {
+		return nil, &models.PolicyCompileError{Issues: issues.Err().Error()}
+	}
+	// Поля user/claims/request динамические: user.is_verified имеет тип dyn
+	if ast.OutputType() != cel.BoolType && ast.OutputType() != cel.DynType {
+		return nil, &models.PolicyCompileError{Issues: "expression must return bool, got " + ast.OutputType().String()}
+	}
+
+	program, err := e.env.Program(ast, cel.CostLimit(policyCostLimit))
+	if err != nil {
+		return nil, &models.PolicyCompileError{Issues: err.Error()}
+	}
+	return program, nil
+}
+
+// program возвращает скомпилированную программу, перекомпилируя измененные политики
+func (e *policyEngine) program(policy *models.Policy) (cel.Program, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if cached, ok := e.programs[policy.ID]; ok && cached.updatedAt.Equal(policy.UpdatedAt) {
+		return cached.program, nil
+	}
+
+	program, err := e.compile(policy.Expression)
+	if err != nil {
+		return nil, err
+	}
+	e.programs[policy.ID] = compiledPolicy{updatedAt: policy.UpdatedAt, program: program}
+	return program, nil
+}
+
+// eval возвращает true, если выражение политики истинно
+func (e *policyEngine) eval(policy *models.Policy, activation map[string]interface{}) (bool, error) {
+	program, err := e.program(policy)
+	if err != nil {
+		return false, err
+	}
+
+	out, _, err := program.Eval(activation)
+	if err != nil {
+		return false, err
+	}
+
+	matched, ok := out.Value().(bool)
+	if !ok {
+		return false, fmt.Errorf("expression returned %s instead of bool", out.Type().TypeName())
+	}
+	return matched, nil
+}
+
+func policyActivation(input *models.PolicyInput) map[string]interface{} {
+	user := map[string]interface{}{}
+	if input.User != nil {
+		user["id"] = input.User.ID
+		user["email"] = input.User.Email
+		user["is_verified"] = input.User.IsVerified
+		user["created_at"] = input.User.CreatedAt
+	}
+	user["roles"] = []string{}
+	user["permissions"] = []string{}
+	if input.Access != nil {
+		user["roles"] = input.Access.Roles
+		user["permissions"] = input.Access.Permissions
+	}
+
+	claims := map[string]interface{}{}
+	if input.Claims != nil {
+		claims["sub"] = strconv.FormatInt(input.Claims.UserID, 10)
+		claims["sid"] = input.Claims.SessionID
+		claims["email"] = input.Claims.Email
+		claims["roles"] = input.Claims.Roles
+		claims["permissions"] = input.Claims.Permissions
+		claims["iat"] = input.Claims.IssuedAt
+		claims["exp"] = input.Claims.ExpiresAt
+		claims["client_id"] = input.Claims.ClientID
+		claims["scopes"] = input.Claims.Scopes
+		if input.Claims.Actor != nil {
+			claims["act"] = actorActivation(input.Claims.Actor)
+		}
+	}
+
+	attributes := input.Attributes
+	if attributes == nil {
+		attributes = map[string]string{}
+	}
+
+	return map[string]interface{}{
+		"user":   user,
+		"claims": claims,
+		"request": map[string]interface{}{
+			"action":     input.Action,
+			"resource":   input.Resource,
+			"ip":         input.IP,
+			"user_agent": input.UserAgent,
+			"attributes": attributes,
+		},
+		"now": input.Time,
+	}
+}
+
+func actorActivation(actor *models.Actor) map[string]interface{} {
+	value := map[string]interface{}{"sub": actor.Subject}
+	if actor.Act != nil {
+		value["act"] = actorActivation(actor.Act)
+	}
+	return value
+}
+
+func ipInRange(ipVal, cidrVal ref.Val) ref.Val {
+	ip := net.ParseIP(ipVal.Value().(string))
+	_, network, err := net.ParseCIDR(cidrVal.Value().(string))
+	if err != nil {
+		return types.NewErr("ipInRange: invalid CIDR %q", cidrVal.Value())
+	}
+	return types.Bool(ip != nil && network.Contains(ip))
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+
+	"github.com/pkg/errors"
+)
+
+type PolicyService struct {
+	policyRepo repository.PolicyRepository
+	rbac       RBAC
+	audit      Audit
+	engine     *policyEngine
+}
+
+func NewPolicyService(policyRepo repository.PolicyRepository, rbac RBAC, audit Audit) (*PolicyService, error) {
+	engine, err := newPolicyEngine()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create CEL environment")
+	}
+
+	return &PolicyService{
+		policyRepo: policyRepo,
+		rbac:       rbac,
+		audit:      audit,
+		engine:     engine,
+	}, nil
+}
+
+// SavePolicy компилирует выражение и сохраняет политику; невалидные политики не сохраняются
+func (s *PolicyService) SavePolicy(ctx context.Context, actorID int64, policy *models.Policy) (*models.Policy, error) {
+	policy.Actions = uniqueSorted(policy.Actions)
+	if err := policy.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := s.engine.compile(policy.Expression); err != nil {
+		return nil, err
+	}
+
+	policy.UpdatedAt = time.Now().UTC().Truncate(time.Microsecond)
+	if err := s.policyRepo.SavePolicy(ctx, policy); err != nil {
+		return nil, errors.Wrap(err, "failed to save policy")
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditPolicySaved,
+		UserID: &actorID,
+		Metadata: map[string]string{
+			"policy":     policy.Name,
+			"effect":     string(policy.Effect),
+			"actions":    strings.Join(policy.Actions, " "),
+			"enabled":    strconv.FormatBool(policy.Enabled),
+			"expression": policy.Expression,
+		},
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to record audit event")
+	}
+
+	return policy, nil
+}
+
+func (s *PolicyService) DeletePolicy(ctx context.Context, actorID int64, name string) error {
+	deleted, err := s.policyRepo.DeletePolicy(ctx, name)
+	if err != nil {
+		return errors.Wrap(err, "failed to delete policy")
+	}
+	if !deleted {
+		return models.ErrPolicyNotFound
+	}
+
+	return errors.Wrap(s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditPolicyDeleted,
+		UserID:   &actorID,
+		Metadata: map[string]string{"policy": name},
+	}), "failed to record audit event")
+}
+
+func (s *PolicyService) ListPolicies(ctx context.Context) ([]*models.Policy, error) {
+	policies, err := s.policyRepo.ListPolicies(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list policies")
+	}
+	return policies, nil
+}
+
+// CheckLogin применяет политики действия login к пользователю с верным паролем.
+// Без подходящих политик вход разрешен.
+func (s *PolicyService) CheckLogin(ctx context.Context, attempt *models.LoginAttempt) (*models.PolicyDecision, error) {
+	access, err := s.rbac.GetUserAccess(ctx, attempt.User.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.evaluate(ctx, &models.PolicyInput{
+		Action:    models.PolicyActionLogin,
+		User:      attempt.User,
+		Access:    access,
+		IP:        attempt.Client.IP,
+		UserAgent: attempt.Client.UserAgent,
+		Time:      attempt.Time,
+	}, true)
+}
+
+// Authorize применяет политики произвольного действия.
+// Без разрешающей политики действие запрещено.
+func (s *PolicyService) Authorize(ctx context.Context, input *models.PolicyInput) (*models.PolicyDecision, error) {
+	if input.Action == "" || input.Action == models.PolicyActionAny {
+		return nil, models.ErrInvalidPolicy
+	}
+
+	if input.User != nil && input.Access == nil {
+		access, err := s.rbac.GetUserAccess(ctx, input.User.ID)
+		if err != nil {
+			return nil, err
+		}
+		input.Access = access
+	}
+	if input.Time.IsZero() {
+		input.Time = time.Now()
+	}
+
+	return s.evaluate(ctx, input, false)
+}
+
+// evaluate: запрет сильнее разрешения. Ошибка вычисления запрещающей политики
+// считается срабатыванием (fail closed), разрешающей - несрабатыванием.
+func (s *PolicyService) evaluate(ctx context.Context, input *models.PolicyInput, defaultAllow bool) (*models.PolicyDecision, error) {
+	policies, err := s.policyRepo.ListPoliciesForAction(ctx, input.Action)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load policies")
+	}
+
+	activation := policyActivation(input)
+
+	var allowedBy *models.Policy
+	for _, policy := range policies {
+		matched, err := s.engine.eval(policy, activation)
+		if err != nil {
+			log.Printf("⚠️  Policy %s failed to evaluate: %v", policy.Name, err)
+			if policy.Effect == models.PolicyDeny {
+				return &models.PolicyDecision{
+					Allowed: false,
+					Policy:  policy.Name,
+					Effect:  models.PolicyDeny,
+					Reason:  "deny policy failed to evaluate: " + err.Error(),
+				}, nil
+			}
+			continue
+		}
+		if !matched {
+			continue
+		}
+
+		if policy.Effect == models.PolicyDeny {
+			return &models.PolicyDecision{
+				Allowed: false,
+				Policy:  policy.Name,
+				Effect:  models.PolicyDeny,
+				Reason:  policyReason(policy),
+			}, nil
+		}
+		if allowedBy == nil {
+			allowedBy = policy
+		}
+	}
+
+	if allowedBy != nil {
+		return &models.PolicyDecision{
+			Allowed: true,
+			Policy:  allowedBy.Name,
+			Effect:  models.PolicyAllow,
+			Reason:  policyReason(allowedBy),
+		}, nil
+	}
+
+	if defaultAllow {
+		return &models.PolicyDecision{Allowed: true, Effect: models.PolicyAllow, Reason: "no policy denied the action"}, nil
+	}
+	return &models.PolicyDecision{Allowed: false, Effect: models.PolicyDeny, Reason: "no policy allowed the action"}, nil
+}
+
+func policyReason(policy *models.Policy) string {
+	if policy.Description != "" {
+		return policy.Description
+	}
+	return "matched " + policy.Expression
+}
@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+
+	"github.com/pkg/errors"
+)
+
+type RBACService struct {
+	rbacRepo repository.RBACRepository
+	userRepo repository.UserRepository
+	audit    Audit
+}
+
+func NewRBACService(rbacRepo repository.RBACRepository, userRepo repository.UserRepository, audit Audit) *RBACService {
+	return &RBACService{
+		rbacRepo: rbacRepo,
+		userRepo: userRepo,
+		audit:    audit,
+	}
+}
+
+func (s *RBACService) CreateRole(ctx context.Context, actorID int64, role *models.Role) (*models.Role, error) {
+	role.Name = strings.TrimSpace(role.Name)
+	role.Permissions = uniqueSorted(role.Permissions)
+	if err := role.Validate(); err != nil {
+		return nil, err
+	}
+	role.CreatedAt = time.Now()
+
+	// Встроенные роли видны во всех тенантах, перекрывать их нельзя
+	existing, err := s.rbacRepo.GetRoleByName(ctx, role.Name)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get role")
+	}
+	if existing != nil {
+		return nil, models.ErrRoleAlreadyExists
+	}
+
+	if err := s.rbacRepo.CreateRole(ctx, role); err != nil {
+		if err == models.ErrRoleAlreadyExists {
+			return nil, err
+		}
+		return nil, errors.Wrap(err, "failed to create role")
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditRoleCreated,
+		UserID: &actorID,
+		Metadata: map[string]string{
+			"role":        role.Name,
+			"permissions": strings.Join(role.Permissions, " "),
+		},
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to record audit event")
+	}
+
+	return role, nil
+}
+
+// GrantRole выдает роль пользователю; actorID == nil - выдача самим сервисом (например, при регистрации)
+func (s *RBACService) GrantRole(ctx context.Context, actorID *int64, userID int64, roleName string) error {
+	role, err := s.findUserRole(ctx, userID, roleName)
+	if err != nil {
+		return err
+	}
+
+	granted, err := s.rbacRepo.GrantRole(ctx, userID, role.ID, actorID, time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to grant role")
+	}
+	if !granted {
+		return nil
+	}
+
+	return errors.Wrap(s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditRoleGranted,
+		UserID:   &userID,
+		Metadata: roleAuditMetadata(role.Name, actorID),
+	}), "failed to record audit event")
+}
+
+func (s *RBACService) RevokeRole(ctx context.Context, actorID *int64, userID int64, roleName string) error {
+	role, err := s.findUserRole(ctx, userID, roleName)
+	if err != nil {
+		return err
+	}
+
+	revoked, err := s.rbacRepo.RevokeRole(ctx, userID, role.ID)
+	if err != nil {
+		return errors.Wrap(err, "failed to revoke role")
+	}
+	if !revoked {
+		return nil
+	}
+
+	return errors.Wrap(s.audit.Record(ctx, &models.AuditEvent{
+		Type:     models.AuditRoleRevoked,
+		UserID:   &userID,
+		Metadata: roleAuditMetadata(role.Name, actorID),
+	}), "failed to record audit event")
+}
+
+// GetUserAccess возвращает роли пользователя и все разрешения, которые они дают
+func (s *RBACService) GetUserAccess(ctx context.Context, userID int64) (*models.UserAccess, error) {
+	access, err := s.rbacRepo.GetUserAccess(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user access")
+	}
+	return access, nil
+}
+
+// RequirePermission проверяет разрешение по базе, а не по токену:
+// отозванная роль перестает действовать сразу, не дожидаясь истечения токена
+func (s *RBACService) RequirePermission(ctx context.Context, userID int64, permission string) error {
+	access, err := s.GetUserAccess(ctx, userID)
+	if err != nil {
+		return err
+	}
+	if !access.HasPermission(permission) {
+		return models.ErrPermissionDenied
+	}
+	return nil
+}
+
+func (s *RBACService) findUserRole(ctx context.Context, userID int64, roleName string) (*models.Role, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user by ID")
+	}
+	if user == nil {
+		return nil, models.ErrUserNotFound
+	}
+
+	role, err := s.rbacRepo.GetRoleByName(ctx, roleName)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get role")
+	}
+	if role == nil {
+		return nil, models.ErrRoleNotFound
+	}
+
+	return role, nil
+}
+
+// syncManagedRoles выдает пользователю роли из desired, а с revoke отзывает
+// остальные роли из managed; роли вне managed не трогаем. Несуществующие роли
+// пропускаются: вход из-за ошибки в настройке внешнего источника ломаться не должен.
+func syncManagedRoles(ctx context.Context, rbac RBAC, source string, userID int64, desired, managed []string, revoke bool) error {
+	want := make(map[string]bool, len(desired))
+	for _, role := range desired {
+		want[role] = true
+	}
+
+	for _, role := range uniqueSorted(managed) {
+		var err error
+		switch {
+		case want[role]:
+			err = rbac.GrantRole(ctx, nil, userID, role)
+		case revoke:
+			err = rbac.RevokeRole(ctx, nil, userID, role)
+		}
+		if err == models.ErrRoleNotFound {
+			log.Printf("%s maps to unknown role %q", source, role)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func roleAuditMetadata(roleName string, actorID *int64) map[string]string {
+	metadata := map[string]string{"role": roleName}
+	if actorID != nil {
+		metadata["actor_id"] = strconv.FormatInt(*actorID, 10)
+	}
+	return metadata
+}
+
+func uniqueSorted(values []string) []string {
+	seen := make(map[string]bool, len(values))
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" || seen[value] {
+			continue
+		}
+		seen[value] = true
+		result = append(result, value)
+	}
+	sort.Strings(result)
+	return result
+}
@@ -2,6 +2,8 @@ package service
 
 import (
 	"context"
+	"log"
+	"strings"
 	"time"
 
 	"github.com/DailyPepper/auth-service/internal/models"
@@ -11,16 +13,100 @@ import (
 )
 
 type RegistrService struct {
-	userRepo repository.UserRepository
+	userRepo    repository.UserRepository
+	sessionRepo repository.SessionRepository
+	resetRepo   repository.PasswordResetRepository
+	orgRepo     repository.OrganizationRepository
+	txs         repository.Transactor
+	tokens      *TokenService
+	dpop        DPoP
+	devices     Devices
+	geo         Geo
+	risk        RiskEngine
+	challenges  Challenges
+	rbac        RBAC
+	policies    Policies
+	domains     Domains
+	// Проверяют пароль при входе, по порядку
+	authProviders []AuthProvider
+	audit         Audit
+	sessionTTL    time.Duration
+	// Срок сессии администратора от имени пользователя
+	impersonationTTL time.Duration
 }
 
-func NewRegistrService(userRepo repository.UserRepository) *RegistrService {
+func NewRegistrService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	resetRepo repository.PasswordResetRepository,
+	orgRepo repository.OrganizationRepository,
+	txs repository.Transactor,
+	tokens *TokenService,
+	dpop DPoP,
+	devices Devices,
+	geo Geo,
+	risk RiskEngine,
+	challenges Challenges,
+	rbac RBAC,
+	policies Policies,
+	domains Domains,
+	authProviders []AuthProvider,
+	audit Audit,
+	sessionTTL time.Duration,
+	impersonationTTL time.Duration,
+) *RegistrService {
+	if len(authProviders) == 0 {
+		authProviders = []AuthProvider{PasswordAuthProvider{}}
+	}
 	return &RegistrService{
-		userRepo: userRepo,
+		userRepo:      userRepo,
+		sessionRepo:   sessionRepo,
+		resetRepo:     resetRepo,
+		orgRepo:       orgRepo,
+		txs:           txs,
+		tokens:        tokens,
+		dpop:          dpop,
+		devices:       devices,
+		geo:           geo,
+		risk:          risk,
+		challenges:    challenges,
+		rbac:          rbac,
+		policies:      policies,
+		domains:       domains,
+		authProviders: authProviders,
+		audit:         audit,
+		sessionTTL:    sessionTTL,
+
+		impersonationTTL: impersonationTTL,
 	}
 }
 
 func (s *RegistrService) Registration(ctx context.Context, req *models.Registr) (*models.User, error) {
+	// Защита от массовой регистрации ботами
+	if err := s.challenges.CheckRegister(ctx, req.Challenge, req.Client); err != nil {
+		return nil, err
+	}
+	if len(req.Password) < 8 {
+		return nil, models.ErrPasswordTooWeak
+	}
+
+	return s.createUser(ctx, req, false)
+}
+
+// RegisterInvited регистрирует пользователя по приглашению. Задание против ботов
+// не нужно, а email считается подтвержденным: ссылка пришла на этот адрес.
+func (s *RegistrService) RegisterInvited(ctx context.Context, req *models.Registr) (*models.User, error) {
+	return s.createUser(ctx, req, true)
+}
+
+// RegisterExternal создает пользователя, который входит через внешнего провайдера.
+// Пароль не задается: войти по паролю такой пользователь не сможет, пока не сбросит его.
+func (s *RegistrService) RegisterExternal(ctx context.Context, req *models.Registr, verified bool) (*models.User, error) {
+	req.Password = ""
+	return s.createUser(ctx, req, verified)
+}
+
+func (s *RegistrService) createUser(ctx context.Context, req *models.Registr, verified bool) (*models.User, error) {
 	// Проверяем, существует ли пользователь с таким email
 	existingUser, err := s.userRepo.GetUserByEmail(ctx, req.Email)
 	if err != nil {
@@ -39,20 +125,38 @@ func (s *RegistrService) Registration(ctx context.Context, req *models.Registr)
 		Phone:      req.Phone,
 		Password:   req.Password, // Пароль будет захеширован в методе
 		IsActive:   true,
-		IsVerified: false,
-		Role:       models.RoleUser,
+		IsVerified: verified,
 		CreatedAt:  time.Now(),
 		UpdatedAt:  time.Now(),
 	}
 
-	// Хешируем пароль
-	if err := user.HashPassword(); err != nil {
-		return nil, errors.Wrap(err, "failed to hash password")
+	// Хешируем пароль. Пустой пароль не хешируем: с пустым хешем
+	// CheckPassword не проходит ни для какого пароля
+	if user.Password != "" {
+		if err := user.HashPassword(); err != nil {
+			return nil, errors.Wrap(err, "failed to hash password")
+		}
 	}
 
-	// Сохраняем в базу
-	if err := s.userRepo.CreateUser(ctx, user); err != nil {
-		return nil, errors.Wrap(err, "failed to create user in database")
+	// Пользователь, запись аудита и роль по умолчанию появляются только вместе
+	err = s.txs.InTx(ctx, func(ctx context.Context) error {
+		if err := s.userRepo.CreateUser(ctx, user); err != nil {
+			return errors.Wrap(err, "failed to create user in database")
+		}
+
+		if err := s.audit.Record(ctx, &models.AuditEvent{
+			Type:   models.AuditUserRegistered,
+			UserID: &user.ID,
+			Email:  user.Email,
+		}); err != nil {
+			return errors.Wrap(err, "failed to record audit event")
+		}
+
+		// Новому пользователю выдаем роль по умолчанию
+		return errors.Wrap(s.rbac.GrantRole(ctx, nil, user.ID, models.DefaultRoleUser), "failed to grant default role")
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	// Возвращаем пользователя без пароля для безопасности
@@ -62,51 +166,366 @@ func (s *RegistrService) Registration(ctx context.Context, req *models.Registr)
 }
 
 func (s *RegistrService) Login(ctx context.Context, req *models.LoginRequest) (*models.LoginResponse, error) {
+	// Неверное DPoP-доказательство - ошибка клиента, а не неудачный вход
+	var jkt string
+	if req.DPoP != nil {
+		var err error
+		if jkt, err = s.dpop.Verify(req.DPoP); err != nil {
+			return nil, err
+		}
+	}
+
 	// Находим пользователя по email
 	user, err := s.userRepo.GetUserByEmail(ctx, req.Email)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to get user by email")
 	}
-	if user == nil {
-		return nil, models.ErrInvalidCredentials
+
+	attempt := &models.LoginAttempt{
+		Email:  req.Email,
+		User:   user,
+		Client: req.Client,
+		Time:   time.Now(),
+
+		DPoPThumbprint: jkt,
+	}
+
+	// Геолоцируем вход, если подключена GeoIP-база
+	if err := s.locateAttempt(ctx, attempt); err != nil {
+		return nil, errors.Wrap(err, "failed to locate login")
+	}
+
+	// Оцениваем риск попытки до проверки пароля
+	assessment, err := s.risk.Assess(ctx, attempt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to assess login risk")
+	}
+
+	if assessment.Decision == models.RiskDeny {
+		return nil, s.loginFailed(ctx, attempt, assessment, "risk_denied", models.ErrLoginDenied)
+	}
+
+	// CAPTCHA / proof-of-work: по политике после неудачных входов или по решению риск-движка.
+	// Второго фактора в сервисе пока нет, поэтому полоса MFA тоже требует задание
+	riskRequired := assessment.Decision == models.RiskRequireCaptcha || assessment.Decision == models.RiskRequireMFA
+	switch err := s.challenges.CheckLogin(ctx, attempt, req.Challenge, riskRequired); err {
+	case nil:
+	case models.ErrCaptchaRequired:
+		return nil, s.loginChallenged(ctx, attempt, assessment, "captcha_required", err)
+	case models.ErrChallengeFailed:
+		return nil, s.loginFailed(ctx, attempt, assessment, "challenge_failed", err)
+	default:
+		return nil, errors.Wrap(err, "failed to verify challenge")
+	}
+
+	// Домен с обязательным SSO не принимает пароль, даже верный
+	if err := s.domains.CheckLogin(ctx, req.Email, ""); err != nil {
+		if err == models.ErrSSORequired {
+			return nil, s.loginFailed(ctx, attempt, assessment, "sso_required", err)
+		}
+		return nil, errors.Wrap(err, "failed to check email domain")
 	}
 
 	// Проверяем активность пользователя
-	if !user.IsActive {
-		return nil, errors.New("user account is deactivated")
+	if user != nil && !user.IsActive {
+		return nil, s.loginFailed(ctx, attempt, assessment, "deactivated", errors.New("user account is deactivated"))
 	}
 
 	// Проверяем пароль
-	if !user.CheckPassword(req.Password) {
-		return nil, models.ErrInvalidCredentials
+	authn, err := s.authenticate(ctx, req.Email, req.Password, user)
+	if err == models.ErrInvalidCredentials {
+		reason := models.LoginFailureInvalidPassword
+		if user == nil {
+			reason = models.LoginFailureUnknownEmail
+		}
+		return nil, s.loginFailed(ctx, attempt, assessment, reason, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Сотрудника, которого пустил каталог, заводим при первом входе
+	if user == nil {
+		user, err = s.RegisterExternal(ctx, &models.Registr{
+			FirstName: authn.FirstName,
+			Surname:   authn.Surname,
+			Email:     strings.ToLower(strings.TrimSpace(req.Email)),
+		}, true)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to provision directory user")
+		}
+		attempt.User = user
+	}
+	if err := syncManagedRoles(ctx, s.rbac, "Auth provider "+authn.Provider, user.ID, authn.Roles, authn.ManagedRoles, true); err != nil {
+		return nil, errors.Wrap(err, "failed to sync directory roles")
+	}
+
+	// После жалобы "это был не я" пускаем только после смены пароля.
+	// Пароль каталога у нас не меняется, поэтому флаг касается только своего пароля.
+	if authn.Provider == models.AuthProviderPassword && user.PasswordResetRequired {
+		return nil, s.loginFailed(ctx, attempt, assessment, "password_reset_required", models.ErrPasswordResetRequired)
+	}
+
+	// Политики входа (CEL) видят роли пользователя, поэтому проверяются после пароля
+	decision, err := s.policies.CheckLogin(ctx, attempt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to evaluate login policies")
+	}
+	if !decision.Allowed {
+		return nil, s.loginFailed(ctx, attempt, assessment, "policy_denied:"+decision.Policy, models.ErrPolicyDenied)
 	}
 
+	// Войти от имени организации может только ее участник
+	var membership *models.OrganizationMembership
+	if req.OrganizationID != 0 {
+		membership, err = s.orgRepo.GetOrganizationMembership(ctx, req.OrganizationID, user.ID)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get organization membership")
+		}
+		if membership == nil {
+			return nil, s.loginFailed(ctx, attempt, assessment, "not_organization_member", models.ErrNotOrganizationMember)
+		}
+	}
+
+	return s.completeLogin(ctx, attempt, assessment, membership, authn.Provider)
+}
+
+// authenticate опрашивает провайдеров аутентификации по порядку. Недоступный
+// провайдер пропускается, чтобы вход по остальным работал; если пароль не принял
+// никто, возвращается его ошибка, а не models.ErrInvalidCredentials.
+func (s *RegistrService) authenticate(ctx context.Context, email, password string, user *models.User) (*models.Authentication, error) {
+	var failure error
+	for _, provider := range s.authProviders {
+		authn, err := provider.Authenticate(ctx, email, password, user)
+		if err == nil {
+			return authn, nil
+		}
+		if err != models.ErrInvalidCredentials {
+			log.Printf("Failed to authenticate with %s provider: %v", provider.Name(), err)
+			failure = errors.Wrapf(err, "auth provider %s failed", provider.Name())
+		}
+	}
+	if failure != nil {
+		return nil, failure
+	}
+	return nil, models.ErrInvalidCredentials
+}
+
+// LoginExternal открывает сессию пользователю, которого уже проверил внешний
+// провайдер. Пароль и CAPTCHA не нужны, но риск и политики входа действуют.
+func (s *RegistrService) LoginExternal(ctx context.Context, req *models.ExternalLoginRequest) (*models.LoginResponse, error) {
+	return s.loginVerified(ctx, req.User, req.Client, req.Method, &req.Provider)
+}
+
+// LoginDevice открывает сессию устройству, вход которого пользователь подтвердил
+// из уже открытой сессии (RFC 8628). Обязательный SSO домена тот вход уже прошел,
+// а риск и политики проверяются для адреса устройства.
+func (s *RegistrService) LoginDevice(ctx context.Context, user *models.User, client models.ClientInfo) (*models.LoginResponse, error) {
+	return s.loginVerified(ctx, user, client, "device_code", nil)
+}
+
+// loginVerified - вход пользователя, личность которого уже подтверждена. provider -
+// провайдер для проверки обязательного SSO домена; nil - проверка не нужна.
+func (s *RegistrService) loginVerified(ctx context.Context, user *models.User, client models.ClientInfo, method string, provider *string) (*models.LoginResponse, error) {
+	attempt := &models.LoginAttempt{
+		Email:  user.Email,
+		User:   user,
+		Client: client,
+		Time:   time.Now(),
+	}
+
+	if err := s.locateAttempt(ctx, attempt); err != nil {
+		return nil, errors.Wrap(err, "failed to locate login")
+	}
+
+	assessment, err := s.risk.Assess(ctx, attempt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to assess login risk")
+	}
+
+	if assessment.Decision == models.RiskDeny {
+		return nil, s.loginFailed(ctx, attempt, assessment, "risk_denied", models.ErrLoginDenied)
+	}
+
+	// Вход через другого провайдера тоже не обходит обязательный SSO домена
+	if provider != nil {
+		if err := s.domains.CheckLogin(ctx, user.Email, *provider); err != nil {
+			if err == models.ErrSSORequired {
+				return nil, s.loginFailed(ctx, attempt, assessment, "sso_required", err)
+			}
+			return nil, errors.Wrap(err, "failed to check email domain")
+		}
+	}
+
+	if !user.IsActive {
+		return nil, s.loginFailed(ctx, attempt, assessment, "deactivated", errors.New("user account is deactivated"))
+	}
+
+	decision, err := s.policies.CheckLogin(ctx, attempt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to evaluate login policies")
+	}
+	if !decision.Allowed {
+		return nil, s.loginFailed(ctx, attempt, assessment, "policy_denied:"+decision.Policy, models.ErrPolicyDenied)
+	}
+
+	return s.completeLogin(ctx, attempt, assessment, nil, method)
+}
+
+// completeLogin завершает успешный вход: сессия, токены, устройство и аудит
+func (s *RegistrService) completeLogin(ctx context.Context, attempt *models.LoginAttempt, assessment *models.RiskAssessment, membership *models.OrganizationMembership, method string) (*models.LoginResponse, error) {
+	user := attempt.User
+
 	// Обновляем время последнего входа
 	loginTime := time.Now()
 	if err := s.userRepo.UpdateLastLogin(ctx, user.ID, loginTime); err != nil {
 		return nil, errors.Wrap(err, "failed to update last login")
 	}
 
-	// Генерируем токены (заглушки - нужно реализовать JWT)
-	accessToken, refreshToken, err := s.generateTokens(user)
+	// Создаем сессию и выпускаем токены
+	session, refreshToken, err := s.createSession(ctx, attempt)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create session")
+	}
+
+	access, err := s.rbac.GetUserAccess(ctx, user.ID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user access")
+	}
+
+	accessToken, expiresAt, err := s.tokens.IssueAccessToken(user, access, membership, session.ID, attempt.DPoPThumbprint, loginTime)
 	if err != nil {
 		return nil, errors.Wrap(err, "failed to generate tokens")
 	}
 
+	// Проверяем, знакомо ли устройство
+	if err := s.devices.CheckLogin(ctx, user, session, attempt.Client); err != nil {
+		return nil, errors.Wrap(err, "failed to check login device")
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditLoginSucceeded,
+		UserID: &user.ID,
+		Email:  user.Email,
+		Metadata: riskAuditMetadata(assessment, locationAuditMetadata(session, map[string]string{
+			"session_id": session.ID,
+			"ip":         attempt.Client.IP,
+			"method":     method,
+		})),
+	}); err != nil {
+		return nil, errors.Wrap(err, "failed to record audit event")
+	}
+
 	// Очищаем пароль в ответе
 	user.Password = ""
 
 	return &models.LoginResponse{
 		AccessToken:  accessToken,
 		RefreshToken: refreshToken,
+		ExpiresAt:    expiresAt,
+		SessionID:    session.ID,
 		User:         *user,
+		TokenType:    tokenType(attempt.DPoPThumbprint),
 	}, nil
 }
 
+func (s *RegistrService) locateAttempt(ctx context.Context, attempt *models.LoginAttempt) error {
+	if s.geo == nil {
+		return nil
+	}
+
+	location, err := s.geo.Locate(attempt.Client.IP)
+	if err != nil {
+		return err
+	}
+	attempt.Location = location
+
+	if attempt.User == nil {
+		return nil
+	}
+
+	attempt.ImpossibleTravel, err = s.geo.IsImpossibleTravel(ctx, attempt.User.ID, location, attempt.Time)
+	return err
+}
+
+func (s *RegistrService) createSession(ctx context.Context, attempt *models.LoginAttempt) (*models.Session, string, error) {
+	sessionID, err := randomToken(16)
+	if err != nil {
+		return nil, "", err
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, "", err
+	}
+
+	session := &models.Session{
+		ID:                sessionID,
+		UserID:            attempt.User.ID,
+		RefreshTokenHash:  hashToken(refreshToken),
+		UserAgent:         attempt.Client.UserAgent,
+		IP:                attempt.Client.IP,
+		DeviceFingerprint: attempt.Client.Fingerprint(),
+		CreatedAt:         attempt.Time,
+		ExpiresAt:         attempt.Time.Add(s.sessionTTL),
+		Location:          attempt.Location,
+		ImpossibleTravel:  attempt.ImpossibleTravel,
+	}
+
+	if err := s.sessionRepo.CreateSession(ctx, session); err != nil {
+		return nil, "", err
+	}
+
+	return session, refreshToken, nil
+}
+
+// locationAuditMetadata добавляет место входа в метаданные события аудита
+func locationAuditMetadata(session *models.Session, metadata map[string]string) map[string]string {
+	if session.Location != nil {
+		metadata["country"] = session.Location.CountryCode
+		metadata["city"] = session.Location.City
+	}
+	if session.ImpossibleTravel {
+		metadata["impossible_travel"] = "true"
+	}
+	return metadata
+}
+
+// Фиксирует неудачный вход в аудите и возвращает исходную ошибку
+func (s *RegistrService) loginFailed(ctx context.Context, attempt *models.LoginAttempt, assessment *models.RiskAssessment, reason string, cause error) error {
+	return s.recordLoginOutcome(ctx, models.AuditLoginFailed, attempt, assessment, reason, cause)
+}
+
+// Фиксирует вход, для которого нужна дополнительная проверка (CAPTCHA, MFA).
+// Такие попытки не считаются неудачными и не влияют на частоту ошибок.
+func (s *RegistrService) loginChallenged(ctx context.Context, attempt *models.LoginAttempt, assessment *models.RiskAssessment, reason string, cause error) error {
+	return s.recordLoginOutcome(ctx, models.AuditLoginChallenged, attempt, assessment, reason, cause)
+}
+
+func (s *RegistrService) recordLoginOutcome(ctx context.Context, eventType models.AuditEventType, attempt *models.LoginAttempt, assessment *models.RiskAssessment, reason string, cause error) error {
+	var userID *int64
+	if attempt.User != nil {
+		userID = &attempt.User.ID
+	}
+
+	if err := s.audit.Record(ctx, &models.AuditEvent{
+		Type:   eventType,
+		UserID: userID,
+		Email:  attempt.Email,
+		Metadata: riskAuditMetadata(assessment, map[string]string{
+			"reason": reason,
+			"ip":     attempt.Client.IP,
+		}),
+	}); err != nil {
+		return errors.Wrap(err, "failed to record audit event")
+	}
+	return cause
+}
+
 func (s *RegistrService) GetUserProfile(ctx context.Context, userID int64) (*models.User, error) {
-	user, err := s.userRepo.GetUserByID(ctx, userID)
+	user, err := s.resolveUser(ctx, userID)
 	if err != nil {
-		return nil, errors.Wrap(err, "failed to get user by ID")
+		return nil, err
 	}
 	if user == nil {
 		return nil, errors.New("user not found")
@@ -119,30 +538,130 @@ func (s *RegistrService) GetUserProfile(ctx context.Context, userID int64) (*mod
 }
 
 func (s *RegistrService) ValidateToken(ctx context.Context, token string) (*models.User, error) {
-	// TODO: Реализовать полноценную валидацию JWT токена
+	_, user, err := s.Authenticate(ctx, token)
+	return user, err
+}
 
+// Authenticate проверяет access-токен и возвращает его claims и владельца
+func (s *RegistrService) Authenticate(ctx context.Context, token string) (*models.TokenClaims, *models.User, error) {
 	if token == "" {
-		return nil, errors.New("empty token")
+		return nil, nil, models.ErrInvalidToken
+	}
+
+	// Проверяем подпись и срок действия JWT
+	claims, err := s.tokens.ParseAccessToken(token)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if len(token) < 10 {
-		return nil, errors.New("invalid token format")
+	// Токен другого тенанта не принимаем, даже если подпись верна
+	if tenant, ok := models.TenantFromContext(ctx); !ok || tenant.ID != claims.TenantID {
+		return nil, nil, models.ErrInvalidToken
 	}
 
-	// Нужно парсить JWT и проверять подпись
-	return &models.User{
-		ID:        1,
-		Email:     "test@example.com",
-		FirstName: "Test",
-		Surname:   "User",
-		IsActive:  true,
-		Role:      models.RoleUser,
-	}, nil
+	// Токен действителен, только пока жива его сессия. После слияния сессия
+	// принадлежит пользователю, в которого перешла ссылка из токена.
+	session, err := s.sessionRepo.GetSession(ctx, claims.SessionID)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to get session")
+	}
+	if session == nil || !session.IsActive(time.Now()) {
+		return nil, nil, models.ErrSessionRevoked
+	}
+
+	user, err := s.resolveUser(ctx, claims.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if user != nil && session.UserID != user.ID {
+		return nil, nil, models.ErrSessionRevoked
+	}
+	if user == nil || !user.IsActive {
+		return nil, nil, models.ErrInvalidToken
+	}
+
+	// Очищаем пароль
+	user.Password = ""
+
+	if session.ImpersonatorID != nil {
+		claims.ImpersonatorID = *session.ImpersonatorID
+	}
+
+	return claims, user, nil
+}
+
+// AuthenticateRequest - Authenticate для токена, предъявленного с запросом. Токен,
+// привязанный к ключу DPoP, принимается только с доказательством этим ключом (RFC 9449, 7).
+// Доказательство к bearer-токену не проверяется.
+func (s *RegistrService) AuthenticateRequest(ctx context.Context, token string, proof *models.DPoPRequest) (*models.TokenClaims, *models.User, error) {
+	claims, user, err := s.Authenticate(ctx, token)
+	if err != nil {
+		return nil, nil, err
+	}
+	if claims.DPoPThumbprint == "" {
+		return claims, user, nil
+	}
+
+	if proof == nil {
+		return nil, nil, models.ErrInvalidDPoPProof
+	}
+	proof.AccessToken = token
+	jkt, err := s.dpop.Verify(proof)
+	if err != nil {
+		return nil, nil, err
+	}
+	if jkt != claims.DPoPThumbprint {
+		return nil, nil, models.ErrInvalidDPoPProof
+	}
+	return claims, user, nil
 }
 
-func (s *RegistrService) generateTokens(user *models.User) (string, string, error) {
-	// TODO: Реализовать генерацию JWT токенов
-	accessToken := "access_token_" + user.Email
-	refreshToken := "refresh_token_" + user.Email
-	return accessToken, refreshToken, nil
+// resolveUser загружает пользователя, переходя по ссылке слитого пользователя
+// к тому, с кем его объединили
+func (s *RegistrService) resolveUser(ctx context.Context, userID int64) (*models.User, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to get user by ID")
+	}
+	if user != nil && user.MergedInto != nil {
+		user, err = s.userRepo.GetUserByID(ctx, *user.MergedInto)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get merged user by ID")
+		}
+	}
+	return user, nil
+}
+
+func (s *RegistrService) ResetPassword(ctx context.Context, req *models.ResetPasswordRequest) error {
+	if len(req.NewPassword) < 8 {
+		return models.ErrPasswordTooWeak
+	}
+
+	userID, err := s.resetRepo.ConsumePasswordResetToken(ctx, hashToken(req.Token), time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to consume password reset token")
+	}
+	if userID == 0 {
+		return models.ErrInvalidResetToken
+	}
+
+	user := &models.User{ID: userID, Password: req.NewPassword}
+	if err := user.HashPassword(); err != nil {
+		return errors.Wrap(err, "failed to hash password")
+	}
+
+	// Новый пароль снимает флаг принудительной смены
+	if err := s.userRepo.UpdatePassword(ctx, userID, user.Password); err != nil {
+		return errors.Wrap(err, "failed to update password")
+	}
+
+	// Все старые сессии после смены пароля недействительны
+	if err := s.sessionRepo.RevokeUserSessions(ctx, userID, time.Now()); err != nil {
+		return errors.Wrap(err, "failed to revoke sessions")
+	}
+
+	return errors.Wrap(s.audit.Record(ctx, &models.AuditEvent{
+		Type:   models.AuditPasswordResetCompleted,
+		UserID: &userID,
+	}), "failed to record audit event")
 }
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"sync"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+)
+
+// Верхняя граница числа записей: при переполнении кеш сбрасывается
+const relationCacheMaxEntries = 100_000
+
+// relationCheckCache хранит результаты проверок вместе с ревизией, на которой
+// они вычислены. Запрос с токеном согласованности получает из кеша только
+// результат не старше этой ревизии.
+type relationCheckCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	entries map[string]relationCheckEntry
+	swept   time.Time
+}
+
+type relationCheckEntry struct {
+	allowed  bool
+	revision models.RelationRevision
+	cachedAt time.Time
+}
+
+// ttl <= 0 отключает кеш
+func newRelationCheckCache(ttl time.Duration) *relationCheckCache {
+	return &relationCheckCache{
+		ttl:     ttl,
+		entries: make(map[string]relationCheckEntry),
+	}
+}
+
+func (c *relationCheckCache) get(key string, consistency models.Consistency, now time.Time) (relationCheckEntry, bool) {
+	if c.ttl <= 0 || consistency.FullyConsistent {
+		return relationCheckEntry{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || now.Sub(entry.cachedAt) >= c.ttl || entry.revision < consistency.AtLeastAsFresh {
+		return relationCheckEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *relationCheckCache) put(key string, allowed bool, revision models.RelationRevision, now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sweep(now)
+
+	// Не затираем результат более свежей ревизии
+	if existing, ok := c.entries[key]; ok && existing.revision > revision && now.Sub(existing.cachedAt) < c.ttl {
+		return
+	}
+	if len(c.entries) >= relationCacheMaxEntries {
+		c.entries = make(map[string]relationCheckEntry)
+	}
+
+	c.entries[key] = relationCheckEntry{allowed: allowed, revision: revision, cachedAt: now}
+}
+
+// Раз в TTL удаляем устаревшие записи
+func (c *relationCheckCache) sweep(now time.Time) {
+	if now.Sub(c.swept) < c.ttl {
+		return
+	}
+	c.swept = now
+
+	for key, entry := range c.entries {
+		if now.Sub(entry.cachedAt) >= c.ttl {
+			delete(c.entries, key)
+		}
+	}
+}
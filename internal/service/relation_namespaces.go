@@ -0,0 +1,172 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+)
+
+// RelationNamespaces - схема отношений в стиле Zanzibar.
+//
+//	{
+//	  "namespaces": {
+//	    "user": {},
+//	    "group": {"relations": {"member": {}}},
+//	    "document": {
+//	      "relations": {
+//	        "parent": {},
+//	        "owner": {},
+//	        "editor": {"union": [{"this": {}}, {"computed_userset": "owner"}]},
+//	        "viewer": {"union": [
+//	          {"this": {}},
+//	          {"computed_userset": "editor"},
+//	          {"tuple_to_userset": {"tupleset": "parent", "computed_userset": "viewer"}}
+//	        ]}
+//	      }
+//	    }
+//	  }
+//	}
+//
+// Отношение без union задается только прямыми кортежами ("this").
+// computed_userset - другое отношение того же объекта, tuple_to_userset -
+// отношение объектов, на которые указывают кортежи tupleset (например, родительской папки).
+type RelationNamespaces struct {
+	Namespaces map[string]*namespaceConfig `json:"namespaces"`
+}
+
+type namespaceConfig struct {
+	Relations map[string]*relationConfig `json:"relations"`
+}
+
+type relationConfig struct {
+	Union []usersetRewrite `json:"union"`
+}
+
+// Ровно одно из полей
+type usersetRewrite struct {
+	This            *struct{}       `json:"this"`
+	ComputedUserset string          `json:"computed_userset"`
+	TupleToUserset  *tupleToUserset `json:"tuple_to_userset"`
+}
+
+type tupleToUserset struct {
+	Tupleset        string `json:"tupleset"`
+	ComputedUserset string `json:"computed_userset"`
+}
+
+func LoadRelationNamespaces(path string) (*RelationNamespaces, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read relation namespaces: %w", err)
+	}
+
+	var namespaces RelationNamespaces
+	if err := json.Unmarshal(data, &namespaces); err != nil {
+		return nil, fmt.Errorf("failed to parse relation namespaces: %w", err)
+	}
+
+	if err := namespaces.validate(); err != nil {
+		return nil, err
+	}
+	return &namespaces, nil
+}
+
+func (n *RelationNamespaces) validate() error {
+	// Пустые объекты в JSON ("owner": {}) и null приводим к одному виду
+	for name, ns := range n.Namespaces {
+		if ns == nil {
+			ns = &namespaceConfig{}
+			n.Namespaces[name] = ns
+		}
+		for relName, rel := range ns.Relations {
+			if rel == nil {
+				ns.Relations[relName] = &relationConfig{}
+			}
+		}
+	}
+
+	for name, ns := range n.Namespaces {
+		if !models.ValidRelationName(name) {
+			return fmt.Errorf("invalid namespace name %q", name)
+		}
+
+		for relName, rel := range ns.Relations {
+			if !models.ValidRelationName(relName) {
+				return fmt.Errorf("invalid relation name %s#%s", name, relName)
+			}
+
+			for _, rewrite := range rel.Union {
+				if err := ns.validateRewrite(rewrite); err != nil {
+					return fmt.Errorf("invalid rewrite in %s#%s: %w", name, relName, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (ns *namespaceConfig) validateRewrite(rewrite usersetRewrite) error {
+	kinds := 0
+	if rewrite.This != nil {
+		kinds++
+	}
+	if rewrite.ComputedUserset != "" {
+		kinds++
+		if ns.Relations[rewrite.ComputedUserset] == nil {
+			return fmt.Errorf("unknown relation %q", rewrite.ComputedUserset)
+		}
+	}
+	if ttu := rewrite.TupleToUserset; ttu != nil {
+		kinds++
+		tupleset := ns.Relations[ttu.Tupleset]
+		if tupleset == nil {
+			return fmt.Errorf("unknown tupleset %q", ttu.Tupleset)
+		}
+		if !tupleset.allowsDirect() {
+			return fmt.Errorf("tupleset %q must allow direct tuples", ttu.Tupleset)
+		}
+		// Отношение проверяется у объектов других пространств имен - их схема здесь неизвестна
+		if !models.ValidRelationName(ttu.ComputedUserset) {
+			return fmt.Errorf("invalid computed_userset %q", ttu.ComputedUserset)
+		}
+	}
+
+	if kinds != 1 {
+		return fmt.Errorf("exactly one of this, computed_userset, tuple_to_userset is required")
+	}
+	return nil
+}
+
+func (n *RelationNamespaces) relation(namespace, relation string) *relationConfig {
+	ns := n.Namespaces[namespace]
+	if ns == nil {
+		return nil
+	}
+	return ns.Relations[relation]
+}
+
+// allowsDirect - можно ли записывать кортежи прямо в это отношение
+func (r *relationConfig) allowsDirect() bool {
+	if r == nil {
+		return false
+	}
+	if len(r.Union) == 0 {
+		return true
+	}
+	for _, rewrite := range r.Union {
+		if rewrite.This != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// rewrites возвращает правила отношения; отношение без union - только прямые кортежи
+func (r *relationConfig) rewrites() []usersetRewrite {
+	if len(r.Union) == 0 {
+		return []usersetRewrite{{This: &struct{}{}}}
+	}
+	return r.Union
+}
@@ -0,0 +1,280 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+
+	"github.com/pkg/errors"
+)
+
+// Максимальная глубина раскрытия множеств при проверке
+const relationMaxDepth = 25
+
+type RelationService struct {
+	relationRepo repository.RelationRepository
+	namespaces   *RelationNamespaces
+	cache        *relationCheckCache
+}
+
+func NewRelationService(relationRepo repository.RelationRepository, namespaces *RelationNamespaces, cacheTTL time.Duration) *RelationService {
+	return &RelationService{
+		relationRepo: relationRepo,
+		namespaces:   namespaces,
+		cache:        newRelationCheckCache(cacheTTL),
+	}
+}
+
+// WriteRelationships атомарно применяет изменения и возвращает токен согласованности
+func (s *RelationService) WriteRelationships(ctx context.Context, updates []models.RelationUpdate) (models.RelationRevision, error) {
+	if len(updates) == 0 {
+		return 0, models.ErrInvalidRelationship
+	}
+
+	for _, update := range updates {
+		if update.Operation != models.RelationTouch && update.Operation != models.RelationDelete {
+			return 0, models.ErrInvalidRelationship
+		}
+		if err := s.validateTuple(update.Tuple); err != nil {
+			return 0, err
+		}
+	}
+
+	revision, err := s.relationRepo.WriteRelationTuples(ctx, updates)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to write relationships")
+	}
+	return revision, nil
+}
+
+// CheckPermission проверяет, входит ли субъект в отношение (разрешение) объекта
+func (s *RelationService) CheckPermission(ctx context.Context, check models.RelationTuple, consistency models.Consistency) (bool, models.RelationRevision, error) {
+	if err := s.validateCheck(check); err != nil {
+		return false, 0, err
+	}
+
+	key := relationCacheKey(ctx, check)
+	if entry, ok := s.cache.get(key, consistency, time.Now()); ok {
+		return entry.allowed, entry.revision, nil
+	}
+
+	revision, err := s.currentRevision(ctx, consistency)
+	if err != nil {
+		return false, 0, err
+	}
+
+	allowed, err := s.checkCached(ctx, check, consistency, revision)
+	return allowed, revision, err
+}
+
+// LookupResources возвращает объекты пространства имен, на которые у субъекта есть разрешение.
+// Перебирает все объекты с кортежами - рассчитано на умеренные объемы.
+func (s *RelationService) LookupResources(ctx context.Context, namespace, permission string, subject models.SubjectRef, consistency models.Consistency) ([]string, models.RelationRevision, error) {
+	probe := models.RelationTuple{
+		Resource: models.ObjectRef{Namespace: namespace, ID: "_"},
+		Relation: permission,
+		Subject:  subject,
+	}
+	if err := s.validateCheck(probe); err != nil {
+		return nil, 0, err
+	}
+
+	revision, err := s.currentRevision(ctx, consistency)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	ids, err := s.relationRepo.ListRelationObjectIDs(ctx, namespace)
+	if err != nil {
+		return nil, 0, errors.Wrap(err, "failed to list candidate resources")
+	}
+
+	var resources []string
+	for _, id := range ids {
+		probe.Resource.ID = id
+
+		allowed, err := s.checkCached(ctx, probe, consistency, revision)
+		if err != nil {
+			return nil, 0, err
+		}
+		if allowed {
+			resources = append(resources, id)
+		}
+	}
+
+	return resources, revision, nil
+}
+
+func (s *RelationService) checkCached(ctx context.Context, check models.RelationTuple, consistency models.Consistency, revision models.RelationRevision) (bool, error) {
+	key := relationCacheKey(ctx, check)
+	now := time.Now()
+
+	if entry, ok := s.cache.get(key, consistency, now); ok {
+		return entry.allowed, nil
+	}
+
+	checker := &relationChecker{
+		repo:       s.relationRepo,
+		namespaces: s.namespaces,
+		visiting:   make(map[string]bool),
+	}
+	allowed, err := checker.check(ctx, check.Resource, check.Relation, check.Subject, 0)
+	if err != nil {
+		return false, err
+	}
+
+	// Ревизия прочитана до вычисления: результат не старше нее
+	s.cache.put(key, allowed, revision, now)
+	return allowed, nil
+}
+
+// Кеш общий для всех тенантов, поэтому тенант входит в ключ
+func relationCacheKey(ctx context.Context, check models.RelationTuple) string {
+	var tenantID int64
+	if tenant, ok := models.TenantFromContext(ctx); ok {
+		tenantID = tenant.ID
+	}
+	return strconv.FormatInt(tenantID, 10) + "/" + check.String()
+}
+
+// currentRevision читает ревизию хранилища; токен из будущего считается недействительным
+func (s *RelationService) currentRevision(ctx context.Context, consistency models.Consistency) (models.RelationRevision, error) {
+	revision, err := s.relationRepo.GetRelationRevision(ctx)
+	if err != nil {
+		return 0, errors.Wrap(err, "failed to get relation revision")
+	}
+	if consistency.AtLeastAsFresh > revision {
+		return 0, models.ErrInvalidConsistencyToken
+	}
+	return revision, nil
+}
+
+// validateTuple проверяет записываемый кортеж по схеме
+func (s *RelationService) validateTuple(tuple models.RelationTuple) error {
+	if err := s.validateCheck(tuple); err != nil {
+		return err
+	}
+	if !s.namespaces.relation(tuple.Resource.Namespace, tuple.Relation).allowsDirect() {
+		return models.ErrInvalidRelationship
+	}
+	return nil
+}
+
+func (s *RelationService) validateCheck(check models.RelationTuple) error {
+	if err := check.Validate(); err != nil {
+		return err
+	}
+
+	if s.namespaces.Namespaces[check.Resource.Namespace] == nil || s.namespaces.Namespaces[check.Subject.Object.Namespace] == nil {
+		return models.ErrUnknownNamespace
+	}
+	if s.namespaces.relation(check.Resource.Namespace, check.Relation) == nil {
+		return models.ErrUnknownRelation
+	}
+	if check.Subject.Relation != "" && s.namespaces.relation(check.Subject.Object.Namespace, check.Subject.Relation) == nil {
+		return models.ErrUnknownRelation
+	}
+	return nil
+}
+
+// relationChecker раскрывает правила схемы для одной проверки
+type relationChecker struct {
+	repo       repository.RelationRepository
+	namespaces *RelationNamespaces
+	// Узлы на текущем пути раскрытия - защита от циклов в данных
+	visiting map[string]bool
+}
+
+func (c *relationChecker) check(ctx context.Context, resource models.ObjectRef, relation string, subject models.SubjectRef, depth int) (bool, error) {
+	if depth > relationMaxDepth {
+		return false, models.ErrRelationDepthExceeded
+	}
+
+	// Множество содержит само себя: group:eng#member входит в group:eng#member
+	if subject.Relation == relation && subject.Object == resource {
+		return true, nil
+	}
+
+	// tuple_to_userset может привести в пространство имен без такого отношения
+	config := c.namespaces.relation(resource.Namespace, relation)
+	if config == nil {
+		return false, nil
+	}
+
+	node := resource.String() + "#" + relation
+	if c.visiting[node] {
+		return false, nil
+	}
+	c.visiting[node] = true
+	defer delete(c.visiting, node)
+
+	for _, rewrite := range config.rewrites() {
+		var (
+			allowed bool
+			err     error
+		)
+
+		switch {
+		case rewrite.This != nil:
+			allowed, err = c.checkDirect(ctx, resource, relation, subject, depth)
+		case rewrite.ComputedUserset != "":
+			allowed, err = c.check(ctx, resource, rewrite.ComputedUserset, subject, depth+1)
+		case rewrite.TupleToUserset != nil:
+			allowed, err = c.checkTupleToUserset(ctx, resource, rewrite.TupleToUserset, subject, depth)
+		}
+
+		if err != nil || allowed {
+			return allowed, err
+		}
+	}
+
+	return false, nil
+}
+
+func (c *relationChecker) checkDirect(ctx context.Context, resource models.ObjectRef, relation string, subject models.SubjectRef, depth int) (bool, error) {
+	subjects, err := c.repo.ListRelationSubjects(ctx, resource, relation)
+	if err != nil {
+		return false, err
+	}
+
+	for _, s := range subjects {
+		if s == subject {
+			return true, nil
+		}
+	}
+
+	// Кортежи с множествами (group:eng#member) раскрываем рекурсивно
+	for _, s := range subjects {
+		if s.Relation == "" {
+			continue
+		}
+		allowed, err := c.check(ctx, s.Object, s.Relation, subject, depth+1)
+		if err != nil || allowed {
+			return allowed, err
+		}
+	}
+
+	return false, nil
+}
+
+func (c *relationChecker) checkTupleToUserset(ctx context.Context, resource models.ObjectRef, ttu *tupleToUserset, subject models.SubjectRef, depth int) (bool, error) {
+	parents, err := c.repo.ListRelationSubjects(ctx, resource, ttu.Tupleset)
+	if err != nil {
+		return false, err
+	}
+
+	for _, parent := range parents {
+		if parent.Relation != "" {
+			continue
+		}
+		allowed, err := c.check(ctx, parent.Object, ttu.ComputedUserset, subject, depth+1)
+		if err != nil || allowed {
+			return allowed, err
+		}
+	}
+
+	return false, nil
+}
@@ -0,0 +1,209 @@
+package service
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+)
+
+// memoryRelationRepository хранит кортежи по тенантам, как Postgres-репозиторий
+type memoryRelationRepository struct {
+	tuples   map[int64][]models.RelationTuple
+	revision models.RelationRevision
+}
+
+func newMemoryRelationRepository() *memoryRelationRepository {
+	return &memoryRelationRepository{tuples: make(map[int64][]models.RelationTuple)}
+}
+
+func (r *memoryRelationRepository) tenant(ctx context.Context) (int64, error) {
+	tenant, ok := models.TenantFromContext(ctx)
+	if !ok {
+		return 0, models.ErrTenantRequired
+	}
+	return tenant.ID, nil
+}
+
+func (r *memoryRelationRepository) WriteRelationTuples(ctx context.Context, updates []models.RelationUpdate) (models.RelationRevision, error) {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	r.revision++
+	for _, update := range updates {
+		kept := r.tuples[tenant][:0]
+		for _, tuple := range r.tuples[tenant] {
+			if tuple != update.Tuple {
+				kept = append(kept, tuple)
+			}
+		}
+		if update.Operation == models.RelationTouch {
+			kept = append(kept, update.Tuple)
+		}
+		r.tuples[tenant] = kept
+	}
+	return r.revision, nil
+}
+
+func (r *memoryRelationRepository) ListRelationSubjects(ctx context.Context, resource models.ObjectRef, relation string) ([]models.SubjectRef, error) {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var subjects []models.SubjectRef
+	for _, tuple := range r.tuples[tenant] {
+		if tuple.Resource == resource && tuple.Relation == relation {
+			subjects = append(subjects, tuple.Subject)
+		}
+	}
+	return subjects, nil
+}
+
+func (r *memoryRelationRepository) ListRelationObjectIDs(ctx context.Context, namespace string) ([]string, error) {
+	tenant, err := r.tenant(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var ids []string
+	for _, tuple := range r.tuples[tenant] {
+		if tuple.Resource.Namespace == namespace && !seen[tuple.Resource.ID] {
+			seen[tuple.Resource.ID] = true
+			ids = append(ids, tuple.Resource.ID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *memoryRelationRepository) GetRelationRevision(ctx context.Context) (models.RelationRevision, error) {
+	return r.revision, nil
+}
+
+func newTestRelationService(t *testing.T, repo *memoryRelationRepository) *RelationService {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "namespaces.json")
+	schema := `{"namespaces": {
+		"user": {},
+		"document": {"relations": {
+			"owner": {},
+			"viewer": {"union": [{"this": {}}, {"computed_userset": "owner"}]}
+		}}
+	}}`
+	if err := os.WriteFile(path, []byte(schema), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	namespaces, err := LoadRelationNamespaces(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewRelationService(repo, namespaces, time.Minute)
+}
+
+func TestRelationServiceTenantIsolation(t *testing.T) {
+	repo := newMemoryRelationRepository()
+	service := newTestRelationService(t, repo)
+
+	tenantA := models.WithTenant(context.Background(), &models.Tenant{ID: 1, Slug: "a"})
+	tenantB := models.WithTenant(context.Background(), &models.Tenant{ID: 2, Slug: "b"})
+
+	owner := models.RelationTuple{
+		Resource: models.ObjectRef{Namespace: "document", ID: "readme"},
+		Relation: "owner",
+		Subject:  models.SubjectRef{Object: models.ObjectRef{Namespace: "user", ID: "1"}},
+	}
+	if _, err := service.WriteRelationships(tenantA, []models.RelationUpdate{{Operation: models.RelationTouch, Tuple: owner}}); err != nil {
+		t.Fatalf("WriteRelationships: %v", err)
+	}
+
+	check := owner
+	check.Relation = "viewer"
+
+	// Результат тенанта A попадает в кеш проверок
+	allowed, _, err := service.CheckPermission(tenantA, check, models.Consistency{})
+	if err != nil {
+		t.Fatalf("CheckPermission in tenant A: %v", err)
+	}
+	if !allowed {
+		t.Fatal("owner of tenant A is not a viewer")
+	}
+
+	// Тот же кортеж в тенанте B не должен прийти ни из хранилища, ни из кеша
+	allowed, _, err = service.CheckPermission(tenantB, check, models.Consistency{})
+	if err != nil {
+		t.Fatalf("CheckPermission in tenant B: %v", err)
+	}
+	if allowed {
+		t.Fatal("tenant B sees relation of tenant A")
+	}
+
+	resources, _, err := service.LookupResources(tenantB, "document", "viewer", check.Subject, models.Consistency{})
+	if err != nil {
+		t.Fatalf("LookupResources in tenant B: %v", err)
+	}
+	if len(resources) != 0 {
+		t.Fatalf("tenant B looks up resources of tenant A: %v", resources)
+	}
+
+	resources, _, err = service.LookupResources(tenantA, "document", "viewer", check.Subject, models.Consistency{})
+	if err != nil {
+		t.Fatalf("LookupResources in tenant A: %v", err)
+	}
+	if len(resources) != 1 || resources[0] != "readme" {
+		t.Fatalf("tenant A resources: %v", resources)
+	}
+}
+
+func TestRelationServiceCacheKeyedByTenant(t *testing.T) {
+	repo := newMemoryRelationRepository()
+	service := newTestRelationService(t, repo)
+
+	tenantA := models.WithTenant(context.Background(), &models.Tenant{ID: 1, Slug: "a"})
+	tenantB := models.WithTenant(context.Background(), &models.Tenant{ID: 2, Slug: "b"})
+
+	tuple := models.RelationTuple{
+		Resource: models.ObjectRef{Namespace: "document", ID: "plan"},
+		Relation: "viewer",
+		Subject:  models.SubjectRef{Object: models.ObjectRef{Namespace: "user", ID: "7"}},
+	}
+
+	// Отрицательный результат тенанта B кешируется раньше записи в тенанте A
+	if allowed, _, err := service.CheckPermission(tenantB, tuple, models.Consistency{}); err != nil || allowed {
+		t.Fatalf("CheckPermission in tenant B before write: %v, %v", allowed, err)
+	}
+
+	revision, err := service.WriteRelationships(tenantA, []models.RelationUpdate{{Operation: models.RelationTouch, Tuple: tuple}})
+	if err != nil {
+		t.Fatalf("WriteRelationships: %v", err)
+	}
+
+	// Кеш тенанта B не маскирует запись тенанта A
+	allowed, _, err := service.CheckPermission(tenantA, tuple, models.Consistency{AtLeastAsFresh: revision})
+	if err != nil {
+		t.Fatalf("CheckPermission in tenant A: %v", err)
+	}
+	if !allowed {
+		t.Fatal("tenant A got cached result of tenant B")
+	}
+
+	// А результат тенанта A не виден тенанту B
+	allowed, _, err = service.CheckPermission(tenantB, tuple, models.Consistency{AtLeastAsFresh: revision})
+	if err != nil {
+		t.Fatalf("CheckPermission in tenant B after write: %v", err)
+	}
+	if allowed {
+		t.Fatal("tenant B got cached result of tenant A")
+	}
+
+	if relationCacheKey(tenantA, tuple) == relationCacheKey(tenantB, tuple) {
+		t.Fatal("cache keys of different tenants collide")
+	}
+}
@@ -0,0 +1,102 @@
+package service
+
+import (
+	"context"
+	"math"
+	"strconv"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+
+	"github.com/pkg/errors"
+)
+
+// RiskSignal оценивает один признак попытки входа.
+// Возвращает силу сигнала от 0 (нет риска) до 1 (максимальный риск).
+type RiskSignal interface {
+	Name() string
+	Evaluate(ctx context.Context, attempt *models.LoginAttempt) (float64, error)
+}
+
+// Пороги балла, начиная с которых принимается решение
+type RiskThresholds struct {
+	Captcha float64
+	MFA     float64
+	Deny    float64
+}
+
+type RiskService struct {
+	signals    []RiskSignal
+	weights    map[string]float64
+	thresholds RiskThresholds
+}
+
+// NewRiskService собирает движок из сигналов. Вес сигнала - его вклад в балл
+// (0..100); сигналы с нулевым весом не вычисляются.
+func NewRiskService(weights map[string]float64, thresholds RiskThresholds, signals ...RiskSignal) *RiskService {
+	var enabled []RiskSignal
+	for _, signal := range signals {
+		if weights[signal.Name()] > 0 {
+			enabled = append(enabled, signal)
+		}
+	}
+
+	return &RiskService{
+		signals:    enabled,
+		weights:    weights,
+		thresholds: thresholds,
+	}
+}
+
+func (s *RiskService) Assess(ctx context.Context, attempt *models.LoginAttempt) (*models.RiskAssessment, error) {
+	assessment := &models.RiskAssessment{
+		Decision: models.RiskAllow,
+		Signals:  make(map[string]float64, len(s.signals)),
+	}
+
+	for _, signal := range s.signals {
+		strength, err := signal.Evaluate(ctx, attempt)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to evaluate risk signal %s", signal.Name())
+		}
+
+		strength = math.Max(0, math.Min(1, strength))
+		contribution := strength * s.weights[signal.Name()]
+
+		assessment.Signals[signal.Name()] = contribution
+		assessment.Score += contribution
+	}
+
+	assessment.Score = math.Min(100, assessment.Score)
+
+	switch {
+	case assessment.Score >= s.thresholds.Deny:
+		assessment.Decision = models.RiskDeny
+	case assessment.Score >= s.thresholds.MFA:
+		assessment.Decision = models.RiskRequireMFA
+	case assessment.Score >= s.thresholds.Captcha:
+		assessment.Decision = models.RiskRequireCaptcha
+	}
+
+	return assessment, nil
+}
+
+// riskAuditMetadata раскладывает оценку в метаданные события аудита
+func riskAuditMetadata(assessment *models.RiskAssessment, metadata map[string]string) map[string]string {
+	if metadata == nil {
+		metadata = map[string]string{}
+	}
+	if assessment == nil {
+		return metadata
+	}
+
+	metadata["risk_score"] = strconv.FormatFloat(assessment.Score, 'f', 1, 64)
+	metadata["risk_decision"] = string(assessment.Decision)
+
+	for name, value := range assessment.Signals {
+		if value > 0 {
+			metadata["risk_signal."+name] = strconv.FormatFloat(value, 'f', 1, 64)
+		}
+	}
+
+	return metadata
+}
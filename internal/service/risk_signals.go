@@ -0,0 +1,179 @@
+package service
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+)
+
+// Имена встроенных сигналов - по ним задаются веса в конфигурации
+const (
+	SignalFailedVelocity   = "failed_velocity"
+	SignalNewDevice        = "new_device"
+	SignalIPReputation     = "ip_reputation"
+	SignalTimeOfDay        = "time_of_day"
+	SignalImpossibleTravel = "impossible_travel"
+)
+
+// FailedVelocitySignal - частота неверных паролей с IP за окно
+type FailedVelocitySignal struct {
+	auditRepo repository.AuditRepository
+	window    time.Duration
+	limit     int
+}
+
+func NewFailedVelocitySignal(auditRepo repository.AuditRepository, window time.Duration, limit int) *FailedVelocitySignal {
+	if limit <= 0 {
+		limit = 1
+	}
+	return &FailedVelocitySignal{auditRepo: auditRepo, window: window, limit: limit}
+}
+
+func (s *FailedVelocitySignal) Name() string { return SignalFailedVelocity }
+
+func (s *FailedVelocitySignal) Evaluate(ctx context.Context, attempt *models.LoginAttempt) (float64, error) {
+	failures, err := s.auditRepo.CountFailedLogins(ctx, attempt.Client.IP, attempt.Time.Add(-s.window))
+	if err != nil {
+		return 0, err
+	}
+	return float64(failures) / float64(s.limit), nil
+}
+
+// NewDeviceSignal - вход из незнакомого контекста
+type NewDeviceSignal struct {
+	deviceRepo repository.DeviceRepository
+}
+
+func NewNewDeviceSignal(deviceRepo repository.DeviceRepository) *NewDeviceSignal {
+	return &NewDeviceSignal{deviceRepo: deviceRepo}
+}
+
+func (s *NewDeviceSignal) Name() string { return SignalNewDevice }
+
+func (s *NewDeviceSignal) Evaluate(ctx context.Context, attempt *models.LoginAttempt) (float64, error) {
+	if attempt.User == nil {
+		return 0, nil
+	}
+
+	device, err := s.deviceRepo.GetKnownDevice(ctx, attempt.User.ID, attempt.Client.Fingerprint())
+	if err != nil || device != nil {
+		return 0, err
+	}
+
+	// Первый вход пользователя - сравнивать не с чем
+	known, err := s.deviceRepo.CountKnownDevices(ctx, attempt.User.ID)
+	if err != nil || known == 0 {
+		return 0, err
+	}
+
+	return 1, nil
+}
+
+// IPReputationSignal - IP из локального блок-листа (адреса и CIDR, по одному в строке)
+type IPReputationSignal struct {
+	networks []*net.IPNet
+}
+
+func LoadIPReputationSignal(path string) (*IPReputationSignal, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ip blocklist: %w", err)
+	}
+	defer file.Close()
+
+	signal := &IPReputationSignal{}
+	scanner := bufio.NewScanner(file)
+	for line := 1; scanner.Scan(); line++ {
+		entry := strings.TrimSpace(scanner.Text())
+		if i := strings.IndexByte(entry, '#'); i >= 0 {
+			entry = strings.TrimSpace(entry[:i])
+		}
+		if entry == "" {
+			continue
+		}
+
+		if !strings.Contains(entry, "/") {
+			if strings.Contains(entry, ":") {
+				entry += "/128"
+			} else {
+				entry += "/32"
+			}
+		}
+
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid ip blocklist entry on line %d: %w", line, err)
+		}
+		signal.networks = append(signal.networks, network)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read ip blocklist: %w", err)
+	}
+
+	return signal, nil
+}
+
+func (s *IPReputationSignal) Name() string { return SignalIPReputation }
+
+func (s *IPReputationSignal) Evaluate(ctx context.Context, attempt *models.LoginAttempt) (float64, error) {
+	ip := net.ParseIP(attempt.Client.IP)
+	if ip == nil {
+		return 0, nil
+	}
+
+	for _, network := range s.networks {
+		if network.Contains(ip) {
+			return 1, nil
+		}
+	}
+	return 0, nil
+}
+
+// TimeOfDaySignal - вход в необычные часы [startHour, endHour) по часовому поясу location
+type TimeOfDaySignal struct {
+	startHour int
+	endHour   int
+	location  *time.Location
+}
+
+func NewTimeOfDaySignal(startHour, endHour int, location *time.Location) *TimeOfDaySignal {
+	return &TimeOfDaySignal{startHour: startHour, endHour: endHour, location: location}
+}
+
+func (s *TimeOfDaySignal) Name() string { return SignalTimeOfDay }
+
+func (s *TimeOfDaySignal) Evaluate(ctx context.Context, attempt *models.LoginAttempt) (float64, error) {
+	hour := attempt.Time.In(s.location).Hour()
+
+	// Окно может переходить через полночь, например 22-6
+	inWindow := hour >= s.startHour && hour < s.endHour
+	if s.startHour > s.endHour {
+		inWindow = hour >= s.startHour || hour < s.endHour
+	}
+
+	if inWindow {
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// ImpossibleTravelSignal - вход из точки, до которой невозможно добраться
+// с момента предыдущего входа. Признак вычисляет GeoService при входе.
+type ImpossibleTravelSignal struct{}
+
+func (ImpossibleTravelSignal) Name() string { return SignalImpossibleTravel }
+
+func (ImpossibleTravelSignal) Evaluate(ctx context.Context, attempt *models.LoginAttempt) (float64, error) {
+	if attempt.ImpossibleTravel {
+		return 1, nil
+	}
+	return 0, nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/internal/repository"
+
+	"github.com/pkg/errors"
+)
+
+// Тенант определяется на каждом запросе, поэтому ответы базы кешируются ненадолго
+const tenantCacheTTL = 30 * time.Second
+
+type TenantService struct {
+	tenantRepo repository.TenantRepository
+
+	mu     sync.Mutex
+	cached map[string]tenantCacheEntry
+}
+
+type tenantCacheEntry struct {
+	tenant   *models.Tenant
+	cachedAt time.Time
+}
+
+func NewTenantService(tenantRepo repository.TenantRepository) *TenantService {
+	return &TenantService{
+		tenantRepo: tenantRepo,
+		cached:     make(map[string]tenantCacheEntry),
+	}
+}
+
+// Resolve находит активный тенант по slug; неизвестный и отключенный тенант неотличимы
+func (s *TenantService) Resolve(ctx context.Context, slug string) (*models.Tenant, error) {
+	slug = strings.ToLower(strings.TrimSpace(slug))
+	if !models.ValidTenantSlug(slug) {
+		return nil, models.ErrTenantNotFound
+	}
+
+	now := time.Now()
+
+	s.mu.Lock()
+	entry, ok := s.cached[slug]
+	s.mu.Unlock()
+
+	if !ok || now.Sub(entry.cachedAt) >= tenantCacheTTL {
+		tenant, err := s.tenantRepo.GetTenantBySlug(ctx, slug)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to get tenant")
+		}
+
+		entry = tenantCacheEntry{tenant: tenant, cachedAt: now}
+		s.mu.Lock()
+		s.cached[slug] = entry
+		s.mu.Unlock()
+	}
+
+	if entry.tenant == nil || !entry.tenant.IsActive {
+		return nil, models.ErrTenantNotFound
+	}
+	return entry.tenant, nil
+}
+
+func (s *TenantService) CreateTenant(ctx context.Context, tenant *models.Tenant) (*models.Tenant, error) {
+	tenant.Slug = strings.ToLower(strings.TrimSpace(tenant.Slug))
+	tenant.Name = strings.TrimSpace(tenant.Name)
+	if !models.ValidTenantSlug(tenant.Slug) {
+		return nil, models.ErrInvalidTenantSlug
+	}
+	if tenant.Name == "" {
+		tenant.Name = tenant.Slug
+	}
+	tenant.IsActive = true
+	tenant.CreatedAt = time.Now()
+
+	if err := s.tenantRepo.CreateTenant(ctx, tenant); err != nil {
+		if err == models.ErrTenantAlreadyExists {
+			return nil, err
+		}
+		return nil, errors.Wrap(err, "failed to create tenant")
+	}
+
+	// Отрицательный ответ мог остаться в кеше
+	s.mu.Lock()
+	delete(s.cached, tenant.Slug)
+	s.mu.Unlock()
+
+	return tenant, nil
+}
+
+func (s *TenantService) ListTenants(ctx context.Context) ([]*models.Tenant, error) {
+	tenants, err := s.tenantRepo.ListTenants(ctx)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list tenants")
+	}
+	return tenants, nil
+}
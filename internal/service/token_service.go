@@ -0,0 +1,403 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/DailyPepper/auth-service/internal/models"
+	"github.com/DailyPepper/auth-service/pkg/signing"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+type TokenService struct {
+	signer    *signing.Signer
+	issuer    string
+	accessTTL time.Duration
+}
+
+func NewTokenService(signer *signing.Signer, issuer string, accessTTL time.Duration) *TokenService {
+	return &TokenService{
+		signer:    signer,
+		issuer:    issuer,
+		accessTTL: accessTTL,
+	}
+}
+
+type accessClaims struct {
+	jwt.RegisteredClaims
+	SessionID   string   `json:"sid"`
+	TenantID    int64    `json:"tenant_id"`
+	Email       string   `json:"email"`
+	Roles       []string `json:"roles,omitempty"`
+	Permissions []string `json:"permissions,omitempty"`
+
+	// Активная организация и роль в ней
+	OrgID   int64  `json:"org_id,omitempty"`
+	OrgRole string `json:"org_role,omitempty"`
+
+	// Токен, выданный клиенту OAuth от имени пользователя
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+
+	// Кто действует от имени пользователя (токен получен обменом)
+	Act *models.Actor `json:"act,omitempty"`
+
+	// Ключ клиента, к которому привязан токен (DPoP)
+	Cnf *confirmation `json:"cnf,omitempty"`
+}
+
+// confirmation - claim cnf с отпечатком ключа DPoP (RFC 9449, 6.1)
+type confirmation struct {
+	JKT string `json:"jkt"`
+}
+
+// dpopConfirmation - cnf для токена, выданного с DPoP-доказательством; nil для bearer-токена
+func dpopConfirmation(jkt string) *confirmation {
+	if jkt == "" {
+		return nil
+	}
+	return &confirmation{JKT: jkt}
+}
+
+// IssueAccessToken выпускает JWT, привязанный к сессии и, если задан jkt, к ключу DPoP.
+// Роли, разрешения и роль в организации (membership может быть nil) - снимок на момент входа.
+func (s *TokenService) IssueAccessToken(user *models.User, access *models.UserAccess, membership *models.OrganizationMembership, sessionID, jkt string, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(s.accessTTL)
+
+	claims := accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		SessionID:   sessionID,
+		TenantID:    user.TenantID,
+		Email:       user.Email,
+		Roles:       access.Roles,
+		Permissions: access.Permissions,
+		Cnf:         dpopConfirmation(jkt),
+	}
+	if membership != nil {
+		claims.OrgID = membership.OrganizationID
+		claims.OrgRole = string(membership.Role)
+	}
+
+	signed, err := s.sign(claims, "")
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to sign access token")
+	}
+
+	return signed, expiresAt, nil
+}
+
+// IssueGrantAccessToken выпускает токен пользователя для клиента OAuth: aud - client_id,
+// области из согласия. Токен привязан к браузерной сессии; ролей в нем нет, а из
+// разрешений остаются только выданные областями - права пользователя клиенту не передаются.
+func (s *TokenService) IssueGrantAccessToken(user *models.User, access *models.UserAccess, client *models.OAuthClient, grant *models.OAuthGrant, jkt string, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(client.AccessTokenTTL)
+
+	claims := accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Audience:  jwt.ClaimStrings{client.ClientID},
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+		},
+		SessionID:   grant.SessionID,
+		TenantID:    user.TenantID,
+		Email:       user.Email,
+		Permissions: scopedPermissions(access.Permissions, grant.Scopes),
+		ClientID:    client.ClientID,
+		Scope:       strings.Join(grant.Scopes, " "),
+		Cnf:         dpopConfirmation(jkt),
+	}
+
+	signed, err := s.sign(claims, "at+jwt")
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to sign access token")
+	}
+
+	return signed, expiresAt, nil
+}
+
+// IssueExchangedToken выпускает токен, полученный обменом (RFC 8693): тот же пользователь
+// и сессия, что у subject, но только для audience, с суженными областями и claim act.
+// Ролей нет, из разрешений subject остаются только выданные области; срок не превышает его срока.
+func (s *TokenService) IssueExchangedToken(user *models.User, subject *models.TokenClaims, client *models.OAuthClient, audience, scopes []string, actor *models.Actor, jkt string, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(client.AccessTokenTTL)
+	if subject.ExpiresAt.Before(expiresAt) {
+		expiresAt = subject.ExpiresAt
+	}
+
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims := accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			Audience:  audience,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        jti,
+		},
+		SessionID:   subject.SessionID,
+		TenantID:    user.TenantID,
+		Email:       user.Email,
+		Permissions: scopedPermissions(subject.Permissions, scopes),
+		OrgID:       subject.OrganizationID,
+		OrgRole:     string(subject.OrganizationRole),
+		ClientID:    client.ClientID,
+		Scope:       strings.Join(scopes, " "),
+		Act:         actor,
+		Cnf:         dpopConfirmation(jkt),
+	}
+
+	signed, err := s.sign(claims, "at+jwt")
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to sign exchanged token")
+	}
+
+	return signed, expiresAt, nil
+}
+
+// IssueImpersonationToken выпускает токен пользователя для администратора, вошедшего
+// от его имени: act называет администратора, срок совпадает со сроком сессии.
+func (s *TokenService) IssueImpersonationToken(user, admin *models.User, access *models.UserAccess, sessionID string, now, expiresAt time.Time) (string, error) {
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", err
+	}
+
+	claims := accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   strconv.FormatInt(user.ID, 10),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        jti,
+		},
+		SessionID:   sessionID,
+		TenantID:    user.TenantID,
+		Email:       user.Email,
+		Roles:       access.Roles,
+		Permissions: access.Permissions,
+		Act:         &models.Actor{Subject: strconv.FormatInt(admin.ID, 10)},
+	}
+
+	signed, err := s.sign(claims, "")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign impersonation token")
+	}
+
+	return signed, nil
+}
+
+// scopedPermissions - разрешения, которые токену явно выданы областями. Токен для
+// другой аудитории или стороннего клиента не должен нести все права пользователя.
+func scopedPermissions(permissions, scopes []string) []string {
+	var granted []string
+	for _, permission := range permissions {
+		if containsScope(scopes, permission) {
+			granted = append(granted, permission)
+		}
+	}
+	return granted
+}
+
+// Из id_token_hint нужны только клиент и сессия
+type idTokenHintClaims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid"`
+}
+
+// IssueIDToken выпускает ID-токен OpenID Connect. issuer - внешний URL провайдера,
+// он может отличаться от iss внутренних access-токенов.
+func (s *TokenService) IssueIDToken(issuer string, user *models.User, grant *models.OAuthGrant, accessToken string, now time.Time) (string, error) {
+	claims := jwt.MapClaims{
+		"iss":       issuer,
+		"sub":       strconv.FormatInt(user.ID, 10),
+		"aud":       grant.ClientID,
+		"iat":       now.Unix(),
+		"exp":       now.Add(s.accessTTL).Unix(),
+		"auth_time": grant.AuthTime.Unix(),
+		"sid":       grant.SessionID,
+	}
+	if grant.Nonce != "" {
+		claims["nonce"] = grant.Nonce
+	}
+	if accessToken != "" {
+		sum := sha256.Sum256([]byte(accessToken))
+		claims["at_hash"] = base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2])
+	}
+	for name, value := range models.StandardClaims(user, grant.Scopes) {
+		claims[name] = value
+	}
+
+	signed, err := s.sign(claims, "")
+	if err != nil {
+		return "", errors.Wrap(err, "failed to sign id token")
+	}
+	return signed, nil
+}
+
+// ParseIDTokenHint проверяет подпись ID-токена из id_token_hint. Срок действия
+// не проверяется: при выходе подсказка обычно уже просрочена.
+func (s *TokenService) ParseIDTokenHint(issuer, token string) (clientID, sessionID string, err error) {
+	var claims idTokenHintClaims
+
+	_, err = jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		return s.signer.PublicKey(), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithoutClaimsValidation(),
+	)
+	if err != nil || claims.Issuer != issuer || len(claims.Audience) != 1 {
+		return "", "", models.ErrInvalidToken
+	}
+
+	return claims.Audience[0], claims.SessionID, nil
+}
+
+type clientClaims struct {
+	jwt.RegisteredClaims
+	ClientID string        `json:"client_id"`
+	TenantID int64         `json:"tenant_id"`
+	Scope    string        `json:"scope,omitempty"`
+	Cnf      *confirmation `json:"cnf,omitempty"`
+}
+
+// IssueClientToken выпускает токен клиента без пользователя: sub - client_id (RFC 9068)
+func (s *TokenService) IssueClientToken(client *models.OAuthClient, scopes, audience []string, jkt string, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(client.AccessTokenTTL)
+
+	jti, err := randomToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	claims := clientClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.issuer,
+			Subject:   client.ClientID,
+			Audience:  audience,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			ID:        jti,
+		},
+		ClientID: client.ClientID,
+		TenantID: client.TenantID,
+		Scope:    strings.Join(scopes, " "),
+		Cnf:      dpopConfirmation(jkt),
+	}
+
+	signed, err := s.sign(claims, "at+jwt")
+	if err != nil {
+		return "", time.Time{}, errors.Wrap(err, "failed to sign client token")
+	}
+
+	return signed, expiresAt, nil
+}
+
+// ParseClientToken проверяет токен клиента (client_credentials) и возвращает client_id и тенант
+func (s *TokenService) ParseClientToken(token string) (string, int64, error) {
+	var claims clientClaims
+
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		return s.signer.PublicKey(), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	// У токена клиента sub совпадает с client_id, у токенов пользователя - нет
+	if err != nil || claims.ClientID == "" || claims.Subject != claims.ClientID || claims.TenantID == 0 {
+		return "", 0, models.ErrInvalidToken
+	}
+	return claims.ClientID, claims.TenantID, nil
+}
+
+// ParseAccessToken проверяет подпись и срок действия токена
+func (s *TokenService) ParseAccessToken(token string) (*models.TokenClaims, error) {
+	var claims accessClaims
+
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (interface{}, error) {
+		return s.signer.PublicKey(), nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg()}),
+		jwt.WithIssuer(s.issuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return nil, models.ErrInvalidToken
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil || claims.SessionID == "" || claims.TenantID == 0 {
+		return nil, models.ErrInvalidToken
+	}
+
+	parsed := &models.TokenClaims{
+		UserID:      userID,
+		TenantID:    claims.TenantID,
+		SessionID:   claims.SessionID,
+		Email:       claims.Email,
+		Roles:       claims.Roles,
+		Permissions: claims.Permissions,
+		IssuedAt:    claims.IssuedAt.Time,
+		ExpiresAt:   claims.ExpiresAt.Time,
+
+		OrganizationID:   claims.OrgID,
+		OrganizationRole: models.OrganizationRole(claims.OrgRole),
+
+		ClientID: claims.ClientID,
+		Scopes:   strings.Fields(claims.Scope),
+		Audience: claims.Audience,
+		Actor:    claims.Act,
+	}
+	if claims.Cnf != nil {
+		parsed.DPoPThumbprint = claims.Cnf.JKT
+	}
+	return parsed, nil
+}
+
+// JWKS - открытые ключи для проверки токенов сторонними сервисами
+func (s *TokenService) JWKS() []signing.JWK {
+	return []signing.JWK{s.signer.JWK()}
+}
+
+// sign подписывает claims ключом сервиса; typ - необязательный заголовок типа токена
+func (s *TokenService) sign(claims jwt.Claims, typ string) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodEdDSA, claims)
+	token.Header["kid"] = s.signer.KeyID()
+	if typ != "" {
+		token.Header["typ"] = typ
+	}
+	return token.SignedString(s.signer.PrivateKey())
+}
+
+// Случайный токен для ссылок, сессий и refresh-токенов
+func randomToken(size int) (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", errors.Wrap(err, "failed to generate random token")
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// В базе храним только хеш секретных токенов
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
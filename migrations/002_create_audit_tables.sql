@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE audit_events (
+    id BIGSERIAL PRIMARY KEY,
+    event_type VARCHAR(100) NOT NULL,
+    user_id INTEGER,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    metadata JSONB NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL,
+    prev_hash CHAR(64) NOT NULL,
+    hash CHAR(64) NOT NULL UNIQUE
+);
+
+CREATE TABLE audit_checkpoints (
+    id BIGSERIAL PRIMARY KEY,
+    event_id BIGINT NOT NULL REFERENCES audit_events(id),
+    event_hash CHAR(64) NOT NULL,
+    key_id VARCHAR(64) NOT NULL,
+    signature BYTEA NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_audit_checkpoints_event_id ON audit_checkpoints(event_id);
+
+-- +goose Down
+DROP TABLE audit_checkpoints;
+DROP TABLE audit_events;
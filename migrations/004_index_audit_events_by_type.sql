@@ -0,0 +1,5 @@
+-- +goose Up
+CREATE INDEX idx_audit_events_type_created_at ON audit_events(event_type, created_at);
+
+-- +goose Down
+DROP INDEX idx_audit_events_type_created_at;
@@ -0,0 +1,20 @@
+-- +goose Up
+ALTER TABLE sessions
+    ADD COLUMN country_code VARCHAR(2),
+    ADD COLUMN country VARCHAR(100),
+    ADD COLUMN city VARCHAR(100),
+    ADD COLUMN latitude DOUBLE PRECISION,
+    ADD COLUMN longitude DOUBLE PRECISION,
+    ADD COLUMN impossible_travel BOOLEAN NOT NULL DEFAULT false;
+
+CREATE INDEX idx_sessions_user_id_created_at ON sessions(user_id, created_at DESC);
+
+-- +goose Down
+DROP INDEX idx_sessions_user_id_created_at;
+ALTER TABLE sessions
+    DROP COLUMN country_code,
+    DROP COLUMN country,
+    DROP COLUMN city,
+    DROP COLUMN latitude,
+    DROP COLUMN longitude,
+    DROP COLUMN impossible_travel;
@@ -0,0 +1,71 @@
+-- +goose Up
+CREATE TABLE roles (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE permissions (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE role_permissions (
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    permission_id INTEGER NOT NULL REFERENCES permissions(id) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission_id)
+);
+
+CREATE TABLE user_roles (
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    role_id INTEGER NOT NULL REFERENCES roles(id) ON DELETE CASCADE,
+    granted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    granted_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    PRIMARY KEY (user_id, role_id)
+);
+
+CREATE INDEX idx_user_roles_role_id ON user_roles(role_id);
+
+INSERT INTO permissions (name, description) VALUES
+    ('profile:read', 'Read own profile'),
+    ('profile:write', 'Update own profile'),
+    ('sessions:read', 'List own sessions'),
+    ('sessions:revoke', 'Revoke own sessions'),
+    ('users:read', 'Read other users and their permissions'),
+    ('roles:manage', 'Create roles, grant and revoke them');
+
+INSERT INTO roles (name, description) VALUES
+    ('user', 'Default role for registered users'),
+    ('admin', 'Full access');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'user' AND p.name IN ('profile:read', 'profile:write', 'sessions:read', 'sessions:revoke');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin';
+
+-- Переносим старую колонку users.role
+INSERT INTO user_roles (user_id, role_id)
+SELECT u.id, r.id FROM users u
+JOIN roles r ON r.name = CASE WHEN u.role = 'admin' THEN 'admin' ELSE 'user' END;
+
+ALTER TABLE users DROP COLUMN role;
+
+-- +goose Down
+ALTER TABLE users ADD COLUMN role VARCHAR(50) DEFAULT 'user';
+
+UPDATE users SET role = 'admin'
+WHERE id IN (
+    SELECT ur.user_id FROM user_roles ur
+    JOIN roles r ON r.id = ur.role_id
+    WHERE r.name = 'admin'
+);
+
+DROP TABLE user_roles;
+DROP TABLE role_permissions;
+DROP TABLE permissions;
+DROP TABLE roles;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE relation_tuples (
+    namespace VARCHAR(64) NOT NULL,
+    object_id VARCHAR(255) NOT NULL,
+    relation VARCHAR(64) NOT NULL,
+    subject_namespace VARCHAR(64) NOT NULL,
+    subject_id VARCHAR(255) NOT NULL,
+    -- Пустая строка - прямой субъект, иначе множество subject#relation
+    subject_relation VARCHAR(64) NOT NULL DEFAULT '',
+    created_revision BIGINT NOT NULL,
+    PRIMARY KEY (namespace, object_id, relation, subject_namespace, subject_id, subject_relation)
+);
+
+-- Единственная строка со счетчиком ревизий: запись кортежей обновляет ее в своей
+-- транзакции, поэтому читатели видят только закоммиченные ревизии
+CREATE TABLE relation_revision (
+    id BOOLEAN PRIMARY KEY DEFAULT true CHECK (id),
+    revision BIGINT NOT NULL
+);
+
+INSERT INTO relation_revision (id, revision) VALUES (true, 0);
+
+-- +goose Down
+DROP TABLE relation_revision;
+DROP TABLE relation_tuples;
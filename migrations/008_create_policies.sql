@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE policies (
+    id SERIAL PRIMARY KEY,
+    name VARCHAR(100) NOT NULL UNIQUE,
+    description TEXT NOT NULL DEFAULT '',
+    expression TEXT NOT NULL,
+    effect VARCHAR(10) NOT NULL CHECK (effect IN ('allow', 'deny')),
+    actions TEXT[] NOT NULL,
+    priority INTEGER NOT NULL DEFAULT 0,
+    enabled BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_policies_actions ON policies USING GIN (actions);
+
+INSERT INTO permissions (name, description) VALUES
+    ('policies:manage', 'Create, update and delete authorization policies');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND p.name = 'policies:manage';
+
+-- +goose Down
+DELETE FROM permissions WHERE name = 'policies:manage';
+DROP TABLE policies;
@@ -0,0 +1,79 @@
+-- +goose Up
+CREATE TABLE tenants (
+    id SERIAL PRIMARY KEY,
+    slug VARCHAR(63) NOT NULL UNIQUE,
+    name VARCHAR(255) NOT NULL,
+    is_active BOOLEAN NOT NULL DEFAULT true,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+-- Все существующие данные переезжают в тенант по умолчанию
+INSERT INTO tenants (slug, name) VALUES ('default', 'Default');
+
+ALTER TABLE users ADD COLUMN tenant_id INTEGER REFERENCES tenants(id);
+UPDATE users SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default');
+ALTER TABLE users ALTER COLUMN tenant_id SET NOT NULL;
+-- Email уникален внутри тенанта, а не глобально
+ALTER TABLE users DROP CONSTRAINT users_email_key;
+ALTER TABLE users ADD CONSTRAINT users_tenant_id_email_key UNIQUE (tenant_id, email);
+
+ALTER TABLE sessions ADD COLUMN tenant_id INTEGER REFERENCES tenants(id);
+UPDATE sessions SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default');
+ALTER TABLE sessions ALTER COLUMN tenant_id SET NOT NULL;
+CREATE INDEX idx_sessions_tenant_id_user_id ON sessions(tenant_id, user_id);
+
+ALTER TABLE known_devices ADD COLUMN tenant_id INTEGER REFERENCES tenants(id);
+UPDATE known_devices SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default');
+ALTER TABLE known_devices ALTER COLUMN tenant_id SET NOT NULL;
+
+ALTER TABLE password_reset_tokens ADD COLUMN tenant_id INTEGER REFERENCES tenants(id);
+UPDATE password_reset_tokens SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default');
+ALTER TABLE password_reset_tokens ALTER COLUMN tenant_id SET NOT NULL;
+
+ALTER TABLE policies ADD COLUMN tenant_id INTEGER REFERENCES tenants(id);
+UPDATE policies SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default');
+ALTER TABLE policies ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE policies DROP CONSTRAINT policies_name_key;
+ALTER TABLE policies ADD CONSTRAINT policies_tenant_id_name_key UNIQUE (tenant_id, name);
+
+ALTER TABLE relation_tuples ADD COLUMN tenant_id INTEGER REFERENCES tenants(id);
+UPDATE relation_tuples SET tenant_id = (SELECT id FROM tenants WHERE slug = 'default');
+ALTER TABLE relation_tuples ALTER COLUMN tenant_id SET NOT NULL;
+ALTER TABLE relation_tuples DROP CONSTRAINT relation_tuples_pkey;
+ALTER TABLE relation_tuples ADD PRIMARY KEY (tenant_id, namespace, object_id, relation, subject_namespace, subject_id, subject_relation);
+
+-- Роли без тенанта (user, admin) встроенные и видны во всех тенантах
+ALTER TABLE roles ADD COLUMN tenant_id INTEGER REFERENCES tenants(id) ON DELETE CASCADE;
+ALTER TABLE roles DROP CONSTRAINT roles_name_key;
+CREATE UNIQUE INDEX roles_tenant_id_name_key ON roles (COALESCE(tenant_id, 0), name);
+
+-- Старые записи аудита не трогаем: tenant_id входит в хеш записи
+ALTER TABLE audit_events ADD COLUMN tenant_id INTEGER REFERENCES tenants(id);
+
+-- +goose Down
+ALTER TABLE audit_events DROP COLUMN tenant_id;
+
+DELETE FROM roles WHERE tenant_id IS NOT NULL;
+DROP INDEX roles_tenant_id_name_key;
+ALTER TABLE roles DROP COLUMN tenant_id;
+ALTER TABLE roles ADD CONSTRAINT roles_name_key UNIQUE (name);
+
+ALTER TABLE relation_tuples DROP CONSTRAINT relation_tuples_pkey;
+ALTER TABLE relation_tuples DROP COLUMN tenant_id;
+ALTER TABLE relation_tuples ADD PRIMARY KEY (namespace, object_id, relation, subject_namespace, subject_id, subject_relation);
+
+ALTER TABLE policies DROP CONSTRAINT policies_tenant_id_name_key;
+ALTER TABLE policies DROP COLUMN tenant_id;
+ALTER TABLE policies ADD CONSTRAINT policies_name_key UNIQUE (name);
+
+ALTER TABLE password_reset_tokens DROP COLUMN tenant_id;
+ALTER TABLE known_devices DROP COLUMN tenant_id;
+
+DROP INDEX idx_sessions_tenant_id_user_id;
+ALTER TABLE sessions DROP COLUMN tenant_id;
+
+ALTER TABLE users DROP CONSTRAINT users_tenant_id_email_key;
+ALTER TABLE users DROP COLUMN tenant_id;
+ALTER TABLE users ADD CONSTRAINT users_email_key UNIQUE (email);
+
+DROP TABLE tenants;
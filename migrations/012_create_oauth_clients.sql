@@ -0,0 +1,23 @@
+-- +goose Up
+-- Клиенты OAuth 2.0: секрет хранится только хешем, для private_key_jwt - публичный ключ
+CREATE TABLE oauth_clients (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id),
+    client_id VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    auth_method VARCHAR(32) NOT NULL CHECK (auth_method IN ('client_secret', 'private_key_jwt')),
+    secret_hash VARCHAR(64),
+    public_key_pem TEXT,
+    grant_types TEXT[] NOT NULL DEFAULT '{}',
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    audiences TEXT[] NOT NULL DEFAULT '{}',
+    access_token_ttl_seconds INTEGER NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    disabled_at TIMESTAMPTZ,
+    UNIQUE (tenant_id, client_id),
+    CHECK ((auth_method = 'client_secret' AND secret_hash IS NOT NULL)
+        OR (auth_method = 'private_key_jwt' AND public_key_pem IS NOT NULL))
+);
+
+-- +goose Down
+DROP TABLE oauth_clients;
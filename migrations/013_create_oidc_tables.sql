@@ -0,0 +1,73 @@
+-- +goose Up
+-- Адреса возврата для authorization code flow и end-session
+ALTER TABLE oauth_clients
+    ADD COLUMN redirect_uris TEXT[] NOT NULL DEFAULT '{}',
+    ADD COLUMN post_logout_redirect_uris TEXT[] NOT NULL DEFAULT '{}';
+
+-- Публичные клиенты (SPA, мобильные приложения) не аутентифицируются и полагаются на PKCE
+ALTER TABLE oauth_clients DROP CONSTRAINT oauth_clients_auth_method_check;
+ALTER TABLE oauth_clients DROP CONSTRAINT oauth_clients_check;
+ALTER TABLE oauth_clients ADD CONSTRAINT oauth_clients_auth_method_check
+    CHECK (auth_method IN ('client_secret', 'private_key_jwt', 'none'));
+ALTER TABLE oauth_clients ADD CONSTRAINT oauth_clients_credentials_check
+    CHECK ((auth_method = 'client_secret' AND secret_hash IS NOT NULL)
+        OR (auth_method = 'private_key_jwt' AND public_key_pem IS NOT NULL)
+        OR auth_method = 'none');
+
+-- Одноразовые коды авторизации; хранится только хеш
+CREATE TABLE oauth_authorization_codes (
+    code_hash VARCHAR(64) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id),
+    client_id VARCHAR(64) NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id VARCHAR(64) NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    redirect_uri TEXT NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    nonce TEXT NOT NULL DEFAULT '',
+    code_challenge VARCHAR(128) NOT NULL,
+    auth_time TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+-- Refresh-токены клиентов: живут не дольше браузерной сессии и ротируются при каждом использовании
+CREATE TABLE oauth_refresh_tokens (
+    token_hash VARCHAR(64) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id),
+    client_id VARCHAR(64) NOT NULL,
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    session_id VARCHAR(64) NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    auth_time TIMESTAMPTZ NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_oauth_refresh_tokens_session_id ON oauth_refresh_tokens(session_id);
+
+-- Согласие пользователя на выдачу областей клиенту
+CREATE TABLE oauth_consents (
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id),
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    client_id VARCHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    granted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (tenant_id, user_id, client_id)
+);
+
+-- +goose Down
+DROP TABLE oauth_consents;
+DROP TABLE oauth_refresh_tokens;
+DROP TABLE oauth_authorization_codes;
+
+DELETE FROM oauth_clients WHERE auth_method = 'none';
+ALTER TABLE oauth_clients DROP CONSTRAINT oauth_clients_credentials_check;
+ALTER TABLE oauth_clients DROP CONSTRAINT oauth_clients_auth_method_check;
+ALTER TABLE oauth_clients ADD CONSTRAINT oauth_clients_auth_method_check
+    CHECK (auth_method IN ('client_secret', 'private_key_jwt'));
+ALTER TABLE oauth_clients ADD CONSTRAINT oauth_clients_check
+    CHECK ((auth_method = 'client_secret' AND secret_hash IS NOT NULL)
+        OR (auth_method = 'private_key_jwt' AND public_key_pem IS NOT NULL));
+ALTER TABLE oauth_clients DROP COLUMN post_logout_redirect_uris, DROP COLUMN redirect_uris;
@@ -0,0 +1,50 @@
+-- +goose Up
+-- Внешние провайдеры входа (Google, Microsoft, корпоративные Okta/Azure AD)
+CREATE TABLE identity_providers (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id),
+    slug VARCHAR(64) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    protocol VARCHAR(16) NOT NULL CHECK (protocol IN ('oidc')),
+    issuer TEXT NOT NULL,
+    client_id TEXT NOT NULL,
+    -- Секрет нужен в открытом виде: им сервис аутентифицируется у провайдера
+    client_secret TEXT NOT NULL DEFAULT '',
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    disabled_at TIMESTAMPTZ,
+    UNIQUE (tenant_id, slug)
+);
+
+-- Внешняя учетная запись, привязанная к локальному пользователю
+CREATE TABLE identities (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id),
+    user_id INTEGER NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    provider VARCHAR(64) NOT NULL,
+    subject TEXT NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_login_at TIMESTAMPTZ,
+    UNIQUE (tenant_id, provider, subject)
+);
+
+CREATE INDEX idx_identities_user_id ON identities(user_id);
+
+-- Незавершенные входы через провайдера: state, nonce и PKCE-verifier
+CREATE TABLE federation_states (
+    state_hash VARCHAR(64) PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id),
+    provider VARCHAR(64) NOT NULL,
+    nonce TEXT NOT NULL,
+    code_verifier TEXT NOT NULL,
+    return_to TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    used_at TIMESTAMPTZ
+);
+
+-- +goose Down
+DROP TABLE federation_states;
+DROP TABLE identities;
+DROP TABLE identity_providers;
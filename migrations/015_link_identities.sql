@@ -0,0 +1,15 @@
+-- +goose Up
+-- Слитый пользователь остается ссылкой на того, с кем его объединили:
+-- выданные ему токены и сохраненные у клиентов ID продолжают работать
+ALTER TABLE users ADD COLUMN merged_into INTEGER REFERENCES users(id);
+
+INSERT INTO permissions (name, description) VALUES
+    ('users:merge', 'Merge duplicate user accounts');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND r.tenant_id IS NULL AND p.name = 'users:merge';
+
+-- +goose Down
+DELETE FROM permissions WHERE name = 'users:merge';
+ALTER TABLE users DROP COLUMN merged_into;
@@ -0,0 +1,14 @@
+-- +goose Up
+-- Создание пользователей при первом входе через провайдера и сопоставление
+-- утверждений ID-токена профилю и ролям
+ALTER TABLE identity_providers
+    ADD COLUMN jit_provisioning BOOLEAN NOT NULL DEFAULT false,
+    ADD COLUMN attribute_mapping JSONB NOT NULL DEFAULT '{}',
+    ADD COLUMN sync_policy VARCHAR(16) NOT NULL DEFAULT 'never'
+        CHECK (sync_policy IN ('never', 'profile', 'all'));
+
+-- +goose Down
+ALTER TABLE identity_providers
+    DROP COLUMN sync_policy,
+    DROP COLUMN attribute_mapping,
+    DROP COLUMN jit_provisioning;
@@ -0,0 +1,31 @@
+-- +goose Up
+-- Домены email, подтвержденные TXT-записью в DNS. Домен принадлежит тенанту
+-- или одной из его организаций; пока он не подтвержден, вход не меняется.
+CREATE TABLE domains (
+    id SERIAL PRIMARY KEY,
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id),
+    organization_id INTEGER REFERENCES organizations(id) ON DELETE CASCADE,
+    domain VARCHAR(253) NOT NULL,
+    verification_token VARCHAR(64) NOT NULL,
+    -- Slug провайдера из identity_providers, через которого входят пользователи домена
+    provider VARCHAR(64) NOT NULL DEFAULT '',
+    sso_enforced BOOLEAN NOT NULL DEFAULT false,
+    created_by INTEGER REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    verified_at TIMESTAMPTZ,
+    CHECK (NOT sso_enforced OR provider <> '')
+);
+
+-- Заявок на домен может быть несколько, подтвержденный владелец - один
+CREATE UNIQUE INDEX idx_domains_verified ON domains(tenant_id, domain) WHERE verified_at IS NOT NULL;
+
+INSERT INTO permissions (name, description) VALUES
+    ('domains:manage', 'Verify email domains and enforce single sign-on');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND r.tenant_id IS NULL AND p.name = 'domains:manage';
+
+-- +goose Down
+DELETE FROM permissions WHERE name = 'domains:manage';
+DROP TABLE domains;
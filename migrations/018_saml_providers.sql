@@ -0,0 +1,13 @@
+-- +goose Up
+-- Провайдеры SAML 2.0: issuer - entityID IdP, client_id и scopes не используются
+ALTER TABLE identity_providers DROP CONSTRAINT identity_providers_protocol_check;
+ALTER TABLE identity_providers ADD CONSTRAINT identity_providers_protocol_check
+    CHECK (protocol IN ('oidc', 'saml'));
+ALTER TABLE identity_providers ADD COLUMN saml_metadata TEXT NOT NULL DEFAULT '';
+
+-- +goose Down
+DELETE FROM identity_providers WHERE protocol = 'saml';
+ALTER TABLE identity_providers DROP COLUMN saml_metadata;
+ALTER TABLE identity_providers DROP CONSTRAINT identity_providers_protocol_check;
+ALTER TABLE identity_providers ADD CONSTRAINT identity_providers_protocol_check
+    CHECK (protocol IN ('oidc'));
@@ -0,0 +1,28 @@
+-- +goose Up
+-- Вход устройств без браузера (RFC 8628); device_code и user_code хранятся только хешами
+CREATE TABLE oauth_device_authorizations (
+    device_code_hash VARCHAR(64) PRIMARY KEY,
+    user_code_hash VARCHAR(64) NOT NULL,
+    tenant_id INTEGER NOT NULL REFERENCES tenants(id),
+    client_id VARCHAR(64) NOT NULL,
+    scopes TEXT[] NOT NULL DEFAULT '{}',
+    status VARCHAR(16) NOT NULL DEFAULT 'pending' CHECK (status IN ('pending', 'approved', 'denied')),
+    user_id INTEGER REFERENCES users(id) ON DELETE CASCADE,
+    session_id VARCHAR(64) REFERENCES sessions(id) ON DELETE CASCADE,
+    ip VARCHAR(45) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    poll_interval_seconds INTEGER NOT NULL,
+    last_polled_at TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMPTZ NOT NULL,
+    decided_at TIMESTAMPTZ,
+    used_at TIMESTAMPTZ,
+    CHECK (status = 'pending' OR decided_at IS NOT NULL),
+    CHECK (status <> 'approved' OR (user_id IS NOT NULL AND session_id IS NOT NULL))
+);
+
+-- Пользователь вводит user_code на странице подтверждения
+CREATE INDEX idx_oauth_device_authorizations_user_code ON oauth_device_authorizations(tenant_id, user_code_hash);
+
+-- +goose Down
+DROP TABLE oauth_device_authorizations;
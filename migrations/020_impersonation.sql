@@ -0,0 +1,14 @@
+-- +goose Up
+-- Сессия, которую администратор открыл от имени пользователя
+ALTER TABLE sessions ADD COLUMN impersonator_id INTEGER REFERENCES users(id) ON DELETE CASCADE;
+
+INSERT INTO permissions (name, description) VALUES
+    ('users:impersonate', 'Sign in as another user for support');
+
+INSERT INTO role_permissions (role_id, permission_id)
+SELECT r.id, p.id FROM roles r, permissions p
+WHERE r.name = 'admin' AND r.tenant_id IS NULL AND p.name = 'users:impersonate';
+
+-- +goose Down
+DELETE FROM permissions WHERE name = 'users:impersonate';
+ALTER TABLE sessions DROP COLUMN impersonator_id;
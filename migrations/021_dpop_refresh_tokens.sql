@@ -0,0 +1,6 @@
+-- +goose Up
+-- Отпечаток ключа DPoP (RFC 9449), к которому привязан refresh-токен публичного клиента
+ALTER TABLE oauth_refresh_tokens ADD COLUMN dpop_jkt TEXT;
+
+-- +goose Down
+ALTER TABLE oauth_refresh_tokens DROP COLUMN dpop_jkt;
@@ -0,0 +1,62 @@
+package dnstxt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+// Resolver читает TXT-записи домена
+type Resolver interface {
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// NewNetResolver возвращает системный резолвер или, если задан addr (host:port),
+// резолвер, который обращается только к этому DNS-серверу
+func NewNetResolver(addr string) *net.Resolver {
+	if addr == "" {
+		return net.DefaultResolver
+	}
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			dialer := net.Dialer{Timeout: 5 * time.Second}
+			return dialer.DialContext(ctx, network, addr)
+		},
+	}
+}
+
+// StaticResolver отдает записи из JSON-файла - для локальной разработки без DNS:
+//
+//	{"_auth-challenge.example.com": ["auth-service-verification=..."]}
+//
+// Файл перечитывается при каждом запросе, чтобы записи можно было добавлять на ходу.
+type StaticResolver struct {
+	path string
+}
+
+func NewStaticResolver(path string) *StaticResolver {
+	return &StaticResolver{path: path}
+}
+
+func (r *StaticResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read TXT records: %w", err)
+	}
+
+	var records map[string][]string
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse TXT records: %w", err)
+	}
+
+	values, ok := records[strings.TrimSuffix(strings.ToLower(name), ".")]
+	if !ok {
+		return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+	}
+	return values, nil
+}
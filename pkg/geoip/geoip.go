@@ -0,0 +1,88 @@
+package geoip
+
+import (
+	"fmt"
+	"math"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Reader геолоцирует IP по локальной базе в формате MaxMind MMDB (GeoLite2/GeoIP2 City)
+type Reader struct {
+	db *maxminddb.Reader
+}
+
+type Location struct {
+	CountryCode string
+	Country     string
+	City        string
+	Latitude    float64
+	Longitude   float64
+}
+
+// Поля City-базы, которые нам нужны
+type cityRecord struct {
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Location struct {
+		Latitude  *float64 `maxminddb:"latitude"`
+		Longitude *float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+func Open(path string) (*Reader, error) {
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geoip database: %w", err)
+	}
+	return &Reader{db: db}, nil
+}
+
+// Lookup возвращает nil, если адрес не найден в базе или у записи нет координат
+func (r *Reader) Lookup(ip string) (*Location, error) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return nil, nil
+	}
+
+	var record cityRecord
+	if err := r.db.Lookup(parsed, &record); err != nil {
+		return nil, fmt.Errorf("failed to lookup ip: %w", err)
+	}
+	if record.Location.Latitude == nil || record.Location.Longitude == nil {
+		return nil, nil
+	}
+
+	return &Location{
+		CountryCode: record.Country.ISOCode,
+		Country:     record.Country.Names["en"],
+		City:        record.City.Names["en"],
+		Latitude:    *record.Location.Latitude,
+		Longitude:   *record.Location.Longitude,
+	}, nil
+}
+
+func (r *Reader) Close() error {
+	return r.db.Close()
+}
+
+const earthRadiusKm = 6371.0
+
+// DistanceKm - расстояние по большой окружности (формула гаверсинусов)
+func DistanceKm(lat1, lon1, lat2, lon2 float64) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(lat2 - lat1)
+	dLon := toRad(lon2 - lon1)
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(lat1))*math.Cos(toRad(lat2))*math.Sin(dLon/2)*math.Sin(dLon/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(a))
+}
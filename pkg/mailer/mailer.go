@@ -0,0 +1,85 @@
+package mailer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"mime"
+	"net"
+	"net/smtp"
+	"regexp"
+	"strings"
+)
+
+// ErrInvalidSubject - тема с переводом строки превратилась бы в лишние заголовки
+var ErrInvalidSubject = errors.New("mail subject must not contain line breaks")
+
+type Mailer interface {
+	Send(ctx context.Context, to, subject, body string) error
+}
+
+// SMTPMailer отправляет письма через SMTP-релей
+type SMTPMailer struct {
+	addr     string
+	from     string
+	username string
+	password string
+}
+
+func NewSMTPMailer(addr, from, username, password string) *SMTPMailer {
+	return &SMTPMailer{
+		addr:     addr,
+		from:     from,
+		username: username,
+		password: password,
+	}
+}
+
+func (m *SMTPMailer) Send(ctx context.Context, to, subject, body string) error {
+	if strings.ContainsAny(subject, "\r\n") {
+		return ErrInvalidSubject
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		host, _, err := net.SplitHostPort(m.addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp address: %w", err)
+		}
+		auth = smtp.PlainAuth("", m.username, m.password, host)
+	}
+
+	msg := strings.Join([]string{
+		"From: " + m.from,
+		"To: " + to,
+		// Не-ASCII тема кодируется по RFC 2047, ASCII остается как есть
+		"Subject: " + mime.QEncoding.Encode("utf-8", subject),
+		"MIME-Version: 1.0",
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		body,
+	}, "\r\n")
+
+	if err := smtp.SendMail(m.addr, auth, m.from, []string{to}, []byte(msg)); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
+
+// Одноразовые токены в ссылках писем (сброс пароля, "это был не я", приглашения)
+var linkToken = regexp.MustCompile(`(?i)(token=)[^&\s]+`)
+
+// LogMailer пишет письма в лог - для локальной разработки без SMTP.
+// Токены из ссылок вырезаются: с доступом к логам по ним можно захватить аккаунт.
+type LogMailer struct{}
+
+func (LogMailer) Send(ctx context.Context, to, subject, body string) error {
+	log.Printf("📧 Mail to %s: %s\n%s", to, subject, redactTokens(body))
+	return nil
+}
+
+// redactTokens заменяет значения параметров token в ссылках на [REDACTED]
+func redactTokens(body string) string {
+	return linkToken.ReplaceAllString(body, "${1}[REDACTED]")
+}
@@ -0,0 +1,50 @@
+package replay
+
+import (
+	"sync"
+	"time"
+)
+
+// Cache запоминает одноразовые значения (nonce, jti) до истечения их срока
+type Cache struct {
+	mu      sync.Mutex
+	entries map[string]time.Time
+	swept   time.Time
+}
+
+func New() *Cache {
+	return &Cache{
+		entries: make(map[string]time.Time),
+	}
+}
+
+// Use отмечает ключ использованным до expiresAt.
+// Возвращает false, если ключ уже был использован и еще не истек.
+func (c *Cache) Use(key string, expiresAt time.Time) bool {
+	now := time.Now()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.sweep(now)
+
+	if until, ok := c.entries[key]; ok && now.Before(until) {
+		return false
+	}
+	c.entries[key] = expiresAt
+	return true
+}
+
+// Раз в минуту удаляем истекшие записи
+func (c *Cache) sweep(now time.Time) {
+	if now.Sub(c.swept) < time.Minute {
+		return
+	}
+	c.swept = now
+
+	for key, until := range c.entries {
+		if !now.Before(until) {
+			delete(c.entries, key)
+		}
+	}
+}